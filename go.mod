@@ -5,6 +5,8 @@ go 1.21
 require (
 	fyne.io/fyne/v2 v2.4.5
 	github.com/phpdave11/gofpdf v1.4.2
+	golang.org/x/crypto v0.14.0
+	golang.org/x/sys v0.13.0
 	modernc.org/sqlite v1.28.0
 )
 
@@ -40,7 +42,6 @@ require (
 	golang.org/x/mobile v0.0.0-20230531173138-3c911d8e3eda // indirect
 	golang.org/x/mod v0.12.0 // indirect
 	golang.org/x/net v0.17.0 // indirect
-	golang.org/x/sys v0.13.0 // indirect
 	golang.org/x/text v0.13.0 // indirect
 	golang.org/x/tools v0.12.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
@@ -0,0 +1,293 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrArchived est retournée lorsqu'une opération vise une clé ou un emprunteur archivé
+var ErrArchived = errors.New("enregistrement archivé")
+
+// archiveRule décrit comment archiver, ressortir des archives et purger une table.
+// Les formats de résumé reçoivent la valeur de nameColumn.
+type archiveRule struct {
+	nameColumn string
+	archived   string
+	recovered  string
+	purged     string
+	purge      func(tx *sql.Tx, id int) error
+}
+
+// archiveRules liste les tables archivables (clé : constante AuditEntity*)
+var archiveRules = map[string]archiveRule{
+	AuditEntityKey:      {"number", "Clé %v archivée", "Clé %v sortie des archives", "Clé %v supprimée définitivement", purgeKey},
+	AuditEntityBorrower: {"name", "Emprunteur %v archivé", "Emprunteur %v sorti des archives", "Emprunteur %v supprimé définitivement", purgeBorrower},
+	AuditEntityBuilding: {"name", "Bâtiment %v archivé", "Bâtiment %v sorti des archives", "Bâtiment %v supprimé définitivement", purgeBuilding},
+	AuditEntityRoom:     {"name", "Salle %v archivée", "Salle %v sortie des archives", "Salle %v supprimée définitivement", purgeRoom},
+}
+
+// archiveRuleFor retourne la règle d'une table archivable
+func archiveRuleFor(entity string) (archiveRule, error) {
+	rule, ok := archiveRules[entity]
+	if !ok {
+		return rule, fmt.Errorf("les enregistrements de %s ne peuvent pas être archivés", entity)
+	}
+	return rule, nil
+}
+
+// ArchiveRecord retire une clé, un emprunteur, un bâtiment ou une salle des listes sans
+// rien effacer : l'historique des emprunts et les rapports continuent de l'afficher.
+// Une clé ou un emprunteur ayant des emprunts en cours ou des réservations en attente
+// ne peut pas être archivé, pas plus qu'un bâtiment contenant des salles en service.
+func (s *Store) ArchiveRecord(entity string, id int) error {
+	rule, err := archiveRuleFor(entity)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := snapshotRow(tx, entity, id)
+	if err != nil {
+		return err
+	}
+	if before == nil {
+		return sql.ErrNoRows
+	}
+	if before["archived_at"] != nil {
+		return fmt.Errorf("%v est déjà archivé", before[rule.nameColumn])
+	}
+	if err := checkArchivable(tx, entity, id, before[rule.nameColumn]); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`UPDATE `+entity+` SET archived_at = ? WHERE id = ?`, time.Now(), id); err != nil {
+		return err
+	}
+
+	after, err := snapshotRow(tx, entity, id)
+	if err != nil {
+		return err
+	}
+	summary := fmt.Sprintf(rule.archived, before[rule.nameColumn])
+	if err := s.audit(tx, AuditArchive, entity, id, summary, before, after); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// checkArchivable vérifie dans tx que rien d'actif ne dépend de l'enregistrement name
+func checkArchivable(tx *sql.Tx, entity string, id int, name interface{}) error {
+	var count int
+	switch entity {
+	case AuditEntityKey, AuditEntityBorrower:
+		column := "key_id"
+		if entity == AuditEntityBorrower {
+			column = "borrower_id"
+		}
+		err := tx.QueryRow(`SELECT COUNT(*) FROM loans WHERE `+column+` = ? AND return_date IS NULL`, id).Scan(&count)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("%v a %d emprunt(s) en cours : enregistrez d'abord leur retour", name, count)
+		}
+		err = tx.QueryRow(`SELECT COUNT(*) FROM reservations WHERE `+column+` = ? AND status = ?`,
+			id, ReservationPending).Scan(&count)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("%v a %d réservation(s) en attente : annulez-les d'abord", name, count)
+		}
+	case AuditEntityBuilding:
+		err := tx.QueryRow(`SELECT COUNT(*) FROM rooms WHERE building_id = ? AND archived_at IS NULL`, id).Scan(&count)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("le bâtiment %v contient %d salle(s) en service : archivez-les d'abord", name, count)
+		}
+	}
+	return nil
+}
+
+// RecoverRecord remet en service un enregistrement archivé. Une salle ne peut
+// sortir des archives que si son bâtiment est lui-même en service.
+func (s *Store) RecoverRecord(entity string, id int) error {
+	rule, err := archiveRuleFor(entity)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := snapshotRow(tx, entity, id)
+	if err != nil {
+		return err
+	}
+	if before == nil {
+		return sql.ErrNoRows
+	}
+	if before["archived_at"] == nil {
+		return fmt.Errorf("%v n'est pas archivé", before[rule.nameColumn])
+	}
+	if entity == AuditEntityRoom {
+		var buildingArchived bool
+		err := tx.QueryRow(`SELECT archived_at IS NOT NULL FROM buildings WHERE id = ?`, before["building_id"]).Scan(&buildingArchived)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if buildingArchived {
+			return fmt.Errorf("le bâtiment de la salle %v est archivé : sortez-le d'abord des archives", before[rule.nameColumn])
+		}
+	}
+
+	if _, err := tx.Exec(`UPDATE `+entity+` SET archived_at = NULL WHERE id = ?`, id); err != nil {
+		return err
+	}
+
+	after, err := snapshotRow(tx, entity, id)
+	if err != nil {
+		return err
+	}
+	summary := fmt.Sprintf(rule.recovered, before[rule.nameColumn])
+	if err := s.audit(tx, AuditRecover, entity, id, summary, before, after); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// PurgeRecord supprime définitivement un enregistrement archivé. Pour une clé ou un
+// emprunteur, tout l'historique de ses emprunts disparaît avec lui. Réservé aux administrateurs.
+func (s *Store) PurgeRecord(entity string, id int) error {
+	rule, err := archiveRuleFor(entity)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	admin, err := operatorIsAdmin(tx, s.Operator())
+	if err != nil {
+		return err
+	}
+	if !admin {
+		return fmt.Errorf("seul un administrateur peut supprimer définitivement un enregistrement")
+	}
+
+	before, err := snapshotRow(tx, entity, id)
+	if err != nil {
+		return err
+	}
+	if before == nil {
+		return sql.ErrNoRows
+	}
+	if before["archived_at"] == nil {
+		return fmt.Errorf("%v doit être archivé avant d'être supprimé définitivement", before[rule.nameColumn])
+	}
+
+	if err := rule.purge(tx, id); err != nil {
+		return err
+	}
+
+	summary := fmt.Sprintf(rule.purged, before[rule.nameColumn])
+	if err := s.audit(tx, AuditDelete, entity, id, summary, before, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// purgeLoans supprime dans tx les réservations et les emprunts répondant à la condition
+// (sur key_id ou borrower_id), avec les signatures et les pièces jointes des emprunts.
+// Les emprunts issus d'un transfert perdent leur lien.
+func purgeLoans(tx *sql.Tx, condition string, id int) error {
+	if _, err := tx.Exec(`DELETE FROM reservations WHERE `+condition, id); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`UPDATE reservations SET loan_id = NULL
+		WHERE loan_id IN (SELECT id FROM loans WHERE `+condition+`)`, id)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE loans SET transferred_from_loan_id = NULL
+		WHERE transferred_from_loan_id IN (SELECT id FROM loans WHERE `+condition+`)`, id)
+	if err != nil {
+		return err
+	}
+	if err := purgeAttachments(tx, AuditEntityLoan, `SELECT id FROM loans WHERE `+condition, id); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`DELETE FROM loan_signatures
+		WHERE loan_id IN (SELECT id FROM loans WHERE `+condition+`)`, id)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`DELETE FROM loans WHERE `+condition, id)
+	return err
+}
+
+// checkNotArchived vérifie dans tx que ni la clé ni l'emprunteur d'un emprunt ne sont archivés
+func checkNotArchived(tx *sql.Tx, keyID, borrowerID int) error {
+	var number string
+	var keyArchived, borrowerArchived bool
+	err := tx.QueryRow(`SELECT k.number, k.archived_at IS NOT NULL, b.archived_at IS NOT NULL
+		FROM keys k, borrowers b WHERE k.id = ? AND b.id = ?`, keyID, borrowerID).Scan(&number, &keyArchived, &borrowerArchived)
+	if err != nil {
+		return err
+	}
+	if keyArchived {
+		return fmt.Errorf("la clé %s est hors service: %w", number, ErrArchived)
+	}
+	if borrowerArchived {
+		return fmt.Errorf("l'emprunteur est archivé: %w", ErrArchived)
+	}
+	return nil
+}
+
+// GetArchivedRecords récupère les enregistrements archivés du site sélectionné, du plus récent
+// au plus ancien, avec le nombre d'emprunts qu'une purge effacerait
+func (s *Store) GetArchivedRecords() ([]ArchivedRecord, error) {
+	site, siteArgs := s.siteCondition("a.site_id")
+	rows, err := s.db().Query(`
+		SELECT a.entity, a.id, a.title, a.archived_at,
+		       CASE a.entity
+		           WHEN ? THEN (SELECT COUNT(*) FROM loans WHERE key_id = a.id)
+		           WHEN ? THEN (SELECT COUNT(*) FROM loans WHERE borrower_id = a.id)
+		           ELSE 0
+		       END
+		FROM archived_records a
+		WHERE 1 = 1`+site+`
+		ORDER BY a.archived_at DESC, a.title`, append([]interface{}{AuditEntityKey, AuditEntityBorrower}, siteArgs...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []ArchivedRecord
+	for rows.Next() {
+		var r ArchivedRecord
+		if err := rows.Scan(&r.Entity, &r.ID, &r.Title, &r.ArchivedAt, &r.LoanCount); err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
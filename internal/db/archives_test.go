@@ -0,0 +1,96 @@
+package db
+
+import (
+	"errors"
+	"testing"
+)
+
+// hasKey indique si la clé figure dans les listes
+func hasKey(t *testing.T, s *Store, keyID int) bool {
+	t.Helper()
+	keys, err := s.GetAllKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range keys {
+		if k.ID == keyID {
+			return true
+		}
+	}
+	return false
+}
+
+func TestArchiveRecoverPurgeKey(t *testing.T) {
+	s := newTestStore(t)
+	admin := &Operator{Username: "admin", Role: RoleAdmin}
+	if err := s.CreateOperator(admin, "motdepasse"); err != nil {
+		t.Fatal(err)
+	}
+	key := createTestKey(t, s, "K001", 1)
+	alice := createTestBorrower(t, s, "Alice", "Martin")
+
+	if err := s.CreateLoan(key.ID, alice.ID); err != nil {
+		t.Fatal(err)
+	}
+	loan := activeLoan(t, s, alice.ID)
+
+	// Une clé sortie ne peut pas être archivée
+	if err := s.ArchiveRecord(AuditEntityKey, key.ID); err == nil {
+		t.Fatal("ArchiveRecord() d'une clé empruntée accepté")
+	}
+	if err := s.ReturnLoan(loan.ID, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.ArchiveRecord(AuditEntityKey, key.ID); err != nil {
+		t.Fatal(err)
+	}
+	if hasKey(t, s, key.ID) {
+		t.Error("une clé archivée ne doit plus figurer dans les listes")
+	}
+	if err := s.CreateLoan(key.ID, alice.ID); !errors.Is(err, ErrArchived) {
+		t.Errorf("CreateLoan() d'une clé archivée = %v, ErrArchived attendue", err)
+	}
+	records, err := s.GetArchivedRecords()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].ID != key.ID || records[0].LoanCount != 1 {
+		t.Fatalf("GetArchivedRecords() = %+v, la clé K001 avec 1 emprunt attendue", records)
+	}
+
+	// Sortie des archives : la clé revient avec son historique
+	if err := s.RecoverRecord(AuditEntityKey, key.ID); err != nil {
+		t.Fatal(err)
+	}
+	if !hasKey(t, s, key.ID) {
+		t.Error("une clé sortie des archives doit figurer dans les listes")
+	}
+	if err := s.PurgeRecord(AuditEntityKey, key.ID); err == nil {
+		t.Error("PurgeRecord() d'une clé non archivée accepté")
+	}
+
+	// Seul un administrateur supprime définitivement
+	if err := s.ArchiveRecord(AuditEntityKey, key.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PurgeRecord(AuditEntityKey, key.ID); err == nil {
+		t.Error("PurgeRecord() accepté pour un opérateur non administrateur")
+	}
+	s.SetOperator(admin.Username)
+	if err := s.PurgeRecord(AuditEntityKey, key.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.GetKeyByID(key.ID); err == nil {
+		t.Error("GetKeyByID() d'une clé purgée doit échouer")
+	}
+	history, err := s.GetLoanHistory(LoanHistoryFilter{BorrowerID: alice.ID})
+	if err != nil || len(history) != 0 {
+		t.Errorf("GetLoanHistory() = %d emprunts, %v après purge ; 0 attendu", len(history), err)
+	}
+	entries, err := s.GetAuditLog(AuditFilter{Entity: AuditEntityKey, Action: AuditDelete})
+	if err != nil || len(entries) != 1 {
+		t.Errorf("journal d'audit de la purge = %d entrées, %v ; 1 attendue", len(entries), err)
+	}
+}
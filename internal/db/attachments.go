@@ -0,0 +1,255 @@
+package db
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"image"
+	_ "image/gif" // Décodeurs des miniatures
+	_ "image/jpeg"
+	"image/png"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// thumbnailSize est le plus grand côté, en pixels, des miniatures des images jointes
+const thumbnailSize = 160
+
+// attachmentSelect est la requête commune aux lectures de pièces jointes, sans leur contenu
+const attachmentSelect = `
+		SELECT a.id, a.entity, a.entity_id, a.filename, a.mime_type, a.size, a.operator, a.created_at, d.thumbnail
+		FROM attachments a
+		LEFT JOIN attachment_data d ON d.attachment_id = a.id`
+
+// scanAttachment lit une ligne de attachmentSelect
+func scanAttachment(row rowScanner) (Attachment, error) {
+	var a Attachment
+	var operator sql.NullString
+	err := row.Scan(&a.ID, &a.Entity, &a.EntityID, &a.Filename, &a.MimeType, &a.Size, &operator, &a.CreatedAt, &a.Thumbnail)
+	a.Operator = operator.String
+	return a, err
+}
+
+// GetAttachments récupère les pièces jointes d'un enregistrement, de la plus récente à la plus ancienne
+func (s *Store) GetAttachments(entity string, entityID int) ([]Attachment, error) {
+	rows, err := s.db().Query(attachmentSelect+` WHERE a.entity = ? AND a.entity_id = ?
+		ORDER BY a.created_at DESC, a.id DESC`, entity, entityID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attachments []Attachment
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+	return attachments, rows.Err()
+}
+
+// GetAttachmentData récupère le contenu d'une pièce jointe
+func (s *Store) GetAttachmentData(id int) ([]byte, error) {
+	var data []byte
+	err := s.db().QueryRow(`SELECT data FROM attachment_data WHERE attachment_id = ?`, id).Scan(&data)
+	return data, err
+}
+
+// AddAttachment joint un fichier à une clé, une salle, un emprunteur ou un emprunt.
+// Le fichier est enregistré dans la base : il suit les sauvegardes et les restaurations.
+func (s *Store) AddAttachment(entity string, entityID int, filename string, data []byte) (*Attachment, error) {
+	filename = strings.TrimSpace(filepath.Base(filename))
+	if filename == "" || filename == "." {
+		return nil, fmt.Errorf("le nom du fichier est requis")
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("le fichier %s est vide", filename)
+	}
+	if len(data) > MaxAttachmentSize {
+		return nil, fmt.Errorf("le fichier %s dépasse la taille maximale de %s", filename, formatFileSize(MaxAttachmentSize))
+	}
+
+	a := &Attachment{
+		Entity:    entity,
+		EntityID:  entityID,
+		Filename:  filename,
+		MimeType:  detectMimeType(filename, data),
+		Size:      int64(len(data)),
+		Operator:  s.Operator(),
+		CreatedAt: time.Now(),
+	}
+	if a.IsImage() {
+		a.Thumbnail = makeThumbnail(data)
+	}
+
+	tx, err := s.beginWrite()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	owner, err := attachmentOwner(tx, entity, entityID)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := tx.Exec(`INSERT INTO attachments (entity, entity_id, filename, mime_type, size, operator, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`, a.Entity, a.EntityID, a.Filename, a.MimeType, a.Size, a.Operator, a.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("erreur lors de l'ajout de la pièce jointe %s: %w", filename, err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	a.ID = int(id)
+
+	if _, err := tx.Exec(`INSERT INTO attachment_data (attachment_id, data, thumbnail) VALUES (?, ?, ?)`, a.ID, data, a.Thumbnail); err != nil {
+		return nil, err
+	}
+	if err := s.auditInsert(tx, AuditEntityAttachment, a.ID, fmt.Sprintf("Pièce jointe %s ajoutée à %s", a.Filename, owner)); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// DeleteAttachment supprime une pièce jointe et son contenu
+func (s *Store) DeleteAttachment(id int) error {
+	tx, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := snapshotRow(tx, AuditEntityAttachment, id)
+	if err != nil {
+		return err
+	}
+	if before == nil {
+		return sql.ErrNoRows
+	}
+	var entity string
+	var entityID int
+	if err := tx.QueryRow(`SELECT entity, entity_id FROM attachments WHERE id = ?`, id).Scan(&entity, &entityID); err != nil {
+		return err
+	}
+	owner, err := attachmentOwner(tx, entity, entityID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM attachment_data WHERE attachment_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM attachments WHERE id = ?`, id); err != nil {
+		return err
+	}
+	summary := fmt.Sprintf("Pièce jointe %v retirée de %s", before["filename"], owner)
+	if err := s.audit(tx, AuditDelete, AuditEntityAttachment, id, summary, before, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// purgeAttachments supprime dans tx les pièces jointes des enregistrements d'une entité
+// dont l'ID est retourné par ids (un paramètre « ? » ou une sous-requête)
+func purgeAttachments(tx *sql.Tx, entity string, ids string, args ...interface{}) error {
+	args = append([]interface{}{entity}, args...)
+	_, err := tx.Exec(`DELETE FROM attachment_data WHERE attachment_id IN
+		(SELECT id FROM attachments WHERE entity = ? AND entity_id IN (`+ids+`))`, args...)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`DELETE FROM attachments WHERE entity = ? AND entity_id IN (`+ids+`)`, args...)
+	return err
+}
+
+// attachmentOwner décrit dans tx l'enregistrement qui porte une pièce jointe, pour le journal
+// d'audit (ex: « la clé K001 »). Retourne sql.ErrNoRows si l'enregistrement n'existe pas.
+func attachmentOwner(tx *sql.Tx, entity string, id int) (string, error) {
+	var name string
+	var err error
+	switch entity {
+	case AuditEntityKey:
+		err = tx.QueryRow(`SELECT number FROM keys WHERE id = ?`, id).Scan(&name)
+		name = "la clé " + name
+	case AuditEntityRoom:
+		err = tx.QueryRow(`SELECT name FROM rooms WHERE id = ?`, id).Scan(&name)
+		name = "la salle " + name
+	case AuditEntityBorrower:
+		err = tx.QueryRow(`SELECT name FROM borrowers WHERE id = ?`, id).Scan(&name)
+		name = "l'emprunteur " + name
+	case AuditEntityLoan:
+		name, err = loanAuditSummary(tx, "l'emprunt", id)
+	default:
+		return "", fmt.Errorf("entité inconnue: %s", entity)
+	}
+	if err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// detectMimeType détermine le type d'un fichier d'après son contenu, ou à défaut son extension
+func detectMimeType(filename string, data []byte) string {
+	detected := http.DetectContentType(data)
+	if detected != "application/octet-stream" && !strings.HasPrefix(detected, "text/plain") {
+		return strings.Split(detected, ";")[0]
+	}
+	if byExtension := mime.TypeByExtension(strings.ToLower(filepath.Ext(filename))); byExtension != "" {
+		return strings.Split(byExtension, ";")[0]
+	}
+	return strings.Split(detected, ";")[0]
+}
+
+// makeThumbnail réduit une image PNG, JPEG ou GIF à thumbnailSize pixels au plus et
+// l'encode en PNG. Retourne nil si l'image ne peut pas être décodée.
+func makeThumbnail(data []byte) []byte {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 {
+		return nil
+	}
+
+	scale := float64(thumbnailSize) / float64(width)
+	if height > width {
+		scale = float64(thumbnailSize) / float64(height)
+	}
+	if scale > 1 {
+		scale = 1
+	}
+	tw, th := int(float64(width)*scale), int(float64(height)*scale)
+	if tw < 1 {
+		tw = 1
+	}
+	if th < 1 {
+		th = 1
+	}
+
+	// Échantillonnage au plus proche voisin, suffisant pour un aperçu
+	thumb := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		for x := 0; x < tw; x++ {
+			thumb.Set(x, y, src.At(bounds.Min.X+x*width/tw, bounds.Min.Y+y*height/th))
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, thumb); err != nil {
+		return nil
+	}
+	return buf.Bytes()
+}
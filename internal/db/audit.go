@@ -0,0 +1,324 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os/user"
+	"strings"
+	"time"
+)
+
+// auditExecer est satisfait par *sql.DB et *sql.Tx : une entrée d'audit est
+// écrite dans la même transaction que l'opération qu'elle décrit
+type auditExecer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// auditQueryer est satisfait par *sql.DB et *sql.Tx
+type auditQueryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// defaultOperator retourne le nom de la session du poste, utilisé comme
+// opérateur tant qu'aucun autre n'a été défini
+func defaultOperator() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	return "inconnu"
+}
+
+// SetOperator définit le nom enregistré dans le journal d'audit pour les opérations suivantes
+func (s *Store) SetOperator(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.operator = name
+}
+
+// Operator retourne le nom enregistré dans le journal d'audit
+func (s *Store) Operator() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.operator
+}
+
+// audit ajoute une entrée au journal au nom de l'opérateur courant. before et after
+// sont sérialisés en JSON (nil pour une création ou une suppression).
+func (s *Store) audit(ex auditExecer, action AuditAction, entity string, entityID int, summary string, before, after interface{}) error {
+	return writeAudit(ex, s.Operator(), action, entity, entityID, summary, before, after)
+}
+
+// writeAudit ajoute une entrée au journal. Utilisée directement lorsque le verrou
+// du Store est déjà pris (restauration, réinitialisation).
+func writeAudit(ex auditExecer, operator string, action AuditAction, entity string, entityID int, summary string, before, after interface{}) error {
+	beforeJSON, err := auditJSON(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := auditJSON(after)
+	if err != nil {
+		return err
+	}
+
+	_, err = ex.Exec(`INSERT INTO audit_log (created_at, operator, action, entity, entity_id, summary, before_json, after_json)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		time.Now(), operator, action, entity, sql.NullInt64{Int64: int64(entityID), Valid: entityID > 0},
+		summary, beforeJSON, afterJSON)
+	if err != nil {
+		return fmt.Errorf("erreur lors de l'écriture du journal d'audit: %w", err)
+	}
+	return nil
+}
+
+// auditInsert journalise la création d'un enregistrement inséré dans tx
+func (s *Store) auditInsert(tx *sql.Tx, entity string, id int, summary string) error {
+	return s.auditInsertAs(tx, AuditCreate, entity, id, summary)
+}
+
+// auditInsertAs journalise un enregistrement inséré dans tx avec une action précise (ex: emprunt)
+func (s *Store) auditInsertAs(tx *sql.Tx, action AuditAction, entity string, id int, summary string) error {
+	after, err := snapshotRow(tx, entity, id)
+	if err != nil {
+		return err
+	}
+	return s.audit(tx, action, entity, id, summary, nil, after)
+}
+
+// auditedUpdate exécute une mise à jour d'un enregistrement et la journalise avec ses valeurs avant/après
+func (s *Store) auditedUpdate(entity string, id int, summary string, query string, args ...interface{}) error {
+	tx, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := snapshotRow(tx, entity, id)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(query, args...); err != nil {
+		return err
+	}
+	after, err := snapshotRow(tx, entity, id)
+	if err != nil {
+		return err
+	}
+	if err := s.audit(tx, AuditUpdate, entity, id, summary, before, after); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// loanAuditSummary décrit un emprunt pour le journal (ex: « Emprunt de la clé A12 (A12-3) par Jean Dupont »)
+func loanAuditSummary(tx *sql.Tx, verb string, loanID int) (string, error) {
+	var number, borrower string
+	var identifier sql.NullString
+	err := tx.QueryRow(`
+		SELECT k.number, b.name, c.identifier
+		FROM loans l
+		INNER JOIN keys k ON l.key_id = k.id
+		INNER JOIN borrowers b ON l.borrower_id = b.id
+		LEFT JOIN key_copies c ON l.copy_id = c.id
+		WHERE l.id = ?`, loanID).Scan(&number, &borrower, &identifier)
+	if err != nil {
+		return "", err
+	}
+	if identifier.Valid {
+		return fmt.Sprintf("%s de la clé %s (%s) par %s", verb, number, identifier.String, borrower), nil
+	}
+	return fmt.Sprintf("%s de la clé %s par %s", verb, number, borrower), nil
+}
+
+// auditJSON sérialise une valeur du journal (NULL pour nil)
+func auditJSON(v interface{}) (sql.NullString, error) {
+	if v == nil {
+		return sql.NullString{}, nil
+	}
+	if m, ok := v.(map[string]interface{}); ok && m == nil {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return sql.NullString{}, fmt.Errorf("erreur lors de la sérialisation du journal d'audit: %w", err)
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+// snapshotRow lit un enregistrement sous forme de colonnes/valeurs pour le journal d'audit.
+// table est toujours une constante AuditEntity*, jamais une saisie de l'utilisateur.
+// Retourne nil si l'enregistrement n'existe pas.
+func snapshotRow(q auditQueryer, table string, id int) (map[string]interface{}, error) {
+	rows, err := q.Query(`SELECT * FROM `+table+` WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	if err := rows.Scan(pointers...); err != nil {
+		return nil, err
+	}
+
+	snapshot := make(map[string]interface{}, len(columns))
+	for i, column := range columns {
+		if b, ok := values[i].([]byte); ok {
+			values[i] = string(b)
+		}
+		snapshot[column] = values[i]
+	}
+	return snapshot, rows.Err()
+}
+
+// auditWhere construit la clause WHERE correspondant à un filtre du journal
+func auditWhere(f AuditFilter) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if f.Entity != "" {
+		conditions = append(conditions, "entity = ?")
+		args = append(args, f.Entity)
+	}
+	if f.Action != "" {
+		conditions = append(conditions, "action = ?")
+		args = append(args, f.Action)
+	}
+	if f.Operator != "" {
+		conditions = append(conditions, "operator LIKE ?")
+		args = append(args, "%"+f.Operator+"%")
+	}
+	// Même format de date que l'historique des emprunts (voir loanHistoryWhere)
+	if f.From != nil {
+		conditions = append(conditions, "substr(created_at, 1, 19) >= ?")
+		args = append(args, f.From.Format("2006-01-02 15:04:05"))
+	}
+	if f.To != nil {
+		conditions = append(conditions, "substr(created_at, 1, 19) <= ?")
+		args = append(args, f.To.Format("2006-01-02 15:04:05"))
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return "\n\t\tWHERE " + strings.Join(conditions, " AND "), args
+}
+
+// GetAuditLog récupère les entrées du journal correspondant au filtre, de la plus récente à la plus ancienne
+func (s *Store) GetAuditLog(f AuditFilter) ([]AuditEntry, error) {
+	where, args := auditWhere(f)
+	query := `
+		SELECT id, created_at, operator, action, entity, entity_id, summary, before_json, after_json
+		FROM audit_log` + where + `
+		ORDER BY id DESC`
+	if f.Limit > 0 {
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, f.Limit, f.Offset)
+	}
+
+	rows, err := s.db().Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanAuditEntries(rows)
+}
+
+// CountAuditLog compte les entrées du journal correspondant au filtre (pagination)
+func (s *Store) CountAuditLog(f AuditFilter) (int, error) {
+	where, args := auditWhere(f)
+	var count int
+	err := s.db().QueryRow(`SELECT COUNT(*) FROM audit_log`+where, args...).Scan(&count)
+	return count, err
+}
+
+// scanAuditEntries lit les lignes du journal
+func scanAuditEntries(rows *sql.Rows) ([]AuditEntry, error) {
+	var entries []AuditEntry
+	for rows.Next() {
+		var e AuditEntry
+		var entityID sql.NullInt64
+		var before, after sql.NullString
+		err := rows.Scan(&e.ID, &e.CreatedAt, &e.Operator, &e.Action, &e.Entity, &entityID, &e.Summary, &before, &after)
+		if err != nil {
+			return nil, err
+		}
+		e.EntityID = int(entityID.Int64)
+		e.Before = before.String
+		e.After = after.String
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// readAuditLog lit tout le journal d'une connexion, dans l'ordre chronologique
+func readAuditLog(conn *sql.DB) ([]AuditEntry, error) {
+	rows, err := conn.Query(`
+		SELECT id, created_at, operator, action, entity, entity_id, summary, before_json, after_json
+		FROM audit_log
+		ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanAuditEntries(rows)
+}
+
+// carryOverAuditLog recopie dans une base restaurée ou réinitialisée les entrées
+// du journal précédent qui lui manquent, afin que le remplacement du fichier
+// n'efface pas la trace des opérations. Une sauvegarde de la même base a pour journal
+// un début du journal précédent : seules les entrées d'identifiant supérieur à sa
+// dernière entrée sont reportées, sans se fier aux horloges des postes. Un journal
+// sans rapport (autre base) reçoit tout le journal précédent.
+func carryOverAuditLog(conn *sql.DB, previous []AuditEntry) error {
+	var lastID int
+	var lastAt time.Time
+	var lastSummary string
+	err := conn.QueryRow(`SELECT id, created_at, summary FROM audit_log ORDER BY id DESC LIMIT 1`).Scan(&lastID, &lastAt, &lastSummary)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	from := 0
+	for _, e := range previous {
+		if e.ID == lastID && e.CreatedAt.Equal(lastAt) && e.Summary == lastSummary {
+			from = lastID
+			break
+		}
+	}
+
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, e := range previous {
+		if e.ID <= from {
+			continue
+		}
+		_, err := tx.Exec(`INSERT INTO audit_log (created_at, operator, action, entity, entity_id, summary, before_json, after_json)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			e.CreatedAt, e.Operator, e.Action, e.Entity,
+			sql.NullInt64{Int64: int64(e.EntityID), Valid: e.EntityID > 0}, e.Summary,
+			sql.NullString{String: e.Before, Valid: e.Before != ""},
+			sql.NullString{String: e.After, Valid: e.After != ""})
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,217 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrNotAuthorized est retournée lorsqu'un emprunteur n'est pas autorisé à emprunter une clé
+var ErrNotAuthorized = errors.New("emprunteur non autorisé pour cette clé")
+
+// authorizationSelect est la requête commune aux lectures d'autorisations
+const authorizationSelect = `
+		SELECT a.id, a.key_id, k.number, a.borrower_id, b.name, a.department,
+		       a.valid_from, a.valid_until, a.notes, a.created_at
+		FROM key_authorizations a
+		JOIN keys k ON k.id = a.key_id
+		LEFT JOIN borrowers b ON b.id = a.borrower_id`
+
+// authorizedKeyCondition sélectionne les clés (alias k) qu'un emprunteur peut prendre à une date :
+// les clés sans aucune autorisation, et celles dont une autorisation en vigueur
+// désigne l'emprunteur ou son service. Paramètres : borrowerID, date, date.
+const authorizedKeyCondition = `(
+		NOT EXISTS (SELECT 1 FROM key_authorizations a WHERE a.key_id = k.id)
+		OR EXISTS (
+			SELECT 1 FROM key_authorizations a, borrowers b
+			WHERE a.key_id = k.id AND b.id = ?
+			  AND (a.borrower_id = b.id
+			       OR (COALESCE(a.department, '') <> '' AND LOWER(a.department) = LOWER(COALESCE(b.department, ''))))
+			  AND (a.valid_from IS NULL OR substr(a.valid_from, 1, 19) <= ?)
+			  AND (a.valid_until IS NULL OR substr(a.valid_until, 1, 19) >= ?)
+		)
+	)`
+
+// GetAuthorizationsForKey récupère les autorisations d'une clé
+func (s *Store) GetAuthorizationsForKey(keyID int) ([]KeyAuthorization, error) {
+	return s.queryAuthorizations(authorizationSelect+`
+		WHERE a.key_id = ?
+		ORDER BY b.name, a.department`, keyID)
+}
+
+// GetAuthorizationsForBorrower récupère les autorisations d'un emprunteur,
+// nominatives ou accordées à son service
+func (s *Store) GetAuthorizationsForBorrower(borrowerID int) ([]KeyAuthorization, error) {
+	return s.queryAuthorizations(authorizationSelect+`
+		WHERE a.borrower_id = ?
+		   OR (COALESCE(a.department, '') <> ''
+		       AND LOWER(a.department) = (SELECT LOWER(COALESCE(department, '')) FROM borrowers WHERE id = ?))
+		ORDER BY k.number`, borrowerID, borrowerID)
+}
+
+// queryAuthorizations exécute une requête basée sur authorizationSelect
+func (s *Store) queryAuthorizations(query string, args ...interface{}) ([]KeyAuthorization, error) {
+	rows, err := s.db().Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var authorizations []KeyAuthorization
+	for rows.Next() {
+		var a KeyAuthorization
+		var borrowerID sql.NullInt64
+		var borrowerName, department, notes sql.NullString
+		var validFrom, validUntil sql.NullTime
+		err := rows.Scan(&a.ID, &a.KeyID, &a.KeyNumber, &borrowerID, &borrowerName, &department,
+			&validFrom, &validUntil, &notes, &a.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if borrowerID.Valid {
+			id := int(borrowerID.Int64)
+			a.BorrowerID = &id
+		}
+		if validFrom.Valid {
+			a.ValidFrom = &validFrom.Time
+		}
+		if validUntil.Valid {
+			a.ValidUntil = &validUntil.Time
+		}
+		a.BorrowerName = borrowerName.String
+		a.Department = department.String
+		a.Notes = notes.String
+		authorizations = append(authorizations, a)
+	}
+	return authorizations, rows.Err()
+}
+
+// GetAuthorizedKeyIDs retourne les clés que l'emprunteur peut emprunter aujourd'hui
+func (s *Store) GetAuthorizedKeyIDs(borrowerID int) (map[int]bool, error) {
+	now := time.Now().Format("2006-01-02 15:04:05")
+	rows, err := s.db().Query(`SELECT k.id FROM keys k WHERE `+authorizedKeyCondition, borrowerID, now, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keyIDs := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		keyIDs[id] = true
+	}
+	return keyIDs, rows.Err()
+}
+
+// isAuthorized vérifie dans tx que l'emprunteur peut emprunter la clé à la date donnée
+func isAuthorized(tx *sql.Tx, keyID, borrowerID int, at time.Time) (bool, error) {
+	date := at.Format("2006-01-02 15:04:05")
+	var count int
+	err := tx.QueryRow(`SELECT COUNT(*) FROM keys k WHERE k.id = ? AND `+authorizedKeyCondition,
+		keyID, borrowerID, date, date).Scan(&count)
+	return count > 0, err
+}
+
+// CreateKeyAuthorization ajoute une autorisation, nominative (BorrowerID) ou de service (Department)
+func (s *Store) CreateKeyAuthorization(a *KeyAuthorization) error {
+	a.Department = strings.TrimSpace(a.Department)
+	if (a.BorrowerID == nil) == (a.Department == "") {
+		return fmt.Errorf("l'autorisation doit désigner soit un emprunteur, soit un service")
+	}
+	if a.ValidFrom != nil && a.ValidUntil != nil && a.ValidUntil.Before(*a.ValidFrom) {
+		return fmt.Errorf("la fin de validité précède le début de validité")
+	}
+
+	tx, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	a.CreatedAt = time.Now()
+	var department interface{}
+	if a.Department != "" {
+		department = a.Department
+	}
+	result, err := tx.Exec(`INSERT INTO key_authorizations (key_id, borrower_id, department, valid_from, valid_until, notes, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		a.KeyID, a.BorrowerID, department, a.ValidFrom, a.ValidUntil, a.Notes, a.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("erreur lors de la création de l'autorisation: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	a.ID = int(id)
+
+	summary, err := authorizationAuditSummary(tx, a.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.auditInsert(tx, AuditEntityAuthorization, a.ID, summary+" accordée"); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteKeyAuthorization retire une autorisation
+func (s *Store) DeleteKeyAuthorization(id int) error {
+	tx, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := snapshotRow(tx, AuditEntityAuthorization, id)
+	if err != nil {
+		return err
+	}
+	summary, err := authorizationAuditSummary(tx, id)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM key_authorizations WHERE id = ?`, id); err != nil {
+		return err
+	}
+	if err := s.audit(tx, AuditDelete, AuditEntityAuthorization, id, summary+" retirée", before, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// authorizationAuditSummary décrit une autorisation pour le journal
+// (ex: « Autorisation de Jean Dupont sur la clé A12 »)
+func authorizationAuditSummary(tx *sql.Tx, id int) (string, error) {
+	var number string
+	var borrower, department sql.NullString
+	err := tx.QueryRow(`
+		SELECT k.number, b.name, a.department
+		FROM key_authorizations a
+		JOIN keys k ON k.id = a.key_id
+		LEFT JOIN borrowers b ON b.id = a.borrower_id
+		WHERE a.id = ?`, id).Scan(&number, &borrower, &department)
+	if err != nil {
+		return "", err
+	}
+	holder := "de " + borrower.String
+	if department.String != "" {
+		holder = "du service " + department.String
+	}
+	return fmt.Sprintf("Autorisation %s sur la clé %s", holder, number), nil
+}
+
+// operatorIsAdmin vérifie dans q (base ou transaction) que l'opérateur est un administrateur actif
+func operatorIsAdmin(q rowQueryer, username string) (bool, error) {
+	var count int
+	err := q.QueryRow(`SELECT COUNT(*) FROM operators WHERE username = ? AND role = ? AND active = 1`,
+		username, RoleAdmin).Scan(&count)
+	return count > 0, err
+}
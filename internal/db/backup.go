@@ -19,7 +19,8 @@ type BackupInfo struct {
 	SizeStr string
 }
 
-// BackupDatabase crée une sauvegarde de la base de données
+// BackupDatabase crée une sauvegarde de la base de données. Les pièces jointes sont
+// enregistrées dans la base : la sauvegarde les contient et une restauration les ramène.
 func BackupDatabase(dbPath string, backupPath string) error {
 	// Ouvrir le fichier source
 	sourceFile, err := os.Open(dbPath)
@@ -28,6 +29,11 @@ func BackupDatabase(dbPath string, backupPath string) error {
 	}
 	defer sourceFile.Close()
 
+	// Reporter le journal WAL dans le fichier, sans quoi la copie manquerait les dernières écritures
+	if err := checkpointWAL(dbPath); err != nil {
+		return fmt.Errorf("erreur lors de la consolidation du journal: %w", err)
+	}
+
 	// Créer le répertoire de destination si nécessaire
 	backupDir := filepath.Dir(backupPath)
 	if err := os.MkdirAll(backupDir, 0755); err != nil {
@@ -56,61 +62,148 @@ func BackupDatabase(dbPath string, backupPath string) error {
 	return nil
 }
 
-// RestoreDatabase restaure une base de données depuis une sauvegarde
-func RestoreDatabase(backupPath string, dbPath string) error {
+// checkpointWAL reporte dans le fichier de base le contenu de son journal WAL et vide ce
+// dernier. Sans journal WAL (base sur un partage réseau), l'opération ne fait rien.
+func checkpointWAL(dbPath string) error {
+	conn, err := sql.Open("sqlite", connDSN(dbPath))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Exec(`PRAGMA wal_checkpoint(TRUNCATE)`)
+	return err
+}
+
+// removeJournalFiles supprime le journal WAL et la mémoire partagée laissés à côté
+// d'un fichier de base : ils ne doivent pas être rejoués sur un fichier remplacé
+func removeJournalFiles(dbPath string) error {
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(dbPath + suffix); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Backup crée une sauvegarde de la base de données ouverte
+func (s *Store) Backup(backupPath string) error {
+	return BackupDatabase(s.path, backupPath)
+}
+
+// Restore restaure la base de données depuis une sauvegarde, en conservant le journal
+// d'audit et les opérateurs actuels. Réservé aux administrateurs.
+// La connexion est remplacée sous verrou : les appelants continuent d'utiliser le même Store.
+func (s *Store) Restore(backupPath string) error {
+	if err := s.requireAdmin("restaurer une sauvegarde"); err != nil {
+		return err
+	}
+
 	// Vérifier que le fichier de sauvegarde existe
 	if _, err := os.Stat(backupPath); os.IsNotExist(err) {
 		return fmt.Errorf("le fichier de sauvegarde n'existe pas: %s", backupPath)
 	}
 
-	// Fermer la connexion actuelle si elle existe
-	if DB != nil {
-		if err := DB.Close(); err != nil {
-			return fmt.Errorf("erreur lors de la fermeture de la base de données: %w", err)
-		}
+	// Refuser une sauvegarde créée par une version plus récente de l'application
+	if err := checkSchemaCompatible(backupPath); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Conserver le journal d'audit et les opérateurs de la base actuelle : ils seront
+	// reportés dans la base restaurée
+	previousAudit, err := readAuditLog(s.conn)
+	if err != nil {
+		return fmt.Errorf("erreur lors de la lecture du journal d'audit: %w", err)
+	}
+	previousOperators, err := readOperatorAccounts(s.conn)
+	if err != nil {
+		return fmt.Errorf("erreur lors de la lecture des opérateurs: %w", err)
 	}
 
 	// Créer une sauvegarde de la base actuelle avant de la remplacer
-	if _, err := os.Stat(dbPath); err == nil {
-		backupCurrent := dbPath + ".before_restore." + time.Now().Format("20060102_150405")
-		if err := BackupDatabase(dbPath, backupCurrent); err != nil {
+	var backupCurrent string
+	if _, err := os.Stat(s.path); err == nil {
+		backupCurrent = s.path + ".before_restore." + time.Now().Format("20060102_150405")
+		if err := BackupDatabase(s.path, backupCurrent); err != nil {
 			return fmt.Errorf("erreur lors de la sauvegarde de la base actuelle: %w", err)
 		}
 	}
 
-	// Ouvrir le fichier de sauvegarde
-	sourceFile, err := os.Open(backupPath)
+	// Fermer la connexion actuelle
+	if s.conn != nil {
+		if err := s.conn.Close(); err != nil {
+			return fmt.Errorf("erreur lors de la fermeture de la base de données: %w", err)
+		}
+		s.conn = nil
+	}
+
+	// Remplacer le fichier puis rouvrir la connexion
+	conn, err := replaceAndOpen(backupPath, s.path)
+	if err != nil {
+		// Revenir à la base d'origine pour ne pas laisser le Store sans connexion
+		if backupCurrent != "" {
+			if previous, reopenErr := replaceAndOpen(backupCurrent, s.path); reopenErr == nil {
+				s.conn = previous
+			}
+		}
+		return err
+	}
+	s.conn = conn
+	// Les sites de l'ancienne base n'existent plus forcément : revenir à la vue consolidée
+	s.site = 0
+
+	if err := carryOverAuditLog(conn, previousAudit); err != nil {
+		return fmt.Errorf("erreur lors du report du journal d'audit: %w", err)
+	}
+	if err := carryOverOperators(conn, previousOperators); err != nil {
+		return fmt.Errorf("erreur lors du report des opérateurs: %w", err)
+	}
+	return writeAudit(conn, s.operator, AuditRestore, AuditEntityDatabase, 0,
+		"Base restaurée depuis "+filepath.Base(backupPath), nil, nil)
+}
+
+// replaceAndOpen copie src sur dbPath puis ouvre la base obtenue
+func replaceAndOpen(src string, dbPath string) (*sql.DB, error) {
+	// Ouvrir le fichier source
+	sourceFile, err := os.Open(src)
 	if err != nil {
-		return fmt.Errorf("erreur lors de l'ouverture du fichier de sauvegarde: %w", err)
+		return nil, fmt.Errorf("erreur lors de l'ouverture du fichier de sauvegarde: %w", err)
 	}
 	defer sourceFile.Close()
 
+	if err := removeJournalFiles(dbPath); err != nil {
+		return nil, fmt.Errorf("erreur lors de la suppression du journal de la base: %w", err)
+	}
+
 	// Créer/écraser le fichier de base de données
 	destFile, err := os.Create(dbPath)
 	if err != nil {
-		return fmt.Errorf("erreur lors de la création de la base de données: %w", err)
+		return nil, fmt.Errorf("erreur lors de la création de la base de données: %w", err)
 	}
 	defer destFile.Close()
 
 	// Copier le contenu
 	_, err = io.Copy(destFile, sourceFile)
 	if err != nil {
-		return fmt.Errorf("erreur lors de la copie de la sauvegarde: %w", err)
+		return nil, fmt.Errorf("erreur lors de la copie de la sauvegarde: %w", err)
 	}
 
 	// Synchroniser
 	err = destFile.Sync()
 	if err != nil {
-		return fmt.Errorf("erreur lors de la synchronisation: %w", err)
+		return nil, fmt.Errorf("erreur lors de la synchronisation: %w", err)
 	}
 
 	// Rouvrir la connexion à la base de données
-	err = InitDB(dbPath)
+	conn, err := openConn(dbPath)
 	if err != nil {
-		return fmt.Errorf("erreur lors de la réouverture de la base de données: %w", err)
+		return nil, fmt.Errorf("erreur lors de la réouverture de la base de données: %w", err)
 	}
 
-	return nil
+	return conn, nil
 }
 
 // GetDefaultBackupPath retourne le chemin par défaut pour une sauvegarde
@@ -128,35 +221,69 @@ func CreateBackupDirectory(dbPath string) error {
 	return os.MkdirAll(backupDir, 0755)
 }
 
-// ResetDatabase réinitialise complètement la base de données
-// ATTENTION: Cette fonction supprime TOUTES les données !
-func ResetDatabase(dbPath string) error {
+// Reset réinitialise complètement la base de données, sauf le journal d'audit et les
+// opérateurs. Réservé aux administrateurs.
+// ATTENTION: Cette fonction supprime TOUTES les autres données !
+func (s *Store) Reset() error {
+	if err := s.requireAdmin("réinitialiser la base de données"); err != nil {
+		return err
+	}
+
 	// Créer une sauvegarde de sécurité avant la réinitialisation
-	if err := CreateBackupDirectory(dbPath); err != nil {
+	if err := CreateBackupDirectory(s.path); err != nil {
 		return fmt.Errorf("erreur lors de la création du répertoire de sauvegarde: %w", err)
 	}
 
-	backupPath := GetDefaultBackupPath(dbPath)
-	if err := BackupDatabase(dbPath, backupPath); err != nil {
+	backupPath := GetDefaultBackupPath(s.path)
+	if err := BackupDatabase(s.path, backupPath); err != nil {
 		return fmt.Errorf("erreur lors de la sauvegarde de sécurité: %w", err)
 	}
 
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Le journal d'audit et les opérateurs survivent à la réinitialisation
+	previousAudit, err := readAuditLog(s.conn)
+	if err != nil {
+		return fmt.Errorf("erreur lors de la lecture du journal d'audit: %w", err)
+	}
+	previousOperators, err := readOperatorAccounts(s.conn)
+	if err != nil {
+		return fmt.Errorf("erreur lors de la lecture des opérateurs: %w", err)
+	}
+
 	// Fermer la connexion actuelle
-	if err := CloseDB(); err != nil {
-		return fmt.Errorf("erreur lors de la fermeture de la base de données: %w", err)
+	if s.conn != nil {
+		if err := s.conn.Close(); err != nil {
+			return fmt.Errorf("erreur lors de la fermeture de la base de données: %w", err)
+		}
+		s.conn = nil
 	}
 
 	// Supprimer le fichier de base de données
-	if err := os.Remove(dbPath); err != nil && !os.IsNotExist(err) {
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("erreur lors de la suppression de la base de données: %w", err)
 	}
+	if err := removeJournalFiles(s.path); err != nil {
+		return fmt.Errorf("erreur lors de la suppression du journal de la base: %w", err)
+	}
 
 	// Réinitialiser la base de données
-	if err := InitDB(dbPath); err != nil {
+	conn, err := openConn(s.path)
+	if err != nil {
 		return fmt.Errorf("erreur lors de la réinitialisation de la base de données: %w", err)
 	}
+	s.conn = conn
+	s.site = 0
 
-	return nil
+	if err := carryOverAuditLog(conn, previousAudit); err != nil {
+		return fmt.Errorf("erreur lors du report du journal d'audit: %w", err)
+	}
+	if err := carryOverOperators(conn, previousOperators); err != nil {
+		return fmt.Errorf("erreur lors du report des opérateurs: %w", err)
+	}
+	return writeAudit(conn, s.operator, AuditReset, AuditEntityDatabase, 0,
+		"Base réinitialisée (sauvegarde : "+filepath.Base(backupPath)+")", nil, nil)
 }
 
 // ListBackups liste toutes les sauvegardes disponibles dans le dossier backups
@@ -268,20 +395,27 @@ func GetBackupInfo(backupPath string) (*BackupInfo, error) {
 	return backup, nil
 }
 
-// ImportFromPythonDB importe les données depuis l'ancienne base de données Python
-func ImportFromPythonDB(pythonDBPath string, currentDBPath string) error {
+// ImportFromPythonDB importe les données depuis l'ancienne base de données Python.
+// Réservé aux administrateurs.
+// Les bases de la version Go sont mises à jour par les migrations (voir migrations.go) :
+// cette importation ne concerne que les fichiers de la version Python.
+func (s *Store) ImportFromPythonDB(pythonDBPath string) error {
+	if err := s.requireAdmin("importer une base de données"); err != nil {
+		return err
+	}
+
 	// Vérifier que le fichier source existe
 	if _, err := os.Stat(pythonDBPath); os.IsNotExist(err) {
 		return fmt.Errorf("le fichier de base de données Python n'existe pas: %s", pythonDBPath)
 	}
 
 	// Créer une sauvegarde de la base actuelle avant l'importation
-	if err := CreateBackupDirectory(currentDBPath); err != nil {
+	if err := CreateBackupDirectory(s.path); err != nil {
 		return fmt.Errorf("erreur lors de la création du répertoire de sauvegarde: %w", err)
 	}
 
-	backupPath := GetDefaultBackupPath(currentDBPath)
-	if err := BackupDatabase(currentDBPath, backupPath); err != nil {
+	backupPath := GetDefaultBackupPath(s.path)
+	if err := BackupDatabase(s.path, backupPath); err != nil {
 		return fmt.Errorf("erreur lors de la sauvegarde de sécurité: %w", err)
 	}
 
@@ -298,12 +432,18 @@ func ImportFromPythonDB(pythonDBPath string, currentDBPath string) error {
 	}
 
 	// Commencer une transaction sur la base actuelle
-	tx, err := DB.Begin()
+	tx, err := s.beginWrite()
 	if err != nil {
 		return fmt.Errorf("erreur lors du démarrage de la transaction: %w", err)
 	}
 	defer tx.Rollback()
 
+	// Les données importées rejoignent le site sélectionné
+	siteID, err := s.siteOrDefault(tx)
+	if err != nil {
+		return err
+	}
+
 	// Importer les bâtiments
 	rows, err := pythonDB.Query("SELECT id, name FROM buildings ORDER BY id")
 	if err != nil {
@@ -318,7 +458,7 @@ func ImportFromPythonDB(pythonDBPath string, currentDBPath string) error {
 		if err := rows.Scan(&id, &name); err != nil {
 			return fmt.Errorf("erreur lors du scan des bâtiments: %w", err)
 		}
-		_, err = tx.Exec("INSERT OR IGNORE INTO buildings (id, name) VALUES (?, ?)", id, name)
+		_, err = tx.Exec("INSERT OR IGNORE INTO buildings (id, name, site_id) VALUES (?, ?, ?)", id, name, siteID)
 		if err != nil {
 			return fmt.Errorf("erreur lors de l'insertion du bâtiment: %w", err)
 		}
@@ -364,8 +504,8 @@ func ImportFromPythonDB(pythonDBPath string, currentDBPath string) error {
 		if err := rows.Scan(&id, &number, &description, &quantityTotal, &quantityReserve, &storageLocation); err != nil {
 			return fmt.Errorf("erreur lors du scan des clés: %w", err)
 		}
-		_, err = tx.Exec("INSERT OR IGNORE INTO keys (id, number, description, quantity_total, quantity_reserve, storage_location) VALUES (?, ?, ?, ?, ?, ?)",
-			id, number, description.String, quantityTotal.Int64, quantityReserve.Int64, storageLocation.String)
+		_, err = tx.Exec("INSERT OR IGNORE INTO keys (id, number, description, quantity_total, quantity_reserve, storage_location, site_id) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			id, number, description.String, quantityTotal.Int64, quantityReserve.Int64, storageLocation.String, siteID)
 		if err != nil {
 			return fmt.Errorf("erreur lors de l'insertion de la clé: %w", err)
 		}
@@ -407,7 +547,7 @@ func ImportFromPythonDB(pythonDBPath string, currentDBPath string) error {
 		if err := rows.Scan(&id, &name, &email); err != nil {
 			return fmt.Errorf("erreur lors du scan des emprunteurs: %w", err)
 		}
-		_, err = tx.Exec("INSERT OR IGNORE INTO borrowers (id, name, email) VALUES (?, ?, ?)", id, name, email.String)
+		_, err = tx.Exec("INSERT OR IGNORE INTO borrowers (id, name, email, site_id) VALUES (?, ?, ?, ?)", id, name, email.String, siteID)
 		if err != nil {
 			return fmt.Errorf("erreur lors de l'insertion de l'emprunteur: %w", err)
 		}
@@ -445,6 +585,28 @@ func ImportFromPythonDB(pythonDBPath string, currentDBPath string) error {
 		loanCount++
 	}
 
+	// Créer les exemplaires des clés importées à partir de leurs quantités
+	if err := generateMissingCopies(tx); err != nil {
+		return fmt.Errorf("erreur lors de la création des exemplaires: %w", err)
+	}
+	if err := linkStorageLocations(tx); err != nil {
+		return fmt.Errorf("erreur lors du rattachement des emplacements de rangement: %w", err)
+	}
+	if err := openStockLedger(tx); err != nil {
+		return fmt.Errorf("erreur lors de l'ouverture du registre de stock: %w", err)
+	}
+
+	// La version Python ne connaît que le nom complet des emprunteurs
+	if err := splitBorrowerNames(tx); err != nil {
+		return fmt.Errorf("erreur lors de la séparation des prénoms et noms: %w", err)
+	}
+
+	summary := fmt.Sprintf("Importation de %s : %d bâtiments, %d salles, %d clés, %d emprunteurs, %d emprunts",
+		filepath.Base(pythonDBPath), buildingCount, roomCount, keyCount, borrowerCount, loanCount)
+	if err := s.audit(tx, AuditImport, AuditEntityDatabase, 0, summary, nil, nil); err != nil {
+		return err
+	}
+
 	// Valider la transaction
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("erreur lors de la validation de la transaction: %w", err)
@@ -462,8 +624,13 @@ func ImportFromPythonDB(pythonDBPath string, currentDBPath string) error {
 	return nil
 }
 
-// GenerateDemoData remplit la base de données avec des données de test
-func GenerateDemoData() error {
+// GenerateDemoData remplit la base de données avec des données de test, dans le site sélectionné
+func (s *Store) GenerateDemoData() error {
+	siteID, err := s.siteOrDefault(s.db())
+	if err != nil {
+		return err
+	}
+
 	// Créer des bâtiments
 	buildings := []string{
 		"Bâtiment Principal",
@@ -475,7 +642,7 @@ func GenerateDemoData() error {
 
 	buildingIDs := make(map[string]int)
 	for _, name := range buildings {
-		result, err := DB.Exec("INSERT INTO buildings (name) VALUES (?)", name)
+		result, err := s.db().Exec("INSERT INTO buildings (name, site_id) VALUES (?, ?)", name, siteID)
 		if err != nil {
 			return fmt.Errorf("erreur lors de la création du bâtiment %s: %w", name, err)
 		}
@@ -506,7 +673,7 @@ func GenerateDemoData() error {
 	roomIDs := make(map[string]int)
 	for _, room := range rooms {
 		buildingID := buildingIDs[room.building]
-		result, err := DB.Exec("INSERT INTO rooms (name, type, building_id) VALUES (?, ?, ?)",
+		result, err := s.db().Exec("INSERT INTO rooms (name, type, building_id) VALUES (?, ?, ?)",
 			room.name, room.roomType, buildingID)
 		if err != nil {
 			return fmt.Errorf("erreur lors de la création de la salle %s: %w", room.name, err)
@@ -515,6 +682,11 @@ func GenerateDemoData() error {
 		roomIDs[room.name] = int(id)
 	}
 
+	// Répartir le bâtiment principal sur deux étages et regrouper les salles sensibles en zone
+	if err := s.placeDemoRooms(siteID, buildingIDs["Bâtiment Principal"], roomIDs); err != nil {
+		return fmt.Errorf("erreur lors de la création des étages et zones: %w", err)
+	}
+
 	// Créer des clés
 	keys := []struct {
 		number      string
@@ -538,8 +710,8 @@ func GenerateDemoData() error {
 
 	keyIDs := make(map[string]int)
 	for _, key := range keys {
-		result, err := DB.Exec("INSERT INTO keys (number, description, quantity_total, quantity_reserve, storage_location) VALUES (?, ?, ?, ?, ?)",
-			key.number, key.description, key.total, key.reserve, key.storage)
+		result, err := s.db().Exec("INSERT INTO keys (number, description, quantity_total, quantity_reserve, storage_location, site_id) VALUES (?, ?, ?, ?, ?, ?)",
+			key.number, key.description, key.total, key.reserve, key.storage, siteID)
 		if err != nil {
 			return fmt.Errorf("erreur lors de la création de la clé %s: %w", key.number, err)
 		}
@@ -549,7 +721,7 @@ func GenerateDemoData() error {
 		// Associer les salles
 		for _, roomName := range key.rooms {
 			if roomID, ok := roomIDs[roomName]; ok {
-				_, err = DB.Exec("INSERT INTO key_room_association (key_id, room_id) VALUES (?, ?)", id, roomID)
+				_, err = s.db().Exec("INSERT INTO key_room_association (key_id, room_id) VALUES (?, ?)", id, roomID)
 				if err != nil {
 					return fmt.Errorf("erreur lors de l'association clé-salle: %w", err)
 				}
@@ -574,8 +746,8 @@ func GenerateDemoData() error {
 
 	borrowerIDs := make([]int, 0)
 	for _, borrower := range borrowers {
-		result, err := DB.Exec("INSERT INTO borrowers (name, email) VALUES (?, ?)",
-			borrower.name, borrower.email)
+		result, err := s.db().Exec("INSERT INTO borrowers (name, email, site_id) VALUES (?, ?, ?)",
+			borrower.name, borrower.email, siteID)
 		if err != nil {
 			return fmt.Errorf("erreur lors de la création de l'emprunteur %s: %w", borrower.name, err)
 		}
@@ -583,6 +755,11 @@ func GenerateDemoData() error {
 		borrowerIDs = append(borrowerIDs, int(id))
 	}
 
+	// Quelques champs personnalisés : couleur et marque de cylindre des clés, plaque des emprunteurs
+	if err := s.addDemoCustomFields(siteID, keyIDs, borrowerIDs); err != nil {
+		return fmt.Errorf("erreur lors de la création des champs personnalisés: %w", err)
+	}
+
 	// Créer quelques emprunts actifs
 	loans := []struct {
 		keyNumber  string
@@ -598,12 +775,151 @@ func GenerateDemoData() error {
 
 	for _, loan := range loans {
 		keyID := keyIDs[loan.keyNumber]
-		_, err := DB.Exec("INSERT INTO loans (key_id, borrower_id, loan_date) VALUES (?, ?, datetime('now', '-' || abs(random() % 10) || ' days'))",
+		_, err := s.db().Exec("INSERT INTO loans (key_id, borrower_id, loan_date) VALUES (?, ?, datetime('now', '-' || abs(random() % 10) || ' days'))",
 			keyID, loan.borrowerID)
 		if err != nil {
 			return fmt.Errorf("erreur lors de la création de l'emprunt: %w", err)
 		}
 	}
 
+	// Créer les exemplaires à partir des quantités et des emprunts ci-dessus
+	tx, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := generateMissingCopies(tx); err != nil {
+		return fmt.Errorf("erreur lors de la création des exemplaires: %w", err)
+	}
+	if err := linkStorageLocations(tx); err != nil {
+		return fmt.Errorf("erreur lors du rattachement des emplacements de rangement: %w", err)
+	}
+	if err := openStockLedger(tx); err != nil {
+		return fmt.Errorf("erreur lors de l'ouverture du registre de stock: %w", err)
+	}
+	if err := splitBorrowerNames(tx); err != nil {
+		return fmt.Errorf("erreur lors de la séparation des prénoms et noms: %w", err)
+	}
+	if err := hangDemoCabinet(tx, siteID, "Bureau d'accueil", 24); err != nil {
+		return fmt.Errorf("erreur lors de la création de l'armoire à clés: %w", err)
+	}
+
+	if err := s.audit(tx, AuditDemo, AuditEntityDatabase, 0, "Données de démonstration générées", nil, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// placeDemoRooms crée les étages du bâtiment principal et la zone Labo des données de démonstration
+func (s *Store) placeDemoRooms(siteID, buildingID int, roomIDs map[string]int) error {
+	floors := []struct {
+		name  string
+		level int
+		rooms []string
+	}{
+		{"Rez-de-chaussée", 0, []string{"Salle 101", "Salle 102", "Amphithéâtre A"}},
+		{"1er étage", 1, []string{"Salle 201"}},
+	}
+	for _, floor := range floors {
+		result, err := s.db().Exec("INSERT INTO floors (building_id, name, level) VALUES (?, ?, ?)", buildingID, floor.name, floor.level)
+		if err != nil {
+			return err
+		}
+		id, _ := result.LastInsertId()
+		for _, room := range floor.rooms {
+			if _, err := s.db().Exec("UPDATE rooms SET floor_id = ? WHERE id = ?", id, roomIDs[room]); err != nil {
+				return err
+			}
+		}
+	}
+
+	result, err := s.db().Exec("INSERT INTO zones (site_id, name, description) VALUES (?, ?, ?)",
+		siteID, "Labo", "Zone à accès restreint : laboratoires et archives")
+	if err != nil {
+		return err
+	}
+	zoneID, _ := result.LastInsertId()
+	for _, room := range []string{"Laboratoire 1", "Laboratoire 2", "Archives"} {
+		if _, err := s.db().Exec("UPDATE rooms SET zone_id = ? WHERE id = ?", zoneID, roomIDs[room]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addDemoCustomFields définit les champs personnalisés des données de démonstration et en renseigne quelques-uns
+func (s *Store) addDemoCustomFields(siteID int, keyIDs map[string]int, borrowerIDs []int) error {
+	fields := []struct {
+		entity    string
+		label     string
+		fieldType CustomFieldType
+		choices   string
+		inReports bool
+		values    map[int]string
+	}{
+		{AuditEntityKey, "Couleur", CustomFieldChoice, "Rouge\nBleu\nVert\nJaune", true,
+			map[int]string{keyIDs["K001"]: "Rouge", keyIDs["K003"]: "Vert", keyIDs["K007"]: "Jaune", keyIDs["K010"]: "Bleu"}},
+		{AuditEntityKey, "Marque du cylindre", CustomFieldText, "", false,
+			map[int]string{keyIDs["K001"]: "Vachette", keyIDs["K007"]: "Bricard"}},
+		{AuditEntityBorrower, "Plaque du véhicule", CustomFieldText, "", true,
+			map[int]string{borrowerIDs[0]: "AB-123-CD", borrowerIDs[3]: "EF-456-GH"}},
+	}
+	for _, field := range fields {
+		result, err := s.db().Exec("INSERT INTO custom_fields (site_id, entity, label, field_type, choices, in_reports) VALUES (?, ?, ?, ?, ?, ?)",
+			siteID, field.entity, field.label, field.fieldType, field.choices, field.inReports)
+		if err != nil {
+			return err
+		}
+		fieldID, _ := result.LastInsertId()
+		for recordID, value := range field.values {
+			_, err := s.db().Exec("INSERT INTO custom_field_values (field_id, entity, entity_id, value) VALUES (?, ?, ?, ?)",
+				fieldID, field.entity, recordID, value)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// hangDemoCabinet transforme un emplacement des données de démonstration en armoire
+// et range ses exemplaires en service sur les premiers crochets
+func hangDemoCabinet(tx *sql.Tx, siteID int, name string, hooks int) error {
+	var locationID int
+	err := tx.QueryRow(`SELECT id FROM storage_locations WHERE site_id = ? AND name = ?`, siteID, name).Scan(&locationID)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE storage_locations SET hook_count = ? WHERE id = ?`, hooks, locationID); err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(`SELECT c.id FROM key_copies c INNER JOIN keys k ON k.id = c.key_id
+		WHERE k.storage_location_id = ? AND c.status NOT IN (?, ?, ?)
+		ORDER BY k.number, c.id LIMIT ?`, locationID, CopyLost, CopyStolen, CopyDestroyed, hooks)
+	if err != nil {
+		return err
+	}
+	var copyIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		copyIDs = append(copyIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i, id := range copyIDs {
+		if _, err := tx.Exec(`UPDATE key_copies SET hook_number = ? WHERE id = ?`, i+1, id); err != nil {
+			return err
+		}
+	}
 	return nil
 }
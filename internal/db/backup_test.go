@@ -0,0 +1,141 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// openFileStore ouvre une base dans un répertoire temporaire (Reset et Restore
+// remplacent un fichier) avec un administrateur connecté, sur le site par défaut
+func openFileStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := Open(filepath.Join(t.TempDir(), "clefs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	admin := &Operator{Username: "admin", Role: RoleAdmin}
+	if err := s.CreateOperator(admin, "motdepasse"); err != nil {
+		t.Fatal(err)
+	}
+	s.SetOperator(admin.Username)
+	s.SetSite(1)
+	return s
+}
+
+func TestResetKeepsOperators(t *testing.T) {
+	s := openFileStore(t)
+	if err := s.CreateBuilding(&Building{Name: "Bâtiment A"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Reset(); err != nil {
+		t.Fatal(err)
+	}
+
+	if count, err := s.CountOperators(); err != nil || count != 1 {
+		t.Fatalf("CountOperators() = %d, %v après réinitialisation, 1 attendu", count, err)
+	}
+	if _, err := s.Authenticate("admin", "motdepasse"); err != nil {
+		t.Fatalf("connexion impossible après réinitialisation: %v", err)
+	}
+	if buildings, err := s.GetAllBuildings(); err != nil || len(buildings) != 0 {
+		t.Fatalf("GetAllBuildings() = %d, %v après réinitialisation, 0 attendu", len(buildings), err)
+	}
+}
+
+func TestRestoreKeepsCurrentOperators(t *testing.T) {
+	s := openFileStore(t)
+
+	// Sauvegarde prise avant la création du gestionnaire
+	backupPath := filepath.Join(t.TempDir(), "avant.db")
+	if err := s.Backup(backupPath); err != nil {
+		t.Fatal(err)
+	}
+	manager := &Operator{Username: "gestion", Role: RoleManager}
+	if err := s.CreateOperator(manager, "motdepasse"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Restore(backupPath); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Authenticate("gestion", "motdepasse"); err != nil {
+		t.Fatalf("le compte créé après la sauvegarde doit survivre à la restauration: %v", err)
+	}
+}
+
+func TestResetRestoreRequireAdmin(t *testing.T) {
+	s := openFileStore(t)
+	manager := &Operator{Username: "gestion", Role: RoleManager}
+	if err := s.CreateOperator(manager, "motdepasse"); err != nil {
+		t.Fatal(err)
+	}
+	backupPath := filepath.Join(t.TempDir(), "sauvegarde.db")
+	if err := s.Backup(backupPath); err != nil {
+		t.Fatal(err)
+	}
+
+	s.SetOperator(manager.Username)
+	if err := s.Reset(); err == nil {
+		t.Error("Reset() accepté pour un gestionnaire")
+	}
+	if err := s.Restore(backupPath); err == nil {
+		t.Error("Restore() accepté pour un gestionnaire")
+	}
+	if err := s.ImportFromPythonDB(backupPath); err == nil {
+		t.Error("ImportFromPythonDB() accepté pour un gestionnaire")
+	}
+}
+
+// auditSummaries compte les entrées du journal par résumé
+func auditSummaries(t *testing.T, s *Store) map[string]int {
+	t.Helper()
+	entries, err := s.GetAuditLog(AuditFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]int)
+	for _, e := range entries {
+		counts[e.Summary]++
+	}
+	return counts
+}
+
+func TestRestoreCarriesOverAuditLogByID(t *testing.T) {
+	s := openFileStore(t)
+	if err := s.CreateBuilding(&Building{Name: "Bâtiment A"}); err != nil {
+		t.Fatal(err)
+	}
+	backupPath := filepath.Join(t.TempDir(), "sauvegarde.db")
+	if err := s.Backup(backupPath); err != nil {
+		t.Fatal(err)
+	}
+
+	// Après la sauvegarde : une entrée de la même seconde que la dernière de la sauvegarde,
+	// une autre d'un poste dont l'horloge retarde
+	var lastAt time.Time
+	if err := s.db().QueryRow(`SELECT created_at FROM audit_log ORDER BY id DESC LIMIT 1`).Scan(&lastAt); err != nil {
+		t.Fatal(err)
+	}
+	for summary, at := range map[string]time.Time{"Même seconde": lastAt, "Horloge en retard": lastAt.Add(-time.Hour)} {
+		_, err := s.db().Exec(`INSERT INTO audit_log (created_at, operator, action, entity, summary) VALUES (?, 'admin', ?, ?, ?)`,
+			at, AuditCreate, AuditEntityBuilding, summary)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	before := auditSummaries(t, s)
+
+	if err := s.Restore(backupPath); err != nil {
+		t.Fatal(err)
+	}
+	after := auditSummaries(t, s)
+	for summary, count := range before {
+		if after[summary] != count {
+			t.Errorf("entrée %q présente %d fois après restauration, %d attendue(s)", summary, after[summary], count)
+		}
+	}
+}
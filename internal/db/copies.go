@@ -0,0 +1,336 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrNoCopyAvailable est retournée lorsqu'aucun exemplaire d'une clé ne peut être prêté
+var ErrNoCopyAvailable = errors.New("aucun exemplaire disponible")
+
+// copySelect est la requête commune aux lectures d'exemplaires, avec l'emprunteur actuel éventuel
+const copySelect = `
+		SELECT c.id, c.key_id, c.identifier, c.status, c.notes, c.hook_number, b.name
+		FROM key_copies c
+		LEFT JOIN loans l ON l.copy_id = c.id AND l.return_date IS NULL
+		LEFT JOIN borrowers b ON b.id = l.borrower_id`
+
+// GetCopiesForKey récupère tous les exemplaires d'une clé
+func (s *Store) GetCopiesForKey(keyID int) ([]KeyCopy, error) {
+	return s.queryCopies(copySelect+`
+		WHERE c.key_id = ?
+		ORDER BY c.id`, keyID)
+}
+
+// GetAvailableCopies récupère les exemplaires d'une clé qui peuvent être prêtés
+func (s *Store) GetAvailableCopies(keyID int) ([]KeyCopy, error) {
+	return s.queryCopies(copySelect+`
+		WHERE c.key_id = ? AND c.status = ?
+		ORDER BY c.id`, keyID, CopyAvailable)
+}
+
+// queryCopies exécute une requête basée sur copySelect
+func (s *Store) queryCopies(query string, args ...interface{}) ([]KeyCopy, error) {
+	rows, err := s.db().Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var copies []KeyCopy
+	for rows.Next() {
+		var c KeyCopy
+		var notes, borrowerName sql.NullString
+		var hook sql.NullInt64
+		if err := rows.Scan(&c.ID, &c.KeyID, &c.Identifier, &c.Status, &notes, &hook, &borrowerName); err != nil {
+			return nil, err
+		}
+		c.Notes = notes.String
+		c.HookNumber = int(hook.Int64)
+		c.BorrowerName = borrowerName.String
+		copies = append(copies, c)
+	}
+	return copies, rows.Err()
+}
+
+// AddKeyCopy ajoute un exemplaire disponible à une clé, acheté ou reproduit (movement),
+// et inscrit le mouvement au registre de stock avec son motif.
+// Sans identifiant, le suivant est généré à partir du numéro de la clé (ex: A12-4).
+func (s *Store) AddKeyCopy(keyID int, identifier string, movement StockMovementType, reason string) error {
+	if movement != MovementPurchase && movement != MovementDuplication {
+		return fmt.Errorf("un exemplaire ajouté provient d'un achat ou d'une reproduction")
+	}
+
+	tx, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if identifier == "" {
+		identifier, err = nextCopyIdentifier(tx, keyID)
+		if err != nil {
+			return err
+		}
+	}
+
+	result, err := tx.Exec(`INSERT INTO key_copies (key_id, identifier, status) VALUES (?, ?, ?)`,
+		keyID, identifier, CopyAvailable)
+	if err != nil {
+		return fmt.Errorf("erreur lors de l'ajout de l'exemplaire %s: %w", identifier, err)
+	}
+	copyID, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	if err := s.auditInsert(tx, AuditEntityCopy, int(copyID), "Exemplaire "+identifier+" ajouté"); err != nil {
+		return err
+	}
+
+	id := int(copyID)
+	if err := s.addStockMovement(tx, keyID, &id, movement, 1, reason); err != nil {
+		return err
+	}
+	if err := syncKeyQuantities(tx, keyID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SetCopyStatus change l'état d'un exemplaire qui n'est pas en cours d'emprunt et inscrit
+// au registre de stock les mouvements qui en découlent, les notes servant de motif.
+// L'état « emprunté » est géré uniquement par les emprunts et les retours.
+// Un exemplaire qui sort du stock libère son crochet d'armoire.
+func (s *Store) SetCopyStatus(copyID int, status CopyStatus, notes string) error {
+	if status == CopyLoaned {
+		return fmt.Errorf("un exemplaire passe à l'état emprunté uniquement via un emprunt")
+	}
+
+	tx, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var keyID int
+	var identifier string
+	var current CopyStatus
+	err = tx.QueryRow(`SELECT key_id, identifier, status FROM key_copies WHERE id = ?`, copyID).Scan(&keyID, &identifier, &current)
+	if err != nil {
+		return err
+	}
+	if current == CopyLoaned {
+		return fmt.Errorf("l'exemplaire est en cours d'emprunt : enregistrez d'abord son retour")
+	}
+
+	before, err := snapshotRow(tx, AuditEntityCopy, copyID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE key_copies SET status = ?, notes = ? WHERE id = ?`, status, notes, copyID)
+	if err != nil {
+		return err
+	}
+	if !status.InService() {
+		if _, err := tx.Exec(`UPDATE key_copies SET hook_number = NULL WHERE id = ?`, copyID); err != nil {
+			return err
+		}
+	}
+
+	after, err := snapshotRow(tx, AuditEntityCopy, copyID)
+	if err != nil {
+		return err
+	}
+	summary := fmt.Sprintf("Exemplaire %s : %s", identifier, status.Label())
+	if err := s.audit(tx, AuditUpdate, AuditEntityCopy, copyID, summary, before, after); err != nil {
+		return err
+	}
+
+	reason := notes
+	if reason == "" {
+		reason = fmt.Sprintf("Exemplaire passé de « %s » à « %s »", current.Label(), status.Label())
+	}
+	for _, movement := range copyStatusMovements(current, status) {
+		if err := s.addStockMovement(tx, keyID, &copyID, movement, 1, reason); err != nil {
+			return err
+		}
+	}
+	if err := syncKeyQuantities(tx, keyID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// nextCopyIdentifier propose l'identifiant du prochain exemplaire d'une clé
+func nextCopyIdentifier(tx *sql.Tx, keyID int) (string, error) {
+	var number string
+	var count int
+	err := tx.QueryRow(`SELECT k.number, (SELECT COUNT(*) FROM key_copies WHERE key_id = k.id)
+		FROM keys k WHERE k.id = ?`, keyID).Scan(&number, &count)
+	if err != nil {
+		return "", err
+	}
+
+	// Éviter les collisions avec un identifiant saisi à la main
+	for n := count + 1; ; n++ {
+		identifier := fmt.Sprintf("%s-%d", number, n)
+		var exists int
+		err := tx.QueryRow(`SELECT COUNT(*) FROM key_copies WHERE key_id = ? AND identifier = ?`, keyID, identifier).Scan(&exists)
+		if err != nil {
+			return "", err
+		}
+		if exists == 0 {
+			return identifier, nil
+		}
+	}
+}
+
+// syncKeyQuantitiesFromCopies recalcule les quantités de la clé à partir de ses exemplaires.
+// Les exemplaires perdus, volés ou détruits ne comptent plus dans le total.
+// Utilisée tant que le registre de stock n'est pas ouvert (migrations et importations) ;
+// ensuite, les quantités découlent du registre (syncKeyQuantities).
+func syncKeyQuantitiesFromCopies(tx *sql.Tx, keyID int) error {
+	_, err := tx.Exec(`UPDATE keys SET
+		quantity_total = (SELECT COUNT(*) FROM key_copies WHERE key_id = ? AND status NOT IN (?, ?, ?)),
+		quantity_reserve = (SELECT COUNT(*) FROM key_copies WHERE key_id = ? AND status = ?)
+		WHERE id = ?`,
+		keyID, CopyLost, CopyStolen, CopyDestroyed, keyID, CopyReserve, keyID)
+	return err
+}
+
+// stockNewKey crée les exemplaires d'une nouvelle clé à partir des quantités saisies dans
+// le formulaire et inscrit leur achat, puis leur mise en réserve, au registre de stock
+func (s *Store) stockNewKey(tx *sql.Tx, k *Key) error {
+	if k.QuantityReserve > k.QuantityTotal {
+		return fmt.Errorf("la réserve (%d) ne peut pas dépasser la quantité totale (%d)", k.QuantityReserve, k.QuantityTotal)
+	}
+
+	for i := 0; i < k.QuantityTotal; i++ {
+		identifier, err := nextCopyIdentifier(tx, k.ID)
+		if err != nil {
+			return err
+		}
+		// Les derniers exemplaires forment la réserve
+		status := CopyAvailable
+		if i >= k.QuantityTotal-k.QuantityReserve {
+			status = CopyReserve
+		}
+		_, err = tx.Exec(`INSERT INTO key_copies (key_id, identifier, status) VALUES (?, ?, ?)`, k.ID, identifier, status)
+		if err != nil {
+			return err
+		}
+	}
+
+	if k.QuantityTotal > 0 {
+		if err := s.addStockMovement(tx, k.ID, nil, MovementPurchase, k.QuantityTotal, "Création de la clé"); err != nil {
+			return err
+		}
+	}
+	if k.QuantityReserve > 0 {
+		if err := s.addStockMovement(tx, k.ID, nil, MovementToReserve, k.QuantityReserve, "Création de la clé"); err != nil {
+			return err
+		}
+	}
+
+	return syncKeyQuantities(tx, k.ID)
+}
+
+// generateMissingCopies crée les exemplaires des clés qui n'en ont pas encore à partir
+// de leurs quantités : les emprunts actifs reçoivent chacun un exemplaire, puis la réserve,
+// puis les exemplaires disponibles. Utilisée par la migration et par les importations.
+func generateMissingCopies(tx *sql.Tx) error {
+	type keyQuantities struct {
+		id, total, reserve int
+		number             string
+	}
+
+	rows, err := tx.Query(`SELECT id, number, quantity_total, quantity_reserve FROM keys
+		WHERE id NOT IN (SELECT key_id FROM key_copies)`)
+	if err != nil {
+		return err
+	}
+	var keys []keyQuantities
+	for rows.Next() {
+		var k keyQuantities
+		var total, reserve sql.NullInt64
+		if err := rows.Scan(&k.id, &k.number, &total, &reserve); err != nil {
+			rows.Close()
+			return err
+		}
+		k.total, k.reserve = int(total.Int64), int(reserve.Int64)
+		keys = append(keys, k)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, k := range keys {
+		loanIDs, err := activeLoanIDsWithoutCopy(tx, k.id)
+		if err != nil {
+			return err
+		}
+
+		// Une base incohérente peut avoir plus d'emprunts que d'exemplaires
+		count := k.total
+		if count < len(loanIDs)+k.reserve {
+			count = len(loanIDs) + k.reserve
+		}
+
+		for i := 0; i < count; i++ {
+			status := CopyAvailable
+			if i < len(loanIDs) {
+				status = CopyLoaned
+			} else if i < len(loanIDs)+k.reserve {
+				status = CopyReserve
+			}
+
+			result, err := tx.Exec(`INSERT INTO key_copies (key_id, identifier, status) VALUES (?, ?, ?)`,
+				k.id, fmt.Sprintf("%s-%d", k.number, i+1), status)
+			if err != nil {
+				return err
+			}
+
+			if i < len(loanIDs) {
+				copyID, err := result.LastInsertId()
+				if err != nil {
+					return err
+				}
+				if _, err := tx.Exec(`UPDATE loans SET copy_id = ? WHERE id = ?`, copyID, loanIDs[i]); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := syncKeyQuantitiesFromCopies(tx, k.id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// activeLoanIDsWithoutCopy liste les emprunts en cours d'une clé qui ne désignent aucun exemplaire
+func activeLoanIDsWithoutCopy(tx *sql.Tx, keyID int) ([]int, error) {
+	rows, err := tx.Query(`SELECT id FROM loans
+		WHERE key_id = ? AND return_date IS NULL AND copy_id IS NULL
+		ORDER BY loan_date, id`, keyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
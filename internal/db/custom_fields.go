@@ -0,0 +1,446 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// customFieldSelect est la requête commune aux lectures de champs personnalisés
+const customFieldSelect = `
+		SELECT f.id, f.site_id, f.entity, f.label, f.field_type, f.choices, f.in_reports
+		FROM custom_fields f`
+
+// scanCustomField lit une ligne de customFieldSelect
+func scanCustomField(row rowScanner) (CustomField, error) {
+	var f CustomField
+	var choices sql.NullString
+	err := row.Scan(&f.ID, &f.SiteID, &f.Entity, &f.Label, &f.Type, &choices, &f.InReports)
+	if choices.String != "" {
+		f.Choices = strings.Split(choices.String, "\n")
+	}
+	return f, err
+}
+
+// queryCustomFields exécute une requête construite sur customFieldSelect
+func queryCustomFields(q auditQueryer, query string, args ...interface{}) ([]CustomField, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fields []CustomField
+	for rows.Next() {
+		f, err := scanCustomField(rows)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, f)
+	}
+	return fields, rows.Err()
+}
+
+// checkCustomField nettoie la définition d'un champ et vérifie qu'elle est complète
+func checkCustomField(f *CustomField) error {
+	f.Label = strings.TrimSpace(f.Label)
+	if f.Label == "" {
+		return fmt.Errorf("le libellé du champ est requis")
+	}
+
+	var choices []string
+	for _, choice := range f.Choices {
+		if choice = strings.TrimSpace(choice); choice != "" {
+			choices = append(choices, choice)
+		}
+	}
+	f.Choices = nil
+	switch f.Type {
+	case CustomFieldText, CustomFieldNumber, CustomFieldDate:
+	case CustomFieldChoice:
+		if len(choices) == 0 {
+			return fmt.Errorf("une liste de choix doit proposer au moins une valeur")
+		}
+		f.Choices = choices
+	default:
+		return fmt.Errorf("type de champ inconnu : %s", f.Type)
+	}
+	return nil
+}
+
+// checkReportColumns vérifie qu'un site ne dépasse pas MaxReportCustomFields colonnes de rapport
+// pour une entité (excludeID est le champ en cours de modification)
+func checkReportColumns(tx *sql.Tx, f *CustomField, excludeID int) error {
+	if !f.InReports {
+		return nil
+	}
+	var count int
+	err := tx.QueryRow(`SELECT COUNT(*) FROM custom_fields WHERE site_id = ? AND entity = ? AND in_reports = 1 AND id <> ?`,
+		f.SiteID, f.Entity, excludeID).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count >= MaxReportCustomFields {
+		return fmt.Errorf("au plus %d champs personnalisés peuvent figurer dans les rapports", MaxReportCustomFields)
+	}
+	return nil
+}
+
+// GetCustomFields récupère les champs personnalisés d'une entité définis par le site sélectionné
+// (par tous les sites dans la vue consolidée)
+func (s *Store) GetCustomFields(entity string) ([]CustomField, error) {
+	site, args := s.siteCondition("f.site_id")
+	return queryCustomFields(s.db(), customFieldSelect+` WHERE f.entity = ?`+site+` ORDER BY f.site_id, f.id`,
+		append([]interface{}{entity}, args...)...)
+}
+
+// GetRecordCustomFields récupère les champs personnalisés qui s'appliquent à un enregistrement :
+// ceux de son site, ou ceux du site sélectionné pour un nouvel enregistrement (recordID 0).
+// Dans la vue consolidée, un nouvel enregistrement n'a pas de champ personnalisé.
+func (s *Store) GetRecordCustomFields(entity string, recordID int) ([]CustomField, error) {
+	siteID := s.Site()
+	if recordID > 0 {
+		err := s.db().QueryRow(`SELECT site_id FROM record_sites WHERE entity = ? AND id = ?`, entity, recordID).Scan(&siteID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if siteID == 0 {
+		return nil, nil
+	}
+	return queryCustomFields(s.db(), customFieldSelect+` WHERE f.entity = ? AND f.site_id = ? ORDER BY f.id`, entity, siteID)
+}
+
+// CreateCustomField définit un champ personnalisé dans le site sélectionné
+func (s *Store) CreateCustomField(f *CustomField) error {
+	if err := checkCustomField(f); err != nil {
+		return err
+	}
+	siteID, err := s.createSite()
+	if err != nil {
+		return err
+	}
+	f.SiteID = siteID
+
+	tx, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkReportColumns(tx, f, 0); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(`INSERT INTO custom_fields (site_id, entity, label, field_type, choices, in_reports) VALUES (?, ?, ?, ?, ?, ?)`,
+		f.SiteID, f.Entity, f.Label, f.Type, strings.Join(f.Choices, "\n"), f.InReports)
+	if err != nil {
+		return fmt.Errorf("erreur lors de la création du champ %s: %w", f.Label, err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	f.ID = int(id)
+
+	if err := s.auditInsert(tx, AuditEntityCustomField, f.ID, "Champ personnalisé "+f.Label+" créé"); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateCustomField modifie le libellé, les choix ou l'affichage dans les rapports d'un champ.
+// Le type n'est pas modifiable : les valeurs déjà saisies ont été vérifiées selon ce type.
+// Un choix retiré de la liste reste enregistré sur les fiches qui l'utilisaient.
+func (s *Store) UpdateCustomField(f *CustomField) error {
+	tx, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	current, err := scanCustomField(tx.QueryRow(customFieldSelect+` WHERE f.id = ?`, f.ID))
+	if err != nil {
+		return err
+	}
+	f.SiteID, f.Entity, f.Type = current.SiteID, current.Entity, current.Type
+	if err := checkCustomField(f); err != nil {
+		return err
+	}
+	if err := checkReportColumns(tx, f, f.ID); err != nil {
+		return err
+	}
+
+	before, err := snapshotRow(tx, AuditEntityCustomField, f.ID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE custom_fields SET label = ?, choices = ?, in_reports = ? WHERE id = ?`,
+		f.Label, strings.Join(f.Choices, "\n"), f.InReports, f.ID)
+	if err != nil {
+		return fmt.Errorf("erreur lors de la modification du champ %s: %w", f.Label, err)
+	}
+	after, err := snapshotRow(tx, AuditEntityCustomField, f.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.audit(tx, AuditUpdate, AuditEntityCustomField, f.ID, "Champ personnalisé "+f.Label+" modifié", before, after); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteCustomField supprime un champ personnalisé et toutes ses valeurs
+func (s *Store) DeleteCustomField(id int) error {
+	tx, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := snapshotRow(tx, AuditEntityCustomField, id)
+	if err != nil {
+		return err
+	}
+	if before == nil {
+		return sql.ErrNoRows
+	}
+
+	if _, err := tx.Exec(`DELETE FROM custom_field_values WHERE field_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM custom_fields WHERE id = ?`, id); err != nil {
+		return err
+	}
+	summary := fmt.Sprintf("Champ personnalisé %v supprimé", before["label"])
+	if err := s.audit(tx, AuditDelete, AuditEntityCustomField, id, summary, before, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetCustomValues récupère les valeurs enregistrées des champs personnalisés d'un enregistrement
+func (s *Store) GetCustomValues(entity string, id int) (CustomValues, error) {
+	rows, err := s.db().Query(`SELECT field_id, value FROM custom_field_values WHERE entity = ? AND entity_id = ?`, entity, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := make(CustomValues)
+	for rows.Next() {
+		var fieldID int
+		var value string
+		if err := rows.Scan(&fieldID, &value); err != nil {
+			return nil, err
+		}
+		values[fieldID] = value
+	}
+	return values, rows.Err()
+}
+
+// GetRecordCustomFieldValues récupère les champs personnalisés d'un enregistrement avec
+// leur valeur affichée, y compris les champs non renseignés
+func (s *Store) GetRecordCustomFieldValues(entity string, id int) ([]CustomFieldValue, error) {
+	fields, err := s.GetRecordCustomFields(entity, id)
+	if err != nil {
+		return nil, err
+	}
+	values, err := s.GetCustomValues(entity, id)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]CustomFieldValue, len(fields))
+	for i, f := range fields {
+		result[i] = CustomFieldValue{Field: f, Value: f.Display(values[f.ID])}
+	}
+	return result, nil
+}
+
+// customColumns lit les valeurs affichées des champs d'une entité du site sélectionné,
+// regroupées par libellé. reportsOnly limite aux champs affichés dans les rapports.
+func (s *Store) customColumns(entity string, reportsOnly bool) (CustomColumns, error) {
+	condition := ` WHERE f.entity = ?`
+	if reportsOnly {
+		condition += ` AND f.in_reports = 1`
+	}
+	site, siteArgs := s.siteCondition("f.site_id")
+	args := append([]interface{}{entity}, siteArgs...)
+
+	fields, err := queryCustomFields(s.db(), customFieldSelect+condition+site+` ORDER BY f.id`, args...)
+	if err != nil {
+		return CustomColumns{}, err
+	}
+
+	columns := CustomColumns{Values: make(map[int]map[string]string)}
+	byID := make(map[int]CustomField, len(fields))
+	seen := make(map[string]bool)
+	for _, f := range fields {
+		byID[f.ID] = f
+		if !seen[f.Label] {
+			seen[f.Label] = true
+			columns.Labels = append(columns.Labels, f.Label)
+		}
+	}
+	if reportsOnly && len(columns.Labels) > MaxReportCustomFields {
+		columns.Labels = columns.Labels[:MaxReportCustomFields]
+	}
+
+	rows, err := s.db().Query(`SELECT v.field_id, v.entity_id, v.value FROM custom_field_values v
+		INNER JOIN custom_fields f ON f.id = v.field_id`+condition+site, args...)
+	if err != nil {
+		return CustomColumns{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var fieldID, recordID int
+		var value string
+		if err := rows.Scan(&fieldID, &recordID, &value); err != nil {
+			return CustomColumns{}, err
+		}
+		f := byID[fieldID]
+		if columns.Values[recordID] == nil {
+			columns.Values[recordID] = make(map[string]string)
+		}
+		columns.Values[recordID][f.Label] = f.Display(value)
+	}
+	return columns, rows.Err()
+}
+
+// GetReportColumns récupère les champs personnalisés d'une entité à afficher en colonnes
+// dans les rapports (au plus MaxReportCustomFields) et leurs valeurs
+func (s *Store) GetReportColumns(entity string) (CustomColumns, error) {
+	return s.customColumns(entity, true)
+}
+
+// customFieldRecordTitles est la requête des enregistrements en service de chaque entité
+// pour l'export, avec la colonne du site
+var customFieldRecordTitles = map[string]struct{ query, siteColumn string }{
+	AuditEntityKey: {`SELECT k.id, k.number FROM keys k WHERE k.archived_at IS NULL`, "k.site_id"},
+	AuditEntityRoom: {`SELECT r.id, b.name || ' > ' || r.name FROM rooms r
+		INNER JOIN buildings b ON b.id = r.building_id WHERE r.archived_at IS NULL`, "b.site_id"},
+	AuditEntityBorrower: {`SELECT bo.id, bo.name FROM borrowers bo WHERE bo.archived_at IS NULL`, "bo.site_id"},
+}
+
+// GetCustomFieldExport récupère tous les champs personnalisés d'une entité du site sélectionné
+// et les valeurs de ses enregistrements en service, pour l'export
+func (s *Store) GetCustomFieldExport(entity string) (*CustomFieldExport, error) {
+	titles, ok := customFieldRecordTitles[entity]
+	if !ok {
+		return nil, fmt.Errorf("pas de champs personnalisés pour %s", entity)
+	}
+	columns, err := s.customColumns(entity, false)
+	if err != nil {
+		return nil, err
+	}
+	export := &CustomFieldExport{Entity: entity, Columns: columns}
+
+	site, args := s.siteCondition(titles.siteColumn)
+	rows, err := s.db().Query(titles.query+site+` ORDER BY 2`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r CustomFieldRecord
+		if err := rows.Scan(&r.ID, &r.Title); err != nil {
+			return nil, err
+		}
+		export.Records = append(export.Records, r)
+	}
+	return export, rows.Err()
+}
+
+// saveCustomValues enregistre dans tx les valeurs des champs personnalisés d'un enregistrement
+// et journalise les changements (libellé -> valeur). Seuls les champs présents dans values
+// sont modifiés (une valeur vide efface le champ) : les autres gardent leur valeur. Les valeurs
+// sont vérifiées selon le type de leur champ, qui doit appartenir au site de l'enregistrement.
+func (s *Store) saveCustomValues(tx *sql.Tx, entity string, id int, title string, values CustomValues) error {
+	if values == nil {
+		return nil
+	}
+
+	var siteID int
+	if err := tx.QueryRow(`SELECT site_id FROM record_sites WHERE entity = ? AND id = ?`, entity, id).Scan(&siteID); err != nil {
+		return err
+	}
+	fields, err := queryCustomFields(tx, customFieldSelect+` WHERE f.entity = ? AND f.site_id = ?`, entity, siteID)
+	if err != nil {
+		return err
+	}
+	byID := make(map[int]CustomField, len(fields))
+	for _, f := range fields {
+		byID[f.ID] = f
+	}
+
+	normalized := make(CustomValues, len(values))
+	for fieldID, value := range values {
+		f, ok := byID[fieldID]
+		if !ok {
+			return fmt.Errorf("le champ personnalisé %d n'existe pas pour ce site", fieldID)
+		}
+		if normalized[fieldID], err = f.Normalize(value); err != nil {
+			return err
+		}
+	}
+
+	before, err := customValuesSnapshot(tx, entity, id)
+	if err != nil {
+		return err
+	}
+	for fieldID, value := range normalized {
+		_, err := tx.Exec(`DELETE FROM custom_field_values WHERE field_id = ? AND entity = ? AND entity_id = ?`,
+			fieldID, entity, id)
+		if err != nil {
+			return err
+		}
+		if value == "" {
+			continue
+		}
+		_, err = tx.Exec(`INSERT INTO custom_field_values (field_id, entity, entity_id, value) VALUES (?, ?, ?, ?)`,
+			fieldID, entity, id, value)
+		if err != nil {
+			return err
+		}
+	}
+	after, err := customValuesSnapshot(tx, entity, id)
+	if err != nil {
+		return err
+	}
+
+	if fmt.Sprint(before) == fmt.Sprint(after) {
+		return nil
+	}
+	return s.audit(tx, AuditUpdate, entity, id, title+" : champs personnalisés modifiés", before, after)
+}
+
+// customValuesSnapshot lit les valeurs personnalisées d'un enregistrement par libellé, pour le journal d'audit
+func customValuesSnapshot(tx *sql.Tx, entity string, id int) (map[string]interface{}, error) {
+	rows, err := tx.Query(`SELECT f.label, v.value FROM custom_field_values v
+		INNER JOIN custom_fields f ON f.id = v.field_id
+		WHERE v.entity = ? AND v.entity_id = ?`, entity, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snapshot := make(map[string]interface{})
+	for rows.Next() {
+		var label, value string
+		if err := rows.Scan(&label, &value); err != nil {
+			return nil, err
+		}
+		snapshot[label] = value
+	}
+	return snapshot, rows.Err()
+}
+
+// purgeCustomValues supprime dans tx les valeurs personnalisées d'un enregistrement supprimé définitivement
+func purgeCustomValues(tx *sql.Tx, entity string, id int) error {
+	_, err := tx.Exec(`DELETE FROM custom_field_values WHERE entity = ? AND entity_id = ?`, entity, id)
+	return err
+}
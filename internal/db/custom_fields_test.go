@@ -0,0 +1,49 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSaveCustomValuesKeepsOmittedFields(t *testing.T) {
+	s := newTestStore(t)
+	badge := &CustomField{Entity: AuditEntityBorrower, Label: "Badge", Type: CustomFieldText}
+	if err := s.CreateCustomField(badge); err != nil {
+		t.Fatal(err)
+	}
+	office := &CustomField{Entity: AuditEntityBorrower, Label: "Bureau", Type: CustomFieldText}
+	if err := s.CreateCustomField(office); err != nil {
+		t.Fatal(err)
+	}
+
+	b := &Borrower{FirstName: "Alice", LastName: "Martin", CustomValues: CustomValues{badge.ID: "B-12", office.ID: "204"}}
+	if err := s.CreateBorrower(b); err != nil {
+		t.Fatal(err)
+	}
+
+	// Seul le bureau change : le badge est conservé
+	b.CustomValues = CustomValues{office.ID: "310"}
+	if err := s.UpdateBorrower(b); err != nil {
+		t.Fatal(err)
+	}
+	values, err := s.GetCustomValues(AuditEntityBorrower, b.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (CustomValues{badge.ID: "B-12", office.ID: "310"}); !reflect.DeepEqual(values, want) {
+		t.Errorf("GetCustomValues() = %v, %v attendu", values, want)
+	}
+
+	// Une valeur vide efface le champ
+	b.CustomValues = CustomValues{badge.ID: ""}
+	if err := s.UpdateBorrower(b); err != nil {
+		t.Fatal(err)
+	}
+	values, err = s.GetCustomValues(AuditEntityBorrower, b.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (CustomValues{office.ID: "310"}); !reflect.DeepEqual(values, want) {
+		t.Errorf("GetCustomValues() = %v, %v attendu", values, want)
+	}
+}
@@ -2,100 +2,149 @@ package db
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
 
-	_ "modernc.org/sqlite"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
 )
 
-var DB *sql.DB
+// ErrDatabaseBusy est retournée lorsqu'un autre poste garde la base verrouillée
+// au-delà du délai d'attente
+var ErrDatabaseBusy = errors.New("base de données occupée par un autre poste")
+
+// Store possède la connexion à une base de données et regroupe toutes les requêtes.
+// La connexion peut être remplacée à chaud (restauration, réinitialisation) sans
+// que les appelants aient à la rouvrir.
+type Store struct {
+	mu       sync.RWMutex
+	conn     *sql.DB
+	path     string
+	operator string // Nom enregistré dans le journal d'audit
+	site     int    // Site auquel les listes sont restreintes (0 : tous les sites)
+}
+
+// Open ouvre (ou crée) la base de données SQLite et applique les migrations
+func Open(dbPath string) (*Store, error) {
+	conn, err := openConn(dbPath)
+	if err != nil {
+		return nil, err
+	}
 
-// InitDB initialise la connexion à la base de données SQLite
-func InitDB(dbPath string) error {
-	var err error
-	DB, err = sql.Open("sqlite", dbPath)
+	log.Println("Base de données initialisée avec succès")
+	return &Store{conn: conn, path: dbPath, operator: defaultOperator()}, nil
+}
+
+// OpenMemory ouvre une base SQLite en mémoire, utile pour les tests et les outils
+// qui ne doivent pas toucher au fichier clefs.db
+func OpenMemory() (*Store, error) {
+	conn, err := sql.Open("sqlite", "file::memory:?_txlock=immediate")
 	if err != nil {
-		return fmt.Errorf("erreur lors de l'ouverture de la base de données: %w", err)
+		return nil, fmt.Errorf("erreur lors de l'ouverture de la base de données: %w", err)
+	}
+
+	// Chaque connexion à :memory: est une base distincte : n'en garder qu'une
+	conn.SetMaxOpenConns(1)
+
+	if err := migrate(conn, ""); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("erreur lors de la migration du schéma: %w", err)
+	}
+
+	return &Store{conn: conn, operator: defaultOperator()}, nil
+}
+
+// busyTimeout est le délai pendant lequel un poste attend qu'un autre libère la base
+// avant d'abandonner ; il est lu à chaque ouverture (les tests le raccourcissent)
+var busyTimeout = 5 * time.Second
+
+// connDSN construit la chaîne de connexion d'un fichier de base. Les transactions
+// prennent le verrou d'écriture dès BEGIN (BEGIN IMMEDIATE) : deux postes ne peuvent
+// pas vérifier la disponibilité d'une clé en même temps, le second attend que le
+// premier ait enregistré son emprunt. Le journal WAL n'est activé que sur un disque
+// local : il repose sur une mémoire partagée que les partages réseau ne garantissent pas.
+func connDSN(dbPath string) string {
+	params := url.Values{}
+	params.Set("_txlock", "immediate")
+	params.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", busyTimeout.Milliseconds()))
+	if isNetworkPath(dbPath) {
+		params.Add("_pragma", "journal_mode(DELETE)")
+	} else {
+		params.Add("_pragma", "journal_mode(WAL)")
+	}
+	return dbPath + "?" + params.Encode()
+}
+
+// isNetworkPath indique si un chemin désigne un partage réseau (chemin UNC ou lecteur réseau)
+func isNetworkPath(path string) bool {
+	if strings.HasPrefix(path, `\\`) || strings.HasPrefix(path, "//") {
+		return true
+	}
+	return isNetworkDrive(path)
+}
+
+// openConn ouvre une connexion vers un fichier et met son schéma à jour
+func openConn(dbPath string) (*sql.DB, error) {
+	if isNetworkPath(dbPath) {
+		log.Println("Base de données sur un partage réseau : journal WAL désactivé")
+	}
+
+	conn, err := sql.Open("sqlite", connDSN(dbPath))
+	if err != nil {
+		return nil, fmt.Errorf("erreur lors de l'ouverture de la base de données: %w", err)
 	}
 
 	// Tester la connexion
-	if err = DB.Ping(); err != nil {
-		return fmt.Errorf("erreur lors du ping de la base de données: %w", err)
+	if err = conn.Ping(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("erreur lors du ping de la base de données: %w", err)
 	}
 
-	// Créer les tables si elles n'existent pas
-	if err = createTables(); err != nil {
-		return fmt.Errorf("erreur lors de la création des tables: %w", err)
+	// Mettre le schéma à jour
+	if err = migrate(conn, dbPath); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("erreur lors de la migration du schéma: %w", err)
 	}
 
-	log.Println("Base de données initialisée avec succès")
-	return nil
+	return conn, nil
 }
 
-// createTables crée toutes les tables nécessaires
-func createTables() error {
-	schema := `
-	CREATE TABLE IF NOT EXISTS buildings (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		name TEXT UNIQUE NOT NULL
-	);
-
-	CREATE TABLE IF NOT EXISTS rooms (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		name TEXT NOT NULL,
-		type TEXT,
-		building_id INTEGER,
-		FOREIGN KEY (building_id) REFERENCES buildings(id) ON DELETE CASCADE
-	);
-
-	CREATE TABLE IF NOT EXISTS keys (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		number TEXT UNIQUE NOT NULL,
-		description TEXT,
-		quantity_total INTEGER DEFAULT 1,
-		quantity_reserve INTEGER DEFAULT 0,
-		storage_location TEXT
-	);
-
-	CREATE TABLE IF NOT EXISTS borrowers (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		name TEXT NOT NULL,
-		email TEXT
-	);
-
-	CREATE TABLE IF NOT EXISTS loans (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		key_id INTEGER NOT NULL,
-		borrower_id INTEGER NOT NULL,
-		loan_date DATETIME DEFAULT CURRENT_TIMESTAMP,
-		return_date DATETIME,
-		FOREIGN KEY (key_id) REFERENCES keys(id) ON DELETE CASCADE,
-		FOREIGN KEY (borrower_id) REFERENCES borrowers(id) ON DELETE CASCADE
-	);
-
-	CREATE TABLE IF NOT EXISTS key_room_association (
-		key_id INTEGER NOT NULL,
-		room_id INTEGER NOT NULL,
-		PRIMARY KEY (key_id, room_id),
-		FOREIGN KEY (key_id) REFERENCES keys(id) ON DELETE CASCADE,
-		FOREIGN KEY (room_id) REFERENCES rooms(id) ON DELETE CASCADE
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_keys_number ON keys(number);
-	CREATE INDEX IF NOT EXISTS idx_borrowers_name ON borrowers(name);
-	CREATE INDEX IF NOT EXISTS idx_loans_key_id ON loans(key_id);
-	CREATE INDEX IF NOT EXISTS idx_loans_borrower_id ON loans(borrower_id);
-	CREATE INDEX IF NOT EXISTS idx_loans_return_date ON loans(return_date);
-	`
-
-	_, err := DB.Exec(schema)
-	return err
+// db retourne la connexion courante
+func (s *Store) db() *sql.DB {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.conn
 }
 
-// CloseDB ferme la connexion à la base de données
-func CloseDB() error {
-	if DB != nil {
-		return DB.Close()
+// beginWrite ouvre une transaction d'écriture. Les transactions prennent le verrou dès
+// BEGIN : si un autre poste le garde au-delà de busyTimeout, l'erreur devient ErrDatabaseBusy.
+func (s *Store) beginWrite() (*sql.Tx, error) {
+	tx, err := s.db().Begin()
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code()&0xff == sqlite3.SQLITE_BUSY {
+		return nil, fmt.Errorf("%w, réessayez dans quelques instants", ErrDatabaseBusy)
 	}
-	return nil
+	return tx, err
+}
+
+// Path retourne le chemin du fichier de base de données (vide pour une base en mémoire)
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Close ferme la connexion à la base de données
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
 }
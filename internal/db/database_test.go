@@ -0,0 +1,56 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriteOnLockedDatabaseReturnsErrDatabaseBusy(t *testing.T) {
+	// Chaque écriture attend l'expiration du délai : inutile d'attendre 5 s
+	defaultTimeout := busyTimeout
+	busyTimeout = 50 * time.Millisecond
+	t.Cleanup(func() { busyTimeout = defaultTimeout })
+
+	path := filepath.Join(t.TempDir(), "clefs.db")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.SetSite(1)
+	key := createTestKey(t, s, "K001", 1)
+	borrower := createTestBorrower(t, s, "Alice", "Martin")
+	if err := s.CreateLoan(key.ID, borrower.ID); err != nil {
+		t.Fatal(err)
+	}
+	loans, err := s.GetActiveLoansByBorrowerID(borrower.ID)
+	if err != nil || len(loans) != 1 {
+		t.Fatalf("GetActiveLoansByBorrowerID() = %d, %v", len(loans), err)
+	}
+
+	// Un autre poste garde le verrou d'écriture
+	other, err := sql.Open("sqlite", connDSN(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	lock, err := other.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Rollback()
+
+	if err := s.ReturnLoan(loans[0].ID, nil); !errors.Is(err, ErrDatabaseBusy) {
+		t.Errorf("ReturnLoan() = %v, ErrDatabaseBusy attendu", err)
+	}
+	if err := s.SettleDeposit(loans[0].ID, DepositRefunded, ""); !errors.Is(err, ErrDatabaseBusy) {
+		t.Errorf("SettleDeposit() = %v, ErrDatabaseBusy attendu", err)
+	}
+	if err := s.DeclareLoanLost(loans[0].ID, CopyLost, ""); !errors.Is(err, ErrDatabaseBusy) {
+		t.Errorf("DeclareLoanLost() = %v, ErrDatabaseBusy attendu", err)
+	}
+}
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// settleDepositTx restitue ou retient dans tx la caution détenue d'un emprunt
+func settleDepositTx(tx *sql.Tx, loanID int, settlement DepositStatus, notes string) error {
+	result, err := tx.Exec(`UPDATE loans SET deposit_status = ?, deposit_notes = ? WHERE id = ? AND deposit_status = ?`,
+		settlement, strings.TrimSpace(notes), loanID, DepositHeld)
+	if err != nil {
+		return err
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("cet emprunt n'a pas de caution en attente de règlement")
+	}
+	return nil
+}
+
+// SettleDeposit restitue ou retient la caution d'un emprunt déjà clos
+// (ex: clé déclarée perdue), sans passer par le retour de la clé
+func (s *Store) SettleDeposit(loanID int, settlement DepositStatus, notes string) error {
+	if settlement != DepositRefunded && settlement != DepositRetained {
+		return fmt.Errorf("la caution doit être restituée ou retenue")
+	}
+
+	tx, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := snapshotRow(tx, AuditEntityLoan, loanID)
+	if err != nil {
+		return err
+	}
+	if err := settleDepositTx(tx, loanID, settlement, notes); err != nil {
+		return err
+	}
+	after, err := snapshotRow(tx, AuditEntityLoan, loanID)
+	if err != nil {
+		return err
+	}
+	summary, err := loanAuditSummary(tx, "Caution "+strings.ToLower(settlement.Label())+" pour l'emprunt", loanID)
+	if err != nil {
+		return err
+	}
+	if err := s.audit(tx, AuditUpdate, AuditEntityLoan, loanID, summary, before, after); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetHeldDepositsByBorrower regroupe par emprunteur les cautions encore détenues pour
+// les clés du site sélectionné, avec leur total
+func (s *Store) GetHeldDepositsByBorrower() ([]BorrowerDeposits, error) {
+	site, siteArgs := s.siteCondition("k.site_id")
+	loans, err := s.queryLoansWithDetails(loanDetailsSelect+`
+		WHERE l.deposit_status = ? AND l.deposit_amount > 0`+site+`
+		ORDER BY b.name, l.borrower_id, l.loan_date`, append([]interface{}{DepositHeld}, siteArgs...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	var deposits []BorrowerDeposits
+	for _, l := range loans {
+		if len(deposits) == 0 || deposits[len(deposits)-1].BorrowerID != l.BorrowerID {
+			deposits = append(deposits, BorrowerDeposits{BorrowerID: l.BorrowerID, BorrowerName: l.BorrowerName})
+		}
+		d := &deposits[len(deposits)-1]
+		d.Loans = append(d.Loans, l)
+		d.Total += l.DepositAmount
+	}
+	return deposits, nil
+}
@@ -0,0 +1,130 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// DeclareLoanLost clôt un emprunt en cours par une déclaration de perte ou de vol :
+// l'exemplaire remis sort du stock et la déclaration est inscrite au journal d'audit.
+func (s *Store) DeclareLoanLost(loanID int, status CopyStatus, notes string) error {
+	if status != CopyLost && status != CopyStolen {
+		return fmt.Errorf("une déclaration de perte doit indiquer une clé perdue ou volée")
+	}
+
+	tx, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var keyID int
+	var copyID sql.NullInt64
+	var returnDate sql.NullTime
+	err = tx.QueryRow(`SELECT key_id, copy_id, return_date FROM loans WHERE id = ?`, loanID).Scan(&keyID, &copyID, &returnDate)
+	if err != nil {
+		return err
+	}
+	if returnDate.Valid {
+		return fmt.Errorf("cet emprunt est déjà clos")
+	}
+
+	before, err := snapshotRow(tx, AuditEntityLoan, loanID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE loans SET return_date = ?, returned_by = ?, loss_status = ?, loss_notes = ? WHERE id = ?`,
+		time.Now(), s.Operator(), status, notes, loanID)
+	if err != nil {
+		return err
+	}
+
+	// L'exemplaire remis ne compte plus dans le stock et libère son crochet
+	if copyID.Valid {
+		_, err = tx.Exec(`UPDATE key_copies SET status = ?, notes = ?, hook_number = NULL WHERE id = ?`, status, notes, copyID.Int64)
+		if err != nil {
+			return err
+		}
+		reason := notes
+		if reason == "" && status == CopyStolen {
+			reason = "Vol déclaré sur un emprunt"
+		} else if reason == "" {
+			reason = "Perte déclarée sur un emprunt"
+		}
+		id := int(copyID.Int64)
+		if err := s.addStockMovement(tx, keyID, &id, MovementLoss, 1, reason); err != nil {
+			return err
+		}
+		if err := syncKeyQuantities(tx, keyID); err != nil {
+			return err
+		}
+	}
+
+	after, err := snapshotRow(tx, AuditEntityLoan, loanID)
+	if err != nil {
+		return err
+	}
+	verb := "Perte"
+	if status == CopyStolen {
+		verb = "Vol"
+	}
+	summary, err := loanAuditSummary(tx, verb, loanID)
+	if err != nil {
+		return err
+	}
+	if err := s.audit(tx, AuditLoss, AuditEntityLoan, loanID, summary, before, after); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetLossImpactReport liste les salles exposées par la perte de la clé d'un emprunt,
+// les autres clés qui ouvrent ces salles et les exemplaires de la même clé encore en service
+func (s *Store) GetLossImpactReport(loanID int) (*LossImpactReport, error) {
+	loan, err := s.GetLoanByID(loanID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Une clé perdue ouvre aussi les salles de ses clés subordonnées
+	rooms, err := s.GetRoomsForKey(loan.KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	buildingNames := make(map[int]string)
+	for i := range rooms {
+		name, ok := buildingNames[rooms[i].BuildingID]
+		if !ok {
+			building, err := s.GetBuildingByID(rooms[i].BuildingID)
+			if err != nil {
+				return nil, err
+			}
+			name = building.Name
+			buildingNames[rooms[i].BuildingID] = name
+		}
+		rooms[i].Building.ID = rooms[i].BuildingID
+		rooms[i].Building.Name = name
+
+		keys, err := s.GetKeysForRoom(rooms[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, k := range keys {
+			if k.ID != loan.KeyID {
+				rooms[i].Keys = append(rooms[i].Keys, k)
+			}
+		}
+	}
+
+	report := &LossImpactReport{Loan: *loan, Rooms: rooms}
+	err = s.db().QueryRow(`SELECT COUNT(*) FROM key_copies WHERE key_id = ? AND status NOT IN (?, ?, ?)`,
+		loan.KeyID, CopyLost, CopyStolen, CopyDestroyed).Scan(&report.OtherCopies)
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
@@ -0,0 +1,659 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrDatabaseTooNew est retournée lorsque la base a été créée par une version plus récente de l'application
+var ErrDatabaseTooNew = errors.New("la base de données a été créée par une version plus récente de l'application")
+
+// migration décrit une étape de mise à jour du schéma.
+// Une étape est soit une requête SQL (sql), soit une fonction Go (up) pour les
+// transformations de données qui ne s'expriment pas simplement en SQL.
+type migration struct {
+	version int
+	name    string
+	sql     string
+	up      func(tx *sql.Tx) error
+}
+
+// migrations liste toutes les étapes dans l'ordre d'application.
+// Une étape déjà livrée ne doit jamais être modifiée : ajouter une nouvelle entrée à la fin.
+var migrations = []migration{
+	{version: 1, name: "schéma initial", sql: schemaV1},
+	{version: 2, name: "date de retour prévue", sql: `
+		ALTER TABLE keys ADD COLUMN default_loan_days INTEGER NOT NULL DEFAULT 0;
+		ALTER TABLE loans ADD COLUMN due_date DATETIME;
+		CREATE INDEX IF NOT EXISTS idx_loans_due_date ON loans(due_date);
+	`},
+	{version: 3, name: "exemplaires de clés", sql: `
+		CREATE TABLE key_copies (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			key_id INTEGER NOT NULL,
+			identifier TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'available',
+			notes TEXT,
+			UNIQUE (key_id, identifier),
+			FOREIGN KEY (key_id) REFERENCES keys(id) ON DELETE CASCADE
+		);
+		CREATE INDEX idx_key_copies_key_id ON key_copies(key_id);
+		ALTER TABLE loans ADD COLUMN copy_id INTEGER REFERENCES key_copies(id);
+		CREATE INDEX idx_loans_copy_id ON loans(copy_id);
+	`, up: generateMissingCopies},
+	{version: 4, name: "journal d'audit", sql: `
+		CREATE TABLE audit_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			created_at DATETIME NOT NULL,
+			operator TEXT NOT NULL,
+			action TEXT NOT NULL,
+			entity TEXT NOT NULL,
+			entity_id INTEGER,
+			summary TEXT NOT NULL,
+			before_json TEXT,
+			after_json TEXT
+		);
+		CREATE INDEX idx_audit_log_created_at ON audit_log(created_at);
+		CREATE INDEX idx_audit_log_entity ON audit_log(entity, entity_id);
+		CREATE TRIGGER audit_log_no_update BEFORE UPDATE ON audit_log
+		BEGIN
+			SELECT RAISE(ABORT, 'le journal d''audit ne peut pas être modifié');
+		END;
+		CREATE TRIGGER audit_log_no_delete BEFORE DELETE ON audit_log
+		BEGIN
+			SELECT RAISE(ABORT, 'le journal d''audit ne peut pas être modifié');
+		END;
+	`},
+	{version: 5, name: "comptes opérateurs", sql: `
+		CREATE TABLE operators (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			username TEXT UNIQUE NOT NULL,
+			display_name TEXT,
+			password_hash TEXT NOT NULL,
+			role TEXT NOT NULL,
+			active INTEGER NOT NULL DEFAULT 1,
+			created_at DATETIME NOT NULL
+		);
+		ALTER TABLE loans ADD COLUMN loaned_by TEXT;
+		ALTER TABLE loans ADD COLUMN returned_by TEXT;
+	`},
+	{version: 6, name: "fiches emprunteurs détaillées", sql: `
+		ALTER TABLE borrowers ADD COLUMN first_name TEXT;
+		ALTER TABLE borrowers ADD COLUMN last_name TEXT;
+		ALTER TABLE borrowers ADD COLUMN phone TEXT;
+		ALTER TABLE borrowers ADD COLUMN badge_number TEXT;
+		ALTER TABLE borrowers ADD COLUMN department TEXT;
+		ALTER TABLE borrowers ADD COLUMN status TEXT NOT NULL DEFAULT 'active';
+		ALTER TABLE borrowers ADD COLUMN start_date DATETIME;
+		ALTER TABLE borrowers ADD COLUMN end_date DATETIME;
+		ALTER TABLE borrowers ADD COLUMN notes TEXT;
+		CREATE INDEX idx_borrowers_badge_number ON borrowers(badge_number);
+	`, up: splitBorrowerNames},
+	{version: 7, name: "autorisations d'accès aux clés", sql: `
+		CREATE TABLE key_authorizations (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			key_id INTEGER NOT NULL,
+			borrower_id INTEGER,
+			department TEXT,
+			valid_from DATETIME,
+			valid_until DATETIME,
+			notes TEXT,
+			created_at DATETIME NOT NULL,
+			FOREIGN KEY (key_id) REFERENCES keys(id) ON DELETE CASCADE,
+			FOREIGN KEY (borrower_id) REFERENCES borrowers(id) ON DELETE CASCADE
+		);
+		CREATE INDEX idx_key_authorizations_key_id ON key_authorizations(key_id);
+		CREATE INDEX idx_key_authorizations_borrower_id ON key_authorizations(borrower_id);
+	`},
+	{version: 8, name: "hiérarchie des passe-partout", sql: `
+		ALTER TABLE keys ADD COLUMN parent_key_id INTEGER REFERENCES keys(id);
+		CREATE INDEX idx_keys_parent_key_id ON keys(parent_key_id);
+	`},
+	{version: 9, name: "déclaration de perte ou de vol", sql: `
+		ALTER TABLE loans ADD COLUMN loss_status TEXT;
+		ALTER TABLE loans ADD COLUMN loss_notes TEXT;
+	`},
+	{version: 10, name: "réservations de clés", sql: `
+		CREATE TABLE reservations (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			key_id INTEGER NOT NULL,
+			borrower_id INTEGER NOT NULL,
+			start_date DATETIME NOT NULL,
+			end_date DATETIME NOT NULL,
+			notes TEXT,
+			status TEXT NOT NULL DEFAULT 'pending',
+			loan_id INTEGER,
+			created_by TEXT,
+			created_at DATETIME NOT NULL,
+			FOREIGN KEY (key_id) REFERENCES keys(id) ON DELETE CASCADE,
+			FOREIGN KEY (borrower_id) REFERENCES borrowers(id) ON DELETE CASCADE,
+			FOREIGN KEY (loan_id) REFERENCES loans(id)
+		);
+		CREATE INDEX idx_reservations_key_id ON reservations(key_id, status);
+		CREATE INDEX idx_reservations_borrower_id ON reservations(borrower_id);
+	`},
+	{version: 11, name: "cautions des emprunts", sql: `
+		ALTER TABLE loans ADD COLUMN deposit_amount REAL;
+		ALTER TABLE loans ADD COLUMN deposit_method TEXT;
+		ALTER TABLE loans ADD COLUMN deposit_status TEXT;
+		ALTER TABLE loans ADD COLUMN deposit_notes TEXT;
+		CREATE INDEX idx_loans_deposit_status ON loans(deposit_status);
+	`},
+	{version: 12, name: "transferts d'emprunts", sql: `
+		ALTER TABLE loans ADD COLUMN transferred_from_loan_id INTEGER REFERENCES loans(id);
+		CREATE INDEX idx_loans_transferred_from ON loans(transferred_from_loan_id);
+	`},
+	{version: 13, name: "recherche globale", sql: schemaSearchIndex},
+	{version: 14, name: "archivage", sql: `
+		ALTER TABLE keys ADD COLUMN archived_at DATETIME;
+		ALTER TABLE borrowers ADD COLUMN archived_at DATETIME;
+		ALTER TABLE buildings ADD COLUMN archived_at DATETIME;
+		ALTER TABLE rooms ADD COLUMN archived_at DATETIME;
+		CREATE VIEW archived_records AS
+			SELECT 'keys' AS entity, id, number AS title, archived_at FROM keys WHERE archived_at IS NOT NULL
+			UNION ALL
+			SELECT 'borrowers', id, name, archived_at FROM borrowers WHERE archived_at IS NOT NULL
+			UNION ALL
+			SELECT 'buildings', id, name, archived_at FROM buildings WHERE archived_at IS NOT NULL
+			UNION ALL
+			SELECT 'rooms', id, name, archived_at FROM rooms WHERE archived_at IS NOT NULL;
+	`},
+	{version: 15, name: "sites", sql: schemaSites},
+	{version: 16, name: "emplacements et armoires à clés", sql: `
+		CREATE TABLE storage_locations (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			site_id INTEGER NOT NULL REFERENCES sites(id),
+			name TEXT NOT NULL,
+			hook_count INTEGER NOT NULL DEFAULT 0,
+			notes TEXT,
+			UNIQUE (site_id, name)
+		);
+		ALTER TABLE keys ADD COLUMN storage_location_id INTEGER REFERENCES storage_locations(id);
+		ALTER TABLE key_copies ADD COLUMN hook_number INTEGER;
+		CREATE INDEX idx_keys_storage_location_id ON keys(storage_location_id);
+	`, up: linkStorageLocations},
+	{version: 17, name: "registre des mouvements de stock", sql: `
+		CREATE TABLE stock_movements (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			key_id INTEGER NOT NULL REFERENCES keys(id),
+			copy_id INTEGER REFERENCES key_copies(id),
+			movement_type TEXT NOT NULL,
+			quantity INTEGER NOT NULL,
+			reason TEXT NOT NULL,
+			operator TEXT,
+			moved_at DATETIME NOT NULL
+		);
+		CREATE INDEX idx_stock_movements_key_id ON stock_movements(key_id);
+		CREATE INDEX idx_stock_movements_moved_at ON stock_movements(moved_at);
+	`, up: openStockLedger},
+	{version: 18, name: "étages et zones", sql: `
+		CREATE TABLE floors (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			building_id INTEGER NOT NULL REFERENCES buildings(id),
+			name TEXT NOT NULL,
+			level INTEGER NOT NULL DEFAULT 0,
+			UNIQUE (building_id, name)
+		);
+		CREATE TABLE zones (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			site_id INTEGER NOT NULL REFERENCES sites(id),
+			name TEXT NOT NULL,
+			description TEXT,
+			UNIQUE (site_id, name)
+		);
+		ALTER TABLE rooms ADD COLUMN floor_id INTEGER REFERENCES floors(id);
+		ALTER TABLE rooms ADD COLUMN zone_id INTEGER REFERENCES zones(id);
+		CREATE INDEX idx_rooms_floor_id ON rooms(floor_id);
+		CREATE INDEX idx_rooms_zone_id ON rooms(zone_id);
+	`},
+	{version: 19, name: "champs personnalisés", sql: schemaCustomFields},
+	{version: 20, name: "pièces jointes", sql: `
+		CREATE TABLE attachments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			entity TEXT NOT NULL,
+			entity_id INTEGER NOT NULL,
+			filename TEXT NOT NULL,
+			mime_type TEXT NOT NULL,
+			size INTEGER NOT NULL,
+			operator TEXT,
+			created_at DATETIME NOT NULL
+		);
+		CREATE INDEX idx_attachments_record ON attachments(entity, entity_id);
+		CREATE TABLE attachment_data (
+			attachment_id INTEGER PRIMARY KEY REFERENCES attachments(id),
+			data BLOB NOT NULL,
+			thumbnail BLOB
+		);
+	`},
+	{version: 21, name: "signatures des emprunts", sql: `
+		CREATE TABLE loan_signatures (
+			loan_id INTEGER NOT NULL REFERENCES loans(id),
+			kind TEXT NOT NULL,
+			image BLOB NOT NULL,
+			signed_at DATETIME NOT NULL,
+			PRIMARY KEY (loan_id, kind)
+		);
+	`},
+}
+
+// schemaCustomFields ajoute les champs personnalisés que chaque site définit pour ses clés,
+// salles et emprunteurs. Les valeurs sont indexées par la recherche globale sous l'entité
+// « custom:<table> », pour que les déclencheurs des tables de base ne les effacent pas.
+const schemaCustomFields = `
+	CREATE TABLE custom_fields (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		site_id INTEGER NOT NULL REFERENCES sites(id),
+		entity TEXT NOT NULL,
+		label TEXT NOT NULL,
+		field_type TEXT NOT NULL,
+		choices TEXT,
+		in_reports INTEGER NOT NULL DEFAULT 0,
+		UNIQUE (site_id, entity, label)
+	);
+	CREATE TABLE custom_field_values (
+		field_id INTEGER NOT NULL REFERENCES custom_fields(id),
+		entity TEXT NOT NULL,
+		entity_id INTEGER NOT NULL,
+		value TEXT NOT NULL,
+		PRIMARY KEY (field_id, entity_id)
+	);
+	CREATE INDEX idx_custom_field_values_record ON custom_field_values(entity, entity_id);
+
+	CREATE TRIGGER search_custom_insert AFTER INSERT ON custom_field_values BEGIN
+		DELETE FROM search_index WHERE entity = 'custom:' || new.entity AND entity_id = new.entity_id;
+		INSERT INTO search_index (entity, entity_id, title, body)
+		SELECT 'custom:' || new.entity, new.entity_id, '', body FROM (
+			SELECT group_concat(value, ' ') AS body FROM custom_field_values
+			WHERE entity = new.entity AND entity_id = new.entity_id
+		) WHERE body IS NOT NULL;
+	END;
+	CREATE TRIGGER search_custom_delete AFTER DELETE ON custom_field_values BEGIN
+		DELETE FROM search_index WHERE entity = 'custom:' || old.entity AND entity_id = old.entity_id;
+		INSERT INTO search_index (entity, entity_id, title, body)
+		SELECT 'custom:' || old.entity, old.entity_id, '', body FROM (
+			SELECT group_concat(value, ' ') AS body FROM custom_field_values
+			WHERE entity = old.entity AND entity_id = old.entity_id
+		) WHERE body IS NOT NULL;
+	END;
+`
+
+// schemaSites ajoute le niveau des sites au-dessus des bâtiments. Les données existantes
+// sont rattachées à un premier site. Les tables keys et buildings sont reconstruites pour
+// que l'unicité du numéro de clé et du nom de bâtiment s'applique à chaque site ; les
+// clés étrangères n'étant pas activées, les tables qui y font référence ne sont pas touchées.
+const schemaSites = `
+	CREATE TABLE sites (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT UNIQUE NOT NULL
+	);
+	INSERT INTO sites (name) VALUES ('Site principal');
+
+	DROP VIEW archived_records;
+
+	CREATE TABLE keys_by_site (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		number TEXT NOT NULL,
+		description TEXT,
+		quantity_total INTEGER DEFAULT 1,
+		quantity_reserve INTEGER DEFAULT 0,
+		storage_location TEXT,
+		default_loan_days INTEGER NOT NULL DEFAULT 0,
+		parent_key_id INTEGER REFERENCES keys(id),
+		archived_at DATETIME,
+		site_id INTEGER NOT NULL REFERENCES sites(id),
+		UNIQUE (site_id, number)
+	);
+	INSERT INTO keys_by_site (id, number, description, quantity_total, quantity_reserve, storage_location,
+		default_loan_days, parent_key_id, archived_at, site_id)
+		SELECT id, number, description, quantity_total, quantity_reserve, storage_location,
+		       default_loan_days, parent_key_id, archived_at, (SELECT MIN(id) FROM sites)
+		FROM keys;
+	DROP TABLE keys;
+	ALTER TABLE keys_by_site RENAME TO keys;
+	CREATE INDEX idx_keys_number ON keys(number);
+	CREATE INDEX idx_keys_parent_key_id ON keys(parent_key_id);
+	CREATE INDEX idx_keys_site_id ON keys(site_id);
+
+	CREATE TABLE buildings_by_site (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		archived_at DATETIME,
+		site_id INTEGER NOT NULL REFERENCES sites(id),
+		UNIQUE (site_id, name)
+	);
+	INSERT INTO buildings_by_site (id, name, archived_at, site_id)
+		SELECT id, name, archived_at, (SELECT MIN(id) FROM sites) FROM buildings;
+	DROP TABLE buildings;
+	ALTER TABLE buildings_by_site RENAME TO buildings;
+	CREATE INDEX idx_buildings_site_id ON buildings(site_id);
+
+	ALTER TABLE borrowers ADD COLUMN site_id INTEGER REFERENCES sites(id);
+	UPDATE borrowers SET site_id = (SELECT MIN(id) FROM sites);
+	CREATE INDEX idx_borrowers_site_id ON borrowers(site_id);
+
+	CREATE TRIGGER search_keys_insert AFTER INSERT ON keys BEGIN
+		INSERT INTO search_index (entity, entity_id, title, body)
+		VALUES ('keys', new.id, new.number, coalesce(new.description, '') || ' ' || coalesce(new.storage_location, ''));
+	END;
+	CREATE TRIGGER search_keys_update AFTER UPDATE OF number, description, storage_location ON keys BEGIN
+		DELETE FROM search_index WHERE entity = 'keys' AND entity_id = old.id;
+		INSERT INTO search_index (entity, entity_id, title, body)
+		VALUES ('keys', new.id, new.number, coalesce(new.description, '') || ' ' || coalesce(new.storage_location, ''));
+	END;
+	CREATE TRIGGER search_keys_delete AFTER DELETE ON keys BEGIN
+		DELETE FROM search_index WHERE entity = 'keys' AND entity_id = old.id;
+	END;
+	CREATE TRIGGER search_buildings_insert AFTER INSERT ON buildings BEGIN
+		INSERT INTO search_index (entity, entity_id, title, body) VALUES ('buildings', new.id, new.name, '');
+	END;
+	CREATE TRIGGER search_buildings_update AFTER UPDATE OF name ON buildings BEGIN
+		DELETE FROM search_index WHERE entity = 'buildings' AND entity_id = old.id;
+		INSERT INTO search_index (entity, entity_id, title, body) VALUES ('buildings', new.id, new.name, '');
+	END;
+	CREATE TRIGGER search_buildings_delete AFTER DELETE ON buildings BEGIN
+		DELETE FROM search_index WHERE entity = 'buildings' AND entity_id = old.id;
+	END;
+
+	CREATE VIEW record_sites AS
+		SELECT 'keys' AS entity, id, site_id FROM keys
+		UNION ALL
+		SELECT 'borrowers', id, site_id FROM borrowers
+		UNION ALL
+		SELECT 'buildings', id, site_id FROM buildings
+		UNION ALL
+		SELECT 'rooms', r.id, b.site_id FROM rooms r INNER JOIN buildings b ON b.id = r.building_id;
+
+	CREATE VIEW archived_records AS
+		SELECT 'keys' AS entity, id, number AS title, archived_at, site_id FROM keys WHERE archived_at IS NOT NULL
+		UNION ALL
+		SELECT 'borrowers', id, name, archived_at, site_id FROM borrowers WHERE archived_at IS NOT NULL
+		UNION ALL
+		SELECT 'buildings', id, name, archived_at, site_id FROM buildings WHERE archived_at IS NOT NULL
+		UNION ALL
+		SELECT 'rooms', r.id, r.name, r.archived_at, b.site_id FROM rooms r
+		LEFT JOIN buildings b ON b.id = r.building_id WHERE r.archived_at IS NOT NULL;
+`
+
+// schemaSearchIndex crée l'index plein texte de la recherche globale (clés, salles,
+// bâtiments, emprunteurs), rempli à partir des données existantes puis tenu à jour
+// par des déclencheurs. remove_diacritics permet à « batiment » de trouver « Bâtiment ».
+const schemaSearchIndex = `
+	CREATE VIRTUAL TABLE search_index USING fts5(
+		entity UNINDEXED,
+		entity_id UNINDEXED,
+		title,
+		body,
+		tokenize = 'unicode61 remove_diacritics 2'
+	);
+
+	INSERT INTO search_index (entity, entity_id, title, body)
+		SELECT 'keys', id, number, coalesce(description, '') || ' ' || coalesce(storage_location, '') FROM keys;
+	INSERT INTO search_index (entity, entity_id, title, body)
+		SELECT 'rooms', id, name, coalesce(type, '') FROM rooms;
+	INSERT INTO search_index (entity, entity_id, title, body)
+		SELECT 'buildings', id, name, '' FROM buildings;
+	INSERT INTO search_index (entity, entity_id, title, body)
+		SELECT 'borrowers', id, name, coalesce(email, '') FROM borrowers;
+
+	CREATE TRIGGER search_keys_insert AFTER INSERT ON keys BEGIN
+		INSERT INTO search_index (entity, entity_id, title, body)
+		VALUES ('keys', new.id, new.number, coalesce(new.description, '') || ' ' || coalesce(new.storage_location, ''));
+	END;
+	CREATE TRIGGER search_keys_update AFTER UPDATE OF number, description, storage_location ON keys BEGIN
+		DELETE FROM search_index WHERE entity = 'keys' AND entity_id = old.id;
+		INSERT INTO search_index (entity, entity_id, title, body)
+		VALUES ('keys', new.id, new.number, coalesce(new.description, '') || ' ' || coalesce(new.storage_location, ''));
+	END;
+	CREATE TRIGGER search_keys_delete AFTER DELETE ON keys BEGIN
+		DELETE FROM search_index WHERE entity = 'keys' AND entity_id = old.id;
+	END;
+
+	CREATE TRIGGER search_rooms_insert AFTER INSERT ON rooms BEGIN
+		INSERT INTO search_index (entity, entity_id, title, body) VALUES ('rooms', new.id, new.name, coalesce(new.type, ''));
+	END;
+	CREATE TRIGGER search_rooms_update AFTER UPDATE OF name, type ON rooms BEGIN
+		DELETE FROM search_index WHERE entity = 'rooms' AND entity_id = old.id;
+		INSERT INTO search_index (entity, entity_id, title, body) VALUES ('rooms', new.id, new.name, coalesce(new.type, ''));
+	END;
+	CREATE TRIGGER search_rooms_delete AFTER DELETE ON rooms BEGIN
+		DELETE FROM search_index WHERE entity = 'rooms' AND entity_id = old.id;
+	END;
+
+	CREATE TRIGGER search_buildings_insert AFTER INSERT ON buildings BEGIN
+		INSERT INTO search_index (entity, entity_id, title, body) VALUES ('buildings', new.id, new.name, '');
+	END;
+	CREATE TRIGGER search_buildings_update AFTER UPDATE OF name ON buildings BEGIN
+		DELETE FROM search_index WHERE entity = 'buildings' AND entity_id = old.id;
+		INSERT INTO search_index (entity, entity_id, title, body) VALUES ('buildings', new.id, new.name, '');
+	END;
+	CREATE TRIGGER search_buildings_delete AFTER DELETE ON buildings BEGIN
+		DELETE FROM search_index WHERE entity = 'buildings' AND entity_id = old.id;
+	END;
+
+	CREATE TRIGGER search_borrowers_insert AFTER INSERT ON borrowers BEGIN
+		INSERT INTO search_index (entity, entity_id, title, body) VALUES ('borrowers', new.id, new.name, coalesce(new.email, ''));
+	END;
+	CREATE TRIGGER search_borrowers_update AFTER UPDATE OF name, email ON borrowers BEGIN
+		DELETE FROM search_index WHERE entity = 'borrowers' AND entity_id = old.id;
+		INSERT INTO search_index (entity, entity_id, title, body) VALUES ('borrowers', new.id, new.name, coalesce(new.email, ''));
+	END;
+	CREATE TRIGGER search_borrowers_delete AFTER DELETE ON borrowers BEGIN
+		DELETE FROM search_index WHERE entity = 'borrowers' AND entity_id = old.id;
+	END;
+`
+
+// schemaV1 correspond au schéma historique (identique à la version Python).
+// Les CREATE ... IF NOT EXISTS permettent de l'appliquer sur les bases existantes
+// qui n'ont pas encore de table schema_migrations.
+const schemaV1 = `
+	CREATE TABLE IF NOT EXISTS buildings (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT UNIQUE NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS rooms (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		type TEXT,
+		building_id INTEGER,
+		FOREIGN KEY (building_id) REFERENCES buildings(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS keys (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		number TEXT UNIQUE NOT NULL,
+		description TEXT,
+		quantity_total INTEGER DEFAULT 1,
+		quantity_reserve INTEGER DEFAULT 0,
+		storage_location TEXT
+	);
+
+	CREATE TABLE IF NOT EXISTS borrowers (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		email TEXT
+	);
+
+	CREATE TABLE IF NOT EXISTS loans (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		key_id INTEGER NOT NULL,
+		borrower_id INTEGER NOT NULL,
+		loan_date DATETIME DEFAULT CURRENT_TIMESTAMP,
+		return_date DATETIME,
+		FOREIGN KEY (key_id) REFERENCES keys(id) ON DELETE CASCADE,
+		FOREIGN KEY (borrower_id) REFERENCES borrowers(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS key_room_association (
+		key_id INTEGER NOT NULL,
+		room_id INTEGER NOT NULL,
+		PRIMARY KEY (key_id, room_id),
+		FOREIGN KEY (key_id) REFERENCES keys(id) ON DELETE CASCADE,
+		FOREIGN KEY (room_id) REFERENCES rooms(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_keys_number ON keys(number);
+	CREATE INDEX IF NOT EXISTS idx_borrowers_name ON borrowers(name);
+	CREATE INDEX IF NOT EXISTS idx_loans_key_id ON loans(key_id);
+	CREATE INDEX IF NOT EXISTS idx_loans_borrower_id ON loans(borrower_id);
+	CREATE INDEX IF NOT EXISTS idx_loans_return_date ON loans(return_date);
+	`
+
+// LatestSchemaVersion retourne la version de schéma gérée par ce binaire
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// migrate applique les migrations manquantes sur la base ouverte.
+// Une sauvegarde est créée avant toute migration d'une base fichier contenant déjà des données.
+func migrate(conn *sql.DB, dbPath string) error {
+	_, err := conn.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at DATETIME NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("erreur lors de la création de la table schema_migrations: %w", err)
+	}
+
+	current, err := readSchemaVersion(conn)
+	if err != nil {
+		return err
+	}
+
+	latest := LatestSchemaVersion()
+	if current > latest {
+		return fmt.Errorf("%w (version %d, cette application gère jusqu'à la version %d)", ErrDatabaseTooNew, current, latest)
+	}
+	if current == latest {
+		return nil
+	}
+
+	// Sauvegarde de sécurité si la base contient déjà des tables
+	hasData, err := hasExistingTables(conn)
+	if err != nil {
+		return err
+	}
+	if hasData && dbPath != "" {
+		backupPath, err := backupBeforeMigration(dbPath, current)
+		if err != nil {
+			return fmt.Errorf("erreur lors de la sauvegarde avant migration: %w", err)
+		}
+		log.Printf("Sauvegarde avant migration: %s", backupPath)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := applyMigration(conn, m); err != nil {
+			return fmt.Errorf("erreur lors de la migration %d (%s): %w", m.version, m.name, err)
+		}
+		log.Printf("Migration %d appliquée: %s", m.version, m.name)
+	}
+
+	return nil
+}
+
+// applyMigration exécute une étape dans sa propre transaction
+func applyMigration(conn *sql.DB, m migration) error {
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if m.sql != "" {
+		if _, err := tx.Exec(m.sql); err != nil {
+			return err
+		}
+	}
+	if m.up != nil {
+		if err := m.up(tx); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+		m.version, m.name, time.Now())
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// readSchemaVersion retourne la dernière version appliquée (0 pour une base sans migration)
+func readSchemaVersion(conn *sql.DB) (int, error) {
+	var exists int
+	err := conn.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'`).Scan(&exists)
+	if err != nil {
+		return 0, fmt.Errorf("erreur lors de la lecture de la version du schéma: %w", err)
+	}
+	if exists == 0 {
+		return 0, nil
+	}
+
+	var version sql.NullInt64
+	err = conn.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("erreur lors de la lecture de la version du schéma: %w", err)
+	}
+	return int(version.Int64), nil
+}
+
+// hasExistingTables indique si la base contient des tables applicatives
+func hasExistingTables(conn *sql.DB) (bool, error) {
+	var count int
+	err := conn.QueryRow(`SELECT COUNT(*) FROM sqlite_master
+		WHERE type = 'table' AND name NOT IN ('schema_migrations', 'sqlite_sequence')`).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// backupBeforeMigration copie la base dans le dossier backups avant migration
+func backupBeforeMigration(dbPath string, fromVersion int) (string, error) {
+	if err := CreateBackupDirectory(dbPath); err != nil {
+		return "", err
+	}
+	filename := fmt.Sprintf("clefs_avant_migration_v%d_%s.db", fromVersion, time.Now().Format("20060102_150405"))
+	backupPath := filepath.Join(filepath.Dir(dbPath), "backups", filename)
+	if err := BackupDatabase(dbPath, backupPath); err != nil {
+		return "", err
+	}
+	return backupPath, nil
+}
+
+// GetFileSchemaVersion lit la version du schéma d'un fichier de base sans le modifier
+func GetFileSchemaVersion(path string) (int, error) {
+	if _, err := os.Stat(path); err != nil {
+		return 0, err
+	}
+
+	conn, err := sql.Open("sqlite", "file:"+filepath.ToSlash(path)+"?mode=ro")
+	if err != nil {
+		return 0, fmt.Errorf("erreur lors de l'ouverture de la base: %w", err)
+	}
+	defer conn.Close()
+
+	return readSchemaVersion(conn)
+}
+
+// checkSchemaCompatible refuse un fichier de base plus récent que ce binaire
+func checkSchemaCompatible(path string) error {
+	version, err := GetFileSchemaVersion(path)
+	if err != nil {
+		return err
+	}
+	if version > LatestSchemaVersion() {
+		return fmt.Errorf("%w (version %d, cette application gère jusqu'à la version %d)", ErrDatabaseTooNew, version, LatestSchemaVersion())
+	}
+	return nil
+}
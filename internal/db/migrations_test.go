@@ -0,0 +1,118 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// createBaselineDatabase crée un fichier au schéma de la première version de l'application,
+// sans table schema_migrations, avec un emprunt en cours et un emprunt rendu
+func createBaselineDatabase(t *testing.T, path string) {
+	t.Helper()
+	conn, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	statements := []string{
+		schemaV1,
+		`INSERT INTO buildings (id, name) VALUES (1, 'Bâtiment A')`,
+		`INSERT INTO rooms (id, name, type, building_id) VALUES (1, 'Salle 101', 'Bureau', 1)`,
+		`INSERT INTO keys (id, number, description, quantity_total, quantity_reserve, storage_location)
+			VALUES (1, 'K001', 'Porte principale', 2, 0, 'Accueil')`,
+		`INSERT INTO borrowers (id, name, email) VALUES (1, 'Alice Martin', 'alice@example.org')`,
+		`INSERT INTO key_room_association (key_id, room_id) VALUES (1, 1)`,
+		`INSERT INTO loans (key_id, borrower_id, loan_date, return_date) VALUES (1, 1, '2024-01-10 09:00:00', '2024-01-12 17:00:00')`,
+		`INSERT INTO loans (key_id, borrower_id, loan_date) VALUES (1, 1, '2024-02-01 09:00:00')`,
+	}
+	for _, statement := range statements {
+		if _, err := conn.Exec(statement); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMigrateBaselineDatabase(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "clefs.db")
+	createBaselineDatabase(t, path)
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.SetSite(1)
+
+	version, err := readSchemaVersion(s.db())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != LatestSchemaVersion() {
+		t.Errorf("version du schéma = %d, %d attendue", version, LatestSchemaVersion())
+	}
+
+	backups, err := filepath.Glob(filepath.Join(dir, "backups", "clefs_avant_migration_v0_*.db"))
+	if err != nil || len(backups) != 1 {
+		t.Errorf("sauvegarde avant migration = %v, %v ; une attendue", backups, err)
+	}
+
+	// Les données existantes sont conservées et complétées (exemplaires, site par défaut)
+	keys, err := s.GetAllKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0].Number != "K001" || keys[0].QuantityTotal != 2 {
+		t.Fatalf("GetAllKeys() = %+v, la clé K001 en 2 exemplaires attendue", keys)
+	}
+	copies, err := s.GetCopiesForKey(keys[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(copies) != 2 {
+		t.Fatalf("%d exemplaires créés, 2 attendus", len(copies))
+	}
+
+	loans, err := s.GetAllActiveLoans()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loans) != 1 || loans[0].CopyID == nil || loans[0].BorrowerName != "Alice Martin" {
+		t.Fatalf("GetAllActiveLoans() = %+v, l'emprunt en cours d'Alice Martin avec son exemplaire attendu", loans)
+	}
+	history, err := s.GetLoanHistory(LoanHistoryFilter{KeyID: keys[0].ID})
+	if err != nil || len(history) != 2 {
+		t.Errorf("GetLoanHistory() = %d emprunts, %v ; 2 attendus", len(history), err)
+	}
+
+	// Une seconde ouverture n'a plus rien à migrer
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("réouverture après migration: %v", err)
+	}
+	reopened.Close()
+}
+
+func TestOpenRejectsNewerSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "clefs.db")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.db().Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, 'future', CURRENT_TIMESTAMP)`,
+		LatestSchemaVersion()+1)
+	s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Open(path); !errors.Is(err, ErrDatabaseTooNew) {
+		t.Errorf("Open() = %v, ErrDatabaseTooNew attendue", err)
+	}
+}
@@ -1,18 +1,70 @@
 package db
 
 import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
 // Key représente une clé dans le système
 type Key struct {
-	ID              int       `db:"id"`
-	Number          string    `db:"number"`
-	Description     string    `db:"description"`
-	QuantityTotal   int       `db:"quantity_total"`
-	QuantityReserve int       `db:"quantity_reserve"`
-	StorageLocation string    `db:"storage_location"`
-	Rooms           []Room    // Relation many-to-many
+	ID                int        `db:"id"`
+	Number            string     `db:"number"`
+	Description       string     `db:"description"`
+	QuantityTotal     int        `db:"quantity_total"`
+	QuantityReserve   int        `db:"quantity_reserve"`
+	StorageLocation   string     `db:"storage_location"`    // Nom de l'emplacement (copie de storage_locations.name)
+	StorageLocationID *int       `db:"storage_location_id"` // Emplacement de rangement (nil = aucun)
+	DefaultLoanDays   int        `db:"default_loan_days"`   // 0 = sans date de retour prévue
+	ParentKeyID       *int       `db:"parent_key_id"`       // Passe-partout qui ouvre aussi les portes de cette clé
+	ParentNumber      string     // Numéro du passe-partout parent
+	ArchivedAt        *time.Time `db:"archived_at"` // Date d'archivage (nil = clé en service)
+	Rooms             []Room     // Relation many-to-many
+	// CustomValues contient les champs personnalisés à enregistrer avec la clé (nil = inchangés)
+	CustomValues CustomValues
+	// Access et AccessVia ne sont renseignés que par GetKeysForRoom
+	Access    AccessKind // Accès direct ou hérité d'une clé subordonnée
+	AccessVia string     // Clé qui ouvre directement la salle (accès hérité)
+}
+
+// KeyTreeEntry est une clé placée dans la hiérarchie des passe-partout
+type KeyTreeEntry struct {
+	Key
+	Depth    int // 0 pour une clé sans passe-partout
+	Children int // Nombre de clés directement subordonnées
+}
+
+// KeyTree ordonne les clés selon la hiérarchie des passe-partout : chaque clé est
+// suivie de ses clés subordonnées, dans l'ordre de la liste reçue
+func KeyTree(keys []Key) []KeyTreeEntry {
+	children := make(map[int][]Key)
+	known := make(map[int]bool)
+	for _, k := range keys {
+		known[k.ID] = true
+	}
+	var roots []Key
+	for _, k := range keys {
+		if k.ParentKeyID != nil && known[*k.ParentKeyID] {
+			children[*k.ParentKeyID] = append(children[*k.ParentKeyID], k)
+		} else {
+			roots = append(roots, k)
+		}
+	}
+
+	var tree []KeyTreeEntry
+	var walk func(k Key, depth int)
+	walk = func(k Key, depth int) {
+		tree = append(tree, KeyTreeEntry{Key: k, Depth: depth, Children: len(children[k.ID])})
+		for _, child := range children[k.ID] {
+			walk(child, depth+1)
+		}
+	}
+	for _, k := range roots {
+		walk(k, 0)
+	}
+	return tree
 }
 
 // Room représente une salle/pièce
@@ -21,16 +73,326 @@ type Room struct {
 	Name       string   `db:"name"`
 	Type       string   `db:"type"`
 	BuildingID int      `db:"building_id"`
+	FloorID    *int     `db:"floor_id"` // Étage facultatif, du même bâtiment
+	ZoneID     *int     `db:"zone_id"`  // Zone facultative (aile, zone de sécurité)
+	Floor      string   // Nom de l'étage (vide = sans étage)
+	FloorLevel int      // Niveau de l'étage, pour le tri
+	Zone       string   // Nom de la zone (vide = sans zone)
 	Building   Building // Relation
 	Keys       []Key    // Relation many-to-many
+	// CustomValues contient les champs personnalisés à enregistrer avec la salle (nil = inchangés)
+	CustomValues CustomValues
+	// Access et AccessVia ne sont renseignés que par GetRoomsForKey
+	Access    AccessKind // Accès direct ou hérité d'une clé subordonnée
+	AccessVia string     // Clé qui ouvre directement la salle (accès hérité)
+}
+
+// Floor représente un étage d'un bâtiment
+type Floor struct {
+	ID         int    `db:"id"`
+	BuildingID int    `db:"building_id"`
+	Name       string `db:"name"`
+	Level      int    `db:"level"` // 0 pour le rez-de-chaussée, négatif pour un sous-sol
+	RoomCount  int    // Salles en service à cet étage
+}
+
+// Zone regroupe des salles d'un site, éventuellement de plusieurs bâtiments :
+// une aile, une zone de sécurité...
+type Zone struct {
+	ID          int    `db:"id"`
+	SiteID      int    `db:"site_id"`
+	Name        string `db:"name"`
+	Description string `db:"description"`
+	RoomCount   int    // Salles en service de la zone
+}
+
+// FloorRooms regroupe les salles d'un même étage ; Floor est vide pour les salles sans étage
+type FloorRooms struct {
+	Floor string
+	Level int
+	Rooms []Room
+}
+
+// GroupRoomsByFloor regroupe les salles d'un bâtiment par étage, du plus bas au plus haut,
+// les salles sans étage en dernier. L'ordre des salles de chaque étage est conservé.
+func GroupRoomsByFloor(rooms []Room) []FloorRooms {
+	var groups []FloorRooms
+	index := make(map[int]int) // ID d'étage -> position dans groups
+	var unassigned []Room
+	for _, r := range rooms {
+		if r.FloorID == nil {
+			unassigned = append(unassigned, r)
+			continue
+		}
+		i, ok := index[*r.FloorID]
+		if !ok {
+			i = len(groups)
+			index[*r.FloorID] = i
+			groups = append(groups, FloorRooms{Floor: r.Floor, Level: r.FloorLevel})
+		}
+		groups[i].Rooms = append(groups[i].Rooms, r)
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groups[i].Level != groups[j].Level {
+			return groups[i].Level < groups[j].Level
+		}
+		return strings.ToLower(groups[i].Floor) < strings.ToLower(groups[j].Floor)
+	})
+	if len(unassigned) > 0 {
+		groups = append(groups, FloorRooms{Rooms: unassigned})
+	}
+	return groups
+}
+
+// CustomFieldType est le type de saisie d'un champ personnalisé
+type CustomFieldType string
+
+const (
+	CustomFieldText   CustomFieldType = "text"
+	CustomFieldNumber CustomFieldType = "number"
+	CustomFieldDate   CustomFieldType = "date"   // Enregistrée au format AAAA-MM-JJ
+	CustomFieldChoice CustomFieldType = "choice" // Une valeur parmi Choices
+)
+
+// CustomFieldTypes liste les types de champs personnalisés, dans l'ordre d'affichage
+var CustomFieldTypes = []CustomFieldType{CustomFieldText, CustomFieldNumber, CustomFieldDate, CustomFieldChoice}
+
+// Label retourne le libellé français du type
+func (t CustomFieldType) Label() string {
+	switch t {
+	case CustomFieldText:
+		return "Texte"
+	case CustomFieldNumber:
+		return "Nombre"
+	case CustomFieldDate:
+		return "Date"
+	case CustomFieldChoice:
+		return "Liste de choix"
+	}
+	return string(t)
+}
+
+// CustomFieldEntities liste les entités qui acceptent des champs personnalisés
+var CustomFieldEntities = []string{AuditEntityKey, AuditEntityRoom, AuditEntityBorrower}
+
+// CustomFieldEntityLabel retourne le libellé français d'une entité à champs personnalisés
+func CustomFieldEntityLabel(entity string) string {
+	switch entity {
+	case AuditEntityKey:
+		return "Clés"
+	case AuditEntityRoom:
+		return "Salles"
+	case AuditEntityBorrower:
+		return "Emprunteurs"
+	}
+	return entity
+}
+
+// MaxReportCustomFields est le nombre de champs personnalisés d'une entité affichables
+// en colonnes dans les rapports, par site
+const MaxReportCustomFields = 3
+
+// CustomField est un champ personnalisé défini par un site pour ses clés, salles ou emprunteurs
+type CustomField struct {
+	ID        int             `db:"id"`
+	SiteID    int             `db:"site_id"`
+	Entity    string          `db:"entity"` // AuditEntityKey, AuditEntityRoom ou AuditEntityBorrower
+	Label     string          `db:"label"`
+	Type      CustomFieldType `db:"field_type"`
+	Choices   []string        `db:"choices"`    // Valeurs possibles d'une liste de choix
+	InReports bool            `db:"in_reports"` // Colonne des rapports PDF
+}
+
+// Normalize vérifie une saisie et retourne la valeur à enregistrer (vide = pas de valeur).
+// Les nombres acceptent la virgule décimale et les dates le format JJ/MM/AAAA.
+func (f CustomField) Normalize(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", nil
+	}
+	switch f.Type {
+	case CustomFieldNumber:
+		n, err := strconv.ParseFloat(strings.Replace(value, ",", ".", 1), 64)
+		if err != nil {
+			return "", fmt.Errorf("%s : %q n'est pas un nombre", f.Label, value)
+		}
+		return strconv.FormatFloat(n, 'f', -1, 64), nil
+	case CustomFieldDate:
+		d, err := time.Parse("02/01/2006", value)
+		if err != nil {
+			return "", fmt.Errorf("%s : date invalide %q (format JJ/MM/AAAA)", f.Label, value)
+		}
+		return d.Format("2006-01-02"), nil
+	case CustomFieldChoice:
+		for _, choice := range f.Choices {
+			if strings.EqualFold(choice, value) {
+				return choice, nil
+			}
+		}
+		return "", fmt.Errorf("%s : %q ne fait pas partie des choix proposés", f.Label, value)
+	}
+	return value, nil
+}
+
+// Display retourne une valeur enregistrée telle qu'elle est saisie (virgule décimale, JJ/MM/AAAA)
+func (f CustomField) Display(value string) string {
+	switch f.Type {
+	case CustomFieldNumber:
+		return strings.Replace(value, ".", ",", 1)
+	case CustomFieldDate:
+		if d, err := time.Parse("2006-01-02", value); err == nil {
+			return d.Format("02/01/2006")
+		}
+	}
+	return value
+}
+
+// CustomValues associe l'ID d'un champ personnalisé à sa valeur enregistrée ; à l'enregistrement,
+// les champs absents gardent leur valeur et une valeur vide efface le champ
+type CustomValues map[int]string
+
+// CustomFieldValue est la valeur d'un champ personnalisé d'un enregistrement, prête à afficher
+type CustomFieldValue struct {
+	Field CustomField
+	Value string // Valeur affichée (vide = non renseignée)
+}
+
+// CustomColumns contient les colonnes personnalisées d'un rapport : les libellés des champs
+// et, pour chaque enregistrement, la valeur affichée de chaque libellé. Dans la vue consolidée,
+// les champs de même libellé de plusieurs sites forment une seule colonne.
+type CustomColumns struct {
+	Labels []string
+	Values map[int]map[string]string // ID de l'enregistrement -> libellé -> valeur affichée
+}
+
+// CustomFieldRecord est un enregistrement de l'export des champs personnalisés
+type CustomFieldRecord struct {
+	ID    int
+	Title string // Numéro de la clé, « Bâtiment > Salle » ou nom de l'emprunteur
+}
+
+// CustomFieldExport contient tous les champs personnalisés d'une entité et ses enregistrements
+// en service, renseignés ou non
+type CustomFieldExport struct {
+	Entity  string
+	Columns CustomColumns
+	Records []CustomFieldRecord
+}
+
+// Cells retourne les valeurs d'un enregistrement dans l'ordre des libellés
+func (c CustomColumns) Cells(id int) []string {
+	cells := make([]string, len(c.Labels))
+	for i, label := range c.Labels {
+		cells[i] = c.Values[id][label]
+	}
+	return cells
+}
+
+// MaxAttachmentSize est la taille maximale d'une pièce jointe, enregistrée dans la base
+const MaxAttachmentSize = 10 << 20
+
+// Attachment est un fichier joint à une clé, une salle, un emprunteur ou un emprunt
+// (photo du profil de la clé, reçu signé scanné, copie d'une pièce d'identité...).
+// Le contenu est lu à part par GetAttachmentData.
+type Attachment struct {
+	ID        int       `db:"id"`
+	Entity    string    `db:"entity"` // AuditEntityKey, AuditEntityRoom, AuditEntityBorrower ou AuditEntityLoan
+	EntityID  int       `db:"entity_id"`
+	Filename  string    `db:"filename"`
+	MimeType  string    `db:"mime_type"`
+	Size      int64     `db:"size"`
+	Operator  string    `db:"operator"`
+	CreatedAt time.Time `db:"created_at"`
+	Thumbnail []byte    `db:"thumbnail"` // Miniature PNG, nil si le fichier n'est pas une image
+}
+
+// IsImage indique si la pièce jointe est une image affichable
+func (a Attachment) IsImage() bool {
+	return strings.HasPrefix(a.MimeType, "image/")
+}
+
+// SizeLabel retourne la taille du fichier lisible (ex: 1.2 MB)
+func (a Attachment) SizeLabel() string {
+	return formatFileSize(a.Size)
+}
+
+// AccessKind indique pourquoi une clé ouvre une salle
+type AccessKind string
+
+const (
+	AccessDirect    AccessKind = "direct"    // La clé est associée à la salle
+	AccessInherited AccessKind = "inherited" // La clé est le passe-partout d'une clé associée à la salle
+)
+
+// Label retourne le libellé français du type d'accès
+func (a AccessKind) Label() string {
+	switch a {
+	case AccessDirect:
+		return "Direct"
+	case AccessInherited:
+		return "Hérité"
+	}
+	return string(a)
 }
 
 // Borrower représente un emprunteur
 type Borrower struct {
-	ID    int    `db:"id"`
-	Name  string `db:"name"`
-	Email string `db:"email"`
-	Loans []Loan // Relation
+	ID          int            `db:"id"`
+	Name        string         `db:"name"` // Nom affiché, recalculé à partir du prénom et du nom
+	FirstName   string         `db:"first_name"`
+	LastName    string         `db:"last_name"`
+	Email       string         `db:"email"`
+	Phone       string         `db:"phone"`
+	BadgeNumber string         `db:"badge_number"` // Badge ou matricule
+	Department  string         `db:"department"`
+	Status      BorrowerStatus `db:"status"`
+	StartDate   *time.Time     `db:"start_date"` // Arrivée dans l'établissement
+	EndDate     *time.Time     `db:"end_date"`   // Départ prévu ou effectif
+	Notes       string         `db:"notes"`
+	ArchivedAt  *time.Time     `db:"archived_at"` // Date d'archivage (nil = emprunteur affiché dans les listes)
+	Loans       []Loan         // Relation
+	// CustomValues contient les champs personnalisés à enregistrer avec l'emprunteur (nil = inchangés)
+	CustomValues CustomValues
+}
+
+// BorrowerStatus indique si un emprunteur fait toujours partie de l'établissement
+type BorrowerStatus string
+
+const (
+	BorrowerActive   BorrowerStatus = "active"
+	BorrowerDeparted BorrowerStatus = "departed"
+)
+
+// Label retourne le libellé français du statut
+func (s BorrowerStatus) Label() string {
+	switch s {
+	case BorrowerActive:
+		return "Actif"
+	case BorrowerDeparted:
+		return "Parti"
+	}
+	return string(s)
+}
+
+// FullName retourne « Prénom Nom », ou le nom enregistré si ni prénom ni nom ne sont renseignés
+func (b Borrower) FullName() string {
+	full := strings.TrimSpace(b.FirstName + " " + b.LastName)
+	if full == "" {
+		return b.Name
+	}
+	return full
+}
+
+// Identification résume ce qui permet de distinguer deux homonymes (badge, service)
+func (b Borrower) Identification() string {
+	var parts []string
+	if b.BadgeNumber != "" {
+		parts = append(parts, "badge "+b.BadgeNumber)
+	}
+	if b.Department != "" {
+		parts = append(parts, b.Department)
+	}
+	return strings.Join(parts, " - ")
 }
 
 // Loan représente un emprunt de clé
@@ -40,15 +402,377 @@ type Loan struct {
 	BorrowerID int        `db:"borrower_id"`
 	LoanDate   time.Time  `db:"loan_date"`
 	ReturnDate *time.Time `db:"return_date"`
-	Key        Key        // Relation
-	Borrower   Borrower   // Relation
+	DueDate    *time.Time `db:"due_date"`
+	CopyID     *int       `db:"copy_id"`     // Exemplaire remis (nil pour les emprunts antérieurs aux exemplaires)
+	LoanedBy   string     `db:"loaned_by"`   // Opérateur ayant enregistré l'emprunt
+	ReturnedBy string     `db:"returned_by"` // Opérateur ayant enregistré le retour
+	LossStatus CopyStatus `db:"loss_status"` // Perdu ou volé si l'emprunt a été clos par une déclaration de perte
+	LossNotes  string     `db:"loss_notes"`
+	// Caution versée à l'emprunt (0 = sans caution)
+	DepositAmount float64       `db:"deposit_amount"`
+	DepositMethod DepositMethod `db:"deposit_method"`
+	DepositStatus DepositStatus `db:"deposit_status"`
+	DepositNotes  string        `db:"deposit_notes"` // Motif de la retenue
+	// TransferredFromLoanID est l'emprunt clos par le transfert qui a ouvert celui-ci
+	TransferredFromLoanID *int     `db:"transferred_from_loan_id"`
+	Key                   Key      // Relation
+	Borrower              Borrower // Relation
+}
+
+// HasHeldDeposit indique si la caution de l'emprunt est encore détenue
+func (l Loan) HasHeldDeposit() bool {
+	return l.DepositAmount > 0 && l.DepositStatus == DepositHeld
+}
+
+// IsOverdue indique si l'emprunt est toujours en cours après sa date de retour prévue
+func (l Loan) IsOverdue(now time.Time) bool {
+	return l.ReturnDate == nil && l.DueDate != nil && now.After(*l.DueDate)
+}
+
+// DaysOverdue retourne le nombre de jours de retard (0 si l'emprunt n'est pas en retard)
+func (l Loan) DaysOverdue(now time.Time) int {
+	if !l.IsOverdue(now) {
+		return 0
+	}
+	return int(now.Sub(*l.DueDate).Hours()/24) + 1
+}
+
+// LoanOptions regroupe les paramètres facultatifs d'un emprunt
+type LoanOptions struct {
+	DueDate *time.Time  // Remplace la durée par défaut de chaque clé (nil = durée de la clé)
+	CopyIDs map[int]int // Exemplaire choisi par clé (sinon le premier exemplaire disponible)
+	// DepositAmount est la caution demandée pour chaque clé (0 = sans caution)
+	DepositAmount float64
+	DepositMethod DepositMethod
+	// OverrideAuthorization permet à un administrateur de prêter une clé que
+	// l'emprunteur n'est pas autorisé à prendre ; la dérogation est journalisée
+	OverrideAuthorization bool
+	// Signature est la signature de l'emprunteur (PNG), enregistrée avec chaque emprunt créé
+	Signature []byte
+}
+
+// SignatureKind indique à quel moment l'emprunteur a signé un emprunt
+type SignatureKind string
+
+const (
+	SignatureLoan   SignatureKind = "loan"   // À la remise de la clé
+	SignatureReturn SignatureKind = "return" // Au retour de la clé
+)
+
+// DepositMethod est le moyen de paiement d'une caution
+type DepositMethod string
+
+const (
+	DepositCash   DepositMethod = "cash"
+	DepositCheque DepositMethod = "cheque"
+)
+
+// DepositMethods liste les moyens de paiement dans l'ordre d'affichage
+var DepositMethods = []DepositMethod{DepositCash, DepositCheque}
+
+// Label retourne le libellé affiché pour le moyen de paiement
+func (m DepositMethod) Label() string {
+	switch m {
+	case DepositCash:
+		return "Espèces"
+	case DepositCheque:
+		return "Chèque"
+	}
+	return string(m)
+}
+
+// DepositStatus est l'état d'une caution
+type DepositStatus string
+
+const (
+	DepositHeld     DepositStatus = "held"
+	DepositRefunded DepositStatus = "refunded"
+	DepositRetained DepositStatus = "retained"
+	// DepositTransferred : la caution garantit désormais l'emprunt ouvert par un transfert
+	DepositTransferred DepositStatus = "transferred"
+)
+
+// Label retourne le libellé affiché pour l'état de la caution
+func (s DepositStatus) Label() string {
+	switch s {
+	case DepositHeld:
+		return "Détenue"
+	case DepositRefunded:
+		return "Restituée"
+	case DepositRetained:
+		return "Retenue"
+	case DepositTransferred:
+		return "Transférée"
+	}
+	return string(s)
+}
+
+// FormatDepositAmount met en forme un montant de caution (ex: « 50,00 € »)
+func FormatDepositAmount(amount float64) string {
+	return strings.Replace(fmt.Sprintf("%.2f €", amount), ".", ",", 1)
+}
+
+// BorrowerDeposits regroupe les cautions encore détenues pour un emprunteur
+type BorrowerDeposits struct {
+	BorrowerID   int
+	BorrowerName string
+	Loans        []LoanWithDetails
+	Total        float64
+}
+
+// CopyStatus est l'état d'un exemplaire physique de clé
+type CopyStatus string
+
+const (
+	CopyAvailable CopyStatus = "available"
+	CopyLoaned    CopyStatus = "loaned"
+	CopyReserve   CopyStatus = "reserve"
+	CopyLost      CopyStatus = "lost"
+	CopyStolen    CopyStatus = "stolen"
+	CopyDestroyed CopyStatus = "destroyed"
+)
+
+// Label retourne le libellé affiché pour l'état
+func (s CopyStatus) Label() string {
+	switch s {
+	case CopyAvailable:
+		return "Disponible"
+	case CopyLoaned:
+		return "Emprunté"
+	case CopyReserve:
+		return "En réserve"
+	case CopyLost:
+		return "Perdu"
+	case CopyStolen:
+		return "Volé"
+	case CopyDestroyed:
+		return "Détruit"
+	}
+	return string(s)
+}
+
+// InService indique si l'exemplaire compte encore dans le stock (ni perdu, ni volé, ni détruit)
+func (s CopyStatus) InService() bool {
+	return s != CopyLost && s != CopyStolen && s != CopyDestroyed
+}
+
+// LossImpactReport décrit les conséquences de la perte ou du vol d'une clé :
+// les salles désormais exposées et les autres clés qui les ouvrent
+type LossImpactReport struct {
+	Loan LoanWithDetails
+	// Rooms sont les salles ouvertes par la clé perdue ; Building.Name est renseigné
+	// et Keys contient les autres clés qui ouvrent la salle
+	Rooms []Room
+	// OtherCopies est le nombre d'exemplaires de la même clé encore en service,
+	// concernés eux aussi par un changement de cylindre
+	OtherCopies int
+}
+
+// ReservationStatus est l'état d'une réservation de clé
+type ReservationStatus string
+
+const (
+	ReservationPending   ReservationStatus = "pending"
+	ReservationConverted ReservationStatus = "converted"
+	ReservationCancelled ReservationStatus = "cancelled"
+)
+
+// Label retourne le libellé affiché pour l'état
+func (s ReservationStatus) Label() string {
+	switch s {
+	case ReservationPending:
+		return "En attente"
+	case ReservationConverted:
+		return "Convertie en emprunt"
+	case ReservationCancelled:
+		return "Annulée"
+	}
+	return string(s)
+}
+
+// Reservation représente la réservation d'une clé par un emprunteur sur une période à venir
+type Reservation struct {
+	ID             int               `db:"id"`
+	KeyID          int               `db:"key_id"`
+	KeyNumber      string            // Relation
+	KeyDescription string            // Relation
+	BorrowerID     int               `db:"borrower_id"`
+	BorrowerName   string            // Relation
+	StartDate      time.Time         `db:"start_date"`
+	EndDate        time.Time         `db:"end_date"`
+	Notes          string            `db:"notes"`
+	Status         ReservationStatus `db:"status"`
+	LoanID         *int              `db:"loan_id"` // Emprunt créé à partir de la réservation
+	CreatedBy      string            `db:"created_by"`
+	CreatedAt      time.Time         `db:"created_at"`
+}
+
+// Expired indique si une réservation en attente n'a pas été honorée avant sa fin
+func (r Reservation) Expired(now time.Time) bool {
+	return r.Status == ReservationPending && r.EndDate.Before(now)
+}
+
+// KeyCopy représente un exemplaire physique d'une clé (ex: « A12-3 » gravé sur la clé)
+type KeyCopy struct {
+	ID           int        `db:"id"`
+	KeyID        int        `db:"key_id"`
+	Identifier   string     `db:"identifier"`
+	Status       CopyStatus `db:"status"`
+	Notes        string     `db:"notes"`
+	HookNumber   int        `db:"hook_number"` // Crochet de l'armoire de la clé (0 = aucun)
+	BorrowerName string     // Emprunteur actuel si l'exemplaire est emprunté
+}
+
+// StockMovementType est la nature d'un mouvement du registre de stock d'une clé
+type StockMovementType string
+
+const (
+	MovementPurchase    StockMovementType = "purchase"     // Achat d'exemplaires neufs
+	MovementDuplication StockMovementType = "duplication"  // Reproduction d'exemplaires
+	MovementDestruction StockMovementType = "destruction"  // Exemplaires détruits
+	MovementLoss        StockMovementType = "loss"         // Exemplaires perdus ou volés
+	MovementToReserve   StockMovementType = "to_reserve"   // Mise en réserve
+	MovementFromReserve StockMovementType = "from_reserve" // Sortie de réserve
+	MovementFound       StockMovementType = "found"        // Exemplaire perdu retrouvé
+)
+
+// ManualStockMovements sont les mouvements qu'un gestionnaire peut saisir depuis une clé
+// (un exemplaire retrouvé se déclare depuis la gestion des exemplaires)
+var ManualStockMovements = []StockMovementType{
+	MovementPurchase, MovementDuplication, MovementDestruction, MovementLoss, MovementToReserve, MovementFromReserve,
+}
+
+// Label retourne le libellé affiché pour le mouvement
+func (t StockMovementType) Label() string {
+	switch t {
+	case MovementPurchase:
+		return "Achat"
+	case MovementDuplication:
+		return "Reproduction"
+	case MovementDestruction:
+		return "Destruction"
+	case MovementLoss:
+		return "Perte ou vol"
+	case MovementToReserve:
+		return "Mise en réserve"
+	case MovementFromReserve:
+		return "Sortie de réserve"
+	case MovementFound:
+		return "Exemplaire retrouvé"
+	}
+	return string(t)
+}
+
+// Deltas retourne l'effet d'un mouvement d'un exemplaire sur la quantité totale et sur la réserve
+func (t StockMovementType) Deltas() (total, reserve int) {
+	switch t {
+	case MovementPurchase, MovementDuplication, MovementFound:
+		return 1, 0
+	case MovementDestruction, MovementLoss:
+		return -1, 0
+	case MovementToReserve:
+		return 0, 1
+	case MovementFromReserve:
+		return 0, -1
+	}
+	return 0, 0
+}
+
+// StockMovement est une ligne du registre de stock : les quantités d'une clé sont la somme de ses mouvements
+type StockMovement struct {
+	ID             int               `db:"id"`
+	KeyID          int               `db:"key_id"`
+	KeyNumber      string            // Relation
+	CopyID         *int              `db:"copy_id"` // Exemplaire concerné lorsqu'il est unique
+	CopyIdentifier string            // Relation
+	Type           StockMovementType `db:"movement_type"`
+	Quantity       int               `db:"quantity"` // Nombre d'exemplaires, toujours positif
+	Reason         string            `db:"reason"`
+	Operator       string            `db:"operator"`
+	MovedAt        time.Time         `db:"moved_at"`
+}
+
+// StockMovementFilter restreint le registre de stock (valeur zéro = pas de filtre)
+type StockMovementFilter struct {
+	KeyID int
+	From  *time.Time // Mouvements à partir de cette date
+	To    *time.Time // Mouvements jusqu'à cette date
+}
+
+// LoanHistoryFilter restreint l'historique des emprunts (valeur zéro = pas de filtre)
+type LoanHistoryFilter struct {
+	KeyID      int
+	BorrowerID int
+	BuildingID int        // Clés ouvrant au moins une salle du bâtiment
+	From       *time.Time // Emprunts commencés à partir de cette date
+	To         *time.Time // Emprunts commencés jusqu'à cette date
+	Limit      int        // Taille de page (0 = tout)
+	Offset     int
+}
+
+// Site regroupe les bâtiments, les clés et les emprunteurs d'un même lieu
+type Site struct {
+	ID   int    `db:"id"`
+	Name string `db:"name"`
+}
+
+// SiteSummary contient les chiffres clés d'un site pour le rapport consolidé
+type SiteSummary struct {
+	Site
+	Buildings    int
+	Rooms        int
+	Keys         int
+	Copies       int // Exemplaires en service (disponibles ou empruntés)
+	Borrowers    int
+	ActiveLoans  int
+	OverdueLoans int
+	HeldDeposits float64 // Total des cautions détenues
+}
+
+// StorageLocation est un lieu de rangement des clés d'un site. Un emplacement doté de
+// crochets numérotés est une armoire à clés.
+type StorageLocation struct {
+	ID        int    `db:"id"`
+	SiteID    int    `db:"site_id"`
+	Name      string `db:"name"`
+	HookCount int    `db:"hook_count"` // 0 = emplacement sans crochets
+	Notes     string `db:"notes"`
+	KeyCount  int    // Clés en service rangées à cet emplacement
+	UsedHooks int    // Crochets attribués à un exemplaire en service
+}
+
+// IsCabinet indique si l'emplacement est une armoire à crochets numérotés
+func (l StorageLocation) IsCabinet() bool {
+	return l.HookCount > 0
+}
+
+// CabinetHook est un crochet d'armoire et l'exemplaire qui y est rangé
+type CabinetHook struct {
+	Number         int      // 0 pour un exemplaire sans crochet
+	Copy           *KeyCopy // nil : crochet libre
+	KeyNumber      string
+	KeyDescription string
+}
+
+// Out indique si le crochet est vide parce que son exemplaire est emprunté
+func (h CabinetHook) Out() bool {
+	return h.Copy != nil && h.Copy.Status == CopyLoaned
+}
+
+// CabinetMap est le plan d'une armoire à clés : chaque crochet et les exemplaires des
+// clés de l'armoire qui n'ont pas encore de crochet
+type CabinetMap struct {
+	Location   StorageLocation
+	Hooks      []CabinetHook // Un par crochet, du premier au dernier
+	Unassigned []CabinetHook
 }
 
 // Building représente un bâtiment
 type Building struct {
-	ID    int    `db:"id"`
-	Name  string `db:"name"`
-	Rooms []Room // Relation
+	ID         int        `db:"id"`
+	Name       string     `db:"name"`
+	ArchivedAt *time.Time `db:"archived_at"` // Date d'archivage (nil = bâtiment en service)
+	Rooms      []Room     // Relation
 }
 
 // KeyRoomAssociation représente la table d'association many-to-many
@@ -61,15 +785,235 @@ type KeyRoomAssociation struct {
 type KeyWithAvailability struct {
 	Key
 	LoanedCount    int
-	AvailableCount int
+	AvailableCount int // Exemplaires prêtables maintenant pour la durée par défaut, réservations qui la chevauchent déduites
+	ReservedCount  int // Réservations en attente couvrant le moment présent
 	BorrowerNames  []string
 }
 
+// SearchResult est un résultat de la recherche globale
+type SearchResult struct {
+	Entity string // AuditEntityKey, AuditEntityRoom, AuditEntityBuilding ou AuditEntityBorrower
+	ID     int
+	Title  string // Numéro de la clé, nom de la salle, du bâtiment ou de l'emprunteur
+	Detail string // Description et emplacement, type et bâtiment, ou email
+}
+
+// SearchEntities liste les catégories de la recherche globale, dans l'ordre d'affichage
+var SearchEntities = []string{AuditEntityKey, AuditEntityRoom, AuditEntityBuilding, AuditEntityBorrower}
+
+// ArchivedRecord est une clé, un emprunteur, un bâtiment ou une salle archivé
+type ArchivedRecord struct {
+	Entity     string // AuditEntityKey, AuditEntityBorrower, AuditEntityBuilding ou AuditEntityRoom
+	ID         int
+	Title      string // Numéro de la clé ou nom de l'enregistrement
+	ArchivedAt time.Time
+	LoanCount  int // Emprunts de l'historique supprimés avec l'enregistrement en cas de purge
+}
+
 // LoanWithDetails contient un emprunt avec tous les détails
 type LoanWithDetails struct {
 	Loan
-	KeyNumber       string
-	KeyDescription  string
-	BorrowerName    string
-	BorrowerEmail   string
+	KeyNumber           string
+	KeyDescription      string
+	BorrowerName        string
+	BorrowerEmail       string
+	BorrowerBadgeNumber string
+	BorrowerDepartment  string
+	CopyIdentifier      string
+	TransferredFrom     string // Emprunteur précédent si la clé a été reçue par transfert
+	TransferredTo       string // Emprunteur suivant si l'emprunt a été clos par un transfert
+	// Signatures de l'emprunteur à la remise et au retour (PNG, nil si non signé),
+	// lues seulement par GetLoanByID et LoadLoanSignatures
+	Signature       []byte
+	ReturnSignature []byte
+}
+
+// KeyAuthorization autorise un emprunteur, ou tout un service, à emprunter une clé,
+// éventuellement sur une période limitée. Une clé sans autorisation est libre d'accès.
+type KeyAuthorization struct {
+	ID           int
+	KeyID        int
+	KeyNumber    string
+	BorrowerID   *int // Emprunteur autorisé (nil pour une autorisation de service)
+	BorrowerName string
+	Department   string // Service autorisé (vide pour une autorisation nominative)
+	ValidFrom    *time.Time
+	ValidUntil   *time.Time
+	Notes        string
+	CreatedAt    time.Time
+}
+
+// Holder retourne le bénéficiaire de l'autorisation (emprunteur ou service)
+func (a KeyAuthorization) Holder() string {
+	if a.BorrowerID != nil {
+		return a.BorrowerName
+	}
+	return "Service " + a.Department
+}
+
+// ValidAt indique si l'autorisation est en vigueur à la date donnée
+func (a KeyAuthorization) ValidAt(t time.Time) bool {
+	if a.ValidFrom != nil && t.Before(*a.ValidFrom) {
+		return false
+	}
+	return a.ValidUntil == nil || !t.After(*a.ValidUntil)
+}
+
+// AuditAction est le type d'opération enregistrée dans le journal d'audit
+type AuditAction string
+
+const (
+	AuditCreate   AuditAction = "create"
+	AuditUpdate   AuditAction = "update"
+	AuditDelete   AuditAction = "delete"
+	AuditLoan     AuditAction = "loan"
+	AuditReturn   AuditAction = "return"
+	AuditRestore  AuditAction = "restore"
+	AuditReset    AuditAction = "reset"
+	AuditImport   AuditAction = "import"
+	AuditDemo     AuditAction = "demo"
+	AuditLogin    AuditAction = "login"
+	AuditOverride AuditAction = "override"
+	AuditLoss     AuditAction = "loss"
+	AuditTransfer AuditAction = "transfer"
+	AuditArchive  AuditAction = "archive"
+	AuditRecover  AuditAction = "recover" // Sortie des archives
+)
+
+// AuditActions liste les actions dans l'ordre d'affichage des filtres
+var AuditActions = []AuditAction{AuditCreate, AuditUpdate, AuditDelete, AuditLoan, AuditReturn, AuditRestore, AuditReset, AuditImport, AuditDemo, AuditLogin, AuditOverride, AuditLoss, AuditTransfer, AuditArchive, AuditRecover}
+
+// Label retourne le libellé français de l'action
+func (a AuditAction) Label() string {
+	switch a {
+	case AuditCreate:
+		return "Création"
+	case AuditUpdate:
+		return "Modification"
+	case AuditDelete:
+		return "Suppression"
+	case AuditLoan:
+		return "Emprunt"
+	case AuditReturn:
+		return "Retour"
+	case AuditRestore:
+		return "Restauration"
+	case AuditReset:
+		return "Réinitialisation"
+	case AuditImport:
+		return "Importation"
+	case AuditDemo:
+		return "Données de démonstration"
+	case AuditLogin:
+		return "Connexion"
+	case AuditOverride:
+		return "Dérogation"
+	case AuditLoss:
+		return "Perte / vol"
+	case AuditTransfer:
+		return "Transfert"
+	case AuditArchive:
+		return "Archivage"
+	case AuditRecover:
+		return "Sortie des archives"
+	}
+	return string(a)
+}
+
+// Entités du journal d'audit (nom de la table concernée)
+const (
+	AuditEntityKey           = "keys"
+	AuditEntityCopy          = "key_copies"
+	AuditEntityBorrower      = "borrowers"
+	AuditEntityBuilding      = "buildings"
+	AuditEntityRoom          = "rooms"
+	AuditEntityLoan          = "loans"
+	AuditEntityOperator      = "operators"
+	AuditEntityAuthorization = "key_authorizations"
+	AuditEntityReservation   = "reservations"
+	AuditEntityDatabase      = "database"
+	AuditEntitySite          = "sites"
+	AuditEntityLocation      = "storage_locations"
+	AuditEntityFloor         = "floors"
+	AuditEntityZone          = "zones"
+	AuditEntityCustomField   = "custom_fields"
+	AuditEntityAttachment    = "attachments"
+)
+
+// AuditEntry est une ligne du journal d'audit. Before et After contiennent
+// l'enregistrement concerné au format JSON avant et après l'opération.
+type AuditEntry struct {
+	ID        int         `db:"id"`
+	CreatedAt time.Time   `db:"created_at"`
+	Operator  string      `db:"operator"`
+	Action    AuditAction `db:"action"`
+	Entity    string      `db:"entity"`
+	EntityID  int         `db:"entity_id"`
+	Summary   string      `db:"summary"`
+	Before    string      `db:"before_json"`
+	After     string      `db:"after_json"`
+}
+
+// AuditFilter restreint la consultation du journal d'audit (valeur zéro = pas de filtre)
+type AuditFilter struct {
+	Entity   string
+	Action   AuditAction
+	Operator string     // Recherche partielle sur le nom de l'opérateur
+	From     *time.Time // Opérations à partir de cette date
+	To       *time.Time // Opérations jusqu'à cette date
+	Limit    int        // Taille de page (0 = tout)
+	Offset   int
+}
+
+// Role détermine ce qu'un opérateur peut faire dans l'application
+type Role string
+
+const (
+	RoleAdmin    Role = "admin"        // Tout, y compris restauration, réinitialisation et comptes
+	RoleManager  Role = "gestionnaire" // Emprunts, retours et gestion des données
+	RoleReadOnly Role = "lecture"      // Consultation uniquement
+)
+
+// Roles liste les rôles dans l'ordre d'affichage
+var Roles = []Role{RoleAdmin, RoleManager, RoleReadOnly}
+
+// Label retourne le libellé français du rôle
+func (r Role) Label() string {
+	switch r {
+	case RoleAdmin:
+		return "Administrateur"
+	case RoleManager:
+		return "Gestionnaire"
+	case RoleReadOnly:
+		return "Lecture seule"
+	}
+	return string(r)
+}
+
+// CanEdit indique si le rôle peut enregistrer des emprunts et modifier les données
+func (r Role) CanEdit() bool {
+	return r == RoleAdmin || r == RoleManager
+}
+
+// IsAdmin indique si le rôle peut effectuer les actions d'administration
+func (r Role) IsAdmin() bool {
+	return r == RoleAdmin
+}
+
+// Operator est un compte permettant de se connecter à l'application
+type Operator struct {
+	ID          int       `db:"id"`
+	Username    string    `db:"username"`
+	DisplayName string    `db:"display_name"`
+	Role        Role      `db:"role"`
+	Active      bool      `db:"active"`
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+// Name retourne le nom affiché de l'opérateur (son identifiant à défaut)
+func (o Operator) Name() string {
+	if o.DisplayName != "" {
+		return o.DisplayName
+	}
+	return o.Username
 }
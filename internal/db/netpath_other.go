@@ -0,0 +1,9 @@
+//go:build !windows
+
+package db
+
+// isNetworkDrive n'a de sens que sous Windows : ailleurs, les partages montés
+// ne se distinguent pas d'un disque local
+func isNetworkDrive(path string) bool {
+	return false
+}
@@ -0,0 +1,24 @@
+package db
+
+import (
+	"path/filepath"
+
+	"golang.org/x/sys/windows"
+)
+
+// isNetworkDrive indique si le chemin se trouve sur un lecteur réseau (ex: Z: monté sur un partage)
+func isNetworkDrive(path string) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	volume := filepath.VolumeName(abs)
+	if volume == "" {
+		return false
+	}
+	root, err := windows.UTF16PtrFromString(volume + `\`)
+	if err != nil {
+		return false
+	}
+	return windows.GetDriveType(root) == windows.DRIVE_REMOTE
+}
@@ -0,0 +1,298 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrInvalidCredentials est retournée lorsque l'identifiant ou le mot de passe est incorrect
+var ErrInvalidCredentials = errors.New("identifiant ou mot de passe incorrect")
+
+// MinPasswordLength est la longueur minimale d'un mot de passe d'opérateur
+const MinPasswordLength = 8
+
+// operatorSelect est la requête commune aux lectures d'opérateurs (sans le mot de passe)
+const operatorSelect = `SELECT id, username, display_name, role, active, created_at FROM operators`
+
+// GetAllOperators récupère tous les comptes opérateurs
+func (s *Store) GetAllOperators() ([]Operator, error) {
+	rows, err := s.db().Query(operatorSelect + ` ORDER BY username`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var operators []Operator
+	for rows.Next() {
+		o, err := scanOperator(rows)
+		if err != nil {
+			return nil, err
+		}
+		operators = append(operators, o)
+	}
+	return operators, rows.Err()
+}
+
+// CountOperators compte les comptes opérateurs (0 au premier lancement)
+func (s *Store) CountOperators() (int, error) {
+	var count int
+	err := s.db().QueryRow(`SELECT COUNT(*) FROM operators`).Scan(&count)
+	return count, err
+}
+
+// scanOperator lit une ligne produite par operatorSelect
+func scanOperator(row rowScanner) (Operator, error) {
+	var o Operator
+	var displayName sql.NullString
+	err := row.Scan(&o.ID, &o.Username, &displayName, &o.Role, &o.Active, &o.CreatedAt)
+	o.DisplayName = displayName.String
+	return o, err
+}
+
+// CreateOperator crée un compte opérateur avec son mot de passe. Seul un administrateur
+// peut le faire, sauf pour le premier compte de la base, qui doit être administrateur.
+func (s *Store) CreateOperator(o *Operator, password string) error {
+	o.Username = strings.TrimSpace(o.Username)
+	if o.Username == "" {
+		return fmt.Errorf("l'identifiant est requis")
+	}
+	count, err := s.CountOperators()
+	if err != nil {
+		return err
+	}
+	if count > 0 || o.Role != RoleAdmin {
+		if err := s.requireAdmin("créer un compte opérateur"); err != nil {
+			return err
+		}
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	o.Active = true
+	o.CreatedAt = time.Now()
+	result, err := tx.Exec(`INSERT INTO operators (username, display_name, password_hash, role, active, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		o.Username, o.DisplayName, hash, o.Role, o.Active, o.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("erreur lors de la création de l'opérateur %s: %w", o.Username, err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	o.ID = int(id)
+
+	after, err := operatorSnapshot(tx, o.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.audit(tx, AuditCreate, AuditEntityOperator, o.ID, "Opérateur "+o.Username+" créé", nil, after); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpdateOperator met à jour le nom, le rôle et l'état d'un opérateur (administrateur uniquement).
+// Le dernier administrateur actif ne peut être ni désactivé ni rétrogradé.
+func (s *Store) UpdateOperator(o *Operator) error {
+	if err := s.requireAdmin("modifier un compte opérateur"); err != nil {
+		return err
+	}
+
+	tx, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := operatorSnapshot(tx, o.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE operators SET display_name = ?, role = ?, active = ? WHERE id = ?`,
+		o.DisplayName, o.Role, o.Active, o.ID)
+	if err != nil {
+		return err
+	}
+
+	var admins int
+	err = tx.QueryRow(`SELECT COUNT(*) FROM operators WHERE role = ? AND active = 1`, RoleAdmin).Scan(&admins)
+	if err != nil {
+		return err
+	}
+	if admins == 0 {
+		return fmt.Errorf("il doit rester au moins un administrateur actif")
+	}
+
+	after, err := operatorSnapshot(tx, o.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.audit(tx, AuditUpdate, AuditEntityOperator, o.ID, "Opérateur "+o.Username+" modifié", before, after); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SetOperatorPassword remplace le mot de passe d'un opérateur (administrateur uniquement)
+func (s *Store) SetOperatorPassword(operatorID int, password string) error {
+	if err := s.requireAdmin("changer le mot de passe d'un opérateur"); err != nil {
+		return err
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var username string
+	if err := tx.QueryRow(`SELECT username FROM operators WHERE id = ?`, operatorID).Scan(&username); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE operators SET password_hash = ? WHERE id = ?`, hash, operatorID); err != nil {
+		return err
+	}
+	if err := s.audit(tx, AuditUpdate, AuditEntityOperator, operatorID, "Mot de passe de "+username+" modifié", nil, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Authenticate vérifie l'identifiant et le mot de passe d'un opérateur actif.
+// Les connexions réussies et les échecs sont inscrits au journal d'audit.
+func (s *Store) Authenticate(username, password string) (*Operator, error) {
+	username = strings.TrimSpace(username)
+
+	var hash string
+	o, err := scanOperator(s.db().QueryRow(
+		`SELECT id, username, display_name, role, active, created_at FROM operators WHERE username = ?`, username))
+	if err == nil {
+		err = s.db().QueryRow(`SELECT password_hash FROM operators WHERE id = ?`, o.ID).Scan(&hash)
+	}
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	if err == sql.ErrNoRows || !o.Active || bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		// L'identifiant saisi est journalisé au nom de l'opérateur inconnu
+		auditErr := writeAudit(s.db(), username, AuditLogin, AuditEntityOperator, o.ID, "Échec de connexion de "+username, nil, nil)
+		if auditErr != nil {
+			return nil, auditErr
+		}
+		return nil, ErrInvalidCredentials
+	}
+
+	if err := writeAudit(s.db(), o.Username, AuditLogin, AuditEntityOperator, o.ID, "Connexion de "+o.Username, nil, nil); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// hashPassword vérifie la longueur du mot de passe et le hache avec bcrypt
+func hashPassword(password string) (string, error) {
+	if len([]rune(password)) < MinPasswordLength {
+		return "", fmt.Errorf("le mot de passe doit contenir au moins %d caractères", MinPasswordLength)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("erreur lors du hachage du mot de passe: %w", err)
+	}
+	return string(hash), nil
+}
+
+// operatorSnapshot lit un opérateur pour le journal d'audit, sans son mot de passe
+func operatorSnapshot(tx *sql.Tx, id int) (map[string]interface{}, error) {
+	snapshot, err := snapshotRow(tx, AuditEntityOperator, id)
+	if snapshot != nil {
+		delete(snapshot, "password_hash")
+	}
+	return snapshot, err
+}
+
+// operatorAccount est un compte opérateur avec son mot de passe haché, tel qu'il est
+// reporté d'une base à l'autre
+type operatorAccount struct {
+	Operator
+	passwordHash string
+}
+
+// readOperatorAccounts lit tous les comptes opérateurs d'une connexion
+func readOperatorAccounts(conn *sql.DB) ([]operatorAccount, error) {
+	rows, err := conn.Query(`SELECT id, username, display_name, role, active, created_at, password_hash FROM operators ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []operatorAccount
+	for rows.Next() {
+		var a operatorAccount
+		var displayName sql.NullString
+		if err := rows.Scan(&a.ID, &a.Username, &displayName, &a.Role, &a.Active, &a.CreatedAt, &a.passwordHash); err != nil {
+			return nil, err
+		}
+		a.DisplayName = displayName.String
+		accounts = append(accounts, a)
+	}
+	return accounts, rows.Err()
+}
+
+// carryOverOperators recopie dans une base restaurée ou réinitialisée les comptes
+// opérateurs de la base précédente. Un compte de même identifiant prend les valeurs
+// actuelles (rôle, état, mot de passe) : sans cela, une base sans opérateur
+// proposerait à quiconque de créer le premier administrateur.
+func carryOverOperators(conn *sql.DB, previous []operatorAccount) error {
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, a := range previous {
+		_, err := tx.Exec(`INSERT INTO operators (username, display_name, password_hash, role, active, created_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT(username) DO UPDATE SET display_name = excluded.display_name,
+				password_hash = excluded.password_hash, role = excluded.role, active = excluded.active`,
+			a.Username, a.DisplayName, a.passwordHash, a.Role, a.Active, a.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// requireAdmin refuse une opération réservée aux administrateurs lorsque l'opérateur
+// courant n'en est pas un ; action complète « seul un administrateur peut ... »
+func (s *Store) requireAdmin(action string) error {
+	admin, err := operatorIsAdmin(s.db(), s.Operator())
+	if err != nil {
+		return err
+	}
+	if !admin {
+		return fmt.Errorf("seul un administrateur peut %s", action)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package db
+
+import (
+	"testing"
+)
+
+func TestOperatorManagementRequiresAdmin(t *testing.T) {
+	s := newTestStore(t)
+
+	// Le premier compte de la base est forcément administrateur
+	if err := s.CreateOperator(&Operator{Username: "gestion", Role: RoleManager}, "motdepasse"); err == nil {
+		t.Fatal("CreateOperator() d'un premier compte non administrateur accepté")
+	}
+	admin := &Operator{Username: "admin", Role: RoleAdmin}
+	if err := s.CreateOperator(admin, "motdepasse"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CreateOperator(&Operator{Username: "intrus", Role: RoleAdmin}, "motdepasse"); err == nil {
+		t.Error("CreateOperator() accepté sans opérateur connecté une fois le premier compte créé")
+	}
+
+	s.SetOperator(admin.Username)
+	manager := &Operator{Username: "gestion", Role: RoleManager}
+	if err := s.CreateOperator(manager, "motdepasse"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Un gestionnaire ne peut ni créer de compte, ni se promouvoir, ni changer un mot de passe
+	s.SetOperator(manager.Username)
+	if err := s.CreateOperator(&Operator{Username: "admin2", Role: RoleAdmin}, "motdepasse"); err == nil {
+		t.Error("CreateOperator() accepté pour un gestionnaire")
+	}
+	promoted := *manager
+	promoted.Role = RoleAdmin
+	if err := s.UpdateOperator(&promoted); err == nil {
+		t.Error("UpdateOperator() accepté pour un gestionnaire")
+	}
+	if err := s.SetOperatorPassword(admin.ID, "nouveaumotdepasse"); err == nil {
+		t.Error("SetOperatorPassword() accepté pour un gestionnaire")
+	}
+	if _, err := s.Authenticate(admin.Username, "motdepasse"); err != nil {
+		t.Errorf("le mot de passe de l'administrateur a changé: %v", err)
+	}
+
+	s.SetOperator(admin.Username)
+	if err := s.SetOperatorPassword(manager.ID, "nouveaumotdepasse"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateOperator(&promoted); err != nil {
+		t.Fatal(err)
+	}
+}
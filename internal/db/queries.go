@@ -2,15 +2,57 @@ package db
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 )
 
 // ============= KEYS =============
 
-// GetAllKeys récupère toutes les clés
-func GetAllKeys() ([]Key, error) {
-	rows, err := DB.Query(`SELECT id, number, description, quantity_total, quantity_reserve, storage_location FROM keys ORDER BY number`)
+// keyColumns sont les colonnes lues par scanKey (k : la clé, p : son passe-partout parent)
+const keyColumns = `k.id, k.number, k.description, k.quantity_total, k.quantity_reserve, k.storage_location,
+		k.storage_location_id, k.default_loan_days, k.parent_key_id, p.number, k.archived_at`
+
+// keySelect est la requête commune aux lectures de clés, avec le numéro du passe-partout parent
+const keySelect = `SELECT ` + keyColumns + `
+		FROM keys k
+		LEFT JOIN keys p ON p.id = k.parent_key_id`
+
+// maxKeyDepth borne le parcours de la hiérarchie des passe-partout
+const maxKeyDepth = 32
+
+// scanKey lit les colonnes keyColumns ; extra reçoit les colonnes ajoutées à la suite
+func scanKey(row rowScanner, extra ...interface{}) (Key, error) {
+	var k Key
+	var storageLocation, parentNumber sql.NullString
+	var parentKeyID, storageLocationID sql.NullInt64
+	var archivedAt sql.NullTime
+	dest := append([]interface{}{&k.ID, &k.Number, &k.Description, &k.QuantityTotal, &k.QuantityReserve,
+		&storageLocation, &storageLocationID, &k.DefaultLoanDays, &parentKeyID, &parentNumber, &archivedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return k, err
+	}
+	k.StorageLocation = storageLocation.String
+	k.ParentNumber = parentNumber.String
+	if storageLocationID.Valid {
+		id := int(storageLocationID.Int64)
+		k.StorageLocationID = &id
+	}
+	if parentKeyID.Valid {
+		id := int(parentKeyID.Int64)
+		k.ParentKeyID = &id
+	}
+	if archivedAt.Valid {
+		k.ArchivedAt = &archivedAt.Time
+	}
+	return k, nil
+}
+
+// GetAllKeys récupère toutes les clés en service (hors archives) du site sélectionné
+func (s *Store) GetAllKeys() ([]Key, error) {
+	site, args := s.siteCondition("k.site_id")
+	rows, err := s.db().Query(keySelect+` WHERE k.archived_at IS NULL`+site+` ORDER BY k.number`, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -18,45 +60,93 @@ func GetAllKeys() ([]Key, error) {
 
 	var keys []Key
 	for rows.Next() {
-		var k Key
-		var storageLocation sql.NullString
-		err := rows.Scan(&k.ID, &k.Number, &k.Description, &k.QuantityTotal, &k.QuantityReserve, &storageLocation)
+		k, err := scanKey(rows)
 		if err != nil {
 			return nil, err
 		}
-		if storageLocation.Valid {
-			k.StorageLocation = storageLocation.String
-		}
 		keys = append(keys, k)
 	}
 	return keys, rows.Err()
 }
 
-// GetKeyByID récupère une clé par son ID
-func GetKeyByID(id int) (*Key, error) {
-	var k Key
-	var storageLocation sql.NullString
-	err := DB.QueryRow(`SELECT id, number, description, quantity_total, quantity_reserve, storage_location FROM keys WHERE id = ?`, id).
-		Scan(&k.ID, &k.Number, &k.Description, &k.QuantityTotal, &k.QuantityReserve, &storageLocation)
+// GetKeyByID récupère une clé par son ID, même archivée
+func (s *Store) GetKeyByID(id int) (*Key, error) {
+	k, err := scanKey(s.db().QueryRow(keySelect+` WHERE k.id = ?`, id))
 	if err != nil {
 		return nil, err
 	}
-	if storageLocation.Valid {
-		k.StorageLocation = storageLocation.String
-	}
 	return &k, nil
 }
 
-// CreateKey crée une nouvelle clé
-func CreateKey(k *Key, roomIDs []int) error {
-	tx, err := DB.Begin()
+// checkKeyParent refuse un passe-partout parent qui ferait boucler la hiérarchie
+// (la clé elle-même ou l'une de ses clés subordonnées)
+func checkKeyParent(tx *sql.Tx, k *Key) error {
+	if k.ParentKeyID == nil {
+		return nil
+	}
+	if k.ID != 0 && *k.ParentKeyID == k.ID {
+		return fmt.Errorf("une clé ne peut pas être son propre passe-partout")
+	}
+
+	var loops int
+	err := tx.QueryRow(`
+		WITH RECURSIVE ancestors(id, depth) AS (
+			SELECT ?, 0
+			UNION
+			SELECT k.parent_key_id, a.depth + 1 FROM keys k
+			JOIN ancestors a ON k.id = a.id
+			WHERE k.parent_key_id IS NOT NULL AND a.depth < ?
+		)
+		SELECT COUNT(*) FROM ancestors WHERE id = ?`, *k.ParentKeyID, maxKeyDepth, k.ID).Scan(&loops)
+	if err != nil {
+		return err
+	}
+	if loops > 0 {
+		return fmt.Errorf("le passe-partout choisi est déjà subordonné à la clé %s", k.Number)
+	}
+	return nil
+}
+
+// checkKeyLocation vérifie que l'emplacement choisi appartient au site de la clé et
+// recopie son nom dans k.StorageLocation (sans emplacement, le nom est vidé)
+func checkKeyLocation(tx *sql.Tx, k *Key, siteID int) error {
+	if k.StorageLocationID == nil {
+		k.StorageLocation = ""
+		return nil
+	}
+	var locationSite int
+	err := tx.QueryRow(`SELECT name, site_id FROM storage_locations WHERE id = ?`, *k.StorageLocationID).Scan(&k.StorageLocation, &locationSite)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("l'emplacement de rangement choisi n'existe plus")
+	}
+	if err != nil {
+		return err
+	}
+	if locationSite != siteID {
+		return fmt.Errorf("l'emplacement %s appartient à un autre site", k.StorageLocation)
+	}
+	return nil
+}
+
+// CreateKey crée une nouvelle clé et ses exemplaires dans le site sélectionné
+func (s *Store) CreateKey(k *Key, roomIDs []int) error {
+	siteID, err := s.createSite()
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.beginWrite()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	result, err := tx.Exec(`INSERT INTO keys (number, description, quantity_total, quantity_reserve, storage_location) VALUES (?, ?, ?, ?, ?)`,
-		k.Number, k.Description, k.QuantityTotal, k.QuantityReserve, k.StorageLocation)
+	if err := checkKeyLocation(tx, k, siteID); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(`INSERT INTO keys (number, description, storage_location, storage_location_id, default_loan_days, parent_key_id, site_id) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		k.Number, k.Description, k.StorageLocation, k.StorageLocationID, k.DefaultLoanDays, k.ParentKeyID, siteID)
 	if err != nil {
 		return err
 	}
@@ -67,6 +157,11 @@ func CreateKey(k *Key, roomIDs []int) error {
 	}
 	k.ID = int(keyID)
 
+	// Créer les exemplaires correspondant aux quantités saisies
+	if err := s.stockNewKey(tx, k); err != nil {
+		return err
+	}
+
 	// Associer les salles
 	for _, roomID := range roomIDs {
 		_, err = tx.Exec(`INSERT INTO key_room_association (key_id, room_id) VALUES (?, ?)`, keyID, roomID)
@@ -75,23 +170,61 @@ func CreateKey(k *Key, roomIDs []int) error {
 		}
 	}
 
+	after, err := snapshotRow(tx, AuditEntityKey, k.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.audit(tx, AuditCreate, AuditEntityKey, k.ID, fmt.Sprintf("Clé %s créée", k.Number), nil, after); err != nil {
+		return err
+	}
+	if err := s.saveCustomValues(tx, AuditEntityKey, k.ID, "Clé "+k.Number, k.CustomValues); err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
 
-// UpdateKey met à jour une clé
-func UpdateKey(k *Key, roomIDs []int) error {
-	tx, err := DB.Begin()
+// UpdateKey met à jour une clé. Les quantités ne sont pas modifiées : elles découlent
+// du registre de stock (RecordStockMovement).
+func (s *Store) UpdateKey(k *Key, roomIDs []int) error {
+	tx, err := s.beginWrite()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	_, err = tx.Exec(`UPDATE keys SET number = ?, description = ?, quantity_total = ?, quantity_reserve = ?, storage_location = ? WHERE id = ?`,
-		k.Number, k.Description, k.QuantityTotal, k.QuantityReserve, k.StorageLocation, k.ID)
+	before, err := snapshotRow(tx, AuditEntityKey, k.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := checkKeyParent(tx, k); err != nil {
+		return err
+	}
+
+	var siteID int
+	var previousLocation sql.NullInt64
+	err = tx.QueryRow(`SELECT site_id, storage_location_id FROM keys WHERE id = ?`, k.ID).Scan(&siteID, &previousLocation)
+	if err != nil {
+		return err
+	}
+	if err := checkKeyLocation(tx, k, siteID); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE keys SET number = ?, description = ?, storage_location = ?, storage_location_id = ?, default_loan_days = ?, parent_key_id = ? WHERE id = ?`,
+		k.Number, k.Description, k.StorageLocation, k.StorageLocationID, k.DefaultLoanDays, k.ParentKeyID, k.ID)
 	if err != nil {
 		return err
 	}
 
+	// Les crochets appartiennent à l'ancienne armoire
+	if k.StorageLocationID == nil || !previousLocation.Valid || int(previousLocation.Int64) != *k.StorageLocationID {
+		if _, err := tx.Exec(`UPDATE key_copies SET hook_number = NULL WHERE key_id = ?`, k.ID); err != nil {
+			return err
+		}
+	}
+
 	// Supprimer les anciennes associations
 	_, err = tx.Exec(`DELETE FROM key_room_association WHERE key_id = ?`, k.ID)
 	if err != nil {
@@ -106,23 +239,72 @@ func UpdateKey(k *Key, roomIDs []int) error {
 		}
 	}
 
+	after, err := snapshotRow(tx, AuditEntityKey, k.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.audit(tx, AuditUpdate, AuditEntityKey, k.ID, fmt.Sprintf("Clé %s modifiée", k.Number), before, after); err != nil {
+		return err
+	}
+	if err := s.saveCustomValues(tx, AuditEntityKey, k.ID, "Clé "+k.Number, k.CustomValues); err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
 
-// DeleteKey supprime une clé
-func DeleteKey(id int) error {
-	_, err := DB.Exec(`DELETE FROM keys WHERE id = ?`, id)
+// purgeKey supprime dans tx une clé, ses exemplaires, ses autorisations, ses réservations,
+// ses pièces jointes et tout son historique d'emprunts
+func purgeKey(tx *sql.Tx, id int) error {
+	if err := purgeLoans(tx, `key_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM stock_movements WHERE key_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM key_copies WHERE key_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM key_authorizations WHERE key_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM key_room_association WHERE key_id = ?`, id); err != nil {
+		return err
+	}
+	if err := purgeCustomValues(tx, AuditEntityKey, id); err != nil {
+		return err
+	}
+	if err := purgeAttachments(tx, AuditEntityKey, `?`, id); err != nil {
+		return err
+	}
+	// Les clés subordonnées remontent sous le passe-partout de la clé supprimée
+	if _, err := tx.Exec(`UPDATE keys SET parent_key_id = (SELECT parent_key_id FROM keys WHERE id = ?) WHERE parent_key_id = ?`, id, id); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`DELETE FROM keys WHERE id = ?`, id)
 	return err
 }
 
-// GetRoomsForKey récupère les salles associées à une clé
-func GetRoomsForKey(keyID int) ([]Room, error) {
-	rows, err := DB.Query(`
-		SELECT r.id, r.name, r.type, r.building_id 
-		FROM rooms r
-		INNER JOIN key_room_association kra ON r.id = kra.room_id
-		WHERE kra.key_id = ?
-		ORDER BY r.name`, keyID)
+// GetRoomsForKey récupère les salles qu'ouvre une clé : celles qui lui sont associées
+// (accès direct) et celles de ses clés subordonnées (accès hérité d'un passe-partout)
+func (s *Store) GetRoomsForKey(keyID int) ([]Room, error) {
+	// Pour chaque salle, la ligne de plus faible profondeur donne la raison de l'accès
+	rows, err := s.db().Query(`
+		WITH RECURSIVE descendants(id, depth) AS (
+			SELECT ?, 0
+			UNION
+			SELECT k.id, d.depth + 1 FROM keys k
+			JOIN descendants d ON k.parent_key_id = d.id
+			WHERE d.depth < ?
+		)
+		SELECT r.id, r.name, r.type, r.building_id, MIN(d.depth), k.number
+		FROM descendants d
+		INNER JOIN key_room_association kra ON kra.key_id = d.id
+		INNER JOIN keys k ON k.id = d.id
+		INNER JOIN rooms r ON r.id = kra.room_id
+		WHERE r.archived_at IS NULL
+		GROUP BY r.id
+		ORDER BY r.name`, keyID, maxKeyDepth)
 	if err != nil {
 		return nil, err
 	}
@@ -132,13 +314,20 @@ func GetRoomsForKey(keyID int) ([]Room, error) {
 	for rows.Next() {
 		var r Room
 		var roomType sql.NullString
-		err := rows.Scan(&r.ID, &r.Name, &roomType, &r.BuildingID)
+		var depth int
+		var via string
+		err := rows.Scan(&r.ID, &r.Name, &roomType, &r.BuildingID, &depth, &via)
 		if err != nil {
 			return nil, err
 		}
 		if roomType.Valid {
 			r.Type = roomType.String
 		}
+		r.Access = AccessDirect
+		if depth > 0 {
+			r.Access = AccessInherited
+			r.AccessVia = via
+		}
 		rooms = append(rooms, r)
 	}
 	return rooms, rows.Err()
@@ -146,9 +335,43 @@ func GetRoomsForKey(keyID int) ([]Room, error) {
 
 // ============= BORROWERS =============
 
-// GetAllBorrowers récupère tous les emprunteurs
-func GetAllBorrowers() ([]Borrower, error) {
-	rows, err := DB.Query(`SELECT id, name, email FROM borrowers ORDER BY name`)
+// borrowerSelect est la requête commune aux lectures d'emprunteurs
+const borrowerSelect = `SELECT id, name, first_name, last_name, email, phone, badge_number, department,
+		status, start_date, end_date, notes, archived_at FROM borrowers`
+
+// scanBorrower lit une ligne produite par borrowerSelect
+func scanBorrower(row rowScanner) (Borrower, error) {
+	var b Borrower
+	var firstName, lastName, email, phone, badge, department, notes sql.NullString
+	var startDate, endDate, archivedAt sql.NullTime
+	err := row.Scan(&b.ID, &b.Name, &firstName, &lastName, &email, &phone, &badge, &department,
+		&b.Status, &startDate, &endDate, &notes, &archivedAt)
+	if err != nil {
+		return b, err
+	}
+	b.FirstName = firstName.String
+	b.LastName = lastName.String
+	b.Email = email.String
+	b.Phone = phone.String
+	b.BadgeNumber = badge.String
+	b.Department = department.String
+	b.Notes = notes.String
+	if startDate.Valid {
+		b.StartDate = &startDate.Time
+	}
+	if endDate.Valid {
+		b.EndDate = &endDate.Time
+	}
+	if archivedAt.Valid {
+		b.ArchivedAt = &archivedAt.Time
+	}
+	return b, nil
+}
+
+// GetAllBorrowers récupère tous les emprunteurs du site sélectionné, hors archives
+func (s *Store) GetAllBorrowers() ([]Borrower, error) {
+	site, args := s.siteCondition("site_id")
+	rows, err := s.db().Query(borrowerSelect+` WHERE archived_at IS NULL`+site+` ORDER BY name`, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -156,38 +379,46 @@ func GetAllBorrowers() ([]Borrower, error) {
 
 	var borrowers []Borrower
 	for rows.Next() {
-		var b Borrower
-		var email sql.NullString
-		err := rows.Scan(&b.ID, &b.Name, &email)
+		b, err := scanBorrower(rows)
 		if err != nil {
 			return nil, err
 		}
-		if email.Valid {
-			b.Email = email.String
-		}
 		borrowers = append(borrowers, b)
 	}
 	return borrowers, rows.Err()
 }
 
-// GetBorrowerByID récupère un emprunteur par son ID
-func GetBorrowerByID(id int) (*Borrower, error) {
-	var b Borrower
-	var email sql.NullString
-	err := DB.QueryRow(`SELECT id, name, email FROM borrowers WHERE id = ?`, id).
-		Scan(&b.ID, &b.Name, &email)
+// GetBorrowerByID récupère un emprunteur par son ID, même archivé
+func (s *Store) GetBorrowerByID(id int) (*Borrower, error) {
+	b, err := scanBorrower(s.db().QueryRow(borrowerSelect+` WHERE id = ?`, id))
 	if err != nil {
 		return nil, err
 	}
-	if email.Valid {
-		b.Email = email.String
-	}
 	return &b, nil
 }
 
-// CreateBorrower crée un nouvel emprunteur
-func CreateBorrower(b *Borrower) error {
-	result, err := DB.Exec(`INSERT INTO borrowers (name, email) VALUES (?, ?)`, b.Name, b.Email)
+// CreateBorrower crée un nouvel emprunteur dans le site sélectionné. Son nom affiché
+// est calculé à partir du prénom et du nom.
+func (s *Store) CreateBorrower(b *Borrower) error {
+	b.Name = b.FullName()
+	if b.Status == "" {
+		b.Status = BorrowerActive
+	}
+	siteID, err := s.createSite()
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`INSERT INTO borrowers (name, first_name, last_name, email, phone, badge_number, department,
+		status, start_date, end_date, notes, site_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		b.Name, b.FirstName, b.LastName, b.Email, b.Phone, b.BadgeNumber, b.Department,
+		b.Status, b.StartDate, b.EndDate, b.Notes, siteID)
 	if err != nil {
 		return err
 	}
@@ -196,26 +427,134 @@ func CreateBorrower(b *Borrower) error {
 		return err
 	}
 	b.ID = int(id)
-	return nil
+
+	if err := s.auditInsert(tx, AuditEntityBorrower, b.ID, "Emprunteur "+b.Name+" créé"); err != nil {
+		return err
+	}
+	if err := s.saveCustomValues(tx, AuditEntityBorrower, b.ID, "Emprunteur "+b.Name, b.CustomValues); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 // UpdateBorrower met à jour un emprunteur
-func UpdateBorrower(b *Borrower) error {
-	_, err := DB.Exec(`UPDATE borrowers SET name = ?, email = ? WHERE id = ?`, b.Name, b.Email, b.ID)
-	return err
+func (s *Store) UpdateBorrower(b *Borrower) error {
+	b.Name = b.FullName()
+	if b.Status == "" {
+		b.Status = BorrowerActive
+	}
+
+	tx, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := snapshotRow(tx, AuditEntityBorrower, b.ID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE borrowers SET name = ?, first_name = ?, last_name = ?, email = ?, phone = ?, badge_number = ?,
+		department = ?, status = ?, start_date = ?, end_date = ?, notes = ? WHERE id = ?`,
+		b.Name, b.FirstName, b.LastName, b.Email, b.Phone, b.BadgeNumber,
+		b.Department, b.Status, b.StartDate, b.EndDate, b.Notes, b.ID)
+	if err != nil {
+		return err
+	}
+	after, err := snapshotRow(tx, AuditEntityBorrower, b.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.audit(tx, AuditUpdate, AuditEntityBorrower, b.ID, "Emprunteur "+b.Name+" modifié", before, after); err != nil {
+		return err
+	}
+	if err := s.saveCustomValues(tx, AuditEntityBorrower, b.ID, "Emprunteur "+b.Name, b.CustomValues); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-// DeleteBorrower supprime un emprunteur
-func DeleteBorrower(id int) error {
-	_, err := DB.Exec(`DELETE FROM borrowers WHERE id = ?`, id)
+// purgeBorrower supprime dans tx un emprunteur, ses autorisations nominatives, ses
+// réservations, ses pièces jointes et tout son historique d'emprunts
+func purgeBorrower(tx *sql.Tx, id int) error {
+	if err := purgeLoans(tx, `borrower_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM key_authorizations WHERE borrower_id = ?`, id); err != nil {
+		return err
+	}
+	if err := purgeCustomValues(tx, AuditEntityBorrower, id); err != nil {
+		return err
+	}
+	if err := purgeAttachments(tx, AuditEntityBorrower, `?`, id); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`DELETE FROM borrowers WHERE id = ?`, id)
 	return err
 }
 
+// splitName sépare un nom complet en prénom et nom. Les mots entièrement en
+// majuscules au début sont lus comme le nom de famille (« DUPONT Jean »), sinon
+// le premier mot est le prénom (« Jean Dupont »). Un seul mot est un nom de famille.
+func splitName(name string) (firstName, lastName string) {
+	words := strings.Fields(name)
+	if len(words) == 0 {
+		return "", ""
+	}
+	if len(words) == 1 {
+		return "", words[0]
+	}
+
+	upper := 0
+	for upper < len(words)-1 && len([]rune(words[upper])) > 1 && words[upper] == strings.ToUpper(words[upper]) &&
+		words[upper] != strings.ToLower(words[upper]) {
+		upper++
+	}
+	if upper > 0 {
+		return strings.Join(words[upper:], " "), strings.Join(words[:upper], " ")
+	}
+	return words[0], strings.Join(words[1:], " ")
+}
+
+// splitBorrowerNames renseigne le prénom et le nom des emprunteurs qui n'ont
+// que le nom complet (bases antérieures, importation de la version Python)
+func splitBorrowerNames(tx *sql.Tx) error {
+	rows, err := tx.Query(`SELECT id, name FROM borrowers
+		WHERE COALESCE(first_name, '') = '' AND COALESCE(last_name, '') = ''`)
+	if err != nil {
+		return err
+	}
+	names := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			rows.Close()
+			return err
+		}
+		names[id] = name
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, name := range names {
+		firstName, lastName := splitName(name)
+		_, err := tx.Exec(`UPDATE borrowers SET first_name = ?, last_name = ? WHERE id = ?`, firstName, lastName, id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // ============= BUILDINGS =============
 
-// GetAllBuildings récupère tous les bâtiments
-func GetAllBuildings() ([]Building, error) {
-	rows, err := DB.Query(`SELECT id, name FROM buildings ORDER BY name`)
+// GetAllBuildings récupère tous les bâtiments en service (hors archives) du site sélectionné
+func (s *Store) GetAllBuildings() ([]Building, error) {
+	site, args := s.siteCondition("site_id")
+	rows, err := s.db().Query(`SELECT id, name FROM buildings WHERE archived_at IS NULL`+site+` ORDER BY name`, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -233,19 +572,34 @@ func GetAllBuildings() ([]Building, error) {
 	return buildings, rows.Err()
 }
 
-// GetBuildingByID récupère un bâtiment par son ID
-func GetBuildingByID(id int) (*Building, error) {
+// GetBuildingByID récupère un bâtiment par son ID, même archivé
+func (s *Store) GetBuildingByID(id int) (*Building, error) {
 	var b Building
-	err := DB.QueryRow(`SELECT id, name FROM buildings WHERE id = ?`, id).Scan(&b.ID, &b.Name)
+	var archivedAt sql.NullTime
+	err := s.db().QueryRow(`SELECT id, name, archived_at FROM buildings WHERE id = ?`, id).Scan(&b.ID, &b.Name, &archivedAt)
 	if err != nil {
 		return nil, err
 	}
+	if archivedAt.Valid {
+		b.ArchivedAt = &archivedAt.Time
+	}
 	return &b, nil
 }
 
-// CreateBuilding crée un nouveau bâtiment
-func CreateBuilding(b *Building) error {
-	result, err := DB.Exec(`INSERT INTO buildings (name) VALUES (?)`, b.Name)
+// CreateBuilding crée un nouveau bâtiment dans le site sélectionné
+func (s *Store) CreateBuilding(b *Building) error {
+	siteID, err := s.createSite()
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`INSERT INTO buildings (name, site_id) VALUES (?, ?)`, b.Name, siteID)
 	if err != nil {
 		return err
 	}
@@ -254,26 +608,72 @@ func CreateBuilding(b *Building) error {
 		return err
 	}
 	b.ID = int(id)
-	return nil
+
+	if err := s.auditInsert(tx, AuditEntityBuilding, b.ID, "Bâtiment "+b.Name+" créé"); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 // UpdateBuilding met à jour un bâtiment
-func UpdateBuilding(b *Building) error {
-	_, err := DB.Exec(`UPDATE buildings SET name = ? WHERE id = ?`, b.Name, b.ID)
-	return err
+func (s *Store) UpdateBuilding(b *Building) error {
+	return s.auditedUpdate(AuditEntityBuilding, b.ID, "Bâtiment "+b.Name+" modifié",
+		`UPDATE buildings SET name = ? WHERE id = ?`, b.Name, b.ID)
 }
 
-// DeleteBuilding supprime un bâtiment
-func DeleteBuilding(id int) error {
-	_, err := DB.Exec(`DELETE FROM buildings WHERE id = ?`, id)
+// purgeBuilding supprime dans tx un bâtiment qui ne contient plus aucune salle, même archivée,
+// avec ses étages
+func purgeBuilding(tx *sql.Tx, id int) error {
+	var rooms int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM rooms WHERE building_id = ?`, id).Scan(&rooms); err != nil {
+		return err
+	}
+	if rooms > 0 {
+		return fmt.Errorf("le bâtiment contient encore %d salle(s) : supprimez-les d'abord définitivement", rooms)
+	}
+	if _, err := tx.Exec(`DELETE FROM floors WHERE building_id = ?`, id); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`DELETE FROM buildings WHERE id = ?`, id)
 	return err
 }
 
 // ============= ROOMS =============
 
-// GetAllRooms récupère toutes les salles
-func GetAllRooms() ([]Room, error) {
-	rows, err := DB.Query(`SELECT id, name, type, building_id FROM rooms ORDER BY name`)
+// roomSelect est la requête commune aux lectures de salles, avec leur étage et leur zone
+const roomSelect = `
+		SELECT r.id, r.name, r.type, r.building_id, r.floor_id, f.name, f.level, r.zone_id, z.name
+		FROM rooms r
+		LEFT JOIN floors f ON f.id = r.floor_id
+		LEFT JOIN zones z ON z.id = r.zone_id`
+
+// scanRoom lit une ligne de roomSelect
+func scanRoom(row rowScanner) (Room, error) {
+	var r Room
+	var roomType, floor, zone sql.NullString
+	var floorID, floorLevel, zoneID sql.NullInt64
+	err := row.Scan(&r.ID, &r.Name, &roomType, &r.BuildingID, &floorID, &floor, &floorLevel, &zoneID, &zone)
+	if err != nil {
+		return r, err
+	}
+	r.Type = roomType.String
+	if floorID.Valid {
+		id := int(floorID.Int64)
+		r.FloorID = &id
+		r.Floor = floor.String
+		r.FloorLevel = int(floorLevel.Int64)
+	}
+	if zoneID.Valid {
+		id := int(zoneID.Int64)
+		r.ZoneID = &id
+		r.Zone = zone.String
+	}
+	return r, nil
+}
+
+// queryRooms exécute une requête basée sur roomSelect
+func (s *Store) queryRooms(query string, args ...interface{}) ([]Room, error) {
+	rows, err := s.db().Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -281,47 +681,80 @@ func GetAllRooms() ([]Room, error) {
 
 	var rooms []Room
 	for rows.Next() {
-		var r Room
-		var roomType sql.NullString
-		err := rows.Scan(&r.ID, &r.Name, &roomType, &r.BuildingID)
+		r, err := scanRoom(rows)
 		if err != nil {
 			return nil, err
 		}
-		if roomType.Valid {
-			r.Type = roomType.String
-		}
 		rooms = append(rooms, r)
 	}
 	return rooms, rows.Err()
 }
 
-// GetRoomsByBuildingID récupère les salles d'un bâtiment
-func GetRoomsByBuildingID(buildingID int) ([]Room, error) {
-	rows, err := DB.Query(`SELECT id, name, type, building_id FROM rooms WHERE building_id = ? ORDER BY name`, buildingID)
+// GetAllRooms récupère toutes les salles en service (hors archives) des bâtiments du site sélectionné
+func (s *Store) GetAllRooms() ([]Room, error) {
+	site, args := s.siteCondition("(SELECT site_id FROM buildings WHERE id = r.building_id)")
+	return s.queryRooms(roomSelect+` WHERE r.archived_at IS NULL`+site+` ORDER BY r.name`, args...)
+}
+
+// GetRoomsByBuildingID récupère les salles en service d'un bâtiment
+func (s *Store) GetRoomsByBuildingID(buildingID int) ([]Room, error) {
+	return s.queryRooms(roomSelect+` WHERE r.building_id = ? AND r.archived_at IS NULL ORDER BY r.name`, buildingID)
+}
+
+// checkRoomPlacement vérifie que le bâtiment d'une salle appartient au site siteID,
+// que son étage appartient à ce bâtiment et sa zone à ce site
+func checkRoomPlacement(tx *sql.Tx, r *Room, siteID int) error {
+	var buildingSite int
+	err := tx.QueryRow(`SELECT site_id FROM buildings WHERE id = ?`, r.BuildingID).Scan(&buildingSite)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("bâtiment introuvable: %w", err)
 	}
-	defer rows.Close()
-
-	var rooms []Room
-	for rows.Next() {
-		var r Room
-		var roomType sql.NullString
-		err := rows.Scan(&r.ID, &r.Name, &roomType, &r.BuildingID)
+	if buildingSite != siteID {
+		return fmt.Errorf("le bâtiment choisi appartient à un autre site")
+	}
+	if r.FloorID != nil {
+		var buildingID int
+		err := tx.QueryRow(`SELECT building_id FROM floors WHERE id = ?`, *r.FloorID).Scan(&buildingID)
 		if err != nil {
-			return nil, err
+			return fmt.Errorf("étage introuvable: %w", err)
 		}
-		if roomType.Valid {
-			r.Type = roomType.String
+		if buildingID != r.BuildingID {
+			return fmt.Errorf("l'étage choisi n'appartient pas au bâtiment de la salle")
 		}
-		rooms = append(rooms, r)
 	}
-	return rooms, rows.Err()
+	if r.ZoneID != nil {
+		var sameSite bool
+		err := tx.QueryRow(`SELECT z.site_id = b.site_id FROM zones z, buildings b WHERE z.id = ? AND b.id = ?`,
+			*r.ZoneID, r.BuildingID).Scan(&sameSite)
+		if err != nil {
+			return fmt.Errorf("zone introuvable: %w", err)
+		}
+		if !sameSite {
+			return fmt.Errorf("la zone choisie n'appartient pas au site du bâtiment")
+		}
+	}
+	return nil
 }
 
-// CreateRoom crée une nouvelle salle
-func CreateRoom(r *Room) error {
-	result, err := DB.Exec(`INSERT INTO rooms (name, type, building_id) VALUES (?, ?, ?)`, r.Name, r.Type, r.BuildingID)
+// CreateRoom crée une nouvelle salle dans un bâtiment du site sélectionné
+func (s *Store) CreateRoom(r *Room) error {
+	siteID, err := s.createSite()
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkRoomPlacement(tx, r, siteID); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(`INSERT INTO rooms (name, type, building_id, floor_id, zone_id) VALUES (?, ?, ?, ?, ?)`,
+		r.Name, r.Type, r.BuildingID, r.FloorID, r.ZoneID)
 	if err != nil {
 		return err
 	}
@@ -330,33 +763,151 @@ func CreateRoom(r *Room) error {
 		return err
 	}
 	r.ID = int(id)
-	return nil
-}
-
-// UpdateRoom met à jour une salle
-func UpdateRoom(r *Room) error {
-	_, err := DB.Exec(`UPDATE rooms SET name = ?, type = ?, building_id = ? WHERE id = ?`, r.Name, r.Type, r.BuildingID, r.ID)
-	return err
-}
 
-// DeleteRoom supprime une salle
-func DeleteRoom(id int) error {
-	_, err := DB.Exec(`DELETE FROM rooms WHERE id = ?`, id)
-	return err
+	if err := s.auditInsert(tx, AuditEntityRoom, r.ID, "Salle "+r.Name+" créée"); err != nil {
+		return err
+	}
+	if err := s.saveCustomValues(tx, AuditEntityRoom, r.ID, "Salle "+r.Name, r.CustomValues); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateRoom met à jour une salle ; elle ne peut pas changer de site
+func (s *Store) UpdateRoom(r *Room) error {
+	tx, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := snapshotRow(tx, AuditEntityRoom, r.ID)
+	if err != nil {
+		return err
+	}
+	if before == nil {
+		return sql.ErrNoRows
+	}
+	var siteID int
+	err = tx.QueryRow(`SELECT b.site_id FROM rooms r INNER JOIN buildings b ON b.id = r.building_id WHERE r.id = ?`, r.ID).Scan(&siteID)
+	if err != nil {
+		return err
+	}
+	if err := checkRoomPlacement(tx, r, siteID); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE rooms SET name = ?, type = ?, building_id = ?, floor_id = ?, zone_id = ? WHERE id = ?`,
+		r.Name, r.Type, r.BuildingID, r.FloorID, r.ZoneID, r.ID)
+	if err != nil {
+		return err
+	}
+
+	after, err := snapshotRow(tx, AuditEntityRoom, r.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.audit(tx, AuditUpdate, AuditEntityRoom, r.ID, "Salle "+r.Name+" modifiée", before, after); err != nil {
+		return err
+	}
+	if err := s.saveCustomValues(tx, AuditEntityRoom, r.ID, "Salle "+r.Name, r.CustomValues); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// purgeRoom supprime dans tx une salle, ses associations aux clés, ses champs personnalisés
+// et ses pièces jointes
+func purgeRoom(tx *sql.Tx, id int) error {
+	if _, err := tx.Exec(`DELETE FROM key_room_association WHERE room_id = ?`, id); err != nil {
+		return err
+	}
+	if err := purgeCustomValues(tx, AuditEntityRoom, id); err != nil {
+		return err
+	}
+	if err := purgeAttachments(tx, AuditEntityRoom, `?`, id); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`DELETE FROM rooms WHERE id = ?`, id)
+	return err
 }
 
 // ============= LOANS =============
 
-// GetAllActiveLoans récupère tous les emprunts actifs
-func GetAllActiveLoans() ([]LoanWithDetails, error) {
-	rows, err := DB.Query(`
-		SELECT l.id, l.key_id, l.borrower_id, l.loan_date, l.return_date,
-		       k.number, k.description, b.name, b.email
+// loanDetailsSelect est la requête commune à toutes les lectures d'emprunts avec détails
+const loanDetailsSelect = `
+		SELECT l.id, l.key_id, l.borrower_id, l.loan_date, l.return_date, l.due_date, l.copy_id,
+		       l.loaned_by, l.returned_by, k.number, k.description, b.name, b.email,
+		       b.badge_number, b.department, c.identifier, l.loss_status, l.loss_notes,
+		       l.deposit_amount, l.deposit_method, l.deposit_status, l.deposit_notes,
+		       l.transferred_from_loan_id,
+		       (SELECT pb.name FROM loans pl INNER JOIN borrowers pb ON pb.id = pl.borrower_id
+		        WHERE pl.id = l.transferred_from_loan_id),
+		       (SELECT nb.name FROM loans nl INNER JOIN borrowers nb ON nb.id = nl.borrower_id
+		        WHERE nl.transferred_from_loan_id = l.id)
 		FROM loans l
 		INNER JOIN keys k ON l.key_id = k.id
 		INNER JOIN borrowers b ON l.borrower_id = b.id
-		WHERE l.return_date IS NULL
-		ORDER BY b.name, l.loan_date`)
+		LEFT JOIN key_copies c ON l.copy_id = c.id`
+
+// rowScanner est satisfait par *sql.Row et *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanLoanWithDetails lit une ligne produite par loanDetailsSelect
+func scanLoanWithDetails(row rowScanner) (LoanWithDetails, error) {
+	var l LoanWithDetails
+	var returnDate, dueDate sql.NullTime
+	var copyID sql.NullInt64
+	var email, badge, department, copyIdentifier, loanedBy, returnedBy, lossStatus, lossNotes sql.NullString
+	var depositAmount sql.NullFloat64
+	var depositMethod, depositStatus, depositNotes sql.NullString
+	var transferredFromLoanID sql.NullInt64
+	var transferredFrom, transferredTo sql.NullString
+	err := row.Scan(&l.ID, &l.KeyID, &l.BorrowerID, &l.LoanDate, &returnDate, &dueDate, &copyID,
+		&loanedBy, &returnedBy, &l.KeyNumber, &l.KeyDescription, &l.BorrowerName, &email, &badge, &department, &copyIdentifier,
+		&lossStatus, &lossNotes, &depositAmount, &depositMethod, &depositStatus, &depositNotes,
+		&transferredFromLoanID, &transferredFrom, &transferredTo)
+	if err != nil {
+		return l, err
+	}
+	if copyID.Valid {
+		id := int(copyID.Int64)
+		l.CopyID = &id
+	}
+	l.CopyIdentifier = copyIdentifier.String
+	l.BorrowerBadgeNumber = badge.String
+	l.BorrowerDepartment = department.String
+	l.LoanedBy = loanedBy.String
+	l.ReturnedBy = returnedBy.String
+	l.LossStatus = CopyStatus(lossStatus.String)
+	l.LossNotes = lossNotes.String
+	l.DepositAmount = depositAmount.Float64
+	l.DepositMethod = DepositMethod(depositMethod.String)
+	l.DepositStatus = DepositStatus(depositStatus.String)
+	l.DepositNotes = depositNotes.String
+	if transferredFromLoanID.Valid {
+		id := int(transferredFromLoanID.Int64)
+		l.TransferredFromLoanID = &id
+	}
+	l.TransferredFrom = transferredFrom.String
+	l.TransferredTo = transferredTo.String
+	if returnDate.Valid {
+		l.ReturnDate = &returnDate.Time
+	}
+	if dueDate.Valid {
+		l.DueDate = &dueDate.Time
+	}
+	if email.Valid {
+		l.BorrowerEmail = email.String
+	}
+	return l, nil
+}
+
+// queryLoansWithDetails exécute une requête basée sur loanDetailsSelect
+func (s *Store) queryLoansWithDetails(query string, args ...interface{}) ([]LoanWithDetails, error) {
+	rows, err := s.db().Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -364,198 +915,389 @@ func GetAllActiveLoans() ([]LoanWithDetails, error) {
 
 	var loans []LoanWithDetails
 	for rows.Next() {
-		var l LoanWithDetails
-		var returnDate sql.NullTime
-		var email sql.NullString
-		err := rows.Scan(&l.ID, &l.KeyID, &l.BorrowerID, &l.LoanDate, &returnDate,
-			&l.KeyNumber, &l.KeyDescription, &l.BorrowerName, &email)
+		l, err := scanLoanWithDetails(rows)
 		if err != nil {
 			return nil, err
 		}
-		if returnDate.Valid {
-			l.ReturnDate = &returnDate.Time
-		}
-		if email.Valid {
-			l.BorrowerEmail = email.String
-		}
 		loans = append(loans, l)
 	}
 	return loans, rows.Err()
 }
 
+// GetAllActiveLoans récupère tous les emprunts actifs des clés du site sélectionné
+func (s *Store) GetAllActiveLoans() ([]LoanWithDetails, error) {
+	site, args := s.siteCondition("k.site_id")
+	return s.queryLoansWithDetails(loanDetailsSelect+`
+		WHERE l.return_date IS NULL`+site+`
+		ORDER BY b.name, l.loan_date`, args...)
+}
+
 // GetActiveLoansByKeyID récupère les emprunts actifs pour une clé
-func GetActiveLoansByKeyID(keyID int) ([]LoanWithDetails, error) {
-	rows, err := DB.Query(`
-		SELECT l.id, l.key_id, l.borrower_id, l.loan_date, l.return_date,
-		       k.number, k.description, b.name, b.email
-		FROM loans l
-		INNER JOIN keys k ON l.key_id = k.id
-		INNER JOIN borrowers b ON l.borrower_id = b.id
+func (s *Store) GetActiveLoansByKeyID(keyID int) ([]LoanWithDetails, error) {
+	return s.queryLoansWithDetails(loanDetailsSelect+`
 		WHERE l.key_id = ? AND l.return_date IS NULL
 		ORDER BY l.loan_date`, keyID)
+}
+
+// GetActiveLoansByBorrowerID récupère les emprunts actifs pour un emprunteur
+func (s *Store) GetActiveLoansByBorrowerID(borrowerID int) ([]LoanWithDetails, error) {
+	return s.queryLoansWithDetails(loanDetailsSelect+`
+		WHERE l.borrower_id = ? AND l.return_date IS NULL
+		ORDER BY l.loan_date`, borrowerID)
+}
+
+// GetOverdueLoans récupère les emprunts en cours du site sélectionné dont la date de retour
+// prévue est dépassée, du plus ancien retard au plus récent
+func (s *Store) GetOverdueLoans() ([]LoanWithDetails, error) {
+	site, args := s.siteCondition("k.site_id")
+	loans, err := s.queryLoansWithDetails(loanDetailsSelect+`
+		WHERE l.return_date IS NULL AND l.due_date IS NOT NULL`+site+`
+		ORDER BY l.due_date, b.name`, args...)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	var loans []LoanWithDetails
-	for rows.Next() {
-		var l LoanWithDetails
-		var returnDate sql.NullTime
-		var email sql.NullString
-		err := rows.Scan(&l.ID, &l.KeyID, &l.BorrowerID, &l.LoanDate, &returnDate,
-			&l.KeyNumber, &l.KeyDescription, &l.BorrowerName, &email)
-		if err != nil {
-			return nil, err
+	// La comparaison se fait en Go : les dates sont stockées avec leur fuseau horaire
+	now := time.Now()
+	var overdue []LoanWithDetails
+	for _, l := range loans {
+		if l.IsOverdue(now) {
+			overdue = append(overdue, l)
 		}
-		if returnDate.Valid {
-			l.ReturnDate = &returnDate.Time
-		}
-		if email.Valid {
-			l.BorrowerEmail = email.String
-		}
-		loans = append(loans, l)
 	}
-	return loans, rows.Err()
+	return overdue, nil
 }
 
-// GetActiveLoansByBorrowerID récupère les emprunts actifs pour un emprunteur
-func GetActiveLoansByBorrowerID(borrowerID int) ([]LoanWithDetails, error) {
-	rows, err := DB.Query(`
-		SELECT l.id, l.key_id, l.borrower_id, l.loan_date, l.return_date,
-		       k.number, k.description, b.name, b.email
-		FROM loans l
-		INNER JOIN keys k ON l.key_id = k.id
-		INNER JOIN borrowers b ON l.borrower_id = b.id
-		WHERE l.borrower_id = ? AND l.return_date IS NULL
-		ORDER BY l.loan_date`, borrowerID)
+// GetLoanByID récupère un emprunt par son ID, avec ses signatures
+func (s *Store) GetLoanByID(id int) (*LoanWithDetails, error) {
+	l, err := scanLoanWithDetails(s.db().QueryRow(loanDetailsSelect+`
+		WHERE l.id = ?`, id))
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	loans := []LoanWithDetails{l}
+	if err := s.LoadLoanSignatures(loans); err != nil {
+		return nil, err
+	}
+	return &loans[0], nil
+}
 
-	var loans []LoanWithDetails
-	for rows.Next() {
-		var l LoanWithDetails
-		var returnDate sql.NullTime
-		var email sql.NullString
-		err := rows.Scan(&l.ID, &l.KeyID, &l.BorrowerID, &l.LoanDate, &returnDate,
-			&l.KeyNumber, &l.KeyDescription, &l.BorrowerName, &email)
-		if err != nil {
-			return nil, err
-		}
-		if returnDate.Valid {
-			l.ReturnDate = &returnDate.Time
-		}
-		if email.Valid {
-			l.BorrowerEmail = email.String
-		}
-		loans = append(loans, l)
+// loanHistoryWhere construit la clause WHERE correspondant au filtre d'historique,
+// restreinte aux clés de siteID (0 : tous les sites)
+func loanHistoryWhere(f LoanHistoryFilter, siteID int) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if siteID > 0 {
+		conditions = append(conditions, "l.key_id IN (SELECT id FROM keys WHERE site_id = ?)")
+		args = append(args, siteID)
 	}
-	return loans, rows.Err()
+
+	if f.KeyID > 0 {
+		conditions = append(conditions, "l.key_id = ?")
+		args = append(args, f.KeyID)
+	}
+	if f.BorrowerID > 0 {
+		conditions = append(conditions, "l.borrower_id = ?")
+		args = append(args, f.BorrowerID)
+	}
+	if f.BuildingID > 0 {
+		conditions = append(conditions, `EXISTS (
+			SELECT 1 FROM key_room_association kra
+			INNER JOIN rooms r ON r.id = kra.room_id
+			WHERE kra.key_id = l.key_id AND r.building_id = ?)`)
+		args = append(args, f.BuildingID)
+	}
+	// Les 19 premiers caractères (AAAA-MM-JJ HH:MM:SS) sont communs aux dates
+	// écrites par l'application et à celles importées de la version Python
+	if f.From != nil {
+		conditions = append(conditions, "substr(l.loan_date, 1, 19) >= ?")
+		args = append(args, f.From.Format("2006-01-02 15:04:05"))
+	}
+	if f.To != nil {
+		conditions = append(conditions, "substr(l.loan_date, 1, 19) <= ?")
+		args = append(args, f.To.Format("2006-01-02 15:04:05"))
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return "\n\t\tWHERE " + strings.Join(conditions, " AND "), args
 }
 
-// GetLoanByID récupère un emprunt par son ID
-func GetLoanByID(id int) (*LoanWithDetails, error) {
-	var l LoanWithDetails
-	var returnDate sql.NullTime
-	var email sql.NullString
-	err := DB.QueryRow(`
-		SELECT l.id, l.key_id, l.borrower_id, l.loan_date, l.return_date,
-		       k.number, k.description, b.name, b.email
-		FROM loans l
-		INNER JOIN keys k ON l.key_id = k.id
-		INNER JOIN borrowers b ON l.borrower_id = b.id
-		WHERE l.id = ?`, id).
-		Scan(&l.ID, &l.KeyID, &l.BorrowerID, &l.LoanDate, &returnDate,
-			&l.KeyNumber, &l.KeyDescription, &l.BorrowerName, &email)
+// GetLoanHistory récupère les emprunts (rendus ou en cours) du site sélectionné correspondant
+// au filtre, du plus récent au plus ancien
+func (s *Store) GetLoanHistory(f LoanHistoryFilter) ([]LoanWithDetails, error) {
+	where, args := loanHistoryWhere(f, s.Site())
+	query := loanDetailsSelect + where + `
+		ORDER BY l.loan_date DESC, l.id DESC`
+	if f.Limit > 0 {
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, f.Limit, f.Offset)
+	}
+	return s.queryLoansWithDetails(query, args...)
+}
+
+// CountLoanHistory compte les emprunts correspondant au filtre (pour la pagination)
+func (s *Store) CountLoanHistory(f LoanHistoryFilter) (int, error) {
+	where, args := loanHistoryWhere(f, s.Site())
+	var count int
+	err := s.db().QueryRow(`SELECT COUNT(*) FROM loans l`+where, args...).Scan(&count)
+	return count, err
+}
+
+// GetLastLoanForKey récupère le dernier emprunt d'une clé, rendu ou non (nil si jamais empruntée)
+func (s *Store) GetLastLoanForKey(keyID int) (*LoanWithDetails, error) {
+	loans, err := s.GetLoanHistory(LoanHistoryFilter{KeyID: keyID, Limit: 1})
 	if err != nil {
 		return nil, err
 	}
-	if returnDate.Valid {
-		l.ReturnDate = &returnDate.Time
+	if len(loans) == 0 {
+		return nil, nil
 	}
-	if email.Valid {
-		l.BorrowerEmail = email.String
+	return &loans[0], nil
+}
+
+// DueDateAfter retourne la date de retour prévue pour un emprunt de days jours commencé à from.
+// L'échéance tombe en fin de journée : une clé due le 24 peut être rendue jusqu'au soir.
+func DueDateAfter(from time.Time, days int) time.Time {
+	y, m, d := from.AddDate(0, 0, days).Date()
+	return time.Date(y, m, d, 23, 59, 59, 0, from.Location())
+}
+
+// dueDateFor calcule la date de retour prévue d'un nouvel emprunt selon la durée par défaut de la clé
+func dueDateFor(defaultLoanDays int, from time.Time, opts LoanOptions) *time.Time {
+	if opts.DueDate != nil {
+		return opts.DueDate
+	}
+	if defaultLoanDays <= 0 {
+		return nil
 	}
-	return &l, nil
+	due := DueDateAfter(from, defaultLoanDays)
+	return &due
 }
 
-// CreateLoan crée un nouvel emprunt
-func CreateLoan(keyID, borrowerID int) error {
-	_, err := DB.Exec(`INSERT INTO loans (key_id, borrower_id, loan_date) VALUES (?, ?, ?)`,
-		keyID, borrowerID, time.Now())
-	return err
+// CreateLoan crée un nouvel emprunt avec la durée par défaut de la clé et son premier exemplaire disponible
+func (s *Store) CreateLoan(keyID, borrowerID int) error {
+	return s.CreateMultipleLoans([]int{keyID}, borrowerID, LoanOptions{})
 }
 
-// ReturnLoan marque un emprunt comme retourné
-func ReturnLoan(loanID int) error {
-	_, err := DB.Exec(`UPDATE loans SET return_date = ? WHERE id = ?`, time.Now(), loanID)
-	return err
+// ErrLoanReturned est retournée lorsque l'emprunt à clore a déjà été rendu, par exemple depuis un autre poste
+var ErrLoanReturned = errors.New("cet emprunt a déjà été rendu")
+
+// ReturnLoan marque un emprunt comme retourné et remet son exemplaire à disposition ;
+// signature est la signature de l'emprunteur au retour (PNG, nil si non signé)
+func (s *Store) ReturnLoan(loanID int, signature []byte) error {
+	return s.returnLoan(loanID, "", "", signature)
+}
+
+// ReturnLoanWithDeposit enregistre le retour d'une clé et, dans la même opération,
+// la restitution ou la retenue de sa caution (notes : motif de la retenue)
+func (s *Store) ReturnLoanWithDeposit(loanID int, settlement DepositStatus, notes string, signature []byte) error {
+	if settlement != DepositRefunded && settlement != DepositRetained {
+		return fmt.Errorf("la caution doit être restituée ou retenue")
+	}
+	return s.returnLoan(loanID, settlement, notes, signature)
+}
+
+// returnLoan clôt un emprunt ; une caution détenue est réglée selon settlement (vide = inchangée)
+func (s *Store) returnLoan(loanID int, settlement DepositStatus, notes string, signature []byte) error {
+	if signature != nil {
+		if err := checkSignature(signature); err != nil {
+			return err
+		}
+	}
+
+	tx, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := snapshotRow(tx, AuditEntityLoan, loanID)
+	if err != nil {
+		return err
+	}
+
+	// Un emprunt rendu entre-temps n'est ni rendu une seconde fois, ni réglé à nouveau
+	now := time.Now()
+	result, err := tx.Exec(`UPDATE loans SET return_date = ?, returned_by = ? WHERE id = ? AND return_date IS NULL`,
+		now, s.Operator(), loanID)
+	if err != nil {
+		return err
+	}
+	returned, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if returned == 0 {
+		return ErrLoanReturned
+	}
+	if signature != nil {
+		if err := saveSignature(tx, loanID, SignatureReturn, signature, now); err != nil {
+			return err
+		}
+	}
+
+	// Un exemplaire déclaré perdu entre-temps garde son état
+	_, err = tx.Exec(`UPDATE key_copies SET status = ?
+		WHERE id = (SELECT copy_id FROM loans WHERE id = ?) AND status = ?`,
+		CopyAvailable, loanID, CopyLoaned)
+	if err != nil {
+		return err
+	}
+
+	if settlement != "" {
+		if err := settleDepositTx(tx, loanID, settlement, notes); err != nil {
+			return err
+		}
+	}
+
+	after, err := snapshotRow(tx, AuditEntityLoan, loanID)
+	if err != nil {
+		return err
+	}
+	summary, err := loanAuditSummary(tx, "Retour", loanID)
+	if err != nil {
+		return err
+	}
+	if settlement != "" {
+		summary += " - caution " + strings.ToLower(settlement.Label())
+	}
+	if signature != nil {
+		summary += " - signé à l'écran"
+	}
+	if err := s.audit(tx, AuditReturn, AuditEntityLoan, loanID, summary, before, after); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// availableCopyCount compte les exemplaires d'une clé qu'un emprunt de durée par défaut
+// peut prendre maintenant : c'est la vérification de createLoanTx, réservations qui
+// chevauchent l'emprunt déduites
+func (s *Store) availableCopyCount(keyID int) (int, error) {
+	var loanDays int
+	if err := s.db().QueryRow(`SELECT default_loan_days FROM keys WHERE id = ?`, keyID).Scan(&loanDays); err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	count, err := freeCopyCount(s.db(), keyID, 0, now, loanPeriodEnd(dueDateFor(loanDays, now, LoanOptions{})))
+	if err != nil || count < 0 {
+		return 0, err
+	}
+	return count, nil
 }
 
 // GetActiveLoanCount récupère le nombre d'emprunts actifs pour une clé
-func GetActiveLoanCount(keyID int) (int, error) {
+func (s *Store) GetActiveLoanCount(keyID int) (int, error) {
 	var count int
-	err := DB.QueryRow(`SELECT COUNT(*) FROM loans WHERE key_id = ? AND return_date IS NULL`, keyID).Scan(&count)
+	err := s.db().QueryRow(`SELECT COUNT(*) FROM loans WHERE key_id = ? AND return_date IS NULL`, keyID).Scan(&count)
 	return count, err
 }
 
-// GetKeysWithAvailability récupère toutes les clés avec leurs informations de disponibilité
-func GetKeysWithAvailability() ([]KeyWithAvailability, error) {
-	keys, err := GetAllKeys()
+// keyAvailabilitySelect lit les clés avec, en une seule requête, le nombre d'emprunts
+// actifs et d'exemplaires disponibles (paramètre : CopyAvailable)
+const keyAvailabilitySelect = `SELECT ` + keyColumns + `,
+		       (SELECT COUNT(*) FROM loans l WHERE l.key_id = k.id AND l.return_date IS NULL),
+		       (SELECT COUNT(*) FROM key_copies c WHERE c.key_id = k.id AND c.status = ?)
+		FROM keys k
+		LEFT JOIN keys p ON p.id = k.parent_key_id
+		WHERE k.archived_at IS NULL`
+
+// queryKeysAvailability lit les clés du site sélectionné et leurs compteurs de disponibilité
+// à l'instant now. Les exemplaires disponibles sont comptés comme availableCopyCount : les
+// réservations qui chevauchent un emprunt de durée par défaut ne sont pas prêtables.
+func (s *Store) queryKeysAvailability(now time.Time) ([]KeyWithAvailability, error) {
+	reservations, err := s.pendingReservationPeriods(now)
 	if err != nil {
 		return nil, err
 	}
 
-	var result []KeyWithAvailability
-	for _, key := range keys {
-		kwa := KeyWithAvailability{Key: key}
+	site, siteArgs := s.siteCondition("k.site_id")
+	args := append([]interface{}{CopyAvailable}, siteArgs...)
+	rows, err := s.db().Query(keyAvailabilitySelect+site+` ORDER BY k.number`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-		// Compter les emprunts actifs
-		count, err := GetActiveLoanCount(key.ID)
+	var result []KeyWithAvailability
+	for rows.Next() {
+		var available int
+		var kwa KeyWithAvailability
+		kwa.Key, err = scanKey(rows, &kwa.LoanedCount, &available)
 		if err != nil {
 			return nil, err
 		}
-		kwa.LoanedCount = count
 
-		// Calculer la disponibilité
-		usable := key.QuantityTotal - key.QuantityReserve
-		kwa.AvailableCount = usable - count
-
-		// Récupérer les noms des emprunteurs
-		if count > 0 {
-			loans, err := GetActiveLoansByKeyID(key.ID)
-			if err != nil {
-				return nil, err
+		until := loanPeriodEnd(dueDateFor(kwa.DefaultLoanDays, now, LoanOptions{}))
+		for _, r := range reservations[kwa.ID] {
+			if r.overlaps(now, now) {
+				kwa.ReservedCount++
 			}
-			for _, loan := range loans {
-				kwa.BorrowerNames = append(kwa.BorrowerNames, loan.BorrowerName)
+			if r.overlaps(now, until) {
+				available--
 			}
 		}
-
+		if available > 0 {
+			kwa.AvailableCount = available
+		}
 		result = append(result, kwa)
 	}
+	return result, rows.Err()
+}
 
+// GetKeysWithAvailability récupère toutes les clés avec leurs informations de disponibilité
+func (s *Store) GetKeysWithAvailability() ([]KeyWithAvailability, error) {
+	result, err := s.queryKeysAvailability(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	// Noms des emprunteurs, lus pour toutes les clés à la fois
+	rows, err := s.db().Query(`
+		SELECT l.key_id, b.name
+		FROM loans l
+		INNER JOIN borrowers b ON l.borrower_id = b.id
+		WHERE l.return_date IS NULL
+		ORDER BY l.key_id, l.loan_date, l.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	borrowerNames := make(map[int][]string)
+	for rows.Next() {
+		var keyID int
+		var name string
+		if err := rows.Scan(&keyID, &name); err != nil {
+			return nil, err
+		}
+		borrowerNames[keyID] = append(borrowerNames[keyID], name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range result {
+		result[i].BorrowerNames = borrowerNames[result[i].ID]
+	}
 	return result, nil
 }
 
-// GetAvailableKeys récupère les clés disponibles pour un emprunt
-func GetAvailableKeys() ([]Key, error) {
-	keys, err := GetAllKeys()
+// GetAvailableKeys récupère les clés qu'un emprunt de durée par défaut peut prendre maintenant
+func (s *Store) GetAvailableKeys() ([]Key, error) {
+	keys, err := s.queryKeysAvailability(time.Now())
 	if err != nil {
 		return nil, err
 	}
 
 	var available []Key
 	for _, key := range keys {
-		count, err := GetActiveLoanCount(key.ID)
-		if err != nil {
-			return nil, err
-		}
-		usable := key.QuantityTotal - key.QuantityReserve
-		if usable > count {
-			available = append(available, key)
+		if key.AvailableCount > 0 {
+			available = append(available, key.Key)
 		}
 	}
 
@@ -563,127 +1305,324 @@ func GetAvailableKeys() ([]Key, error) {
 }
 
 // GetBorrowerActiveLoanCount récupère le nombre d'emprunts actifs pour un emprunteur
-func GetBorrowerActiveLoanCount(borrowerID int) (int, error) {
+func (s *Store) GetBorrowerActiveLoanCount(borrowerID int) (int, error) {
 	var count int
-	err := DB.QueryRow(`SELECT COUNT(*) FROM loans WHERE borrower_id = ? AND return_date IS NULL`, borrowerID).Scan(&count)
+	err := s.db().QueryRow(`SELECT COUNT(*) FROM loans WHERE borrower_id = ? AND return_date IS NULL`, borrowerID).Scan(&count)
 	return count, err
 }
 
 // GetKeyActiveLoanCount récupère le nombre d'emprunts actifs pour une clé
-func GetKeyActiveLoanCount(keyID int) (int, error) {
+func (s *Store) GetKeyActiveLoanCount(keyID int) (int, error) {
 	var count int
-	err := DB.QueryRow(`SELECT COUNT(*) FROM loans WHERE key_id = ? AND return_date IS NULL`, keyID).Scan(&count)
+	err := s.db().QueryRow(`SELECT COUNT(*) FROM loans WHERE key_id = ? AND return_date IS NULL`, keyID).Scan(&count)
 	return count, err
 }
 
-// GetKeyPlanData récupère les données pour le plan de clés
-func GetKeyPlanData() (map[int]Building, error) {
-	buildings, err := GetAllBuildings()
+// GetKeyPlanData récupère les données pour le plan de clés : les salles des bâtiments du
+// site sélectionné, avec leur étage et leur zone, et les clés qui les ouvrent, lues en trois
+// requêtes quel que soit leur nombre
+func (s *Store) GetKeyPlanData() (map[int]Building, error) {
+	buildings, err := s.GetAllBuildings()
 	if err != nil {
 		return nil, err
 	}
 
-	buildingMap := make(map[int]Building)
-	for _, building := range buildings {
-		// Récupérer les salles du bâtiment
-		rooms, err := GetRoomsByBuildingID(building.ID)
-		if err != nil {
-			return nil, err
-		}
+	keysByRoom, err := s.keysByRoom()
+	if err != nil {
+		return nil, err
+	}
 
-		// Pour chaque salle, récupérer les clés
-		for i := range rooms {
-			keys, err := GetKeysForRoom(rooms[i].ID)
-			if err != nil {
-				return nil, err
-			}
-			rooms[i].Keys = keys
-		}
+	site, args := s.siteCondition("(SELECT site_id FROM buildings WHERE id = r.building_id)")
+	rooms, err := s.queryRooms(roomSelect+` WHERE r.archived_at IS NULL`+site+` ORDER BY r.name`, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	roomsByBuilding := make(map[int][]Room)
+	for _, r := range rooms {
+		r.Keys = keysByRoom[r.ID]
+		roomsByBuilding[r.BuildingID] = append(roomsByBuilding[r.BuildingID], r)
+	}
 
-		building.Rooms = rooms
+	buildingMap := make(map[int]Building)
+	for _, building := range buildings {
+		building.Rooms = roomsByBuilding[building.ID]
 		buildingMap[building.ID] = building
 	}
 
 	return buildingMap, nil
 }
 
-// GetKeysForRoom récupère les clés associées à une salle
-func GetKeysForRoom(roomID int) ([]Key, error) {
-	rows, err := DB.Query(`
-		SELECT k.id, k.number, k.description, k.quantity_total, k.quantity_reserve, k.storage_location
-		FROM keys k
-		INNER JOIN key_room_association kra ON k.id = kra.key_id
-		WHERE kra.room_id = ?
-		ORDER BY k.number`, roomID)
+// keysByRoom récupère pour toutes les salles du site sélectionné à la fois les clés
+// qui les ouvrent, avec les mêmes règles d'accès direct ou hérité que GetKeysForRoom
+func (s *Store) keysByRoom() (map[int][]Key, error) {
+	site, args := s.siteCondition(`(SELECT b.site_id FROM rooms r
+		INNER JOIN buildings b ON b.id = r.building_id WHERE r.id = a.room_id)`)
+	args = append(args, maxKeyDepth)
+	rows, err := s.db().Query(`
+		WITH RECURSIVE openers(room_id, id, depth, via) AS (
+			SELECT a.room_id, a.key_id, 0, a.key_id FROM key_room_association a WHERE 1 = 1`+site+`
+			UNION
+			SELECT o.room_id, k.parent_key_id, o.depth + 1, o.via FROM keys k
+			JOIN openers o ON k.id = o.id
+			WHERE k.parent_key_id IS NOT NULL AND o.depth < ?
+		),
+		nearest AS (
+			SELECT room_id, id, MIN(depth) AS depth, via FROM openers GROUP BY room_id, id
+		)
+		SELECT `+keyColumns+`, n.depth, v.number, n.room_id
+		FROM nearest n
+		INNER JOIN keys k ON k.id = n.id
+		INNER JOIN keys v ON v.id = n.via
+		LEFT JOIN keys p ON p.id = k.parent_key_id
+		WHERE k.archived_at IS NULL
+		ORDER BY n.room_id, k.number`, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var keys []Key
+	keys := make(map[int][]Key)
 	for rows.Next() {
-		var k Key
-		var storageLocation sql.NullString
-		err := rows.Scan(&k.ID, &k.Number, &k.Description, &k.QuantityTotal, &k.QuantityReserve, &storageLocation)
+		var depth, roomID int
+		var via string
+		k, err := scanKey(rows, &depth, &via, &roomID)
 		if err != nil {
 			return nil, err
 		}
-		if storageLocation.Valid {
-			k.StorageLocation = storageLocation.String
+		k.Access = AccessDirect
+		if depth > 0 {
+			k.Access = AccessInherited
+			k.AccessVia = via
 		}
-		keys = append(keys, k)
+		keys[roomID] = append(keys[roomID], k)
 	}
 	return keys, rows.Err()
 }
 
-// CheckKeyAvailability vérifie si une clé est disponible pour un emprunt
-func CheckKeyAvailability(keyID int) (bool, error) {
-	key, err := GetKeyByID(keyID)
+// GetKeysForRoom récupère les clés qui ouvrent une salle : celles qui lui sont associées
+// (accès direct) et leurs passe-partout à tous les niveaux (accès hérité)
+func (s *Store) GetKeysForRoom(roomID int) ([]Key, error) {
+	// via garde la clé associée à la salle dont l'accès est hérité
+	rows, err := s.db().Query(`
+		WITH RECURSIVE openers(id, depth, via) AS (
+			SELECT key_id, 0, key_id FROM key_room_association WHERE room_id = ?
+			UNION
+			SELECT k.parent_key_id, o.depth + 1, o.via FROM keys k
+			JOIN openers o ON k.id = o.id
+			WHERE k.parent_key_id IS NOT NULL AND o.depth < ?
+		),
+		nearest AS (
+			SELECT id, MIN(depth) AS depth, via FROM openers GROUP BY id
+		)
+		SELECT `+keyColumns+`, n.depth, v.number
+		FROM nearest n
+		INNER JOIN keys k ON k.id = n.id
+		INNER JOIN keys v ON v.id = n.via
+		LEFT JOIN keys p ON p.id = k.parent_key_id
+		WHERE k.archived_at IS NULL
+		ORDER BY k.number`, roomID, maxKeyDepth)
 	if err != nil {
-		return false, err
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []Key
+	for rows.Next() {
+		var depth int
+		var via string
+		k, err := scanKey(rows, &depth, &via)
+		if err != nil {
+			return nil, err
+		}
+		k.Access = AccessDirect
+		if depth > 0 {
+			k.Access = AccessInherited
+			k.AccessVia = via
+		}
+		keys = append(keys, k)
 	}
+	return keys, rows.Err()
+}
 
-	count, err := GetActiveLoanCount(keyID)
+// CheckKeyAvailability vérifie si un emprunt de durée par défaut de la clé serait accepté
+// maintenant, réservations comprises (même règle que CreateLoan)
+func (s *Store) CheckKeyAvailability(keyID int) (bool, error) {
+	count, err := s.availableCopyCount(keyID)
 	if err != nil {
 		return false, err
 	}
-
-	usable := key.QuantityTotal - key.QuantityReserve
-	return usable > count, nil
+	return count > 0, nil
 }
 
-// CreateMultipleLoans crée plusieurs emprunts pour un emprunteur
-func CreateMultipleLoans(keyIDs []int, borrowerID int) error {
-	tx, err := DB.Begin()
+// CreateMultipleLoans crée plusieurs emprunts pour un emprunteur.
+// Sans date imposée dans opts, chaque emprunt reçoit la durée par défaut de sa clé.
+func (s *Store) CreateMultipleLoans(keyIDs []int, borrowerID int, opts LoanOptions) error {
+	tx, err := s.beginWrite()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
+	var borrowerName string
+	if err := tx.QueryRow(`SELECT name FROM borrowers WHERE id = ?`, borrowerID).Scan(&borrowerName); err != nil {
+		return err
+	}
+
 	for _, keyID := range keyIDs {
-		// Vérifier la disponibilité
-		available, err := CheckKeyAvailability(keyID)
-		if err != nil {
+		if _, err := s.createLoanTx(tx, keyID, borrowerID, borrowerName, opts); err != nil {
 			return err
 		}
-		if !available {
-			return fmt.Errorf("la clé %d n'est pas disponible", keyID)
+	}
+
+	return tx.Commit()
+}
+
+// checkLoanAuthorization vérifie dans tx que l'emprunteur peut prendre la clé. Un emprunteur
+// non autorisé n'est accepté que par dérogation d'un administrateur ; authorized vaut alors false.
+func checkLoanAuthorization(tx *sql.Tx, keyID int, number string, borrowerID int, borrowerName, operator string, now time.Time, opts LoanOptions) (authorized bool, err error) {
+	authorized, err = isAuthorized(tx, keyID, borrowerID, now)
+	if err != nil || authorized {
+		return authorized, err
+	}
+	if !opts.OverrideAuthorization {
+		return false, fmt.Errorf("%s ne peut pas emprunter la clé %s: %w", borrowerName, number, ErrNotAuthorized)
+	}
+	admin, err := operatorIsAdmin(tx, operator)
+	if err != nil {
+		return false, err
+	}
+	if !admin {
+		return false, fmt.Errorf("seul un administrateur peut prêter la clé %s à %s: %w", number, borrowerName, ErrNotAuthorized)
+	}
+	return false, nil
+}
+
+// createLoanTx crée un emprunt dans tx après avoir vérifié les autorisations, les
+// réservations des autres emprunteurs et la disponibilité d'un exemplaire
+func (s *Store) createLoanTx(tx *sql.Tx, keyID, borrowerID int, borrowerName string, opts LoanOptions) (int, error) {
+	now := time.Now()
+	operator := s.Operator()
+
+	// Lire la clé dans la transaction (une base en mémoire n'a qu'une connexion)
+	var number string
+	var loanDays int
+	err := tx.QueryRow(`SELECT number, default_loan_days FROM keys WHERE id = ?`, keyID).Scan(&number, &loanDays)
+	if err != nil {
+		return 0, err
+	}
+	if err := checkNotArchived(tx, keyID, borrowerID); err != nil {
+		return 0, err
+	}
+
+	// Vérifier les autorisations ; seul un administrateur peut passer outre
+	authorized, err := checkLoanAuthorization(tx, keyID, number, borrowerID, borrowerName, operator, now, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	// Caution éventuelle, enregistrée comme détenue jusqu'au retour
+	var depositAmount, depositMethod, depositStatus interface{}
+	if opts.DepositAmount < 0 {
+		return 0, fmt.Errorf("le montant de la caution ne peut pas être négatif")
+	}
+	if opts.DepositAmount > 0 {
+		if opts.DepositMethod == "" {
+			return 0, fmt.Errorf("le moyen de paiement de la caution est obligatoire")
 		}
+		depositAmount, depositMethod, depositStatus = opts.DepositAmount, opts.DepositMethod, DepositHeld
+	}
 
-		// Créer l'emprunt
-		_, err = tx.Exec(`INSERT INTO loans (key_id, borrower_id, loan_date) VALUES (?, ?, ?)`,
-			keyID, borrowerID, time.Now())
-		if err != nil {
-			return err
+	// Choisir l'exemplaire remis : celui demandé ou le premier disponible
+	var copyID int
+	if requested, ok := opts.CopyIDs[keyID]; ok {
+		err = tx.QueryRow(`SELECT id FROM key_copies WHERE id = ? AND key_id = ? AND status = ?`,
+			requested, keyID, CopyAvailable).Scan(&copyID)
+	} else {
+		err = tx.QueryRow(`SELECT id FROM key_copies WHERE key_id = ? AND status = ? ORDER BY id LIMIT 1`,
+			keyID, CopyAvailable).Scan(&copyID)
+	}
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("la clé %s n'est pas disponible: %w", number, ErrNoCopyAvailable)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	// Les exemplaires réservés par d'autres pendant la durée de l'emprunt ne peuvent pas être prêtés.
+	// Sans échéance, l'emprunt est sorti indéfiniment (voir reservationCapacity) : toutes
+	// les réservations à venir comptent.
+	dueDate := dueDateFor(loanDays, now, opts)
+	free, err := freeCopyCount(tx, keyID, borrowerID, now, loanPeriodEnd(dueDate))
+	if err != nil {
+		return 0, err
+	}
+	if free <= 0 && dueDate == nil {
+		return 0, fmt.Errorf("la clé %s est réservée prochainement, fixez une date de retour avant la réservation: %w", number, ErrKeyReserved)
+	}
+	if free <= 0 {
+		return 0, fmt.Errorf("la clé %s est réservée pendant cette période: %w", number, ErrKeyReserved)
+	}
+
+	// Créer l'emprunt
+	result, err := tx.Exec(`INSERT INTO loans (key_id, borrower_id, loan_date, due_date, copy_id, loaned_by,
+			deposit_amount, deposit_method, deposit_status) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		keyID, borrowerID, now, dueDate, copyID, operator, depositAmount, depositMethod, depositStatus)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	loanID := int(id)
+
+	// L'exemplaire ne passe en prêt que s'il est encore disponible : la transaction
+	// est ouverte en BEGIN IMMEDIATE, mais on ne se fie pas qu'au verrou
+	result, err = tx.Exec(`UPDATE key_copies SET status = ? WHERE id = ? AND status = ?`, CopyLoaned, copyID, CopyAvailable)
+	if err != nil {
+		return 0, err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if updated == 0 {
+		return 0, fmt.Errorf("la clé %s n'est plus disponible: %w", number, ErrNoCopyAvailable)
+	}
+
+	// Signature de l'emprunteur à la remise
+	if opts.Signature != nil {
+		if err := checkSignature(opts.Signature); err != nil {
+			return 0, err
+		}
+		if err := saveSignature(tx, loanID, SignatureLoan, opts.Signature, now); err != nil {
+			return 0, err
 		}
 	}
 
-	return tx.Commit()
+	summary, err := loanAuditSummary(tx, "Emprunt", loanID)
+	if err != nil {
+		return 0, err
+	}
+	action := AuditLoan
+	if !authorized {
+		action = AuditOverride
+		summary += " (dérogation : emprunteur non autorisé)"
+	}
+	if opts.Signature != nil {
+		summary += " - signé à l'écran"
+	}
+	if err := s.auditInsertAs(tx, action, AuditEntityLoan, loanID, summary); err != nil {
+		return 0, err
+	}
+
+	return loanID, nil
 }
 
 // GetActiveLoansForKey est un alias pour GetActiveLoansByKeyID
-func GetActiveLoansForKey(keyID int) ([]LoanWithDetails, error) {
-	return GetActiveLoansByKeyID(keyID)
+func (s *Store) GetActiveLoansForKey(keyID int) ([]LoanWithDetails, error) {
+	return s.GetActiveLoansByKeyID(keyID)
 }
 
 // GetLoanDuration calcule la durée d'un emprunt en jours
@@ -0,0 +1,330 @@
+package db
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Volume du jeu de données des benchmarks : de l'ordre d'un grand établissement
+const (
+	largeBuildings        = 8
+	largeRoomsPerBuilding = 50 // 400 salles
+	largeKeys             = 1500
+	largeBorrowers        = 300
+	largeDoubleLoanedKeys = 550 // Clés sorties deux fois : 1 100 emprunts actifs
+	largeReservations     = 30
+)
+
+var (
+	largeStoreOnce sync.Once
+	largeStore     *Store
+	largeStoreErr  error
+)
+
+// openLargeStore retourne une base en mémoire remplie une seule fois pour tous les
+// benchmarks : bâtiments et salles, passe-partout à deux niveaux, clés ouvrant une ou
+// deux salles, emprunts actifs (dont deux par clé pour certaines) et réservations en cours
+func openLargeStore(tb testing.TB) *Store {
+	tb.Helper()
+	largeStoreOnce.Do(func() {
+		largeStore, largeStoreErr = seedLargeStore()
+	})
+	if largeStoreErr != nil {
+		tb.Fatal(largeStoreErr)
+	}
+	return largeStore
+}
+
+func seedLargeStore() (*Store, error) {
+	s, err := OpenMemory()
+	if err != nil {
+		return nil, err
+	}
+	s.SetSite(1)
+
+	var roomIDs []int
+	var subMasters []int
+	keyCount := 0
+	for b := 1; b <= largeBuildings; b++ {
+		building := &Building{Name: fmt.Sprintf("Bâtiment %02d", b)}
+		if err := s.CreateBuilding(building); err != nil {
+			return nil, err
+		}
+		for r := 1; r <= largeRoomsPerBuilding; r++ {
+			room := &Room{Name: fmt.Sprintf("Salle %02d-%03d", b, r), Type: "Bureau", BuildingID: building.ID}
+			if err := s.CreateRoom(room); err != nil {
+				return nil, err
+			}
+			roomIDs = append(roomIDs, room.ID)
+		}
+
+		// Un passe-partout général par bâtiment et quatre passe-partout partiels
+		master := &Key{Number: fmt.Sprintf("PG%02d", b), QuantityTotal: 2}
+		if err := s.CreateKey(master, nil); err != nil {
+			return nil, err
+		}
+		keyCount++
+		for p := 1; p <= 4; p++ {
+			sub := &Key{Number: fmt.Sprintf("PP%02d-%d", b, p), QuantityTotal: 2, ParentKeyID: &master.ID}
+			if err := s.CreateKey(sub, nil); err != nil {
+				return nil, err
+			}
+			subMasters = append(subMasters, sub.ID)
+			keyCount++
+		}
+	}
+
+	// Les clés ordinaires ouvrent une ou deux salles ; une sur deux dépend d'un passe-partout
+	var keyIDs []int
+	for i := 0; keyCount < largeKeys; i++ {
+		k := &Key{Number: fmt.Sprintf("K%04d", i), QuantityTotal: 2}
+		if i%2 == 0 {
+			k.ParentKeyID = &subMasters[i%len(subMasters)]
+		}
+		rooms := []int{roomIDs[i%len(roomIDs)]}
+		if i%3 == 0 {
+			rooms = append(rooms, roomIDs[(i*7+1)%len(roomIDs)])
+		}
+		if err := s.CreateKey(k, rooms); err != nil {
+			return nil, err
+		}
+		keyIDs = append(keyIDs, k.ID)
+		keyCount++
+	}
+
+	var borrowerIDs []int
+	for i := 0; i < largeBorrowers; i++ {
+		b := &Borrower{FirstName: fmt.Sprintf("Prénom%03d", i), LastName: fmt.Sprintf("Nom%03d", i)}
+		if err := s.CreateBorrower(b); err != nil {
+			return nil, err
+		}
+		borrowerIDs = append(borrowerIDs, b.ID)
+	}
+
+	for i := 0; i < 2*largeDoubleLoanedKeys; i++ {
+		keyID := keyIDs[i%largeDoubleLoanedKeys]
+		if err := s.CreateLoan(keyID, borrowerIDs[i%len(borrowerIDs)]); err != nil {
+			return nil, err
+		}
+	}
+	// Dates d'emprunt distinctes et sans rapport avec l'ordre de création, pour que
+	// l'ordre des emprunteurs d'une clé dépende bien de la date
+	if _, err := s.db().Exec(`UPDATE loans SET loan_date = datetime('now', '-' || ((id * 37) % 5003) || ' minutes')`); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for i := 0; i < largeReservations; i++ {
+		r := &Reservation{
+			KeyID:      keyIDs[largeDoubleLoanedKeys+i],
+			BorrowerID: borrowerIDs[i],
+			StartDate:  now.Add(-time.Hour),
+			EndDate:    now.Add(24 * time.Hour),
+		}
+		if err := s.CreateReservation(r); err != nil {
+			return nil, err
+		}
+	}
+
+	// Un second site, qui ne doit apparaître dans aucune lecture du premier
+	other := &Site{Name: "Annexe"}
+	if err := s.CreateSite(other); err != nil {
+		return nil, err
+	}
+	s.SetSite(other.ID)
+	defer s.SetSite(1)
+	building := &Building{Name: "Bâtiment annexe"}
+	if err := s.CreateBuilding(building); err != nil {
+		return nil, err
+	}
+	room := &Room{Name: "Salle annexe", Type: "Bureau", BuildingID: building.ID}
+	if err := s.CreateRoom(room); err != nil {
+		return nil, err
+	}
+	if err := s.CreateKey(&Key{Number: "A001", QuantityTotal: 1}, []int{room.ID}); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// keysWithAvailabilityPerKey est l'ancienne lecture de GetKeysWithAvailability :
+// plusieurs requêtes par clé
+func keysWithAvailabilityPerKey(s *Store) ([]KeyWithAvailability, error) {
+	keys, err := s.GetAllKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []KeyWithAvailability
+	for _, key := range keys {
+		kwa := KeyWithAvailability{Key: key}
+
+		count, err := s.GetActiveLoanCount(key.ID)
+		if err != nil {
+			return nil, err
+		}
+		kwa.LoanedCount = count
+
+		kwa.AvailableCount, err = s.availableCopyCount(key.ID)
+		if err != nil {
+			return nil, err
+		}
+		now := time.Now()
+		kwa.ReservedCount, err = reservedCopyCount(s.db(), key.ID, 0, now, now)
+		if err != nil {
+			return nil, err
+		}
+
+		if count > 0 {
+			loans, err := s.GetActiveLoansByKeyID(key.ID)
+			if err != nil {
+				return nil, err
+			}
+			for _, loan := range loans {
+				kwa.BorrowerNames = append(kwa.BorrowerNames, loan.BorrowerName)
+			}
+		}
+		result = append(result, kwa)
+	}
+	return result, nil
+}
+
+// keyPlanDataPerRoom est l'ancienne lecture de GetKeyPlanData : une requête par
+// bâtiment puis une par salle
+func keyPlanDataPerRoom(s *Store) (map[int]Building, error) {
+	buildings, err := s.GetAllBuildings()
+	if err != nil {
+		return nil, err
+	}
+
+	buildingMap := make(map[int]Building)
+	for _, building := range buildings {
+		rooms, err := s.GetRoomsByBuildingID(building.ID)
+		if err != nil {
+			return nil, err
+		}
+		for i := range rooms {
+			keys, err := s.GetKeysForRoom(rooms[i].ID)
+			if err != nil {
+				return nil, err
+			}
+			rooms[i].Keys = keys
+		}
+		building.Rooms = rooms
+		buildingMap[building.ID] = building
+	}
+	return buildingMap, nil
+}
+
+func TestAggregateQueriesMatchPerRecordQueries(t *testing.T) {
+	s := openLargeStore(t)
+
+	perKey, err := keysWithAvailabilityPerKey(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aggregate, err := s.GetKeysWithAvailability()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(aggregate) != largeKeys {
+		t.Fatalf("GetKeysWithAvailability() = %d clés, %d attendues", len(aggregate), largeKeys)
+	}
+	var doubleLoaned, reserved int
+	for i := range perKey {
+		if !reflect.DeepEqual(perKey[i], aggregate[i]) {
+			t.Fatalf("clé %s :\n par clé  = %+v\n agrégée = %+v", perKey[i].Number, perKey[i], aggregate[i])
+		}
+		if len(aggregate[i].BorrowerNames) == 2 {
+			doubleLoaned++
+		}
+		if aggregate[i].ReservedCount > 0 {
+			reserved++
+		}
+	}
+	if doubleLoaned != largeDoubleLoanedKeys || reserved != largeReservations {
+		t.Errorf("%d clés sorties deux fois et %d réservées, %d et %d attendues",
+			doubleLoaned, reserved, largeDoubleLoanedKeys, largeReservations)
+	}
+
+	perRoom, err := keyPlanDataPerRoom(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := s.GetKeyPlanData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan) != largeBuildings {
+		t.Fatalf("GetKeyPlanData() = %d bâtiments, %d attendus", len(plan), largeBuildings)
+	}
+	var inherited int
+	for id, building := range perRoom {
+		if !reflect.DeepEqual(building, plan[id]) {
+			for i, room := range building.Rooms {
+				if i < len(plan[id].Rooms) && !reflect.DeepEqual(room, plan[id].Rooms[i]) {
+					t.Fatalf("salle %s :\n par salle = %+v\n agrégée  = %+v", room.Name, room, plan[id].Rooms[i])
+				}
+			}
+			t.Fatalf("bâtiment %s différent", building.Name)
+		}
+		for _, room := range plan[id].Rooms {
+			for _, k := range room.Keys {
+				if k.Access == AccessInherited && k.AccessVia != "" {
+					inherited++
+				}
+			}
+		}
+	}
+	if inherited == 0 {
+		t.Error("le plan de clés doit comporter des accès hérités de passe-partout")
+	}
+
+	// Les salles des autres sites ne sont pas lues
+	keysByRoom, err := s.keysByRoom()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keysByRoom) != largeBuildings*largeRoomsPerBuilding {
+		t.Errorf("keysByRoom() = %d salles, %d attendues", len(keysByRoom), largeBuildings*largeRoomsPerBuilding)
+	}
+}
+
+func BenchmarkKeysWithAvailability(b *testing.B) {
+	s := openLargeStore(b)
+	b.Run("par-cle", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := keysWithAvailabilityPerKey(s); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("agregee", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := s.GetKeysWithAvailability(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkKeyPlanData(b *testing.B) {
+	s := openLargeStore(b)
+	b.Run("par-salle", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := keyPlanDataPerRoom(s); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("agregee", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := s.GetKeyPlanData(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,229 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// copyStatus retourne l'état d'un exemplaire
+func copyStatus(t *testing.T, s *Store, keyID, copyID int) CopyStatus {
+	t.Helper()
+	copies, err := s.GetCopiesForKey(keyID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range copies {
+		if c.ID == copyID {
+			return c.Status
+		}
+	}
+	t.Fatalf("exemplaire %d introuvable", copyID)
+	return ""
+}
+
+// activeLoan retourne l'unique emprunt en cours d'un emprunteur
+func activeLoan(t *testing.T, s *Store, borrowerID int) LoanWithDetails {
+	t.Helper()
+	loans, err := s.GetActiveLoansByBorrowerID(borrowerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loans) != 1 {
+		t.Fatalf("%d emprunts en cours, 1 attendu", len(loans))
+	}
+	return loans[0]
+}
+
+func TestCreateAndReturnLoan(t *testing.T) {
+	s := newTestStore(t)
+	s.SetOperator("accueil")
+	key := createTestKey(t, s, "K001", 1)
+	alice := createTestBorrower(t, s, "Alice", "Martin")
+	bob := createTestBorrower(t, s, "Bob", "Durand")
+
+	if err := s.CreateLoan(key.ID, alice.ID); err != nil {
+		t.Fatal(err)
+	}
+	loan := activeLoan(t, s, alice.ID)
+	if loan.CopyID == nil || loan.LoanedBy != "accueil" {
+		t.Fatalf("emprunt = exemplaire %v, opérateur %q ; exemplaire et opérateur accueil attendus", loan.CopyID, loan.LoanedBy)
+	}
+	if status := copyStatus(t, s, key.ID, *loan.CopyID); status != CopyLoaned {
+		t.Errorf("exemplaire prêté à l'état %s, %s attendu", status, CopyLoaned)
+	}
+
+	// Le seul exemplaire est sorti
+	if err := s.CreateLoan(key.ID, bob.ID); !errors.Is(err, ErrNoCopyAvailable) {
+		t.Errorf("CreateLoan() sans exemplaire disponible = %v, ErrNoCopyAvailable attendue", err)
+	}
+
+	if err := s.ReturnLoan(loan.ID, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ReturnLoan(loan.ID, nil); !errors.Is(err, ErrLoanReturned) {
+		t.Errorf("second ReturnLoan() = %v, ErrLoanReturned attendue", err)
+	}
+	returned, err := s.GetLoanByID(loan.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if returned.ReturnDate == nil || returned.ReturnedBy != "accueil" {
+		t.Errorf("emprunt rendu = date %v, opérateur %q", returned.ReturnDate, returned.ReturnedBy)
+	}
+	if status := copyStatus(t, s, key.ID, *loan.CopyID); status != CopyAvailable {
+		t.Errorf("exemplaire rendu à l'état %s, %s attendu", status, CopyAvailable)
+	}
+	if active, err := s.GetAllActiveLoans(); err != nil || len(active) != 0 {
+		t.Errorf("GetAllActiveLoans() = %d, %v après le retour ; 0 attendu", len(active), err)
+	}
+
+	for _, action := range []AuditAction{AuditLoan, AuditReturn} {
+		entries, err := s.GetAuditLog(AuditFilter{Entity: AuditEntityLoan, Action: action})
+		if err != nil || len(entries) != 1 {
+			t.Errorf("journal d'audit %s = %d entrées, %v ; 1 attendue", action, len(entries), err)
+		}
+	}
+}
+
+func TestTransferLoan(t *testing.T) {
+	s := newTestStore(t)
+	key := createTestKey(t, s, "K001", 1)
+	alice := createTestBorrower(t, s, "Alice", "Martin")
+	bob := createTestBorrower(t, s, "Bob", "Durand")
+
+	err := s.CreateMultipleLoans([]int{key.ID}, alice.ID, LoanOptions{DepositAmount: 20, DepositMethod: DepositCash})
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := activeLoan(t, s, alice.ID)
+
+	if _, err := s.TransferLoan(original.ID, alice.ID, DepositTransferred, LoanOptions{}); err == nil {
+		t.Error("TransferLoan() vers l'emprunteur actuel accepté")
+	}
+	if _, err := s.TransferLoan(original.ID, bob.ID, "", LoanOptions{}); err == nil {
+		t.Error("TransferLoan() accepté sans décider du sort de la caution")
+	}
+
+	newID, err := s.TransferLoan(original.ID, bob.ID, DepositTransferred, LoanOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	closed, err := s.GetLoanByID(original.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if closed.ReturnDate == nil || closed.DepositStatus != DepositTransferred {
+		t.Errorf("emprunt d'origine = retour %v, caution %s ; clos avec caution reportée attendu", closed.ReturnDate, closed.DepositStatus)
+	}
+
+	transferred, err := s.GetLoanByID(newID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transferred.BorrowerID != bob.ID || transferred.TransferredFromLoanID == nil || *transferred.TransferredFromLoanID != original.ID {
+		t.Errorf("nouvel emprunt = emprunteur %d, transféré de %v", transferred.BorrowerID, transferred.TransferredFromLoanID)
+	}
+	if transferred.CopyID == nil || *transferred.CopyID != *original.CopyID {
+		t.Errorf("nouvel emprunt sur l'exemplaire %v, %d attendu", transferred.CopyID, *original.CopyID)
+	}
+	if !transferred.HasHeldDeposit() || transferred.DepositAmount != 20 {
+		t.Errorf("caution du nouvel emprunt = %v (%s), 20 détenue attendue", transferred.DepositAmount, transferred.DepositStatus)
+	}
+
+	// L'exemplaire ne repasse pas par le stock
+	if status := copyStatus(t, s, key.ID, *original.CopyID); status != CopyLoaned {
+		t.Errorf("exemplaire transféré à l'état %s, %s attendu", status, CopyLoaned)
+	}
+	if _, err := s.TransferLoan(original.ID, bob.ID, DepositTransferred, LoanOptions{}); err == nil {
+		t.Error("TransferLoan() d'un emprunt clos accepté")
+	}
+}
+
+func TestTransferLoanRefundsDeposit(t *testing.T) {
+	s := newTestStore(t)
+	key := createTestKey(t, s, "K001", 1)
+	alice := createTestBorrower(t, s, "Alice", "Martin")
+	bob := createTestBorrower(t, s, "Bob", "Durand")
+
+	err := s.CreateMultipleLoans([]int{key.ID}, alice.ID, LoanOptions{DepositAmount: 20, DepositMethod: DepositCash})
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := activeLoan(t, s, alice.ID)
+
+	newID, err := s.TransferLoan(original.ID, bob.ID, DepositRefunded, LoanOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed, err := s.GetLoanByID(original.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if closed.DepositStatus != DepositRefunded {
+		t.Errorf("caution de l'emprunt d'origine = %s, %s attendue", closed.DepositStatus, DepositRefunded)
+	}
+	transferred, err := s.GetLoanByID(newID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transferred.HasHeldDeposit() || transferred.DepositAmount != 0 {
+		t.Errorf("caution du nouvel emprunt = %v (%s), aucune attendue", transferred.DepositAmount, transferred.DepositStatus)
+	}
+}
+
+func TestTransferLoanRespectsReservations(t *testing.T) {
+	s := newTestStore(t)
+	key := createTestKey(t, s, "K001", 1)
+	alice := createTestBorrower(t, s, "Alice", "Martin")
+	bob := createTestBorrower(t, s, "Bob", "Durand")
+	carol := createTestBorrower(t, s, "Carol", "Petit")
+
+	// Alice rend la clé ce soir, Carol l'a réservée pour demain
+	tonight := time.Now().Add(time.Hour)
+	if err := s.CreateMultipleLoans([]int{key.ID}, alice.ID, LoanOptions{DueDate: &tonight}); err != nil {
+		t.Fatal(err)
+	}
+	reserveTomorrow(t, s, key.ID, carol.ID)
+	original := activeLoan(t, s, alice.ID)
+
+	// Sans échéance, le transfert garderait la clé pendant la réservation
+	if _, err := s.TransferLoan(original.ID, bob.ID, "", LoanOptions{}); !errors.Is(err, ErrKeyReserved) {
+		t.Fatalf("TransferLoan() sans échéance = %v, ErrKeyReserved attendue", err)
+	}
+	if _, err := s.TransferLoan(original.ID, bob.ID, "", LoanOptions{DueDate: &tonight}); err != nil {
+		t.Fatalf("TransferLoan() rendu avant la réservation: %v", err)
+	}
+}
+
+func TestRoomBuildingMustBelongToSite(t *testing.T) {
+	s := newTestStore(t)
+	building := &Building{Name: "Bâtiment A"}
+	if err := s.CreateBuilding(building); err != nil {
+		t.Fatal(err)
+	}
+
+	annex := &Site{Name: "Annexe"}
+	if err := s.CreateSite(annex); err != nil {
+		t.Fatal(err)
+	}
+	s.SetSite(annex.ID)
+	other := &Building{Name: "Bâtiment annexe"}
+	if err := s.CreateBuilding(other); err != nil {
+		t.Fatal(err)
+	}
+	s.SetSite(1)
+
+	if err := s.CreateRoom(&Room{Name: "Salle 1", Type: "Bureau", BuildingID: other.ID}); err == nil {
+		t.Error("CreateRoom() dans un bâtiment d'un autre site acceptée")
+	}
+	room := &Room{Name: "Salle 1", Type: "Bureau", BuildingID: building.ID}
+	if err := s.CreateRoom(room); err != nil {
+		t.Fatal(err)
+	}
+	room.BuildingID = other.ID
+	if err := s.UpdateRoom(room); err == nil {
+		t.Error("UpdateRoom() vers un bâtiment d'un autre site acceptée")
+	}
+}
@@ -0,0 +1,132 @@
+package db
+
+// Repository regroupe les opérations dont l'interface graphique a besoin.
+// Store en est l'implémentation SQLite ; d'autres outils peuvent dépendre de
+// cette interface pour embarquer ou simuler la gestion des clés.
+type Repository interface {
+	GetAllSites() ([]Site, error)
+	CreateSite(site *Site) error
+	UpdateSite(site *Site) error
+	DeleteSite(id int) error
+	GetSiteSummaries() ([]SiteSummary, error)
+	SetSite(id int)
+	Site() int
+	GetAllKeys() ([]Key, error)
+	GetKeyByID(id int) (*Key, error)
+	CreateKey(k *Key, roomIDs []int) error
+	UpdateKey(k *Key, roomIDs []int) error
+	GetRoomsForKey(keyID int) ([]Room, error)
+	GetCopiesForKey(keyID int) ([]KeyCopy, error)
+	GetAvailableCopies(keyID int) ([]KeyCopy, error)
+	AddKeyCopy(keyID int, identifier string, movement StockMovementType, reason string) error
+	SetCopyStatus(copyID int, status CopyStatus, notes string) error
+	AssignCopyHook(copyID, hook int) error
+	RecordStockMovement(keyID int, movement StockMovementType, quantity int, reason string) error
+	GetStockMovements(f StockMovementFilter) ([]StockMovement, error)
+	GetStorageLocations() ([]StorageLocation, error)
+	GetKeyStorageLocations(keyID int) ([]StorageLocation, error)
+	GetStorageLocationByID(id int) (*StorageLocation, error)
+	CreateStorageLocation(loc *StorageLocation) error
+	UpdateStorageLocation(loc *StorageLocation) error
+	MergeStorageLocation(fromID, intoID int) error
+	DeleteStorageLocation(id int) error
+	GetCabinetMap(locationID int) (*CabinetMap, error)
+	GetAuthorizationsForKey(keyID int) ([]KeyAuthorization, error)
+	GetAuthorizationsForBorrower(borrowerID int) ([]KeyAuthorization, error)
+	GetAuthorizedKeyIDs(borrowerID int) (map[int]bool, error)
+	CreateKeyAuthorization(a *KeyAuthorization) error
+	DeleteKeyAuthorization(id int) error
+	GetAllBorrowers() ([]Borrower, error)
+	GetBorrowerByID(id int) (*Borrower, error)
+	CreateBorrower(b *Borrower) error
+	UpdateBorrower(b *Borrower) error
+	GetAllBuildings() ([]Building, error)
+	GetBuildingByID(id int) (*Building, error)
+	CreateBuilding(b *Building) error
+	UpdateBuilding(b *Building) error
+	GetAllRooms() ([]Room, error)
+	GetRoomsByBuildingID(buildingID int) ([]Room, error)
+	CreateRoom(r *Room) error
+	UpdateRoom(r *Room) error
+	GetFloorsByBuildingID(buildingID int) ([]Floor, error)
+	CreateFloor(f *Floor) error
+	UpdateFloor(f *Floor) error
+	DeleteFloor(id int) error
+	GetZones() ([]Zone, error)
+	GetZoneByID(id int) (*Zone, error)
+	CreateZone(z *Zone) error
+	UpdateZone(z *Zone) error
+	DeleteZone(id int) error
+	GetRoomsForZone(zoneID int) ([]Room, error)
+	GetKeysForZone(zoneID int) ([]Key, error)
+	GetCustomFields(entity string) ([]CustomField, error)
+	GetRecordCustomFields(entity string, recordID int) ([]CustomField, error)
+	CreateCustomField(f *CustomField) error
+	UpdateCustomField(f *CustomField) error
+	DeleteCustomField(id int) error
+	GetCustomValues(entity string, id int) (CustomValues, error)
+	GetRecordCustomFieldValues(entity string, id int) ([]CustomFieldValue, error)
+	GetReportColumns(entity string) (CustomColumns, error)
+	GetCustomFieldExport(entity string) (*CustomFieldExport, error)
+	GetAttachments(entity string, entityID int) ([]Attachment, error)
+	GetAttachmentData(id int) ([]byte, error)
+	AddAttachment(entity string, entityID int, filename string, data []byte) (*Attachment, error)
+	DeleteAttachment(id int) error
+	ArchiveRecord(entity string, id int) error
+	RecoverRecord(entity string, id int) error
+	PurgeRecord(entity string, id int) error
+	GetArchivedRecords() ([]ArchivedRecord, error)
+	GetAllActiveLoans() ([]LoanWithDetails, error)
+	GetActiveLoansByKeyID(keyID int) ([]LoanWithDetails, error)
+	GetActiveLoansByBorrowerID(borrowerID int) ([]LoanWithDetails, error)
+	GetOverdueLoans() ([]LoanWithDetails, error)
+	GetLoanByID(id int) (*LoanWithDetails, error)
+	GetLoanHistory(f LoanHistoryFilter) ([]LoanWithDetails, error)
+	CountLoanHistory(f LoanHistoryFilter) (int, error)
+	GetLastLoanForKey(keyID int) (*LoanWithDetails, error)
+	CreateLoan(keyID, borrowerID int) error
+	ReturnLoan(loanID int, signature []byte) error
+	ReturnLoanWithDeposit(loanID int, settlement DepositStatus, notes string, signature []byte) error
+	LoadLoanSignatures(loans []LoanWithDetails) error
+	TransferLoan(loanID, toBorrowerID int, deposit DepositStatus, opts LoanOptions) (int, error)
+	SettleDeposit(loanID int, settlement DepositStatus, notes string) error
+	GetHeldDepositsByBorrower() ([]BorrowerDeposits, error)
+	DeclareLoanLost(loanID int, status CopyStatus, notes string) error
+	GetLossImpactReport(loanID int) (*LossImpactReport, error)
+	GetReservations(pendingOnly bool) ([]Reservation, error)
+	GetReservationByID(id int) (*Reservation, error)
+	CreateReservation(r *Reservation) error
+	CancelReservation(id int) error
+	ConvertReservationToLoan(id int) (int, error)
+	GetActiveLoanCount(keyID int) (int, error)
+	GetKeysWithAvailability() ([]KeyWithAvailability, error)
+	GetAvailableKeys() ([]Key, error)
+	GetBorrowerActiveLoanCount(borrowerID int) (int, error)
+	GetKeyActiveLoanCount(keyID int) (int, error)
+	GetKeyPlanData() (map[int]Building, error)
+	GetKeysForRoom(roomID int) ([]Key, error)
+	Search(query string, limit int) ([]SearchResult, error)
+	CheckKeyAvailability(keyID int) (bool, error)
+	CreateMultipleLoans(keyIDs []int, borrowerID int, opts LoanOptions) error
+	GetActiveLoansForKey(keyID int) ([]LoanWithDetails, error)
+	Backup(backupPath string) error
+	Restore(backupPath string) error
+	Reset() error
+	ImportFromPythonDB(pythonDBPath string) error
+	GenerateDemoData() error
+	GetAuditLog(f AuditFilter) ([]AuditEntry, error)
+	CountAuditLog(f AuditFilter) (int, error)
+	SetOperator(name string)
+	Operator() string
+	GetAllOperators() ([]Operator, error)
+	CountOperators() (int, error)
+	CreateOperator(o *Operator, password string) error
+	UpdateOperator(o *Operator) error
+	SetOperatorPassword(operatorID int, password string) error
+	Authenticate(username, password string) (*Operator, error)
+	Path() string
+	Close() error
+}
+
+// Store doit satisfaire Repository
+var _ Repository = (*Store)(nil)
@@ -0,0 +1,316 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrKeyReserved est retournée lorsque tous les exemplaires d'une clé sont réservés sur la période demandée
+var ErrKeyReserved = errors.New("aucun exemplaire libre sur cette période")
+
+// rowQueryer est satisfait par *sql.DB et *sql.Tx
+type rowQueryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// openEnded est la fin de période d'un emprunt sans échéance : il chevauche toutes
+// les réservations à venir
+var openEnded = time.Date(9999, 12, 31, 23, 59, 59, 0, time.Local)
+
+// loanPeriodEnd retourne la fin de la période occupée par un emprunt : son échéance,
+// ou openEnded s'il n'en a pas
+func loanPeriodEnd(dueDate *time.Time) time.Time {
+	if dueDate == nil {
+		return openEnded
+	}
+	return *dueDate
+}
+
+// reservationSelect est la requête commune aux lectures de réservations
+const reservationSelect = `
+		SELECT r.id, r.key_id, k.number, k.description, r.borrower_id, b.name,
+		       r.start_date, r.end_date, r.notes, r.status, r.loan_id, r.created_by, r.created_at
+		FROM reservations r
+		JOIN keys k ON k.id = r.key_id
+		JOIN borrowers b ON b.id = r.borrower_id`
+
+// reservedCopyCount compte les réservations en attente d'une clé qui chevauchent
+// la période [from, to], hors celles de exceptBorrowerID (0 = toutes)
+func reservedCopyCount(q rowQueryer, keyID, exceptBorrowerID int, from, to time.Time) (int, error) {
+	var count int
+	err := q.QueryRow(`
+		SELECT COUNT(*) FROM reservations
+		WHERE key_id = ? AND status = ? AND borrower_id <> ?
+		  AND substr(start_date, 1, 19) <= ? AND substr(end_date, 1, 19) >= ?`,
+		keyID, ReservationPending, exceptBorrowerID,
+		to.Format("2006-01-02 15:04:05"), from.Format("2006-01-02 15:04:05")).Scan(&count)
+	return count, err
+}
+
+// reservationPeriod est la période d'une réservation en attente, bornes au format de la base
+type reservationPeriod struct {
+	start, end string
+}
+
+// overlaps indique si la réservation chevauche [from, to], selon la même règle que reservedCopyCount
+func (p reservationPeriod) overlaps(from, to time.Time) bool {
+	return p.start <= to.Format("2006-01-02 15:04:05") && p.end >= from.Format("2006-01-02 15:04:05")
+}
+
+// pendingReservationPeriods lit, par clé, les réservations en attente qui ne sont pas terminées à from
+func (s *Store) pendingReservationPeriods(from time.Time) (map[int][]reservationPeriod, error) {
+	rows, err := s.db().Query(`
+		SELECT key_id, substr(start_date, 1, 19), substr(end_date, 1, 19) FROM reservations
+		WHERE status = ? AND substr(end_date, 1, 19) >= ?`,
+		ReservationPending, from.Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	periods := make(map[int][]reservationPeriod)
+	for rows.Next() {
+		var keyID int
+		var p reservationPeriod
+		if err := rows.Scan(&keyID, &p.start, &p.end); err != nil {
+			return nil, err
+		}
+		periods[keyID] = append(periods[keyID], p)
+	}
+	return periods, rows.Err()
+}
+
+// freeCopyCount compte les exemplaires disponibles d'une clé qui ne sont pas promis
+// à une réservation d'un autre emprunteur pendant [from, to]
+func freeCopyCount(q rowQueryer, keyID, borrowerID int, from, to time.Time) (int, error) {
+	var available int
+	err := q.QueryRow(`SELECT COUNT(*) FROM key_copies WHERE key_id = ? AND status = ?`, keyID, CopyAvailable).Scan(&available)
+	if err != nil {
+		return 0, err
+	}
+	reserved, err := reservedCopyCount(q, keyID, borrowerID, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return available - reserved, nil
+}
+
+// reservationCapacity compte les exemplaires d'une clé encore libres sur [start, end] :
+// exemplaires en service, moins les emprunts qui ne seront pas rendus au début de
+// la période (sans échéance, en retard ou à rendre après start) et les réservations qui la chevauchent
+func reservationCapacity(q rowQueryer, keyID int, start, end time.Time) (int, error) {
+	var copies int
+	err := q.QueryRow(`SELECT COUNT(*) FROM key_copies WHERE key_id = ? AND status IN (?, ?)`,
+		keyID, CopyAvailable, CopyLoaned).Scan(&copies)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now().Format("2006-01-02 15:04:05")
+	var loaned int
+	err = q.QueryRow(`
+		SELECT COUNT(*) FROM loans
+		WHERE key_id = ? AND return_date IS NULL
+		  AND (due_date IS NULL OR substr(due_date, 1, 19) >= ? OR substr(due_date, 1, 19) < ?)`,
+		keyID, start.Format("2006-01-02 15:04:05"), now).Scan(&loaned)
+	if err != nil {
+		return 0, err
+	}
+
+	reserved, err := reservedCopyCount(q, keyID, 0, start, end)
+	if err != nil {
+		return 0, err
+	}
+	return copies - loaned - reserved, nil
+}
+
+// GetReservations récupère les réservations par date de début ;
+// pendingOnly se limite à celles qui n'ont été ni converties ni annulées
+func (s *Store) GetReservations(pendingOnly bool) ([]Reservation, error) {
+	site, args := s.siteCondition("k.site_id")
+	query := reservationSelect + ` WHERE 1 = 1` + site
+	if pendingOnly {
+		query += ` AND r.status = ?`
+		args = append(args, ReservationPending)
+	}
+	query += ` ORDER BY r.start_date, k.number`
+
+	rows, err := s.db().Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reservations []Reservation
+	for rows.Next() {
+		r, err := scanReservation(rows)
+		if err != nil {
+			return nil, err
+		}
+		reservations = append(reservations, r)
+	}
+	return reservations, rows.Err()
+}
+
+// GetReservationByID récupère une réservation par son ID
+func (s *Store) GetReservationByID(id int) (*Reservation, error) {
+	r, err := scanReservation(s.db().QueryRow(reservationSelect+` WHERE r.id = ?`, id))
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// scanReservation lit une ligne produite par reservationSelect
+func scanReservation(row rowScanner) (Reservation, error) {
+	var r Reservation
+	var notes, createdBy sql.NullString
+	var loanID sql.NullInt64
+	err := row.Scan(&r.ID, &r.KeyID, &r.KeyNumber, &r.KeyDescription, &r.BorrowerID, &r.BorrowerName,
+		&r.StartDate, &r.EndDate, &notes, &r.Status, &loanID, &createdBy, &r.CreatedAt)
+	if err != nil {
+		return r, err
+	}
+	if loanID.Valid {
+		id := int(loanID.Int64)
+		r.LoanID = &id
+	}
+	r.Notes = notes.String
+	r.CreatedBy = createdBy.String
+	return r, nil
+}
+
+// CreateReservation enregistre une réservation si l'emprunteur est autorisé à prendre
+// la clé au début de la période et qu'un exemplaire reste libre sur toute la période
+func (s *Store) CreateReservation(r *Reservation) error {
+	if !r.EndDate.After(r.StartDate) {
+		return fmt.Errorf("la fin de la réservation doit suivre son début")
+	}
+	if r.EndDate.Before(time.Now()) {
+		return fmt.Errorf("la période de réservation est déjà passée")
+	}
+
+	tx, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var number, borrowerName string
+	if err := tx.QueryRow(`SELECT number FROM keys WHERE id = ?`, r.KeyID).Scan(&number); err != nil {
+		return err
+	}
+	if err := tx.QueryRow(`SELECT name FROM borrowers WHERE id = ?`, r.BorrowerID).Scan(&borrowerName); err != nil {
+		return err
+	}
+	if err := checkNotArchived(tx, r.KeyID, r.BorrowerID); err != nil {
+		return err
+	}
+
+	authorized, err := isAuthorized(tx, r.KeyID, r.BorrowerID, r.StartDate)
+	if err != nil {
+		return err
+	}
+	if !authorized {
+		return fmt.Errorf("%s ne peut pas emprunter la clé %s: %w", borrowerName, number, ErrNotAuthorized)
+	}
+
+	capacity, err := reservationCapacity(tx, r.KeyID, r.StartDate, r.EndDate)
+	if err != nil {
+		return err
+	}
+	if capacity <= 0 {
+		return fmt.Errorf("la clé %s n'a plus d'exemplaire libre du %s au %s: %w", number,
+			r.StartDate.Format("02/01/2006 15:04"), r.EndDate.Format("02/01/2006 15:04"), ErrKeyReserved)
+	}
+
+	r.Notes = strings.TrimSpace(r.Notes)
+	r.Status = ReservationPending
+	r.CreatedBy = s.Operator()
+	r.CreatedAt = time.Now()
+	result, err := tx.Exec(`INSERT INTO reservations (key_id, borrower_id, start_date, end_date, notes, status, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.KeyID, r.BorrowerID, r.StartDate, r.EndDate, r.Notes, r.Status, r.CreatedBy, r.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("erreur lors de la création de la réservation: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	r.ID = int(id)
+
+	summary := fmt.Sprintf("Réservation de la clé %s par %s du %s au %s", number, borrowerName,
+		r.StartDate.Format("02/01/2006"), r.EndDate.Format("02/01/2006"))
+	if err := s.auditInsert(tx, AuditEntityReservation, r.ID, summary); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CancelReservation annule une réservation en attente
+func (s *Store) CancelReservation(id int) error {
+	r, err := s.GetReservationByID(id)
+	if err != nil {
+		return err
+	}
+	if r.Status != ReservationPending {
+		return fmt.Errorf("cette réservation n'est plus en attente")
+	}
+	return s.auditedUpdate(AuditEntityReservation, id,
+		fmt.Sprintf("Réservation de la clé %s par %s annulée", r.KeyNumber, r.BorrowerName),
+		`UPDATE reservations SET status = ? WHERE id = ?`, ReservationCancelled, id)
+}
+
+// ConvertReservationToLoan transforme une réservation en attente en emprunt lorsque
+// l'emprunteur se présente ; l'emprunt est à rendre à la fin de la réservation
+func (s *Store) ConvertReservationToLoan(id int) (int, error) {
+	tx, err := s.beginWrite()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	r, err := scanReservation(tx.QueryRow(reservationSelect+` WHERE r.id = ?`, id))
+	if err != nil {
+		return 0, err
+	}
+	if r.Status != ReservationPending {
+		return 0, fmt.Errorf("cette réservation n'est plus en attente")
+	}
+	if r.Expired(time.Now()) {
+		return 0, fmt.Errorf("cette réservation a expiré le %s", r.EndDate.Format("02/01/2006 15:04"))
+	}
+
+	before, err := snapshotRow(tx, AuditEntityReservation, id)
+	if err != nil {
+		return 0, err
+	}
+
+	loanID, err := s.createLoanTx(tx, r.KeyID, r.BorrowerID, r.BorrowerName, LoanOptions{DueDate: &r.EndDate})
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`UPDATE reservations SET status = ?, loan_id = ? WHERE id = ?`, ReservationConverted, loanID, id)
+	if err != nil {
+		return 0, err
+	}
+	after, err := snapshotRow(tx, AuditEntityReservation, id)
+	if err != nil {
+		return 0, err
+	}
+	summary := fmt.Sprintf("Réservation de la clé %s par %s convertie en emprunt", r.KeyNumber, r.BorrowerName)
+	if err := s.audit(tx, AuditUpdate, AuditEntityReservation, id, summary, before, after); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return loanID, nil
+}
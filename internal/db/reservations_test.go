@@ -0,0 +1,142 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// reserveTomorrow réserve la clé pour l'emprunteur pendant toute la journée de demain
+func reserveTomorrow(t *testing.T, s *Store, keyID, borrowerID int) *Reservation {
+	t.Helper()
+	tomorrow := time.Now().AddDate(0, 0, 1)
+	r := &Reservation{
+		KeyID:      keyID,
+		BorrowerID: borrowerID,
+		StartDate:  time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), 8, 0, 0, 0, time.Local),
+		EndDate:    time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), 18, 0, 0, 0, time.Local),
+	}
+	if err := s.CreateReservation(r); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestOpenEndedLoanRespectsFutureReservations(t *testing.T) {
+	s := newTestStore(t)
+	key := createTestKey(t, s, "K001", 2)
+	alice := createTestBorrower(t, s, "Alice", "Martin")
+	bob := createTestBorrower(t, s, "Bob", "Durand")
+	carol := createTestBorrower(t, s, "Carol", "Petit")
+
+	// Les deux exemplaires sont promis pour demain
+	reserveTomorrow(t, s, key.ID, alice.ID)
+	reserveTomorrow(t, s, key.ID, bob.ID)
+
+	// Sans échéance, l'emprunt empiéterait sur les réservations
+	err := s.CreateLoan(key.ID, carol.ID)
+	if !errors.Is(err, ErrKeyReserved) {
+		t.Fatalf("CreateLoan() sans échéance = %v, ErrKeyReserved attendu", err)
+	}
+
+	// Rendue avant la première réservation, la clé peut être prêtée
+	tonight := time.Now().Add(time.Hour)
+	if err := s.CreateMultipleLoans([]int{key.ID}, carol.ID, LoanOptions{DueDate: &tonight}); err != nil {
+		t.Fatalf("CreateMultipleLoans() rendu avant la réservation: %v", err)
+	}
+}
+
+func TestConvertReservationToLoan(t *testing.T) {
+	s := newTestStore(t)
+	key := createTestKey(t, s, "K001", 1)
+	alice := createTestBorrower(t, s, "Alice", "Martin")
+	bob := createTestBorrower(t, s, "Bob", "Durand")
+
+	now := time.Now()
+	r := &Reservation{KeyID: key.ID, BorrowerID: alice.ID, StartDate: now.Add(-time.Hour), EndDate: now.Add(48 * time.Hour)}
+	if err := s.CreateReservation(r); err != nil {
+		t.Fatal(err)
+	}
+
+	// Le seul exemplaire est réservé : un autre emprunteur ne peut pas le prendre
+	if err := s.CreateLoan(key.ID, bob.ID); !errors.Is(err, ErrKeyReserved) {
+		t.Fatalf("CreateLoan() pendant la réservation d'un autre = %v, ErrKeyReserved attendu", err)
+	}
+
+	loanID, err := s.ConvertReservationToLoan(r.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loan, err := s.GetLoanByID(loanID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loan.BorrowerID != alice.ID || loan.DueDate == nil || !loan.DueDate.Equal(r.EndDate) {
+		t.Errorf("emprunt converti = emprunteur %d, échéance %v ; attendu %d, %v", loan.BorrowerID, loan.DueDate, alice.ID, r.EndDate)
+	}
+
+	converted, err := s.GetReservationByID(r.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if converted.Status != ReservationConverted || converted.LoanID == nil || *converted.LoanID != loanID {
+		t.Errorf("réservation = %s (emprunt %v), convertie vers l'emprunt %d attendue", converted.Status, converted.LoanID, loanID)
+	}
+	if _, err := s.ConvertReservationToLoan(r.ID); err == nil {
+		t.Error("une réservation déjà convertie ne doit pas l'être une seconde fois")
+	}
+}
+
+func TestAvailabilityMatchesCreateLoan(t *testing.T) {
+	s := newTestStore(t)
+	openEndedKey := createTestKey(t, s, "K001", 1)
+	dayKey := &Key{Number: "K002", Description: "Clé K002", QuantityTotal: 1, DefaultLoanDays: 1}
+	if err := s.CreateKey(dayKey, nil); err != nil {
+		t.Fatal(err)
+	}
+	alice := createTestBorrower(t, s, "Alice", "Martin")
+	bob := createTestBorrower(t, s, "Bob", "Durand")
+
+	// Réservée demain, la clé sans échéance ne peut plus sortir ; la clé prêtée
+	// pour un jour est rendue avant une réservation dans cinq jours
+	reserveTomorrow(t, s, openEndedKey.ID, alice.ID)
+	in5Days := time.Now().AddDate(0, 0, 5)
+	r := &Reservation{KeyID: dayKey.ID, BorrowerID: alice.ID, StartDate: in5Days, EndDate: in5Days.Add(time.Hour)}
+	if err := s.CreateReservation(r); err != nil {
+		t.Fatal(err)
+	}
+
+	available, err := s.GetAvailableKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	listed := make(map[int]bool)
+	for _, k := range available {
+		listed[k.ID] = true
+	}
+	withAvailability, err := s.GetKeysWithAvailability()
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[int]int)
+	for _, k := range withAvailability {
+		counts[k.ID] = k.AvailableCount
+	}
+
+	for _, c := range []struct {
+		key  *Key
+		want bool
+	}{{openEndedKey, false}, {dayKey, true}} {
+		checked, err := s.CheckKeyAvailability(c.key.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if checked != c.want || listed[c.key.ID] != c.want || (counts[c.key.ID] > 0) != c.want {
+			t.Errorf("%s : CheckKeyAvailability() = %v, GetAvailableKeys() = %v, AvailableCount = %d ; disponible %v attendu",
+				c.key.Number, checked, listed[c.key.ID], counts[c.key.ID], c.want)
+		}
+		if err := s.CreateLoan(c.key.ID, bob.ID); (err == nil) != c.want {
+			t.Errorf("%s : CreateLoan() = %v alors que la clé est annoncée disponible %v", c.key.Number, err, c.want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+	"unicode"
+)
+
+// searchMatchQuery transforme la saisie de l'utilisateur en requête FTS5 : chaque mot
+// doit apparaître, éventuellement comme début de mot (« bat » trouve « Bâtiment »).
+// La ponctuation est ignorée, ce qui neutralise la syntaxe FTS5 dans la saisie.
+func searchMatchQuery(query string) string {
+	words := strings.FieldsFunc(query, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	terms := make([]string, len(words))
+	for i, w := range words {
+		terms[i] = `"` + w + `"*`
+	}
+	return strings.Join(terms, " ")
+}
+
+// Search recherche dans les clés, salles, bâtiments et emprunteurs du site sélectionné hors
+// archives, ainsi que dans les champs personnalisés des clés, salles et emprunteurs, sans tenir
+// compte des accents ni de la casse. Les résultats sont groupés dans l'ordre de SearchEntities,
+// les plus pertinents en tête, avec au plus limit résultats par catégorie.
+func (s *Store) Search(query string, limit int) ([]SearchResult, error) {
+	match := searchMatchQuery(query)
+	if match == "" {
+		return nil, nil
+	}
+
+	site, siteArgs := s.siteCondition(`(SELECT rs.site_id FROM record_sites rs
+			                  WHERE rs.entity = f.entity AND rs.id = f.entity_id)`)
+	args := append([]interface{}{match}, siteArgs...)
+	args = append(args, AuditEntityKey, AuditEntityRoom, AuditEntityBuilding, AuditEntityBorrower, limit)
+
+	// Les champs personnalisés sont indexés sous « custom:<table> » : un enregistrement trouvé
+	// par ses champs et par ses colonnes n'apparaît qu'une fois, au meilleur rang
+	rows, err := s.db().Query(`
+		SELECT m.entity, m.entity_id, coalesce(k.number, r.name, bu.name, bo.name, ''),
+		       k.description, k.storage_location, r.type, b.name, bo.email
+		FROM (
+			SELECT entity, entity_id,
+			       ROW_NUMBER() OVER (PARTITION BY entity ORDER BY MIN(rank)) AS position
+			FROM (
+				SELECT replace(entity, 'custom:', '') AS entity, entity_id, rank
+				FROM search_index
+				WHERE search_index MATCH ?
+			) f
+			WHERE NOT EXISTS (SELECT 1 FROM archived_records a
+			                  WHERE a.entity = f.entity AND a.id = f.entity_id)`+site+`
+			GROUP BY entity, entity_id
+		) m
+		LEFT JOIN keys k ON m.entity = ? AND k.id = m.entity_id
+		LEFT JOIN rooms r ON m.entity = ? AND r.id = m.entity_id
+		LEFT JOIN buildings b ON b.id = r.building_id
+		LEFT JOIN buildings bu ON m.entity = ? AND bu.id = m.entity_id
+		LEFT JOIN borrowers bo ON m.entity = ? AND bo.id = m.entity_id
+		WHERE m.position <= ?
+		ORDER BY m.position`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	grouped := make(map[string][]SearchResult)
+	for rows.Next() {
+		var r SearchResult
+		var description, location, roomType, buildingName, email sql.NullString
+		err := rows.Scan(&r.Entity, &r.ID, &r.Title, &description, &location, &roomType, &buildingName, &email)
+		if err != nil {
+			return nil, err
+		}
+		// Le détail affiché est relu dans les tables ; pour les salles, il inclut le bâtiment
+		switch r.Entity {
+		case AuditEntityKey:
+			r.Detail = joinNonEmpty(" - ", description.String, location.String)
+		case AuditEntityRoom:
+			r.Detail = joinNonEmpty(" - ", roomType.String, buildingName.String)
+		case AuditEntityBorrower:
+			r.Detail = email.String
+		}
+		grouped[r.Entity] = append(grouped[r.Entity], r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var results []SearchResult
+	for _, entity := range SearchEntities {
+		results = append(results, grouped[entity]...)
+	}
+	return results, nil
+}
+
+// joinNonEmpty assemble les valeurs non vides avec sep
+func joinNonEmpty(sep string, values ...string) string {
+	var parts []string
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			parts = append(parts, v)
+		}
+	}
+	return strings.Join(parts, sep)
+}
@@ -0,0 +1,68 @@
+package db
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"image/png"
+	"strings"
+	"time"
+)
+
+// maxSignatureSize est la taille maximale de l'image d'une signature
+const maxSignatureSize = 512 << 10
+
+// checkSignature vérifie qu'une signature est une image PNG de taille raisonnable
+func checkSignature(signature []byte) error {
+	if len(signature) > maxSignatureSize {
+		return fmt.Errorf("l'image de la signature dépasse %s", formatFileSize(maxSignatureSize))
+	}
+	if _, err := png.DecodeConfig(bytes.NewReader(signature)); err != nil {
+		return fmt.Errorf("l'image de la signature n'est pas valide: %w", err)
+	}
+	return nil
+}
+
+// saveSignature enregistre dans tx la signature de l'emprunteur pour un emprunt
+func saveSignature(tx *sql.Tx, loanID int, kind SignatureKind, signature []byte, signedAt time.Time) error {
+	_, err := tx.Exec(`INSERT OR REPLACE INTO loan_signatures (loan_id, kind, image, signed_at) VALUES (?, ?, ?, ?)`,
+		loanID, kind, signature, signedAt)
+	return err
+}
+
+// LoadLoanSignatures complète les emprunts avec leurs signatures, pour les reçus
+func (s *Store) LoadLoanSignatures(loans []LoanWithDetails) error {
+	if len(loans) == 0 {
+		return nil
+	}
+	index := make(map[int]int, len(loans))
+	args := make([]interface{}, len(loans))
+	for i, l := range loans {
+		index[l.ID] = i
+		args[i] = l.ID
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(loans)), ", ")
+	rows, err := s.db().Query(`SELECT loan_id, kind, image FROM loan_signatures WHERE loan_id IN (`+placeholders+`)`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var loanID int
+		var kind SignatureKind
+		var signature []byte
+		if err := rows.Scan(&loanID, &kind, &signature); err != nil {
+			return err
+		}
+		l := &loans[index[loanID]]
+		switch kind {
+		case SignatureLoan:
+			l.Signature = signature
+		case SignatureReturn:
+			l.ReturnSignature = signature
+		}
+	}
+	return rows.Err()
+}
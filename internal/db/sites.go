@@ -0,0 +1,233 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrNoSiteSelected est retournée lorsqu'une création est demandée depuis la vue consolidée :
+// un enregistrement appartient toujours à un site précis
+var ErrNoSiteSelected = errors.New("aucun site sélectionné")
+
+// SetSite restreint les listes, rapports et recherches suivants au site id (0 : tous les sites)
+func (s *Store) SetSite(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.site = id
+}
+
+// Site retourne le site sélectionné (0 pour la vue consolidée de tous les sites)
+func (s *Store) Site() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.site
+}
+
+// siteCondition retourne la condition « AND column = ? » restreignant une requête au site
+// sélectionné, ou une condition vide dans la vue consolidée
+func (s *Store) siteCondition(column string) (string, []interface{}) {
+	site := s.Site()
+	if site == 0 {
+		return "", nil
+	}
+	return " AND " + column + " = ?", []interface{}{site}
+}
+
+// createSite retourne le site des enregistrements créés (le site sélectionné)
+func (s *Store) createSite() (int, error) {
+	site := s.Site()
+	if site == 0 {
+		return 0, fmt.Errorf("%w : choisissez un site avant de créer un enregistrement", ErrNoSiteSelected)
+	}
+	return site, nil
+}
+
+// siteOrDefault retourne le site sélectionné ou, dans la vue consolidée, le premier site.
+// Utilisé par l'importation et les données de démonstration.
+func (s *Store) siteOrDefault(q rowQueryer) (int, error) {
+	if site := s.Site(); site != 0 {
+		return site, nil
+	}
+	var site int
+	err := q.QueryRow(`SELECT MIN(id) FROM sites`).Scan(&site)
+	return site, err
+}
+
+// GetAllSites récupère tous les sites
+func (s *Store) GetAllSites() ([]Site, error) {
+	rows, err := s.db().Query(`SELECT id, name FROM sites ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sites []Site
+	for rows.Next() {
+		var site Site
+		if err := rows.Scan(&site.ID, &site.Name); err != nil {
+			return nil, err
+		}
+		sites = append(sites, site)
+	}
+	return sites, rows.Err()
+}
+
+// CreateSite crée un nouveau site
+func (s *Store) CreateSite(site *Site) error {
+	site.Name = strings.TrimSpace(site.Name)
+	if site.Name == "" {
+		return fmt.Errorf("le nom du site est requis")
+	}
+
+	tx, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`INSERT INTO sites (name) VALUES (?)`, site.Name)
+	if err != nil {
+		return fmt.Errorf("erreur lors de la création du site %s: %w", site.Name, err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	site.ID = int(id)
+
+	if err := s.auditInsert(tx, AuditEntitySite, site.ID, "Site "+site.Name+" créé"); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateSite renomme un site
+func (s *Store) UpdateSite(site *Site) error {
+	site.Name = strings.TrimSpace(site.Name)
+	if site.Name == "" {
+		return fmt.Errorf("le nom du site est requis")
+	}
+	return s.auditedUpdate(AuditEntitySite, site.ID, "Site "+site.Name+" modifié",
+		`UPDATE sites SET name = ? WHERE id = ?`, site.Name, site.ID)
+}
+
+// DeleteSite supprime un site vide : ni clé, ni bâtiment, ni emprunteur, même archivé.
+// Le dernier site ne peut pas être supprimé.
+func (s *Store) DeleteSite(id int) error {
+	tx, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := snapshotRow(tx, AuditEntitySite, id)
+	if err != nil {
+		return err
+	}
+	if before == nil {
+		return sql.ErrNoRows
+	}
+
+	var sites, records int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM sites`).Scan(&sites); err != nil {
+		return err
+	}
+	if sites <= 1 {
+		return fmt.Errorf("le dernier site ne peut pas être supprimé")
+	}
+	err = tx.QueryRow(`SELECT COUNT(*) FROM record_sites WHERE site_id = ? AND entity <> ?`, id, AuditEntityRoom).Scan(&records)
+	if err != nil {
+		return err
+	}
+	if records > 0 {
+		return fmt.Errorf("le site %v contient encore %d clé(s), bâtiment(s) ou emprunteur(s)", before["name"], records)
+	}
+
+	// Sans clé, les emplacements de rangement du site sont vides
+	if _, err := tx.Exec(`DELETE FROM storage_locations WHERE site_id = ?`, id); err != nil {
+		return err
+	}
+	// Sans bâtiment, ses zones ne contiennent plus aucune salle
+	if _, err := tx.Exec(`DELETE FROM zones WHERE site_id = ?`, id); err != nil {
+		return err
+	}
+	// Leurs valeurs ont été supprimées avec les enregistrements du site
+	if _, err := tx.Exec(`DELETE FROM custom_fields WHERE site_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM sites WHERE id = ?`, id); err != nil {
+		return err
+	}
+	summary := fmt.Sprintf("Site %v supprimé", before["name"])
+	if err := s.audit(tx, AuditDelete, AuditEntitySite, id, summary, before, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetSiteSummaries récupère les chiffres clés de chaque site pour le rapport consolidé,
+// quel que soit le site sélectionné. Les enregistrements archivés ne sont pas comptés.
+func (s *Store) GetSiteSummaries() ([]SiteSummary, error) {
+	rows, err := s.db().Query(`
+		SELECT si.id, si.name,
+		       (SELECT COUNT(*) FROM buildings b WHERE b.site_id = si.id AND b.archived_at IS NULL),
+		       (SELECT COUNT(*) FROM rooms r INNER JOIN buildings b ON b.id = r.building_id
+		        WHERE b.site_id = si.id AND r.archived_at IS NULL AND b.archived_at IS NULL),
+		       (SELECT COUNT(*) FROM keys k WHERE k.site_id = si.id AND k.archived_at IS NULL),
+		       (SELECT COUNT(*) FROM key_copies c INNER JOIN keys k ON k.id = c.key_id
+		        WHERE k.site_id = si.id AND k.archived_at IS NULL AND c.status IN (?, ?)),
+		       (SELECT COUNT(*) FROM borrowers b WHERE b.site_id = si.id AND b.archived_at IS NULL),
+		       (SELECT COUNT(*) FROM loans l INNER JOIN keys k ON k.id = l.key_id
+		        WHERE k.site_id = si.id AND l.return_date IS NULL),
+		       (SELECT COALESCE(SUM(l.deposit_amount), 0) FROM loans l INNER JOIN keys k ON k.id = l.key_id
+		        WHERE k.site_id = si.id AND l.deposit_status = ?)
+		FROM sites si
+		ORDER BY si.name`, CopyAvailable, CopyLoaned, DepositHeld)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var summaries []SiteSummary
+	index := make(map[int]int)
+	for rows.Next() {
+		var sum SiteSummary
+		err := rows.Scan(&sum.ID, &sum.Name, &sum.Buildings, &sum.Rooms, &sum.Keys, &sum.Copies,
+			&sum.Borrowers, &sum.ActiveLoans, &sum.HeldDeposits)
+		if err != nil {
+			return nil, err
+		}
+		index[sum.ID] = len(summaries)
+		summaries = append(summaries, sum)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Les retards sont évalués en Go : les dates sont stockées avec leur fuseau horaire
+	dueRows, err := s.db().Query(`
+		SELECT k.site_id, l.due_date FROM loans l
+		INNER JOIN keys k ON k.id = l.key_id
+		WHERE l.return_date IS NULL AND l.due_date IS NOT NULL`)
+	if err != nil {
+		return nil, err
+	}
+	defer dueRows.Close()
+
+	now := time.Now()
+	for dueRows.Next() {
+		var siteID int
+		var due time.Time
+		if err := dueRows.Scan(&siteID, &due); err != nil {
+			return nil, err
+		}
+		if i, ok := index[siteID]; ok && now.After(due) {
+			summaries[i].OverdueLoans++
+		}
+	}
+	return summaries, dueRows.Err()
+}
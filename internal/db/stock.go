@@ -0,0 +1,293 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// addStockMovement inscrit un mouvement au registre de stock dans tx, au nom de l'opérateur courant
+func (s *Store) addStockMovement(tx *sql.Tx, keyID int, copyID *int, movement StockMovementType, quantity int, reason string) error {
+	_, err := tx.Exec(`INSERT INTO stock_movements (key_id, copy_id, movement_type, quantity, reason, operator, moved_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`, keyID, copyID, movement, quantity, reason, s.Operator(), time.Now())
+	return err
+}
+
+// syncKeyQuantities recalcule les quantités de la clé à partir de son registre de stock
+func syncKeyQuantities(tx *sql.Tx, keyID int) error {
+	_, err := tx.Exec(`UPDATE keys SET
+		quantity_total = (SELECT COALESCE(SUM(CASE
+			WHEN movement_type IN (?, ?, ?) THEN quantity
+			WHEN movement_type IN (?, ?) THEN -quantity
+			ELSE 0 END), 0) FROM stock_movements WHERE key_id = ?),
+		quantity_reserve = (SELECT COALESCE(SUM(CASE movement_type
+			WHEN ? THEN quantity
+			WHEN ? THEN -quantity
+			ELSE 0 END), 0) FROM stock_movements WHERE key_id = ?)
+		WHERE id = ?`,
+		MovementPurchase, MovementDuplication, MovementFound, MovementDestruction, MovementLoss, keyID,
+		MovementToReserve, MovementFromReserve, keyID, keyID)
+	return err
+}
+
+// copyStatusMovements retourne les mouvements de stock qu'entraîne le changement d'état d'un
+// exemplaire, dans l'ordre : un exemplaire en réserve qui est détruit sort d'abord de la réserve
+func copyStatusMovements(from, to CopyStatus) []StockMovementType {
+	var movements []StockMovementType
+	if from == CopyReserve && to != CopyReserve {
+		movements = append(movements, MovementFromReserve)
+	}
+	if !from.InService() && to.InService() {
+		movements = append(movements, MovementFound)
+	}
+	if from.InService() && !to.InService() {
+		if to == CopyDestroyed {
+			movements = append(movements, MovementDestruction)
+		} else {
+			movements = append(movements, MovementLoss)
+		}
+	}
+	if to == CopyReserve && from != CopyReserve {
+		movements = append(movements, MovementToReserve)
+	}
+	return movements
+}
+
+// RecordStockMovement inscrit un mouvement de stock saisi par un gestionnaire et l'applique
+// aux exemplaires : un achat ou une reproduction en crée, une destruction ou une perte retire
+// des exemplaires disponibles (les derniers d'abord), la réserve en reçoit ou en rend.
+// Les quantités de la clé sont ensuite recalculées à partir du registre.
+func (s *Store) RecordStockMovement(keyID int, movement StockMovementType, quantity int, reason string) error {
+	reason = strings.TrimSpace(reason)
+	if quantity < 1 {
+		return fmt.Errorf("la quantité d'un mouvement doit être d'au moins un exemplaire")
+	}
+	if reason == "" {
+		return fmt.Errorf("le motif du mouvement est requis")
+	}
+
+	tx, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := snapshotRow(tx, AuditEntityKey, keyID)
+	if err != nil {
+		return err
+	}
+	if before == nil {
+		return sql.ErrNoRows
+	}
+
+	var copyIDs []int
+	switch movement {
+	case MovementPurchase, MovementDuplication:
+		for i := 0; i < quantity; i++ {
+			identifier, err := nextCopyIdentifier(tx, keyID)
+			if err != nil {
+				return err
+			}
+			result, err := tx.Exec(`INSERT INTO key_copies (key_id, identifier, status) VALUES (?, ?, ?)`,
+				keyID, identifier, CopyAvailable)
+			if err != nil {
+				return err
+			}
+			id, err := result.LastInsertId()
+			if err != nil {
+				return err
+			}
+			copyIDs = append(copyIDs, int(id))
+		}
+	case MovementDestruction, MovementLoss:
+		status := CopyDestroyed
+		if movement == MovementLoss {
+			status = CopyLost
+		}
+		copyIDs, err = moveCopies(tx, keyID, CopyAvailable, status, quantity, reason)
+		if err != nil {
+			return err
+		}
+	case MovementToReserve:
+		if copyIDs, err = moveCopies(tx, keyID, CopyAvailable, CopyReserve, quantity, ""); err != nil {
+			return err
+		}
+	case MovementFromReserve:
+		if copyIDs, err = moveCopies(tx, keyID, CopyReserve, CopyAvailable, quantity, ""); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("mouvement de stock inconnu : %s", movement)
+	}
+
+	// Un mouvement d'un seul exemplaire désigne cet exemplaire
+	var copyID *int
+	if len(copyIDs) == 1 {
+		copyID = &copyIDs[0]
+	}
+	if err := s.addStockMovement(tx, keyID, copyID, movement, quantity, reason); err != nil {
+		return err
+	}
+	if err := syncKeyQuantities(tx, keyID); err != nil {
+		return err
+	}
+
+	after, err := snapshotRow(tx, AuditEntityKey, keyID)
+	if err != nil {
+		return err
+	}
+	summary := fmt.Sprintf("Clé %v : %s de %d exemplaire(s) (%s)", before["number"], movement.Label(), quantity, reason)
+	if err := s.audit(tx, AuditUpdate, AuditEntityKey, keyID, summary, before, after); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// moveCopies fait passer quantity exemplaires d'une clé de l'état from à l'état to,
+// les derniers d'abord. Un exemplaire qui sort du stock libère son crochet et garde
+// le motif en note.
+func moveCopies(tx *sql.Tx, keyID int, from, to CopyStatus, quantity int, notes string) ([]int, error) {
+	rows, err := tx.Query(`SELECT id FROM key_copies WHERE key_id = ? AND status = ? ORDER BY id DESC LIMIT ?`,
+		keyID, from, quantity)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) < quantity {
+		return nil, fmt.Errorf("la clé ne compte que %d exemplaire(s) à l'état %s", len(ids), strings.ToLower(from.Label()))
+	}
+
+	for _, id := range ids {
+		if to.InService() {
+			_, err = tx.Exec(`UPDATE key_copies SET status = ? WHERE id = ?`, to, id)
+		} else {
+			_, err = tx.Exec(`UPDATE key_copies SET status = ?, notes = ?, hook_number = NULL WHERE id = ?`, to, notes, id)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+// GetStockMovements récupère le registre de stock des clés du site sélectionné, du plus
+// ancien au plus récent mouvement
+func (s *Store) GetStockMovements(f StockMovementFilter) ([]StockMovement, error) {
+	site, args := s.siteCondition("k.site_id")
+	query := `
+		SELECT m.id, m.key_id, k.number, m.copy_id, c.identifier, m.movement_type, m.quantity,
+		       m.reason, m.operator, m.moved_at
+		FROM stock_movements m
+		INNER JOIN keys k ON k.id = m.key_id
+		LEFT JOIN key_copies c ON c.id = m.copy_id
+		WHERE 1 = 1` + site
+	if f.KeyID > 0 {
+		query += ` AND m.key_id = ?`
+		args = append(args, f.KeyID)
+	}
+	// Comparaison sur AAAA-MM-JJ HH:MM:SS, comme pour l'historique des emprunts
+	if f.From != nil {
+		query += ` AND substr(m.moved_at, 1, 19) >= ?`
+		args = append(args, f.From.Format("2006-01-02 15:04:05"))
+	}
+	if f.To != nil {
+		query += ` AND substr(m.moved_at, 1, 19) <= ?`
+		args = append(args, f.To.Format("2006-01-02 15:04:05"))
+	}
+	query += ` ORDER BY m.moved_at, m.id`
+
+	rows, err := s.db().Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var movements []StockMovement
+	for rows.Next() {
+		var m StockMovement
+		var copyID sql.NullInt64
+		var identifier, operator sql.NullString
+		err := rows.Scan(&m.ID, &m.KeyID, &m.KeyNumber, &copyID, &identifier, &m.Type, &m.Quantity,
+			&m.Reason, &operator, &m.MovedAt)
+		if err != nil {
+			return nil, err
+		}
+		if copyID.Valid {
+			id := int(copyID.Int64)
+			m.CopyID = &id
+		}
+		m.CopyIdentifier = identifier.String
+		m.Operator = operator.String
+		movements = append(movements, m)
+	}
+	return movements, rows.Err()
+}
+
+// openStockLedger ouvre le registre de stock des clés qui n'y ont encore aucun mouvement :
+// leurs exemplaires en service y entrent comme stock initial, puis leur réserve.
+// Utilisée par la migration et par les importations.
+func openStockLedger(tx *sql.Tx) error {
+	type openingStock struct {
+		keyID, total, reserve int
+	}
+
+	rows, err := tx.Query(`SELECT k.id,
+		(SELECT COUNT(*) FROM key_copies c WHERE c.key_id = k.id AND c.status NOT IN (?, ?, ?)),
+		(SELECT COUNT(*) FROM key_copies c WHERE c.key_id = k.id AND c.status = ?)
+		FROM keys k
+		WHERE k.id NOT IN (SELECT key_id FROM stock_movements)`,
+		CopyLost, CopyStolen, CopyDestroyed, CopyReserve)
+	if err != nil {
+		return err
+	}
+	var stocks []openingStock
+	for rows.Next() {
+		var o openingStock
+		if err := rows.Scan(&o.keyID, &o.total, &o.reserve); err != nil {
+			rows.Close()
+			return err
+		}
+		stocks = append(stocks, o)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	const reason = "Stock initial à l'ouverture du registre"
+	now := time.Now()
+	for _, o := range stocks {
+		if o.total > 0 {
+			_, err := tx.Exec(`INSERT INTO stock_movements (key_id, movement_type, quantity, reason, moved_at) VALUES (?, ?, ?, ?, ?)`,
+				o.keyID, MovementPurchase, o.total, reason, now)
+			if err != nil {
+				return err
+			}
+		}
+		if o.reserve > 0 {
+			_, err := tx.Exec(`INSERT INTO stock_movements (key_id, movement_type, quantity, reason, moved_at) VALUES (?, ?, ?, ?, ?)`,
+				o.keyID, MovementToReserve, o.reserve, reason, now)
+			if err != nil {
+				return err
+			}
+		}
+		if err := syncKeyQuantities(tx, o.keyID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,643 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// locationSelect est la requête commune aux lectures d'emplacements, avec le nombre de clés
+// en service qui y sont rangées et le nombre de crochets occupés
+const locationSelect = `
+		SELECT sl.id, sl.site_id, sl.name, sl.hook_count, sl.notes,
+		       (SELECT COUNT(*) FROM keys k WHERE k.storage_location_id = sl.id AND k.archived_at IS NULL),
+		       (SELECT COUNT(DISTINCT c.hook_number) FROM key_copies c INNER JOIN keys k ON k.id = c.key_id
+		        WHERE k.storage_location_id = sl.id AND k.archived_at IS NULL
+		          AND c.hook_number IS NOT NULL AND c.status NOT IN (?, ?, ?))
+		FROM storage_locations sl`
+
+// locationArgs sont les arguments de locationSelect
+func locationArgs(args ...interface{}) []interface{} {
+	return append([]interface{}{CopyLost, CopyStolen, CopyDestroyed}, args...)
+}
+
+// GetStorageLocations récupère les emplacements de rangement du site sélectionné
+func (s *Store) GetStorageLocations() ([]StorageLocation, error) {
+	site, args := s.siteCondition("sl.site_id")
+	return s.queryLocations(locationSelect+` WHERE 1 = 1`+site+` ORDER BY sl.name`, locationArgs(args...)...)
+}
+
+// GetKeyStorageLocations récupère les emplacements proposés dans le formulaire d'une clé :
+// ceux du site de la clé (keyID 0 : ceux du site sélectionné, pour une création)
+func (s *Store) GetKeyStorageLocations(keyID int) ([]StorageLocation, error) {
+	return s.queryLocations(locationSelect+`
+		WHERE sl.site_id = COALESCE((SELECT site_id FROM keys WHERE id = ?), ?)
+		ORDER BY sl.name`, locationArgs(keyID, s.Site())...)
+}
+
+// GetStorageLocationByID récupère un emplacement par son ID
+func (s *Store) GetStorageLocationByID(id int) (*StorageLocation, error) {
+	loc, err := scanLocation(s.db().QueryRow(locationSelect+` WHERE sl.id = ?`, locationArgs(id)...))
+	if err != nil {
+		return nil, err
+	}
+	return &loc, nil
+}
+
+// queryLocations exécute une requête basée sur locationSelect
+func (s *Store) queryLocations(query string, args ...interface{}) ([]StorageLocation, error) {
+	rows, err := s.db().Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var locations []StorageLocation
+	for rows.Next() {
+		loc, err := scanLocation(rows)
+		if err != nil {
+			return nil, err
+		}
+		locations = append(locations, loc)
+	}
+	return locations, rows.Err()
+}
+
+// scanLocation lit une ligne de locationSelect
+func scanLocation(row rowScanner) (StorageLocation, error) {
+	var loc StorageLocation
+	var notes sql.NullString
+	err := row.Scan(&loc.ID, &loc.SiteID, &loc.Name, &loc.HookCount, &notes, &loc.KeyCount, &loc.UsedHooks)
+	loc.Notes = notes.String
+	return loc, err
+}
+
+// checkLocation valide un emplacement saisi et refuse un doublon du même site,
+// y compris une variante de casse, d'accents ou d'espaces d'un nom existant
+func checkLocation(tx *sql.Tx, loc *StorageLocation) error {
+	loc.Name = strings.Join(strings.Fields(loc.Name), " ")
+	if loc.Name == "" {
+		return fmt.Errorf("le nom de l'emplacement est requis")
+	}
+	if loc.HookCount < 0 {
+		return fmt.Errorf("le nombre de crochets doit être positif ou nul")
+	}
+
+	rows, err := tx.Query(`SELECT name FROM storage_locations WHERE site_id = ? AND id <> ?`, loc.SiteID, loc.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	normalized := normalizeLocationName(loc.Name)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		if normalizeLocationName(name) == normalized {
+			return fmt.Errorf("l'emplacement %s existe déjà sur ce site", name)
+		}
+	}
+	return rows.Err()
+}
+
+// CreateStorageLocation crée un emplacement de rangement dans le site sélectionné
+func (s *Store) CreateStorageLocation(loc *StorageLocation) error {
+	siteID, err := s.createSite()
+	if err != nil {
+		return err
+	}
+	loc.SiteID = siteID
+
+	tx, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkLocation(tx, loc); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(`INSERT INTO storage_locations (site_id, name, hook_count, notes) VALUES (?, ?, ?, ?)`,
+		loc.SiteID, loc.Name, loc.HookCount, loc.Notes)
+	if err != nil {
+		return fmt.Errorf("erreur lors de la création de l'emplacement %s: %w", loc.Name, err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	loc.ID = int(id)
+
+	if err := s.auditInsert(tx, AuditEntityLocation, loc.ID, "Emplacement "+loc.Name+" créé"); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateStorageLocation renomme un emplacement ou change son nombre de crochets.
+// Une armoire ne peut pas être réduite en deçà d'un crochet encore attribué.
+func (s *Store) UpdateStorageLocation(loc *StorageLocation) error {
+	tx, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := snapshotRow(tx, AuditEntityLocation, loc.ID)
+	if err != nil {
+		return err
+	}
+	if before == nil {
+		return sql.ErrNoRows
+	}
+	if err := tx.QueryRow(`SELECT site_id FROM storage_locations WHERE id = ?`, loc.ID).Scan(&loc.SiteID); err != nil {
+		return err
+	}
+	if err := checkLocation(tx, loc); err != nil {
+		return err
+	}
+
+	var highest int
+	err = tx.QueryRow(`SELECT COALESCE(MAX(c.hook_number), 0) FROM key_copies c
+		INNER JOIN keys k ON k.id = c.key_id
+		WHERE k.storage_location_id = ? AND k.archived_at IS NULL AND c.status NOT IN (?, ?, ?)`,
+		loc.ID, CopyLost, CopyStolen, CopyDestroyed).Scan(&highest)
+	if err != nil {
+		return err
+	}
+	if highest > loc.HookCount {
+		return fmt.Errorf("le crochet %d est encore attribué : libérez-le avant de réduire l'armoire à %d crochet(s)", highest, loc.HookCount)
+	}
+
+	// Les exemplaires hors service ou archivés ne gardent pas un crochet qui disparaît
+	_, err = tx.Exec(`UPDATE key_copies SET hook_number = NULL
+		WHERE hook_number > ? AND key_id IN (SELECT id FROM keys WHERE storage_location_id = ?)`, loc.HookCount, loc.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE storage_locations SET name = ?, hook_count = ?, notes = ? WHERE id = ?`,
+		loc.Name, loc.HookCount, loc.Notes, loc.ID)
+	if err != nil {
+		return fmt.Errorf("erreur lors de la modification de l'emplacement %s: %w", loc.Name, err)
+	}
+	_, err = tx.Exec(`UPDATE keys SET storage_location = ? WHERE storage_location_id = ?`, loc.Name, loc.ID)
+	if err != nil {
+		return err
+	}
+
+	after, err := snapshotRow(tx, AuditEntityLocation, loc.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.audit(tx, AuditUpdate, AuditEntityLocation, loc.ID, "Emplacement "+loc.Name+" modifié", before, after); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MergeStorageLocation fusionne l'emplacement fromID dans intoID (ex: « Acceuil » dans
+// « Accueil ») : ses clés y sont déplacées puis il est supprimé. Les crochets qui
+// n'existent pas dans l'armoire de destination ou y sont déjà occupés sont libérés.
+func (s *Store) MergeStorageLocation(fromID, intoID int) error {
+	if fromID == intoID {
+		return fmt.Errorf("un emplacement ne peut pas être fusionné avec lui-même")
+	}
+
+	tx, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := snapshotRow(tx, AuditEntityLocation, fromID)
+	if err != nil {
+		return err
+	}
+	if before == nil {
+		return sql.ErrNoRows
+	}
+
+	var fromName, intoName string
+	var fromSite, intoSite, intoHooks int
+	err = tx.QueryRow(`SELECT name, site_id FROM storage_locations WHERE id = ?`, fromID).Scan(&fromName, &fromSite)
+	if err != nil {
+		return err
+	}
+	err = tx.QueryRow(`SELECT name, site_id, hook_count FROM storage_locations WHERE id = ?`, intoID).Scan(&intoName, &intoSite, &intoHooks)
+	if err != nil {
+		return err
+	}
+	if fromSite != intoSite {
+		return fmt.Errorf("les emplacements %s et %s n'appartiennent pas au même site", fromName, intoName)
+	}
+
+	_, err = tx.Exec(`UPDATE key_copies SET hook_number = NULL
+		WHERE key_id IN (SELECT id FROM keys WHERE storage_location_id = ?)
+		  AND (hook_number > ? OR hook_number IN (
+		      SELECT c.hook_number FROM key_copies c INNER JOIN keys k ON k.id = c.key_id
+		      WHERE k.storage_location_id = ? AND c.hook_number IS NOT NULL))`, fromID, intoHooks, intoID)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(`UPDATE keys SET storage_location_id = ?, storage_location = ? WHERE storage_location_id = ?`,
+		intoID, intoName, fromID)
+	if err != nil {
+		return err
+	}
+	moved, _ := result.RowsAffected()
+
+	if _, err := tx.Exec(`DELETE FROM storage_locations WHERE id = ?`, fromID); err != nil {
+		return err
+	}
+	summary := fmt.Sprintf("Emplacement %s fusionné dans %s (%d clé(s) déplacée(s))", fromName, intoName, moved)
+	if err := s.audit(tx, AuditDelete, AuditEntityLocation, fromID, summary, before, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteStorageLocation supprime un emplacement où aucune clé, même archivée, n'est rangée
+func (s *Store) DeleteStorageLocation(id int) error {
+	tx, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := snapshotRow(tx, AuditEntityLocation, id)
+	if err != nil {
+		return err
+	}
+	if before == nil {
+		return sql.ErrNoRows
+	}
+
+	var keys int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM keys WHERE storage_location_id = ?`, id).Scan(&keys); err != nil {
+		return err
+	}
+	if keys > 0 {
+		return fmt.Errorf("%d clé(s) sont encore rangées à l'emplacement %v : déplacez-les ou fusionnez l'emplacement", keys, before["name"])
+	}
+
+	if _, err := tx.Exec(`DELETE FROM storage_locations WHERE id = ?`, id); err != nil {
+		return err
+	}
+	summary := fmt.Sprintf("Emplacement %v supprimé", before["name"])
+	if err := s.audit(tx, AuditDelete, AuditEntityLocation, id, summary, before, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetCabinetMap récupère le plan d'une armoire : l'exemplaire de chaque crochet, présent
+// ou sorti avec son emprunteur, et les exemplaires en service qui n'ont pas de crochet
+func (s *Store) GetCabinetMap(locationID int) (*CabinetMap, error) {
+	loc, err := s.GetStorageLocationByID(locationID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db().Query(`
+		SELECT c.id, c.key_id, c.identifier, c.status, c.notes, c.hook_number, b.name, k.number, k.description
+		FROM key_copies c
+		INNER JOIN keys k ON k.id = c.key_id
+		LEFT JOIN loans l ON l.copy_id = c.id AND l.return_date IS NULL
+		LEFT JOIN borrowers b ON b.id = l.borrower_id
+		WHERE k.storage_location_id = ? AND k.archived_at IS NULL AND c.status NOT IN (?, ?, ?)
+		ORDER BY c.hook_number, k.number, c.id`, locationID, CopyLost, CopyStolen, CopyDestroyed)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cabinet := &CabinetMap{Location: *loc, Hooks: make([]CabinetHook, loc.HookCount)}
+	for i := range cabinet.Hooks {
+		cabinet.Hooks[i].Number = i + 1
+	}
+	for rows.Next() {
+		var c KeyCopy
+		var notes, borrowerName, description sql.NullString
+		var hook sql.NullInt64
+		var keyNumber string
+		err := rows.Scan(&c.ID, &c.KeyID, &c.Identifier, &c.Status, &notes, &hook, &borrowerName, &keyNumber, &description)
+		if err != nil {
+			return nil, err
+		}
+		c.Notes = notes.String
+		c.HookNumber = int(hook.Int64)
+		c.BorrowerName = borrowerName.String
+
+		entry := CabinetHook{Copy: &c, KeyNumber: keyNumber, KeyDescription: description.String}
+		if n := c.HookNumber; n >= 1 && n <= loc.HookCount && cabinet.Hooks[n-1].Copy == nil {
+			entry.Number = n
+			cabinet.Hooks[n-1] = entry
+			continue
+		}
+		cabinet.Unassigned = append(cabinet.Unassigned, entry)
+	}
+	return cabinet, rows.Err()
+}
+
+// AssignCopyHook range un exemplaire sur un crochet de l'armoire de sa clé (hook 0 : aucun crochet)
+func (s *Store) AssignCopyHook(copyID, hook int) error {
+	tx, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var identifier string
+	var status CopyStatus
+	var locationID sql.NullInt64
+	var hookCount sql.NullInt64
+	err = tx.QueryRow(`SELECT c.identifier, c.status, k.storage_location_id, sl.hook_count
+		FROM key_copies c
+		INNER JOIN keys k ON k.id = c.key_id
+		LEFT JOIN storage_locations sl ON sl.id = k.storage_location_id
+		WHERE c.id = ?`, copyID).Scan(&identifier, &status, &locationID, &hookCount)
+	if err != nil {
+		return err
+	}
+
+	var value interface{}
+	summary := fmt.Sprintf("Exemplaire %s : crochet libéré", identifier)
+	if hook != 0 {
+		if !locationID.Valid || hookCount.Int64 == 0 {
+			return fmt.Errorf("la clé de l'exemplaire %s n'est rangée dans aucune armoire à crochets", identifier)
+		}
+		if hook < 1 || int64(hook) > hookCount.Int64 {
+			return fmt.Errorf("l'armoire compte %d crochet(s) : le crochet %d n'existe pas", hookCount.Int64, hook)
+		}
+		if !status.InService() {
+			return fmt.Errorf("l'exemplaire %s est hors service (%s)", identifier, status.Label())
+		}
+
+		var holder string
+		err = tx.QueryRow(`SELECT c.identifier FROM key_copies c
+			INNER JOIN keys k ON k.id = c.key_id
+			WHERE k.storage_location_id = ? AND k.archived_at IS NULL AND c.hook_number = ? AND c.id <> ?
+			  AND c.status NOT IN (?, ?, ?)`,
+			locationID.Int64, hook, copyID, CopyLost, CopyStolen, CopyDestroyed).Scan(&holder)
+		if err == nil {
+			return fmt.Errorf("le crochet %d est déjà attribué à l'exemplaire %s", hook, holder)
+		}
+		if err != sql.ErrNoRows {
+			return err
+		}
+
+		value = hook
+		summary = fmt.Sprintf("Exemplaire %s : crochet %d", identifier, hook)
+	}
+
+	before, err := snapshotRow(tx, AuditEntityCopy, copyID)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE key_copies SET hook_number = ? WHERE id = ?`, value, copyID); err != nil {
+		return err
+	}
+	after, err := snapshotRow(tx, AuditEntityCopy, copyID)
+	if err != nil {
+		return err
+	}
+	if err := s.audit(tx, AuditUpdate, AuditEntityCopy, copyID, summary, before, after); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// locationAccents retire les accents pour comparer les noms d'emplacements
+var locationAccents = strings.NewReplacer(
+	"à", "a", "â", "a", "ä", "a", "ç", "c", "é", "e", "è", "e", "ê", "e", "ë", "e",
+	"î", "i", "ï", "i", "ô", "o", "ö", "o", "ù", "u", "û", "u", "ü", "u", "ÿ", "y",
+	"œ", "oe", "æ", "ae",
+)
+
+// normalizeLocationName ramène un nom d'emplacement à une forme comparable :
+// « Accueil », « accueil  » et « ACCUEIL » deviennent « accueil »
+func normalizeLocationName(name string) string {
+	return locationAccents.Replace(strings.ToLower(strings.Join(strings.Fields(name), " ")))
+}
+
+// similarLocationNames indique si deux noms normalisés désignent probablement le même lieu :
+// identiques, ou à deux lettres voisines inversées près (« acceuil » et « accueil »).
+// Les chiffres ne sont pas concernés : « Armoire 12 » et « Armoire 21 » restent distinctes.
+func similarLocationNames(a, b string) bool {
+	if a == b {
+		return true
+	}
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) != len(rb) {
+		return false
+	}
+	for i := 0; i < len(ra); i++ {
+		if ra[i] == rb[i] {
+			continue
+		}
+		if i+1 >= len(ra) || ra[i] != rb[i+1] || ra[i+1] != rb[i] {
+			return false
+		}
+		if !unicode.IsLetter(ra[i]) || !unicode.IsLetter(ra[i+1]) {
+			return false
+		}
+		return string(ra[i+2:]) == string(rb[i+2:])
+	}
+	return true
+}
+
+// linkStorageLocations rattache aux emplacements gérés les clés dont l'emplacement n'est
+// encore qu'un texte libre. Les variantes d'un même lieu sur un site (casse, accents,
+// espaces, deux lettres inversées) rejoignent un emplacement existant ou sont regroupées
+// sous l'orthographe la plus fréquente. Utilisée par la migration et par les importations.
+func linkStorageLocations(tx *sql.Tx) error {
+	type location struct {
+		id        int
+		name      string
+		spellings map[string]int // Orthographe -> nombre de clés
+		keyIDs    []int
+	}
+
+	// Emplacements de chaque site, indexés par nom normalisé
+	sites := make(map[int]map[string]*location)
+	siteLocations := func(siteID int) map[string]*location {
+		if sites[siteID] == nil {
+			sites[siteID] = make(map[string]*location)
+		}
+		return sites[siteID]
+	}
+
+	rows, err := tx.Query(`SELECT id, site_id, name FROM storage_locations`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		loc := &location{spellings: make(map[string]int)}
+		var siteID int
+		if err := rows.Scan(&loc.id, &siteID, &loc.name); err != nil {
+			rows.Close()
+			return err
+		}
+		siteLocations(siteID)[normalizeLocationName(loc.name)] = loc
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = tx.Query(`SELECT id, site_id, storage_location FROM keys
+		WHERE storage_location_id IS NULL AND TRIM(COALESCE(storage_location, '')) <> ''`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var keyID, siteID int
+		var name string
+		if err := rows.Scan(&keyID, &siteID, &name); err != nil {
+			rows.Close()
+			return err
+		}
+		spelling := strings.Join(strings.Fields(name), " ")
+		normalized := normalizeLocationName(spelling)
+		locations := siteLocations(siteID)
+		loc := locations[normalized]
+		if loc == nil {
+			loc = &location{spellings: make(map[string]int)}
+			locations[normalized] = loc
+		}
+		loc.spellings[spelling]++
+		loc.keyIDs = append(loc.keyIDs, keyID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	siteIDs := make([]int, 0, len(sites))
+	for siteID := range sites {
+		siteIDs = append(siteIDs, siteID)
+	}
+	sort.Ints(siteIDs)
+
+	for _, siteID := range siteIDs {
+		locations := sites[siteID]
+		names := make([]string, 0, len(locations))
+		for normalized := range locations {
+			names = append(names, normalized)
+		}
+		sort.Strings(names)
+
+		// Regrouper les variantes voisines, en privilégiant un emplacement existant
+		for i, a := range names {
+			for _, b := range names[i+1:] {
+				la, lb := locations[a], locations[b]
+				if la == lb || !similarLocationNames(a, b) {
+					continue
+				}
+				if la.id == 0 && lb.id != 0 {
+					la, lb = lb, la
+				}
+				if lb.id != 0 {
+					continue // Deux emplacements déjà gérés restent distincts
+				}
+				for spelling, count := range lb.spellings {
+					la.spellings[spelling] += count
+				}
+				la.keyIDs = append(la.keyIDs, lb.keyIDs...)
+				for _, name := range names {
+					if locations[name] == lb {
+						locations[name] = la
+					}
+				}
+			}
+		}
+
+		done := make(map[*location]bool)
+		for _, normalized := range names {
+			loc := locations[normalized]
+			if done[loc] || len(loc.keyIDs) == 0 {
+				continue
+			}
+			done[loc] = true
+
+			if loc.id == 0 {
+				loc.name = canonicalLocationName(loc.spellings)
+				result, err := tx.Exec(`INSERT INTO storage_locations (site_id, name) VALUES (?, ?)`, siteID, loc.name)
+				if err != nil {
+					return fmt.Errorf("erreur lors de la création de l'emplacement %s: %w", loc.name, err)
+				}
+				id, err := result.LastInsertId()
+				if err != nil {
+					return err
+				}
+				loc.id = int(id)
+			}
+
+			for _, keyID := range loc.keyIDs {
+				_, err := tx.Exec(`UPDATE keys SET storage_location_id = ?, storage_location = ? WHERE id = ?`, loc.id, loc.name, keyID)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// canonicalLocationName choisit le nom d'un emplacement parmi les orthographes relevées
+// (orthographe -> nombre de clés) : la forme normalisée la plus utilisée l'emporte, ce qui
+// écarte une faute de frappe isolée, puis son orthographe la plus fréquente et, à égalité,
+// la plus soignée (majuscules et accents) : « Économat » plutôt que « economat »
+func canonicalLocationName(spellings map[string]int) string {
+	forms := make(map[string]int)
+	for spelling, count := range spellings {
+		forms[normalizeLocationName(spelling)] += count
+	}
+
+	care := func(spelling string) int {
+		n := 0
+		for _, r := range spelling {
+			if unicode.IsUpper(r) || r > unicode.MaxASCII {
+				n++
+			}
+		}
+		return n
+	}
+
+	best := ""
+	for spelling, count := range spellings {
+		if best == "" {
+			best = spelling
+			continue
+		}
+		form, bestForm := forms[normalizeLocationName(spelling)], forms[normalizeLocationName(best)]
+		switch {
+		case form != bestForm:
+			if form > bestForm {
+				best = spelling
+			}
+		case count != spellings[best]:
+			if count > spellings[best] {
+				best = spelling
+			}
+		case care(spelling) != care(best):
+			if care(spelling) > care(best) {
+				best = spelling
+			}
+		case spelling < best:
+			best = spelling
+		}
+	}
+	return best
+}
@@ -0,0 +1,37 @@
+package db
+
+import (
+	"testing"
+)
+
+// newTestStore ouvre une base en mémoire migrée, sur le site par défaut
+func newTestStore(t testing.TB) *Store {
+	t.Helper()
+	s, err := OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	s.SetSite(1)
+	return s
+}
+
+// createTestKey crée une clé avec copies exemplaires, ouvrant les salles roomIDs
+func createTestKey(t testing.TB, s *Store, number string, copies int, roomIDs ...int) *Key {
+	t.Helper()
+	k := &Key{Number: number, Description: "Clé " + number, QuantityTotal: copies}
+	if err := s.CreateKey(k, roomIDs); err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+// createTestBorrower crée un emprunteur actif
+func createTestBorrower(t testing.TB, s *Store, firstName, lastName string) *Borrower {
+	t.Helper()
+	b := &Borrower{FirstName: firstName, LastName: lastName}
+	if err := s.CreateBorrower(b); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
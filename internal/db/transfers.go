@@ -0,0 +1,143 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// TransferLoan remet directement la clé d'un emprunt en cours à un autre emprunteur :
+// l'emprunt est clos et un nouvel emprunt du même exemplaire, lié au premier, est ouvert
+// dans la même transaction, sans que la clé repasse par le stock. Une caution détenue
+// doit être reportée sur le nouvel emprunt (deposit = DepositTransferred) ou restituée
+// au premier emprunteur (DepositRefunded) ; deposit est ignoré sans caution détenue.
+// Sans date imposée dans opts, le nouvel emprunt reçoit la durée par défaut de la clé,
+// et il ne doit pas empiéter sur les réservations des autres emprunteurs.
+func (s *Store) TransferLoan(loanID, toBorrowerID int, deposit DepositStatus, opts LoanOptions) (int, error) {
+	tx, err := s.beginWrite()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	operator := s.Operator()
+
+	var keyID, fromBorrowerID, loanDays int
+	var number, fromName string
+	var copyID sql.NullInt64
+	var returnDate sql.NullTime
+	var depositAmount sql.NullFloat64
+	var depositMethod, depositStatus sql.NullString
+	err = tx.QueryRow(`
+		SELECT l.key_id, l.borrower_id, k.default_loan_days, k.number, b.name, l.copy_id, l.return_date,
+		       l.deposit_amount, l.deposit_method, l.deposit_status
+		FROM loans l
+		INNER JOIN keys k ON k.id = l.key_id
+		INNER JOIN borrowers b ON b.id = l.borrower_id
+		WHERE l.id = ?`, loanID).Scan(&keyID, &fromBorrowerID, &loanDays, &number, &fromName, &copyID, &returnDate,
+		&depositAmount, &depositMethod, &depositStatus)
+	if err != nil {
+		return 0, err
+	}
+	if returnDate.Valid {
+		return 0, fmt.Errorf("cet emprunt est déjà clos")
+	}
+	if toBorrowerID == fromBorrowerID {
+		return 0, fmt.Errorf("la clé %s est déjà empruntée par %s", number, fromName)
+	}
+
+	var toName string
+	if err := tx.QueryRow(`SELECT name FROM borrowers WHERE id = ?`, toBorrowerID).Scan(&toName); err != nil {
+		return 0, err
+	}
+	if err := checkNotArchived(tx, keyID, toBorrowerID); err != nil {
+		return 0, err
+	}
+	authorized, err := checkLoanAuthorization(tx, keyID, number, toBorrowerID, toName, operator, now, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	// Même règle que createLoanTx, l'exemplaire transféré comptant comme disponible
+	dueDate := dueDateFor(loanDays, now, opts)
+	free, err := freeCopyCount(tx, keyID, toBorrowerID, now, loanPeriodEnd(dueDate))
+	if err != nil {
+		return 0, err
+	}
+	if free+1 <= 0 && dueDate == nil {
+		return 0, fmt.Errorf("la clé %s est réservée prochainement, fixez une date de retour avant la réservation: %w", number, ErrKeyReserved)
+	}
+	if free+1 <= 0 {
+		return 0, fmt.Errorf("la clé %s est réservée pendant cette période: %w", number, ErrKeyReserved)
+	}
+
+	heldDeposit := DepositStatus(depositStatus.String) == DepositHeld && depositAmount.Float64 > 0
+	if heldDeposit && deposit != DepositTransferred && deposit != DepositRefunded {
+		return 0, fmt.Errorf("la caution de %s doit être reportée sur le nouvel emprunt ou restituée", fromName)
+	}
+
+	// Clore l'emprunt d'origine ; l'exemplaire reste emprunté
+	before, err := snapshotRow(tx, AuditEntityLoan, loanID)
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec(`UPDATE loans SET return_date = ?, returned_by = ? WHERE id = ?`, now, operator, loanID)
+	if err != nil {
+		return 0, err
+	}
+
+	// La caution détenue suit la clé, ou est rendue au premier emprunteur
+	var newDeposit, newMethod, newStatus interface{}
+	if heldDeposit {
+		if deposit == DepositTransferred {
+			newDeposit, newMethod, newStatus = depositAmount.Float64, depositMethod.String, DepositHeld
+		}
+		_, err = tx.Exec(`UPDATE loans SET deposit_status = ? WHERE id = ?`, deposit, loanID)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	after, err := snapshotRow(tx, AuditEntityLoan, loanID)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := tx.Exec(`INSERT INTO loans (key_id, borrower_id, loan_date, due_date, copy_id, loaned_by,
+			deposit_amount, deposit_method, deposit_status, transferred_from_loan_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		keyID, toBorrowerID, now, dueDate, copyID, operator,
+		newDeposit, newMethod, newStatus, loanID)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	newLoanID := int(id)
+
+	summary := fmt.Sprintf("Transfert de la clé %s de %s à %s", number, fromName, toName)
+	if heldDeposit && deposit == DepositRefunded {
+		summary += fmt.Sprintf(" (caution de %s restituée)", FormatDepositAmount(depositAmount.Float64))
+	}
+	if err := s.audit(tx, AuditTransfer, AuditEntityLoan, loanID, summary, before, after); err != nil {
+		return 0, err
+	}
+	newSummary, err := loanAuditSummary(tx, "Emprunt par transfert", newLoanID)
+	if err != nil {
+		return 0, err
+	}
+	if !authorized {
+		newSummary += " (dérogation : emprunteur non autorisé)"
+	}
+	if err := s.auditInsertAs(tx, AuditTransfer, AuditEntityLoan, newLoanID, newSummary); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return newLoanID, nil
+}
@@ -0,0 +1,278 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// ============= FLOORS =============
+
+// floorSelect est la requête commune aux lectures d'étages, avec le nombre de salles en service
+const floorSelect = `
+		SELECT f.id, f.building_id, f.name, f.level,
+		       (SELECT COUNT(*) FROM rooms r WHERE r.floor_id = f.id AND r.archived_at IS NULL)
+		FROM floors f`
+
+// GetFloorsByBuildingID récupère les étages d'un bâtiment, du plus bas au plus haut
+func (s *Store) GetFloorsByBuildingID(buildingID int) ([]Floor, error) {
+	rows, err := s.db().Query(floorSelect+` WHERE f.building_id = ? ORDER BY f.level, f.name`, buildingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var floors []Floor
+	for rows.Next() {
+		var f Floor
+		if err := rows.Scan(&f.ID, &f.BuildingID, &f.Name, &f.Level, &f.RoomCount); err != nil {
+			return nil, err
+		}
+		floors = append(floors, f)
+	}
+	return floors, rows.Err()
+}
+
+// CreateFloor ajoute un étage à un bâtiment
+func (s *Store) CreateFloor(f *Floor) error {
+	f.Name = strings.TrimSpace(f.Name)
+	if f.Name == "" {
+		return fmt.Errorf("le nom de l'étage est requis")
+	}
+
+	tx, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`INSERT INTO floors (building_id, name, level) VALUES (?, ?, ?)`, f.BuildingID, f.Name, f.Level)
+	if err != nil {
+		return fmt.Errorf("erreur lors de la création de l'étage %s: %w", f.Name, err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	f.ID = int(id)
+
+	if err := s.auditInsert(tx, AuditEntityFloor, f.ID, "Étage "+f.Name+" créé"); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateFloor renomme un étage ou change son niveau
+func (s *Store) UpdateFloor(f *Floor) error {
+	f.Name = strings.TrimSpace(f.Name)
+	if f.Name == "" {
+		return fmt.Errorf("le nom de l'étage est requis")
+	}
+	return s.auditedUpdate(AuditEntityFloor, f.ID, "Étage "+f.Name+" modifié",
+		`UPDATE floors SET name = ?, level = ? WHERE id = ?`, f.Name, f.Level, f.ID)
+}
+
+// DeleteFloor supprime un étage ; ses salles, même archivées, restent dans le bâtiment sans étage
+func (s *Store) DeleteFloor(id int) error {
+	tx, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := snapshotRow(tx, AuditEntityFloor, id)
+	if err != nil {
+		return err
+	}
+	if before == nil {
+		return sql.ErrNoRows
+	}
+
+	if _, err := tx.Exec(`UPDATE rooms SET floor_id = NULL WHERE floor_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM floors WHERE id = ?`, id); err != nil {
+		return err
+	}
+	summary := fmt.Sprintf("Étage %v supprimé", before["name"])
+	if err := s.audit(tx, AuditDelete, AuditEntityFloor, id, summary, before, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ============= ZONES =============
+
+// zoneSelect est la requête commune aux lectures de zones, avec le nombre de salles en service
+const zoneSelect = `
+		SELECT z.id, z.site_id, z.name, z.description,
+		       (SELECT COUNT(*) FROM rooms r WHERE r.zone_id = z.id AND r.archived_at IS NULL)
+		FROM zones z`
+
+// scanZone lit une ligne de zoneSelect
+func scanZone(row rowScanner) (Zone, error) {
+	var z Zone
+	var description sql.NullString
+	err := row.Scan(&z.ID, &z.SiteID, &z.Name, &description, &z.RoomCount)
+	z.Description = description.String
+	return z, err
+}
+
+// GetZones récupère les zones du site sélectionné
+func (s *Store) GetZones() ([]Zone, error) {
+	site, args := s.siteCondition("z.site_id")
+	rows, err := s.db().Query(zoneSelect+` WHERE 1 = 1`+site+` ORDER BY z.name`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var zones []Zone
+	for rows.Next() {
+		z, err := scanZone(rows)
+		if err != nil {
+			return nil, err
+		}
+		zones = append(zones, z)
+	}
+	return zones, rows.Err()
+}
+
+// GetZoneByID récupère une zone par son ID
+func (s *Store) GetZoneByID(id int) (*Zone, error) {
+	z, err := scanZone(s.db().QueryRow(zoneSelect+` WHERE z.id = ?`, id))
+	if err != nil {
+		return nil, err
+	}
+	return &z, nil
+}
+
+// CreateZone crée une zone dans le site sélectionné
+func (s *Store) CreateZone(z *Zone) error {
+	z.Name = strings.TrimSpace(z.Name)
+	if z.Name == "" {
+		return fmt.Errorf("le nom de la zone est requis")
+	}
+	siteID, err := s.createSite()
+	if err != nil {
+		return err
+	}
+	z.SiteID = siteID
+
+	tx, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`INSERT INTO zones (site_id, name, description) VALUES (?, ?, ?)`, z.SiteID, z.Name, z.Description)
+	if err != nil {
+		return fmt.Errorf("erreur lors de la création de la zone %s: %w", z.Name, err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	z.ID = int(id)
+
+	if err := s.auditInsert(tx, AuditEntityZone, z.ID, "Zone "+z.Name+" créée"); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateZone renomme une zone ou change sa description
+func (s *Store) UpdateZone(z *Zone) error {
+	z.Name = strings.TrimSpace(z.Name)
+	if z.Name == "" {
+		return fmt.Errorf("le nom de la zone est requis")
+	}
+	return s.auditedUpdate(AuditEntityZone, z.ID, "Zone "+z.Name+" modifiée",
+		`UPDATE zones SET name = ?, description = ? WHERE id = ?`, z.Name, z.Description, z.ID)
+}
+
+// DeleteZone supprime une zone ; ses salles, même archivées, n'appartiennent plus à aucune zone
+func (s *Store) DeleteZone(id int) error {
+	tx, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := snapshotRow(tx, AuditEntityZone, id)
+	if err != nil {
+		return err
+	}
+	if before == nil {
+		return sql.ErrNoRows
+	}
+
+	if _, err := tx.Exec(`UPDATE rooms SET zone_id = NULL WHERE zone_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM zones WHERE id = ?`, id); err != nil {
+		return err
+	}
+	summary := fmt.Sprintf("Zone %v supprimée", before["name"])
+	if err := s.audit(tx, AuditDelete, AuditEntityZone, id, summary, before, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetRoomsForZone récupère les salles en service d'une zone, tous bâtiments confondus
+func (s *Store) GetRoomsForZone(zoneID int) ([]Room, error) {
+	return s.queryRooms(roomSelect+`
+		INNER JOIN buildings b ON b.id = r.building_id
+		WHERE r.zone_id = ? AND r.archived_at IS NULL
+		ORDER BY b.name, f.level, r.name`, zoneID)
+}
+
+// GetKeysForZone récupère les clés qui ouvrent au moins une salle de la zone, directement
+// ou comme passe-partout d'une clé associée (mêmes règles que GetKeysForRoom)
+func (s *Store) GetKeysForZone(zoneID int) ([]Key, error) {
+	// via garde la clé associée à une salle de la zone dont l'accès est hérité
+	rows, err := s.db().Query(`
+		WITH RECURSIVE openers(id, depth, via) AS (
+			SELECT kra.key_id, 0, kra.key_id FROM key_room_association kra
+			INNER JOIN rooms r ON r.id = kra.room_id
+			WHERE r.zone_id = ? AND r.archived_at IS NULL
+			UNION
+			SELECT k.parent_key_id, o.depth + 1, o.via FROM keys k
+			JOIN openers o ON k.id = o.id
+			WHERE k.parent_key_id IS NOT NULL AND o.depth < ?
+		),
+		nearest AS (
+			SELECT id, MIN(depth) AS depth, via FROM openers GROUP BY id
+		)
+		SELECT `+keyColumns+`, n.depth, v.number
+		FROM nearest n
+		INNER JOIN keys k ON k.id = n.id
+		INNER JOIN keys v ON v.id = n.via
+		LEFT JOIN keys p ON p.id = k.parent_key_id
+		WHERE k.archived_at IS NULL
+		ORDER BY k.number`, zoneID, maxKeyDepth)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []Key
+	for rows.Next() {
+		var depth int
+		var via string
+		k, err := scanKey(rows, &depth, &via)
+		if err != nil {
+			return nil, err
+		}
+		k.Access = AccessDirect
+		if depth > 0 {
+			k.Access = AccessInherited
+			k.AccessVia = via
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
@@ -2,7 +2,9 @@ package gui
 
 import (
 	"clefs/internal/db"
+	"fmt"
 	"log"
+	"strings"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
@@ -15,11 +17,16 @@ type App struct {
 	fyneApp fyne.App
 	window  fyne.Window
 	content *fyne.Container
+	store   db.Repository
 	dbPath  string
+	// operator est le compte connecté (nil tant que l'écran de connexion est affiché)
+	operator *db.Operator
+	// searchEntry est le champ de la recherche globale, partagé par tous les écrans
+	searchEntry *widget.Entry
 }
 
 // NewApp crée une nouvelle instance de l'application
-func NewApp(dbPath string) *App {
+func NewApp(store db.Repository, dbPath string) *App {
 	a := app.New()
 
 	// Appliquer le thème simple et lisible
@@ -32,23 +39,126 @@ func NewApp(dbPath string) *App {
 	return &App{
 		fyneApp: a,
 		window:  w,
+		store:   store,
 		dbPath:  dbPath,
 	}
 }
 
-// Run démarre l'application
+// Run démarre l'application sur l'écran de connexion
 func (a *App) Run() {
-	// Créer le menu de navigation
-	menu := a.createMenu()
+	a.showLogin()
+	a.window.ShowAndRun()
+}
 
-	// Afficher le tableau de bord par défaut
+// login ouvre la session d'un opérateur et affiche le tableau de bord
+func (a *App) login(operator *db.Operator) {
+	a.operator = operator
+	a.store.SetOperator(operator.Username)
+	// Sans site choisi, la session s'ouvre sur le premier site plutôt que sur la vue consolidée
+	if a.store.Site() == 0 {
+		if sites, err := a.store.GetAllSites(); err == nil && len(sites) > 0 {
+			a.store.SetSite(sites[0].ID)
+		}
+	}
 	a.showDashboard()
+}
 
-	// Layout principal avec menu à gauche et contenu à droite
-	mainContent := container.NewBorder(nil, nil, menu, nil, a.content)
+// logout ferme la session et revient à l'écran de connexion. Le store oublie l'opérateur
+// et le site : rien ne doit être journalisé au nom du compte qui vient de partir.
+func (a *App) logout() {
+	a.operator = nil
+	a.store.SetOperator("")
+	a.store.SetSite(0)
+	if a.searchEntry != nil {
+		a.searchEntry.SetText("")
+	}
+	a.showLogin()
+}
 
-	a.window.SetContent(mainContent)
-	a.window.ShowAndRun()
+// canEdit indique si l'opérateur connecté peut modifier les données
+func (a *App) canEdit() bool {
+	return a.operator != nil && a.operator.Role.CanEdit()
+}
+
+// isAdmin indique si l'opérateur connecté est administrateur
+func (a *App) isAdmin() bool {
+	return a.operator != nil && a.operator.Role.IsAdmin()
+}
+
+// requireEdit vérifie que l'opérateur connecté peut modifier les données, sinon affiche une erreur
+func (a *App) requireEdit() bool {
+	if a.canEdit() {
+		return true
+	}
+	a.showError("Accès refusé", "Votre compte est en lecture seule : cette action est réservée aux gestionnaires.")
+	return false
+}
+
+// requireAdmin vérifie que l'opérateur connecté est administrateur, sinon affiche une erreur
+func (a *App) requireAdmin() bool {
+	if a.isAdmin() {
+		return true
+	}
+	a.showError("Accès refusé", "Cette action est réservée aux administrateurs.")
+	return false
+}
+
+// allSitesOption est l'option du sélecteur de site correspondant à la vue consolidée
+const allSitesOption = "🌐 Tous les sites"
+
+// siteName retourne le nom du site sélectionné (vide dans la vue consolidée)
+func (a *App) siteName() string {
+	current := a.store.Site()
+	if current == 0 {
+		return ""
+	}
+	sites, err := a.store.GetAllSites()
+	if err != nil {
+		return ""
+	}
+	for _, site := range sites {
+		if site.ID == current {
+			return site.Name
+		}
+	}
+	return ""
+}
+
+// siteLabel retourne le site couvert par les rapports (ex: « Site : Nord » ou « Tous les sites »)
+func (a *App) siteLabel() string {
+	if name := a.siteName(); name != "" {
+		return "Site : " + name
+	}
+	return "Tous les sites"
+}
+
+// createSiteSelector crée le sélecteur du site affiché. Changer de site restreint toutes
+// les listes, rapports et PDF au site choisi ; la vue consolidée regroupe tous les sites.
+func (a *App) createSiteSelector() fyne.CanvasObject {
+	sites, err := a.store.GetAllSites()
+	if err != nil {
+		return widget.NewLabel(fmt.Sprintf("Erreur: %v", err))
+	}
+
+	options := []string{allSitesOption}
+	siteIDs := map[string]int{allSitesOption: 0}
+	selected := allSitesOption
+	for _, site := range sites {
+		option := "🏫 " + site.Name
+		options = append(options, option)
+		siteIDs[option] = site.ID
+		if site.ID == a.store.Site() {
+			selected = option
+		}
+	}
+
+	siteSelect := widget.NewSelect(options, nil)
+	siteSelect.Selected = selected
+	siteSelect.OnChanged = func(option string) {
+		a.store.SetSite(siteIDs[option])
+		a.refreshCurrentView()
+	}
+	return siteSelect
 }
 
 // createMenu crée le menu de navigation moderne
@@ -68,22 +178,66 @@ func (a *App) createMenu() fyne.CanvasObject {
 	})
 	activeLoansBtn.Importance = widget.MediumImportance
 
+	reservationsBtn := widget.NewButton("📅 Réservations", func() {
+		a.showReservations()
+	})
+	reservationsBtn.Importance = widget.MediumImportance
+
+	depositsBtn := widget.NewButton("💶 Cautions", func() {
+		a.showDeposits()
+	})
+	depositsBtn.Importance = widget.MediumImportance
+
 	reportsBtn := widget.NewButton("📄 Rapport des Clés", func() {
 		a.showLoansReport()
 	})
 	reportsBtn.Importance = widget.MediumImportance
 
+	historyBtn := widget.NewButton("🕘 Historique", func() {
+		a.showLoanHistory()
+	})
+	historyBtn.Importance = widget.MediumImportance
+
 	keyPlanBtn := widget.NewButton("🗺️ Plan de Clés", func() {
 		a.showKeyPlan()
 	})
 	keyPlanBtn.Importance = widget.MediumImportance
 
-	// Section Configuration
-	configSection := widget.NewCard("", "", container.NewVBox(
-		widget.NewButton("⚙️ Configuration", func() {
+	sitesReportBtn := widget.NewButton("🌐 Vue Multi-Sites", func() {
+		a.showSitesReport()
+	})
+	sitesReportBtn.Importance = widget.MediumImportance
+
+	// Section Configuration (selon le rôle de l'opérateur)
+	configBox := container.NewVBox()
+	if a.canEdit() {
+		configBox.Add(widget.NewButton("⚙️ Configuration", func() {
 			a.showConfig()
-		}),
-	))
+		}))
+	}
+	if a.isAdmin() {
+		configBox.Add(widget.NewButton("🏫 Sites", func() {
+			a.showSites()
+		}))
+		configBox.Add(widget.NewButton("👤 Opérateurs", func() {
+			a.showOperators()
+		}))
+		configBox.Add(widget.NewButton("🛡️ Journal d'Audit", func() {
+			a.showAuditLog()
+		}))
+	}
+	configSection := widget.NewCard("", "", configBox)
+	configSection.Hidden = len(configBox.Objects) == 0
+
+	// Opérateur connecté
+	operatorText := ""
+	if a.operator != nil {
+		operatorText = fmt.Sprintf("👤 %s (%s)", a.operator.Name(), a.operator.Role.Label())
+	}
+	operatorLabel := widget.NewLabelWithStyle(operatorText, fyne.TextAlignCenter, fyne.TextStyle{Italic: true})
+	logoutBtn := widget.NewButton("🔒 Déconnexion", func() {
+		a.logout()
+	})
 
 	// Section Aide
 	helpSection := widget.NewCard("", "", container.NewVBox(
@@ -104,18 +258,24 @@ func (a *App) createMenu() fyne.CanvasObject {
 	// Conteneur principal du menu avec espacement amélioré
 	menuBox := container.NewVBox(
 		titleCard,
+		operatorLabel,
+		container.NewPadded(a.createSiteSelector()),
 		widget.NewSeparator(),
 		container.NewPadded(container.NewVBox(
 			dashboardBtn,
 			activeLoansBtn,
+			reservationsBtn,
+			depositsBtn,
 			reportsBtn,
+			historyBtn,
 			keyPlanBtn,
+			sitesReportBtn,
 		)),
 		widget.NewSeparator(),
 		container.NewPadded(configSection),
 		container.NewPadded(helpSection),
 		widget.NewSeparator(),
-		container.NewPadded(quitBtn),
+		container.NewPadded(container.NewVBox(logoutBtn, quitBtn)),
 	)
 
 	// Retourner le menu dans un scroll avec une largeur fixe
@@ -129,7 +289,8 @@ func (a *App) setContent(content fyne.CanvasObject) {
 
 	// Recréer le layout principal
 	menu := a.createMenu()
-	mainContent := container.NewBorder(nil, nil, menu, nil, a.content)
+	mainContent := container.NewBorder(nil, nil, menu, nil,
+		container.NewBorder(a.createSearchBar(), nil, nil, nil, a.content))
 	a.window.SetContent(mainContent)
 }
 
@@ -163,18 +324,99 @@ func (a *App) showRooms() {
 	a.setContent(content)
 }
 
+// showZones affiche la gestion des zones
+func (a *App) showZones() {
+	content := createZonesView(a)
+	a.setContent(content)
+}
+
+// showCustomFields affiche la définition des champs personnalisés
+func (a *App) showCustomFields() {
+	content := createCustomFieldsView(a)
+	a.setContent(content)
+}
+
+// showArchives affiche les clés, emprunteurs, bâtiments et salles archivés
+func (a *App) showArchives() {
+	content := createArchivesView(a)
+	a.setContent(content)
+}
+
+// showStorageLocations affiche la gestion des emplacements de rangement et des armoires
+func (a *App) showStorageLocations() {
+	content := createStorageLocationsView(a)
+	a.setContent(content)
+}
+
+// showCabinetMap affiche le plan d'une armoire à clés
+func (a *App) showCabinetMap(locationID int) {
+	content := createCabinetMapView(a, locationID)
+	a.setContent(content)
+}
+
+// showSites affiche la gestion des sites
+func (a *App) showSites() {
+	content := createSitesView(a)
+	a.setContent(content)
+}
+
+// showSitesReport affiche le rapport consolidé de tous les sites
+func (a *App) showSitesReport() {
+	content := createSitesReportView(a)
+	a.setContent(content)
+}
+
 // showActiveLoans affiche les emprunts actifs
 func (a *App) showActiveLoans() {
 	content := createActiveLoansView(a)
 	a.setContent(content)
 }
 
+// showReservations affiche les réservations de clés
+func (a *App) showReservations() {
+	content := createReservationsView(a)
+	a.setContent(content)
+}
+
+// showSearch affiche les résultats de la recherche globale
+func (a *App) showSearch(query string) {
+	if strings.TrimSpace(query) == "" {
+		return
+	}
+	content := createSearchView(a, query)
+	a.setContent(content)
+}
+
+// showDeposits affiche les cautions détenues
+func (a *App) showDeposits() {
+	content := createDepositsView(a)
+	a.setContent(content)
+}
+
 // showLoansReport affiche le rapport des emprunts
 func (a *App) showLoansReport() {
 	content := createLoansReportView(a)
 	a.setContent(content)
 }
 
+// showLoanHistory affiche l'historique des emprunts
+func (a *App) showLoanHistory() {
+	content := createLoanHistoryView(a)
+	a.setContent(content)
+}
+
+// showOperators affiche la gestion des comptes opérateurs
+func (a *App) showOperators() {
+	content := createOperatorsView(a)
+	a.setContent(content)
+}
+
+// showAuditLog affiche le journal d'audit
+func (a *App) showAuditLog() {
+	content := createAuditLogView(a)
+	a.setContent(content)
+}
+
 // showKeyPlan affiche le plan de clés
 func (a *App) showKeyPlan() {
 	content := createKeyPlanView(a)
@@ -211,7 +453,7 @@ func (a *App) quit() {
 		"Êtes-vous sûr de vouloir quitter ?",
 		func() {
 			// Fermer la base de données proprement
-			if err := db.CloseDB(); err != nil {
+			if err := a.store.Close(); err != nil {
 				log.Printf("Erreur lors de la fermeture de la base de données: %v", err)
 			}
 
@@ -284,12 +526,13 @@ func (a *App) refreshCurrentView() {
 // Initialize initialise l'application et la base de données
 func Initialize(dbPath string) (*App, error) {
 	// Initialiser la base de données
-	if err := db.InitDB(dbPath); err != nil {
+	store, err := db.Open(dbPath)
+	if err != nil {
 		log.Fatalf("Erreur lors de l'initialisation de la base de données: %v", err)
 		return nil, err
 	}
 
 	// Créer l'application
-	app := NewApp(dbPath)
+	app := NewApp(store, dbPath)
 	return app, nil
 }
@@ -0,0 +1,101 @@
+package gui
+
+import (
+	"clefs/internal/db"
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// archiveEntities liste les catégories de la vue des archives, dans l'ordre d'affichage
+var archiveEntities = []string{db.AuditEntityKey, db.AuditEntityBorrower, db.AuditEntityBuilding, db.AuditEntityRoom}
+
+// createArchivesView crée la vue des enregistrements archivés, groupés par catégorie.
+// Un gestionnaire peut les remettre en service ; seul un administrateur peut les supprimer définitivement.
+func createArchivesView(app *App) fyne.CanvasObject {
+	title := widget.NewLabelWithStyle("📦 Archives", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	info := widget.NewLabel("Les éléments archivés n'apparaissent plus dans les listes mais restent visibles dans l'historique et les rapports.")
+	info.Wrapping = fyne.TextWrapWord
+
+	records, err := app.store.GetArchivedRecords()
+	if err != nil {
+		return container.NewVBox(title, widget.NewLabel(fmt.Sprintf("Erreur: %v", err)))
+	}
+
+	grouped := make(map[string][]db.ArchivedRecord)
+	for _, r := range records {
+		grouped[r.Entity] = append(grouped[r.Entity], r)
+	}
+
+	listBox := container.NewVBox()
+	if len(records) == 0 {
+		listBox.Add(widget.NewLabel("Aucun élément archivé."))
+	}
+	for _, entity := range archiveEntities {
+		if len(grouped[entity]) == 0 {
+			continue
+		}
+		group := container.NewVBox()
+		for _, r := range grouped[entity] {
+			group.Add(createArchivedRecordRow(app, r))
+		}
+		listBox.Add(widget.NewCard(searchGroups[entity], "", group))
+	}
+
+	return container.NewBorder(
+		container.NewVBox(title, info, widget.NewSeparator()),
+		nil,
+		nil,
+		nil,
+		container.NewVScroll(listBox),
+	)
+}
+
+// createArchivedRecordRow crée la ligne d'un enregistrement archivé avec ses actions
+func createArchivedRecordRow(app *App, r db.ArchivedRecord) fyne.CanvasObject {
+	text := fmt.Sprintf("%s - archivé le %s", r.Title, r.ArchivedAt.Format("02/01/2006"))
+	if r.LoanCount > 0 {
+		text += fmt.Sprintf(" - %d emprunt(s) dans l'historique", r.LoanCount)
+	}
+
+	actions := container.NewHBox()
+
+	recoverBtn := widget.NewButton("♻️ Remettre en service", func() {
+		if !app.requireEdit() {
+			return
+		}
+		if err := app.store.RecoverRecord(r.Entity, r.ID); err != nil {
+			app.showError("Erreur", fmt.Sprintf("Impossible de remettre en service: %v", err))
+			return
+		}
+		app.showSuccess(fmt.Sprintf("%s est de nouveau en service.", r.Title))
+		app.showArchives()
+	})
+	actions.Add(recoverBtn)
+
+	if app.isAdmin() {
+		purgeBtn := widget.NewButton("🗑️ Supprimer définitivement", func() {
+			if !app.requireAdmin() {
+				return
+			}
+			message := fmt.Sprintf("Supprimer définitivement %s ? Cette action est irréversible.", r.Title)
+			if r.LoanCount > 0 {
+				message += fmt.Sprintf("\nLes %d emprunt(s) de son historique seront effacés.", r.LoanCount)
+			}
+			app.showConfirm("Confirmer la suppression définitive", message, func() {
+				if err := app.store.PurgeRecord(r.Entity, r.ID); err != nil {
+					app.showError("Erreur", fmt.Sprintf("Erreur lors de la suppression: %v", err))
+					return
+				}
+				app.showSuccess(fmt.Sprintf("%s a été supprimé définitivement.", r.Title))
+				app.showArchives()
+			})
+		})
+		purgeBtn.Importance = widget.DangerImportance
+		actions.Add(purgeBtn)
+	}
+
+	return container.NewBorder(nil, nil, nil, actions, widget.NewLabel(text))
+}
@@ -0,0 +1,208 @@
+package gui
+
+import (
+	"bytes"
+	"clefs/internal/db"
+	"fmt"
+	"io"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// attachmentTileSize est la taille d'une miniature de pièce jointe à l'écran
+var attachmentTileSize = fyne.NewSize(96, 96)
+
+// newAttachmentsButton crée le bouton « Pièces jointes » d'un enregistrement ;
+// refresh réaffiche l'écran appelant lorsque les pièces jointes changent
+func newAttachmentsButton(app *App, entity string, id int, title string, refresh func()) *widget.Button {
+	label := "📎 Pièces jointes"
+	if attachments, err := app.store.GetAttachments(entity, id); err == nil && len(attachments) > 0 {
+		label = fmt.Sprintf("📎 Pièces jointes (%d)", len(attachments))
+	}
+	return widget.NewButton(label, func() {
+		showAttachmentsDialog(app, entity, id, title, refresh)
+	})
+}
+
+// newAttachmentThumbnail crée l'aperçu d'une pièce jointe : sa miniature pour une image,
+// une icône de document sinon
+func newAttachmentThumbnail(a db.Attachment) fyne.CanvasObject {
+	var preview fyne.CanvasObject
+	if a.Thumbnail != nil {
+		img := canvas.NewImageFromReader(bytes.NewReader(a.Thumbnail), a.Filename)
+		img.FillMode = canvas.ImageFillContain
+		preview = img
+	} else {
+		preview = widget.NewIcon(theme.FileIcon())
+	}
+	return container.NewGridWrap(attachmentTileSize, preview)
+}
+
+// newAttachmentTile crée la vignette d'une pièce jointe, avec son nom sous l'aperçu
+func newAttachmentTile(a db.Attachment) fyne.CanvasObject {
+	name := widget.NewLabel(a.Filename)
+	name.Truncation = fyne.TextTruncateEllipsis
+	return container.NewGridWrap(fyne.NewSize(attachmentTileSize.Width+20, attachmentTileSize.Height+40),
+		container.NewBorder(nil, name, nil, nil, container.NewCenter(newAttachmentThumbnail(a))))
+}
+
+// addAttachmentThumbnails ajoute à box, après un séparateur, les vignettes des pièces jointes
+// d'un enregistrement (rien s'il n'en a aucune)
+func addAttachmentThumbnails(app *App, box *fyne.Container, entity string, id int) {
+	attachments, err := app.store.GetAttachments(entity, id)
+	if err != nil || len(attachments) == 0 {
+		return
+	}
+	tiles := container.NewHBox()
+	for _, a := range attachments {
+		tiles.Add(newAttachmentTile(a))
+	}
+	box.Add(widget.NewSeparator())
+	box.Add(widget.NewLabelWithStyle(fmt.Sprintf("Pièces jointes (%d):", len(attachments)), fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+	box.Add(container.NewHScroll(tiles))
+}
+
+// showAttachmentsDialog affiche les pièces jointes d'un enregistrement, pour en ajouter,
+// en enregistrer une copie sur le disque ou en retirer
+func showAttachmentsDialog(app *App, entity string, id int, title string, refresh func()) {
+	attachments, err := app.store.GetAttachments(entity, id)
+	if err != nil {
+		app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération des pièces jointes: %v", err))
+		return
+	}
+
+	var popupDialog *widget.PopUp
+
+	// reopen réaffiche l'écran appelant puis la boîte de dialogue après un ajout ou une suppression
+	reopen := func() {
+		app.window.Canvas().Overlays().Remove(popupDialog)
+		if refresh != nil {
+			refresh()
+		}
+		showAttachmentsDialog(app, entity, id, title, refresh)
+	}
+
+	list := container.NewVBox()
+	if len(attachments) == 0 {
+		list.Add(widget.NewLabel("Aucune pièce jointe (photo, reçu signé scanné, copie de pièce d'identité...)."))
+	}
+	for _, attachment := range attachments {
+		a := attachment // Capture
+
+		details := fmt.Sprintf("%s | ajoutée le %s", a.SizeLabel(), a.CreatedAt.Format("02/01/2006 à 15:04"))
+		if a.Operator != "" {
+			details += " par " + a.Operator
+		}
+		info := container.NewVBox(
+			widget.NewLabelWithStyle(a.Filename, fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+			widget.NewLabel(details),
+		)
+
+		saveBtn := widget.NewButton("💾 Enregistrer", func() {
+			saveAttachmentAs(app, a)
+		})
+
+		deleteBtn := widget.NewButton("🗑️", func() {
+			if !app.requireEdit() {
+				return
+			}
+			app.showConfirm("Confirmer la suppression",
+				fmt.Sprintf("Retirer la pièce jointe %s ?\nLe fichier est supprimé de la base de données.", a.Filename),
+				func() {
+					if err := app.store.DeleteAttachment(a.ID); err != nil {
+						app.showError("Erreur", fmt.Sprintf("Impossible de retirer la pièce jointe: %v", err))
+						return
+					}
+					reopen()
+				})
+		})
+		deleteBtn.Importance = widget.DangerImportance
+
+		list.Add(container.NewBorder(nil, nil, newAttachmentThumbnail(a), container.NewHBox(saveBtn, deleteBtn), info))
+		list.Add(widget.NewSeparator())
+	}
+
+	addBtn := widget.NewButton("➕ Joindre un fichier", func() {
+		if !app.requireEdit() {
+			return
+		}
+		openDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+			if err != nil {
+				app.showError("Erreur", fmt.Sprintf("Erreur: %v", err))
+				return
+			}
+			if reader == nil {
+				return // Annulé
+			}
+			defer reader.Close()
+
+			// Lire un octet de plus que la limite pour détecter les fichiers trop volumineux
+			data, err := io.ReadAll(io.LimitReader(reader, db.MaxAttachmentSize+1))
+			if err != nil {
+				app.showError("Erreur", fmt.Sprintf("Erreur lors de la lecture du fichier: %v", err))
+				return
+			}
+			if _, err := app.store.AddAttachment(entity, id, reader.URI().Name(), data); err != nil {
+				app.showError("Erreur", fmt.Sprintf("Impossible de joindre le fichier: %v", err))
+				return
+			}
+			reopen()
+		}, app.window)
+		openDialog.Show()
+	})
+	addBtn.Importance = widget.HighImportance
+
+	closeBtn := widget.NewButton("Fermer", func() {
+		app.window.Canvas().Overlays().Remove(popupDialog)
+	})
+
+	body := container.NewVScroll(list)
+	body.SetMinSize(fyne.NewSize(650, 380))
+
+	content := container.NewVBox(
+		widget.NewLabelWithStyle("📎 Pièces jointes - "+title, fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
+		widget.NewSeparator(),
+		body,
+		widget.NewSeparator(),
+		container.NewHBox(closeBtn, addBtn),
+	)
+
+	popupDialog = widget.NewModalPopUp(content, app.window.Canvas())
+	popupDialog.Resize(fyne.NewSize(700, 500))
+	popupDialog.Show()
+}
+
+// saveAttachmentAs enregistre une copie d'une pièce jointe à l'emplacement choisi
+func saveAttachmentAs(app *App, a db.Attachment) {
+	data, err := app.store.GetAttachmentData(a.ID)
+	if err != nil {
+		app.showError("Erreur", fmt.Sprintf("Erreur lors de la lecture de la pièce jointe: %v", err))
+		return
+	}
+
+	saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			app.showError("Erreur", fmt.Sprintf("Erreur: %v", err))
+			return
+		}
+		if writer == nil {
+			return
+		}
+		defer writer.Close()
+
+		if _, err := writer.Write(data); err != nil {
+			app.showError("Erreur", fmt.Sprintf("Erreur lors de l'écriture du fichier: %v", err))
+			return
+		}
+
+		app.showSuccess("Pièce jointe enregistrée avec succès!")
+	}, app.window)
+
+	saveDialog.SetFileName(a.Filename)
+	saveDialog.Show()
+}
@@ -0,0 +1,226 @@
+package gui
+
+import (
+	"bytes"
+	"clefs/internal/db"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// auditEntityLabels donne le libellé des entités du journal d'audit, dans l'ordre des filtres
+var auditEntityLabels = []struct {
+	entity string
+	label  string
+}{
+	{db.AuditEntityKey, "Clés"},
+	{db.AuditEntityCopy, "Exemplaires"},
+	{db.AuditEntityLocation, "Emplacements"},
+	{db.AuditEntityBorrower, "Emprunteurs"},
+	{db.AuditEntityBuilding, "Bâtiments"},
+	{db.AuditEntityFloor, "Étages"},
+	{db.AuditEntityRoom, "Salles"},
+	{db.AuditEntityZone, "Zones"},
+	{db.AuditEntityCustomField, "Champs personnalisés"},
+	{db.AuditEntityLoan, "Emprunts"},
+	{db.AuditEntityAttachment, "Pièces jointes"},
+	{db.AuditEntityReservation, "Réservations"},
+	{db.AuditEntityAuthorization, "Autorisations"},
+	{db.AuditEntitySite, "Sites"},
+	{db.AuditEntityDatabase, "Base de données"},
+}
+
+// createAuditLogView crée la vue du journal d'audit (lecture seule)
+func createAuditLogView(app *App) fyne.CanvasObject {
+	title := widget.NewLabelWithStyle("Journal d'Audit", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+
+	// Listes de choix pour les filtres (la première option désactive le filtre)
+	entityOptions := []string{"Tous les éléments"}
+	entityMap := make(map[string]string)
+	for _, e := range auditEntityLabels {
+		entityOptions = append(entityOptions, e.label)
+		entityMap[e.label] = e.entity
+	}
+
+	actionOptions := []string{"Toutes les opérations"}
+	actionMap := make(map[string]db.AuditAction)
+	for _, a := range db.AuditActions {
+		actionOptions = append(actionOptions, a.Label())
+		actionMap[a.Label()] = a
+	}
+
+	entitySelect := widget.NewSelect(entityOptions, nil)
+	entitySelect.SetSelected(entityOptions[0])
+	actionSelect := widget.NewSelect(actionOptions, nil)
+	actionSelect.SetSelected(actionOptions[0])
+
+	operatorEntry := widget.NewEntry()
+	operatorEntry.SetPlaceHolder("Opérateur")
+	fromEntry := widget.NewEntry()
+	fromEntry.SetPlaceHolder("Du (JJ/MM/AAAA)")
+	toEntry := widget.NewEntry()
+	toEntry.SetPlaceHolder("Au (JJ/MM/AAAA)")
+
+	// État de la vue
+	var filter db.AuditFilter
+	page := 0
+	total := 0
+
+	resultsBox := container.NewVBox()
+	pageLabel := widget.NewLabel("")
+	prevBtn := widget.NewButton("◀ Précédent", nil)
+	nextBtn := widget.NewButton("Suivant ▶", nil)
+
+	// loadPage recharge la page courante avec le filtre courant
+	loadPage := func() {
+		resultsBox.Objects = nil
+
+		var err error
+		total, err = app.store.CountAuditLog(filter)
+		if err != nil {
+			resultsBox.Add(widget.NewLabel(fmt.Sprintf("Erreur: %v", err)))
+			resultsBox.Refresh()
+			return
+		}
+
+		pageFilter := filter
+		pageFilter.Limit = historyPageSize
+		pageFilter.Offset = page * historyPageSize
+		entries, err := app.store.GetAuditLog(pageFilter)
+		if err != nil {
+			resultsBox.Add(widget.NewLabel(fmt.Sprintf("Erreur: %v", err)))
+			resultsBox.Refresh()
+			return
+		}
+
+		if len(entries) == 0 {
+			resultsBox.Add(widget.NewCard("", "Aucune opération",
+				widget.NewLabel("Aucune opération ne correspond à ces critères.")))
+		}
+		for _, entry := range entries {
+			resultsBox.Add(createAuditRow(entry))
+		}
+		resultsBox.Refresh()
+
+		pages := (total + historyPageSize - 1) / historyPageSize
+		if pages == 0 {
+			pages = 1
+		}
+		pageLabel.SetText(fmt.Sprintf("Page %d / %d (%d opération(s))", page+1, pages, total))
+		if page > 0 {
+			prevBtn.Enable()
+		} else {
+			prevBtn.Disable()
+		}
+		if (page+1)*historyPageSize < total {
+			nextBtn.Enable()
+		} else {
+			nextBtn.Disable()
+		}
+	}
+
+	prevBtn.OnTapped = func() {
+		if page > 0 {
+			page--
+			loadPage()
+		}
+	}
+	nextBtn.OnTapped = func() {
+		if (page+1)*historyPageSize < total {
+			page++
+			loadPage()
+		}
+	}
+
+	// applyFilters lit les champs du formulaire et recharge la première page
+	applyFilters := func() {
+		from, err := parseHistoryDate(fromEntry.Text, false)
+		if err != nil {
+			app.showError("Erreur", err.Error())
+			return
+		}
+		to, err := parseHistoryDate(toEntry.Text, true)
+		if err != nil {
+			app.showError("Erreur", err.Error())
+			return
+		}
+
+		filter = db.AuditFilter{
+			Entity:   entityMap[entitySelect.Selected],
+			Action:   actionMap[actionSelect.Selected],
+			Operator: strings.TrimSpace(operatorEntry.Text),
+			From:     from,
+			To:       to,
+		}
+		page = 0
+		loadPage()
+	}
+
+	filterBtn := widget.NewButton("🔍 Filtrer", applyFilters)
+	filterBtn.Importance = widget.HighImportance
+
+	resetBtn := widget.NewButton("Réinitialiser", func() {
+		entitySelect.SetSelected(entityOptions[0])
+		actionSelect.SetSelected(actionOptions[0])
+		operatorEntry.SetText("")
+		fromEntry.SetText("")
+		toEntry.SetText("")
+		applyFilters()
+	})
+
+	operatorLabel := widget.NewLabel("👤 Opérateur actuel : " + app.store.Operator())
+
+	filters := container.NewVBox(
+		container.NewGridWithColumns(3, entitySelect, actionSelect, operatorEntry),
+		container.NewGridWithColumns(4, fromEntry, toEntry, filterBtn, resetBtn),
+	)
+
+	pagination := container.NewHBox(prevBtn, pageLabel, nextBtn)
+
+	// Premier chargement sans filtre
+	loadPage()
+
+	return container.NewBorder(
+		container.NewVBox(container.NewBorder(nil, nil, title, operatorLabel), filters, widget.NewSeparator()),
+		container.NewCenter(pagination),
+		nil,
+		nil,
+		container.NewVScroll(resultsBox),
+	)
+}
+
+// createAuditRow crée une ligne du journal ; les valeurs avant/après sont dépliables
+func createAuditRow(entry db.AuditEntry) fyne.CanvasObject {
+	title := fmt.Sprintf("%s - %s - %s : %s",
+		entry.CreatedAt.Format("02/01/2006 15:04:05"), entry.Operator, entry.Action.Label(), entry.Summary)
+
+	details := container.NewVBox()
+	if entry.Before != "" {
+		details.Add(widget.NewLabelWithStyle("Avant :", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+		details.Add(newAuditJSONLabel(entry.Before))
+	}
+	if entry.After != "" {
+		details.Add(widget.NewLabelWithStyle("Après :", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+		details.Add(newAuditJSONLabel(entry.After))
+	}
+	if len(details.Objects) == 0 {
+		details.Add(widget.NewLabel("Aucun détail enregistré pour cette opération."))
+	}
+
+	return widget.NewAccordion(widget.NewAccordionItem(title, details))
+}
+
+// newAuditJSONLabel affiche un enregistrement JSON du journal de façon lisible
+func newAuditJSONLabel(data string) fyne.CanvasObject {
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, []byte(data), "", "  "); err == nil {
+		data = indented.String()
+	}
+	label := widget.NewLabelWithStyle(data, fyne.TextAlignLeading, fyne.TextStyle{Monospace: true})
+	label.Wrapping = fyne.TextWrapWord
+	return label
+}
@@ -0,0 +1,308 @@
+package gui
+
+import (
+	"clefs/internal/db"
+	"fmt"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// authorizationValidityFields regroupe la période de validité et les notes d'une nouvelle autorisation
+type authorizationValidityFields struct {
+	from  *widget.Entry
+	until *widget.Entry
+	notes *widget.Entry
+}
+
+// newAuthorizationValidityFields crée les champs de validité (vides = sans limite)
+func newAuthorizationValidityFields() *authorizationValidityFields {
+	f := &authorizationValidityFields{
+		from:  widget.NewEntry(),
+		until: widget.NewEntry(),
+		notes: widget.NewEntry(),
+	}
+	f.from.SetPlaceHolder("Valide du (JJ/MM/AAAA)")
+	f.until.SetPlaceHolder("Au (JJ/MM/AAAA)")
+	f.notes.SetPlaceHolder("Notes (optionnel)")
+	return f
+}
+
+// content retourne la mise en page des champs de validité
+func (f *authorizationValidityFields) content() fyne.CanvasObject {
+	return container.NewGridWithColumns(3, f.from, f.until, f.notes)
+}
+
+// apply recopie la période de validité et les notes dans a
+func (f *authorizationValidityFields) apply(a *db.KeyAuthorization) error {
+	from, err := parseHistoryDate(f.from.Text, false)
+	if err != nil {
+		return err
+	}
+	until, err := parseHistoryDate(f.until.Text, true)
+	if err != nil {
+		return err
+	}
+	a.ValidFrom = from
+	a.ValidUntil = until
+	a.Notes = f.notes.Text
+	return nil
+}
+
+// describeAuthorizationValidity résume la période de validité d'une autorisation
+func describeAuthorizationValidity(a db.KeyAuthorization) string {
+	status := ""
+	if !a.ValidAt(time.Now()) {
+		status = " ⛔ hors période"
+	}
+	switch {
+	case a.ValidFrom != nil && a.ValidUntil != nil:
+		return fmt.Sprintf("du %s au %s%s", a.ValidFrom.Format("02/01/2006"), a.ValidUntil.Format("02/01/2006"), status)
+	case a.ValidFrom != nil:
+		return fmt.Sprintf("à partir du %s%s", a.ValidFrom.Format("02/01/2006"), status)
+	case a.ValidUntil != nil:
+		return fmt.Sprintf("jusqu'au %s%s", a.ValidUntil.Format("02/01/2006"), status)
+	}
+	return "sans limite de durée"
+}
+
+// createAuthorizationRow crée une ligne d'autorisation avec son bouton de retrait
+func createAuthorizationRow(app *App, a db.KeyAuthorization, title string, reopen func()) fyne.CanvasObject {
+	text := fmt.Sprintf("%s - %s", title, describeAuthorizationValidity(a))
+	if a.Notes != "" {
+		text += " - " + a.Notes
+	}
+
+	removeBtn := widget.NewButton("🗑️", func() {
+		if !app.requireEdit() {
+			return
+		}
+		if err := app.store.DeleteKeyAuthorization(a.ID); err != nil {
+			app.showError("Erreur", fmt.Sprintf("Erreur lors du retrait de l'autorisation: %v", err))
+			return
+		}
+		reopen()
+	})
+	removeBtn.Importance = widget.DangerImportance
+
+	return container.NewBorder(nil, nil, nil, removeBtn, widget.NewLabel(text))
+}
+
+// showKeyAuthorizationsDialog affiche les personnes et services autorisés à emprunter une clé
+func showKeyAuthorizationsDialog(app *App, key db.Key) {
+	var dialog *widget.PopUp
+
+	// reopen rafraîchit la boîte de dialogue après une modification
+	reopen := func() {
+		app.window.Canvas().Overlays().Remove(dialog)
+		showKeyAuthorizationsDialog(app, key)
+	}
+
+	authorizations, err := app.store.GetAuthorizationsForKey(key.ID)
+	if err != nil {
+		app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération des autorisations: %v", err))
+		return
+	}
+
+	listBox := container.NewVBox()
+	if len(authorizations) == 0 {
+		listBox.Add(widget.NewLabel("Aucune autorisation : cette clé peut être prêtée à tous les emprunteurs."))
+	}
+	for _, a := range authorizations {
+		listBox.Add(createAuthorizationRow(app, a, a.Holder(), reopen))
+		listBox.Add(widget.NewSeparator())
+	}
+
+	listScroll := container.NewVScroll(listBox)
+	listScroll.SetMinSize(fyne.NewSize(600, 250))
+
+	// Ajout d'une autorisation nominative ou de service
+	borrowers, err := app.store.GetAllBorrowers()
+	if err != nil {
+		app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération des emprunteurs: %v", err))
+		return
+	}
+	borrowerOptions := make([]string, len(borrowers))
+	borrowerMap := make(map[string]int)
+	for i, b := range borrowers {
+		borrowerOptions[i] = borrowerOptionLabel(b)
+		borrowerMap[borrowerOptions[i]] = b.ID
+	}
+	borrowerSelect := widget.NewSelect(borrowerOptions, nil)
+	borrowerSelect.PlaceHolder = "Emprunteur..."
+
+	departmentEntry := widget.NewEntry()
+	departmentEntry.SetPlaceHolder("Service")
+	departmentEntry.Hide()
+
+	const holderBorrower, holderDepartment = "Un emprunteur", "Tout un service"
+	holderRadio := widget.NewRadioGroup([]string{holderBorrower, holderDepartment}, func(selected string) {
+		if selected == holderDepartment {
+			borrowerSelect.Hide()
+			departmentEntry.Show()
+		} else {
+			departmentEntry.Hide()
+			borrowerSelect.Show()
+		}
+	})
+	holderRadio.Horizontal = true
+	holderRadio.SetSelected(holderBorrower)
+
+	validity := newAuthorizationValidityFields()
+
+	addBtn := widget.NewButton("➕ Autoriser", func() {
+		if !app.requireEdit() {
+			return
+		}
+		authorization := &db.KeyAuthorization{KeyID: key.ID}
+		if holderRadio.Selected == holderDepartment {
+			authorization.Department = departmentEntry.Text
+		} else {
+			borrowerID, ok := borrowerMap[borrowerSelect.Selected]
+			if !ok {
+				app.showError("Erreur", "Veuillez sélectionner un emprunteur.")
+				return
+			}
+			authorization.BorrowerID = &borrowerID
+		}
+		if err := validity.apply(authorization); err != nil {
+			app.showError("Erreur", err.Error())
+			return
+		}
+		if err := app.store.CreateKeyAuthorization(authorization); err != nil {
+			app.showError("Erreur", fmt.Sprintf("Erreur lors de l'ajout de l'autorisation: %v", err))
+			return
+		}
+		reopen()
+	})
+	addBtn.Importance = widget.HighImportance
+
+	closeBtn := widget.NewButton("Fermer", func() {
+		app.window.Canvas().Overlays().Remove(dialog)
+		app.showKeys()
+	})
+
+	content := container.NewVBox(
+		widget.NewLabelWithStyle(fmt.Sprintf("Autorisations de la clé %s", key.Number), fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
+		widget.NewSeparator(),
+		listScroll,
+		widget.NewSeparator(),
+		widget.NewLabelWithStyle("Nouvelle autorisation:", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		holderRadio,
+		container.NewBorder(nil, nil, nil, addBtn, container.NewMax(borrowerSelect, departmentEntry)),
+		validity.content(),
+		widget.NewSeparator(),
+		container.NewHBox(closeBtn),
+	)
+
+	dialog = widget.NewModalPopUp(content, app.window.Canvas())
+	dialog.Resize(fyne.NewSize(700, 550))
+	dialog.Show()
+}
+
+// showBorrowerAuthorizationsDialog affiche les clés qu'un emprunteur est autorisé à emprunter
+func showBorrowerAuthorizationsDialog(app *App, borrower db.Borrower) {
+	var dialog *widget.PopUp
+
+	// reopen rafraîchit la boîte de dialogue après une modification
+	reopen := func() {
+		app.window.Canvas().Overlays().Remove(dialog)
+		showBorrowerAuthorizationsDialog(app, borrower)
+	}
+
+	authorizations, err := app.store.GetAuthorizationsForBorrower(borrower.ID)
+	if err != nil {
+		app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération des autorisations: %v", err))
+		return
+	}
+
+	listBox := container.NewVBox()
+	if len(authorizations) == 0 {
+		listBox.Add(widget.NewLabel("Aucune autorisation particulière : seules les clés sans restriction peuvent être prêtées."))
+	}
+	for _, a := range authorizations {
+		title := "🔑 " + a.KeyNumber
+		if a.BorrowerID == nil {
+			title += " (" + a.Holder() + ")"
+		}
+		listBox.Add(createAuthorizationRow(app, a, title, reopen))
+		listBox.Add(widget.NewSeparator())
+	}
+
+	listScroll := container.NewVScroll(listBox)
+	listScroll.SetMinSize(fyne.NewSize(600, 250))
+
+	// Ajout d'une autorisation sur une clé
+	keys, err := app.store.GetAllKeys()
+	if err != nil {
+		app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération des clés: %v", err))
+		return
+	}
+	keyOptions := make([]string, len(keys))
+	keyMap := make(map[string]int)
+	for i, k := range keys {
+		keyOptions[i] = fmt.Sprintf("%s - %s", k.Number, k.Description)
+		keyMap[keyOptions[i]] = k.ID
+	}
+	keySelect := widget.NewSelect(keyOptions, nil)
+	keySelect.PlaceHolder = "Clé..."
+
+	// Une autorisation peut être accordée à tout le service de l'emprunteur
+	departmentCheck := widget.NewCheck(fmt.Sprintf("Pour tout le service %s", borrower.Department), nil)
+	if borrower.Department == "" {
+		departmentCheck.Hide()
+	}
+
+	validity := newAuthorizationValidityFields()
+
+	addBtn := widget.NewButton("➕ Autoriser", func() {
+		if !app.requireEdit() {
+			return
+		}
+		keyID, ok := keyMap[keySelect.Selected]
+		if !ok {
+			app.showError("Erreur", "Veuillez sélectionner une clé.")
+			return
+		}
+		authorization := &db.KeyAuthorization{KeyID: keyID}
+		if departmentCheck.Checked {
+			authorization.Department = borrower.Department
+		} else {
+			authorization.BorrowerID = &borrower.ID
+		}
+		if err := validity.apply(authorization); err != nil {
+			app.showError("Erreur", err.Error())
+			return
+		}
+		if err := app.store.CreateKeyAuthorization(authorization); err != nil {
+			app.showError("Erreur", fmt.Sprintf("Erreur lors de l'ajout de l'autorisation: %v", err))
+			return
+		}
+		reopen()
+	})
+	addBtn.Importance = widget.HighImportance
+
+	closeBtn := widget.NewButton("Fermer", func() {
+		app.window.Canvas().Overlays().Remove(dialog)
+		app.showBorrowers()
+	})
+
+	content := container.NewVBox(
+		widget.NewLabelWithStyle(fmt.Sprintf("Autorisations de %s", borrower.Name), fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
+		widget.NewSeparator(),
+		listScroll,
+		widget.NewSeparator(),
+		widget.NewLabelWithStyle("Nouvelle autorisation:", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		container.NewBorder(nil, nil, nil, addBtn, keySelect),
+		departmentCheck,
+		validity.content(),
+		widget.NewSeparator(),
+		container.NewHBox(closeBtn),
+	)
+
+	dialog = widget.NewModalPopUp(content, app.window.Canvas())
+	dialog.Resize(fyne.NewSize(700, 550))
+	dialog.Show()
+}
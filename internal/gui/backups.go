@@ -135,6 +135,10 @@ func createBackupRow(backup db.BackupInfo, app *App) fyne.CanvasObject {
 
 // showRestoreConfirmDialog affiche la confirmation de restauration
 func showRestoreConfirmDialog(app *App, backup db.BackupInfo) {
+	if !app.requireAdmin() {
+		return
+	}
+
 	message := fmt.Sprintf(
 		"⚠️ ATTENTION : Cette action va remplacer votre base de données actuelle.\n\n"+
 			"Sauvegarde à restaurer :\n"+
@@ -150,7 +154,7 @@ func showRestoreConfirmDialog(app *App, backup db.BackupInfo) {
 
 	app.showConfirm("Confirmer la Restauration", message, func() {
 		// Effectuer la restauration
-		err := db.RestoreDatabase(backup.Path, app.dbPath)
+		err := app.store.Restore(backup.Path)
 		if err != nil {
 			app.showError("Erreur", fmt.Sprintf("Erreur lors de la restauration: %v", err))
 			return
@@ -170,6 +174,10 @@ func showRestoreConfirmDialog(app *App, backup db.BackupInfo) {
 
 // showDeleteBackupDialog affiche la confirmation de suppression
 func showDeleteBackupDialog(app *App, backup db.BackupInfo) {
+	if !app.requireAdmin() {
+		return
+	}
+
 	message := fmt.Sprintf(
 		"🗑️ Êtes-vous sûr de vouloir supprimer cette sauvegarde ?\n\n"+
 			"• Nom : %s\n"+
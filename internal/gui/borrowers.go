@@ -4,6 +4,7 @@ import (
 	"clefs/internal/db"
 	"clefs/internal/pdf"
 	"fmt"
+	"strings"
 	"time"
 
 	"fyne.io/fyne/v2"
@@ -24,7 +25,7 @@ func createBorrowersView(app *App) fyne.CanvasObject {
 	header := container.NewBorder(nil, nil, nil, addBtn, title)
 
 	// Récupérer les emprunteurs
-	borrowers, err := db.GetAllBorrowers()
+	borrowers, err := app.store.GetAllBorrowers()
 	if err != nil {
 		return container.NewVBox(
 			header,
@@ -54,13 +55,25 @@ func createBorrowersListView(borrowers []db.Borrower, app *App) fyne.CanvasObjec
 		b := borrower // Capture
 
 		// Récupérer le nombre d'emprunts actifs
-		loanCount, _ := db.GetBorrowerActiveLoanCount(b.ID)
+		loanCount, _ := app.store.GetBorrowerActiveLoanCount(b.ID)
 
+		nameText := b.Name
+		if b.Status == db.BorrowerDeparted {
+			nameText += " (" + b.Status.Label() + ")"
+		}
 		borrowerInfo := container.NewVBox(
-			widget.NewLabelWithStyle(b.Name, fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
-			widget.NewLabel(fmt.Sprintf("Email: %s", b.Email)),
-			widget.NewLabel(fmt.Sprintf("Emprunts actifs: %d", loanCount)),
+			widget.NewLabelWithStyle(nameText, fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
 		)
+		if id := b.Identification(); id != "" {
+			borrowerInfo.Add(widget.NewLabel(id))
+		}
+		contact := fmt.Sprintf("Email: %s", b.Email)
+		if b.Phone != "" {
+			contact += fmt.Sprintf(" | Tél: %s", b.Phone)
+		}
+		borrowerInfo.Add(widget.NewLabel(contact))
+		addCustomFieldLabels(app, borrowerInfo, db.AuditEntityBorrower, b.ID)
+		borrowerInfo.Add(widget.NewLabel(fmt.Sprintf("Emprunts actifs: %d", loanCount)))
 
 		actions := container.NewHBox()
 
@@ -76,25 +89,36 @@ func createBorrowersListView(borrowers []db.Borrower, app *App) fyne.CanvasObjec
 		})
 		actions.Add(editBtn)
 
-		deleteBtn := widget.NewButton("🗑️ Supprimer", func() {
+		authorizationsBtn := widget.NewButton("🔐 Autorisations", func() {
+			showBorrowerAuthorizationsDialog(app, b)
+		})
+		actions.Add(authorizationsBtn)
+
+		attachmentsBtn := newAttachmentsButton(app, db.AuditEntityBorrower, b.ID, b.Name, app.showBorrowers)
+		actions.Add(attachmentsBtn)
+
+		archiveBtn := widget.NewButton("📦 Archiver", func() {
+			if !app.requireEdit() {
+				return
+			}
 			if loanCount > 0 {
-				app.showError("Impossible de supprimer", "Cet emprunteur a des emprunts actifs.")
+				app.showError("Impossible d'archiver", "Cet emprunteur a des emprunts actifs.")
 				return
 			}
-			app.showConfirm("Confirmer la suppression",
-				fmt.Sprintf("Êtes-vous sûr de vouloir supprimer %s?", b.Name),
+			app.showConfirm("Confirmer l'archivage",
+				fmt.Sprintf("Archiver %s ?\nIl n'apparaîtra plus dans les listes mais reste dans l'historique des emprunts.", b.Name),
 				func() {
-					err := db.DeleteBorrower(b.ID)
+					err := app.store.ArchiveRecord(db.AuditEntityBorrower, b.ID)
 					if err != nil {
-						app.showError("Erreur", fmt.Sprintf("Erreur lors de la suppression: %v", err))
+						app.showError("Erreur", fmt.Sprintf("Impossible d'archiver l'emprunteur: %v", err))
 						return
 					}
-					app.showSuccess("Emprunteur supprimé avec succès!")
+					app.showSuccess("Emprunteur archivé avec succès!")
 					app.showBorrowers()
 				})
 		})
-		deleteBtn.Importance = widget.DangerImportance
-		actions.Add(deleteBtn)
+		archiveBtn.Importance = widget.DangerImportance
+		actions.Add(archiveBtn)
 
 		borrowerCard := container.NewBorder(nil, nil, nil, actions, borrowerInfo)
 		list.Add(borrowerCard)
@@ -107,20 +131,128 @@ func createBorrowersListView(borrowers []db.Borrower, app *App) fyne.CanvasObjec
 	return list
 }
 
-// showAddBorrowerDialog affiche la boîte de dialogue pour ajouter un emprunteur
-func showAddBorrowerDialog(app *App) {
-	nameEntry := widget.NewEntry()
-	nameEntry.SetPlaceHolder("Nom de l'emprunteur")
+// borrowerForm regroupe les champs de la fiche emprunteur, communs à l'ajout et à la modification
+type borrowerForm struct {
+	firstName  *widget.Entry
+	lastName   *widget.Entry
+	email      *widget.Entry
+	phone      *widget.Entry
+	badge      *widget.Entry
+	department *widget.Entry
+	status     *widget.Select
+	startDate  *widget.Entry
+	endDate    *widget.Entry
+	notes      *widget.Entry
+	statusMap  map[string]db.BorrowerStatus
+}
 
-	emailEntry := widget.NewEntry()
-	emailEntry.SetPlaceHolder("Email (optionnel)")
+// newBorrowerForm crée les champs de la fiche, préremplis avec b
+func newBorrowerForm(b *db.Borrower) *borrowerForm {
+	f := &borrowerForm{
+		firstName:  widget.NewEntry(),
+		lastName:   widget.NewEntry(),
+		email:      widget.NewEntry(),
+		phone:      widget.NewEntry(),
+		badge:      widget.NewEntry(),
+		department: widget.NewEntry(),
+		startDate:  widget.NewEntry(),
+		endDate:    widget.NewEntry(),
+		notes:      widget.NewMultiLineEntry(),
+		statusMap:  make(map[string]db.BorrowerStatus),
+	}
+	f.firstName.SetPlaceHolder("Prénom")
+	f.lastName.SetPlaceHolder("Nom")
+	f.email.SetPlaceHolder("Email (optionnel)")
+	f.phone.SetPlaceHolder("Téléphone (optionnel)")
+	f.badge.SetPlaceHolder("Badge / matricule (optionnel)")
+	f.department.SetPlaceHolder("Service (optionnel)")
+	f.startDate.SetPlaceHolder("Arrivée (JJ/MM/AAAA)")
+	f.endDate.SetPlaceHolder("Départ (JJ/MM/AAAA)")
+	f.notes.SetPlaceHolder("Notes")
+
+	var statusOptions []string
+	for _, status := range []db.BorrowerStatus{db.BorrowerActive, db.BorrowerDeparted} {
+		statusOptions = append(statusOptions, status.Label())
+		f.statusMap[status.Label()] = status
+	}
+	f.status = widget.NewSelect(statusOptions, nil)
+	f.status.SetSelected(db.BorrowerActive.Label())
+
+	f.firstName.SetText(b.FirstName)
+	f.lastName.SetText(b.LastName)
+	// Fiche ancienne sans prénom ni nom séparés
+	if b.FirstName == "" && b.LastName == "" {
+		f.lastName.SetText(b.Name)
+	}
+	f.email.SetText(b.Email)
+	f.phone.SetText(b.Phone)
+	f.badge.SetText(b.BadgeNumber)
+	f.department.SetText(b.Department)
+	f.notes.SetText(b.Notes)
+	if b.Status != "" {
+		f.status.SetSelected(b.Status.Label())
+	}
+	if b.StartDate != nil {
+		f.startDate.SetText(b.StartDate.Format("02/01/2006"))
+	}
+	if b.EndDate != nil {
+		f.endDate.SetText(b.EndDate.Format("02/01/2006"))
+	}
+
+	return f
+}
 
-	form := container.NewVBox(
-		widget.NewLabel("Nom:"),
-		nameEntry,
-		widget.NewLabel("Email:"),
-		emailEntry,
+// content retourne la mise en page de la fiche
+func (f *borrowerForm) content() fyne.CanvasObject {
+	return container.NewVBox(
+		widget.NewLabel("Prénom et nom:"),
+		container.NewGridWithColumns(2, f.firstName, f.lastName),
+		widget.NewLabel("Contact:"),
+		container.NewGridWithColumns(2, f.email, f.phone),
+		widget.NewLabel("Badge et service:"),
+		container.NewGridWithColumns(2, f.badge, f.department),
+		widget.NewLabel("Statut et dates de présence:"),
+		container.NewGridWithColumns(3, f.status, f.startDate, f.endDate),
+		widget.NewLabel("Notes:"),
+		f.notes,
 	)
+}
+
+// apply recopie les champs dans b après les avoir vérifiés
+func (f *borrowerForm) apply(b *db.Borrower) error {
+	if strings.TrimSpace(f.firstName.Text) == "" && strings.TrimSpace(f.lastName.Text) == "" {
+		return fmt.Errorf("le nom est requis")
+	}
+	startDate, err := parseHistoryDate(f.startDate.Text, false)
+	if err != nil {
+		return err
+	}
+	endDate, err := parseHistoryDate(f.endDate.Text, false)
+	if err != nil {
+		return err
+	}
+
+	b.FirstName = strings.TrimSpace(f.firstName.Text)
+	b.LastName = strings.TrimSpace(f.lastName.Text)
+	b.Email = strings.TrimSpace(f.email.Text)
+	b.Phone = strings.TrimSpace(f.phone.Text)
+	b.BadgeNumber = strings.TrimSpace(f.badge.Text)
+	b.Department = strings.TrimSpace(f.department.Text)
+	b.Status = f.statusMap[f.status.Selected]
+	b.StartDate = startDate
+	b.EndDate = endDate
+	b.Notes = f.notes.Text
+	return nil
+}
+
+// showAddBorrowerDialog affiche la boîte de dialogue pour ajouter un emprunteur
+func showAddBorrowerDialog(app *App) {
+	if !app.requireEdit() {
+		return
+	}
+
+	form := newBorrowerForm(&db.Borrower{})
+	custom := newCustomFieldInputs(app, db.AuditEntityBorrower, 0)
 
 	var popupDialog *widget.PopUp
 
@@ -129,17 +261,19 @@ func showAddBorrowerDialog(app *App) {
 	})
 
 	saveBtn := widget.NewButton("Enregistrer", func() {
-		if nameEntry.Text == "" {
-			app.showError("Erreur", "Le nom est requis.")
+		borrower := &db.Borrower{}
+		if err := form.apply(borrower); err != nil {
+			app.showError("Erreur", err.Error())
 			return
 		}
-
-		borrower := &db.Borrower{
-			Name:  nameEntry.Text,
-			Email: emailEntry.Text,
+		customValues, err := custom.values()
+		if err != nil {
+			app.showError("Erreur", err.Error())
+			return
 		}
+		borrower.CustomValues = customValues
 
-		err := db.CreateBorrower(borrower)
+		err = app.store.CreateBorrower(borrower)
 		if err != nil {
 			app.showError("Erreur", fmt.Sprintf("Erreur lors de la création: %v", err))
 			return
@@ -154,37 +288,32 @@ func showAddBorrowerDialog(app *App) {
 	content := container.NewVBox(
 		widget.NewLabelWithStyle("Ajouter un Emprunteur", fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
 		widget.NewSeparator(),
-		form,
+		form.content(),
+		custom.content(),
 		widget.NewSeparator(),
 		container.NewHBox(cancelBtn, saveBtn),
 	)
 
 	popupDialog = widget.NewModalPopUp(content, app.window.Canvas())
-	popupDialog.Resize(fyne.NewSize(400, 250))
+	popupDialog.Resize(fyne.NewSize(600, 550+custom.height()))
 	popupDialog.Show()
 }
 
 // showEditBorrowerDialog affiche la boîte de dialogue pour modifier un emprunteur
 func showEditBorrowerDialog(app *App, borrowerID int) {
+	if !app.requireEdit() {
+		return
+	}
+
 	// Récupérer l'emprunteur
-	borrower, err := db.GetBorrowerByID(borrowerID)
+	borrower, err := app.store.GetBorrowerByID(borrowerID)
 	if err != nil {
 		app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération de l'emprunteur: %v", err))
 		return
 	}
 
-	nameEntry := widget.NewEntry()
-	nameEntry.SetText(borrower.Name)
-
-	emailEntry := widget.NewEntry()
-	emailEntry.SetText(borrower.Email)
-
-	form := container.NewVBox(
-		widget.NewLabel("Nom:"),
-		nameEntry,
-		widget.NewLabel("Email:"),
-		emailEntry,
-	)
+	form := newBorrowerForm(borrower)
+	custom := newCustomFieldInputs(app, db.AuditEntityBorrower, borrower.ID)
 
 	var popupDialog *widget.PopUp
 
@@ -193,15 +322,18 @@ func showEditBorrowerDialog(app *App, borrowerID int) {
 	})
 
 	saveBtn := widget.NewButton("Enregistrer", func() {
-		if nameEntry.Text == "" {
-			app.showError("Erreur", "Le nom est requis.")
+		if err := form.apply(borrower); err != nil {
+			app.showError("Erreur", err.Error())
 			return
 		}
+		customValues, err := custom.values()
+		if err != nil {
+			app.showError("Erreur", err.Error())
+			return
+		}
+		borrower.CustomValues = customValues
 
-		borrower.Name = nameEntry.Text
-		borrower.Email = emailEntry.Text
-
-		err := db.UpdateBorrower(borrower)
+		err = app.store.UpdateBorrower(borrower)
 		if err != nil {
 			app.showError("Erreur", fmt.Sprintf("Erreur lors de la modification: %v", err))
 			return
@@ -216,27 +348,28 @@ func showEditBorrowerDialog(app *App, borrowerID int) {
 	content := container.NewVBox(
 		widget.NewLabelWithStyle("Modifier l'Emprunteur", fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
 		widget.NewSeparator(),
-		form,
+		form.content(),
+		custom.content(),
 		widget.NewSeparator(),
 		container.NewHBox(cancelBtn, saveBtn),
 	)
 
 	popupDialog = widget.NewModalPopUp(content, app.window.Canvas())
-	popupDialog.Resize(fyne.NewSize(400, 250))
+	popupDialog.Resize(fyne.NewSize(600, 550+custom.height()))
 	popupDialog.Show()
 }
 
 // generateBorrowerReceipt génère un reçu PDF pour un emprunteur
 func generateBorrowerReceipt(app *App, borrowerID int) {
 	// Récupérer l'emprunteur
-	borrower, err := db.GetBorrowerByID(borrowerID)
+	borrower, err := app.store.GetBorrowerByID(borrowerID)
 	if err != nil {
 		app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération de l'emprunteur: %v", err))
 		return
 	}
 
 	// Récupérer les emprunts actifs
-	loans, err := db.GetActiveLoansByBorrowerID(borrowerID)
+	loans, err := app.store.GetActiveLoansByBorrowerID(borrowerID)
 	if err != nil {
 		app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération des emprunts: %v", err))
 		return
@@ -247,6 +380,12 @@ func generateBorrowerReceipt(app *App, borrowerID int) {
 		return
 	}
 
+	// Les signatures recueillies à l'écran figurent sur le reçu
+	if err := app.store.LoadLoanSignatures(loans); err != nil {
+		app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération des signatures: %v", err))
+		return
+	}
+
 	// Générer le PDF
 	pdfData, err := pdf.GenerateBorrowerReceipt(borrower, loans)
 	if err != nil {
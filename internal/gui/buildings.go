@@ -3,6 +3,7 @@ package gui
 import (
 	"clefs/internal/db"
 	"fmt"
+	"strconv"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
@@ -21,7 +22,7 @@ func createBuildingsView(app *App) fyne.CanvasObject {
 	header := container.NewBorder(nil, nil, nil, addBtn, title)
 
 	// Récupérer les bâtiments
-	buildings, err := db.GetAllBuildings()
+	buildings, err := app.store.GetAllBuildings()
 	if err != nil {
 		return container.NewVBox(
 			header,
@@ -51,7 +52,7 @@ func createBuildingsListView(buildings []db.Building, app *App) fyne.CanvasObjec
 		b := building // Capture
 
 		// Récupérer le nombre de salles
-		rooms, _ := db.GetRoomsByBuildingID(b.ID)
+		rooms, _ := app.store.GetRoomsByBuildingID(b.ID)
 		roomCount := len(rooms)
 
 		buildingInfo := container.NewVBox(
@@ -63,26 +64,33 @@ func createBuildingsListView(buildings []db.Building, app *App) fyne.CanvasObjec
 			showEditBuildingDialog(app, b.ID)
 		})
 
-		deleteBtn := widget.NewButton("🗑️ Supprimer", func() {
+		floorsBtn := widget.NewButton("🏬 Étages", func() {
+			showBuildingFloorsDialog(app, b)
+		})
+
+		archiveBtn := widget.NewButton("📦 Archiver", func() {
+			if !app.requireEdit() {
+				return
+			}
 			if roomCount > 0 {
-				app.showError("Impossible de supprimer", "Ce bâtiment contient des salles.")
+				app.showError("Impossible d'archiver", "Ce bâtiment contient des salles en service : archivez-les d'abord.")
 				return
 			}
-			app.showConfirm("Confirmer la suppression",
-				fmt.Sprintf("Êtes-vous sûr de vouloir supprimer le bâtiment %s?", b.Name),
+			app.showConfirm("Confirmer l'archivage",
+				fmt.Sprintf("Archiver le bâtiment %s ?", b.Name),
 				func() {
-					err := db.DeleteBuilding(b.ID)
+					err := app.store.ArchiveRecord(db.AuditEntityBuilding, b.ID)
 					if err != nil {
-						app.showError("Erreur", fmt.Sprintf("Erreur lors de la suppression: %v", err))
+						app.showError("Erreur", fmt.Sprintf("Impossible d'archiver le bâtiment: %v", err))
 						return
 					}
-					app.showSuccess("Bâtiment supprimé avec succès!")
+					app.showSuccess("Bâtiment archivé avec succès!")
 					app.showBuildings()
 				})
 		})
-		deleteBtn.Importance = widget.DangerImportance
+		archiveBtn.Importance = widget.DangerImportance
 
-		actions := container.NewHBox(editBtn, deleteBtn)
+		actions := container.NewHBox(editBtn, floorsBtn, archiveBtn)
 
 		buildingCard := container.NewBorder(nil, nil, nil, actions, buildingInfo)
 		list.Add(buildingCard)
@@ -97,6 +105,10 @@ func createBuildingsListView(buildings []db.Building, app *App) fyne.CanvasObjec
 
 // showAddBuildingDialog affiche la boîte de dialogue pour ajouter un bâtiment
 func showAddBuildingDialog(app *App) {
+	if !app.requireEdit() {
+		return
+	}
+
 	nameEntry := widget.NewEntry()
 	nameEntry.SetPlaceHolder("Nom du bâtiment")
 
@@ -121,7 +133,7 @@ func showAddBuildingDialog(app *App) {
 			Name: nameEntry.Text,
 		}
 
-		err := db.CreateBuilding(building)
+		err := app.store.CreateBuilding(building)
 		if err != nil {
 			app.showError("Erreur", fmt.Sprintf("Erreur lors de la création: %v", err))
 			return
@@ -148,8 +160,12 @@ func showAddBuildingDialog(app *App) {
 
 // showEditBuildingDialog affiche la boîte de dialogue pour modifier un bâtiment
 func showEditBuildingDialog(app *App, buildingID int) {
+	if !app.requireEdit() {
+		return
+	}
+
 	// Récupérer le bâtiment
-	building, err := db.GetBuildingByID(buildingID)
+	building, err := app.store.GetBuildingByID(buildingID)
 	if err != nil {
 		app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération du bâtiment: %v", err))
 		return
@@ -177,7 +193,7 @@ func showEditBuildingDialog(app *App, buildingID int) {
 
 		building.Name = nameEntry.Text
 
-		err := db.UpdateBuilding(building)
+		err := app.store.UpdateBuilding(building)
 		if err != nil {
 			app.showError("Erreur", fmt.Sprintf("Erreur lors de la modification: %v", err))
 			return
@@ -201,3 +217,128 @@ func showEditBuildingDialog(app *App, buildingID int) {
 	popupDialog.Resize(fyne.NewSize(400, 200))
 	popupDialog.Show()
 }
+
+// showBuildingFloorsDialog affiche les étages d'un bâtiment pour les ajouter, les renommer
+// ou les supprimer ; les salles y sont rattachées depuis leur fiche
+func showBuildingFloorsDialog(app *App, building db.Building) {
+	var dialog *widget.PopUp
+
+	// reopen rafraîchit la boîte de dialogue après une modification
+	reopen := func() {
+		app.window.Canvas().Overlays().Remove(dialog)
+		showBuildingFloorsDialog(app, building)
+	}
+
+	floors, err := app.store.GetFloorsByBuildingID(building.ID)
+	if err != nil {
+		app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération des étages: %v", err))
+		return
+	}
+
+	// parseLevel lit le niveau saisi (0 = rez-de-chaussée, négatif = sous-sol)
+	parseLevel := func(text string) (int, bool) {
+		level, err := strconv.Atoi(text)
+		if err != nil {
+			app.showError("Erreur", "Le niveau doit être un nombre entier (0 = rez-de-chaussée, -1 = sous-sol).")
+			return 0, false
+		}
+		return level, true
+	}
+
+	listBox := container.NewVBox()
+	if len(floors) == 0 {
+		listBox.Add(widget.NewLabel("Aucun étage : les salles du bâtiment sont listées ensemble."))
+	}
+	for _, floor := range floors {
+		f := floor // Capture
+
+		nameEntry := widget.NewEntry()
+		nameEntry.SetText(f.Name)
+		levelEntry := widget.NewEntry()
+		levelEntry.SetText(strconv.Itoa(f.Level))
+
+		saveBtn := widget.NewButton("💾", func() {
+			if !app.requireEdit() {
+				return
+			}
+			level, ok := parseLevel(levelEntry.Text)
+			if !ok {
+				return
+			}
+			f.Name = nameEntry.Text
+			f.Level = level
+			if err := app.store.UpdateFloor(&f); err != nil {
+				app.showError("Erreur", fmt.Sprintf("Erreur lors de la modification de l'étage: %v", err))
+				return
+			}
+			reopen()
+		})
+
+		deleteBtn := widget.NewButton("🗑️", func() {
+			if !app.requireEdit() {
+				return
+			}
+			app.window.Canvas().Overlays().Remove(dialog)
+			app.showConfirm("Confirmer la suppression",
+				fmt.Sprintf("Supprimer l'étage %s ? Ses %d salle(s) restent dans le bâtiment, sans étage.", f.Name, f.RoomCount),
+				func() {
+					if err := app.store.DeleteFloor(f.ID); err != nil {
+						app.showError("Erreur", fmt.Sprintf("Impossible de supprimer l'étage: %v", err))
+						return
+					}
+					showBuildingFloorsDialog(app, building)
+				})
+		})
+		deleteBtn.Importance = widget.DangerImportance
+
+		rooms := widget.NewLabel(fmt.Sprintf("%d salle(s)", f.RoomCount))
+		listBox.Add(container.NewBorder(nil, nil, nil, container.NewHBox(rooms, saveBtn, deleteBtn),
+			container.NewGridWithColumns(2, nameEntry, levelEntry)))
+	}
+
+	listScroll := container.NewVScroll(listBox)
+	listScroll.SetMinSize(fyne.NewSize(500, 220))
+
+	// Ajout d'un étage
+	nameEntry := widget.NewEntry()
+	nameEntry.SetPlaceHolder("Nom (ex: 1er étage)")
+	levelEntry := widget.NewEntry()
+	levelEntry.SetPlaceHolder("Niveau (0 = RDC)")
+
+	addBtn := widget.NewButton("➕ Ajouter", func() {
+		if !app.requireEdit() {
+			return
+		}
+		level, ok := parseLevel(levelEntry.Text)
+		if !ok {
+			return
+		}
+		if err := app.store.CreateFloor(&db.Floor{BuildingID: building.ID, Name: nameEntry.Text, Level: level}); err != nil {
+			app.showError("Erreur", fmt.Sprintf("Erreur lors de l'ajout de l'étage: %v", err))
+			return
+		}
+		reopen()
+	})
+	addBtn.Importance = widget.HighImportance
+
+	closeBtn := widget.NewButton("Fermer", func() {
+		app.window.Canvas().Overlays().Remove(dialog)
+		app.showBuildings()
+	})
+
+	content := container.NewVBox(
+		widget.NewLabelWithStyle(fmt.Sprintf("Étages du bâtiment %s", building.Name), fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
+		widget.NewLabel("Le niveau ordonne les étages : 0 pour le rez-de-chaussée, -1 pour un sous-sol."),
+		widget.NewSeparator(),
+		listScroll,
+		widget.NewSeparator(),
+		widget.NewLabelWithStyle("Nouvel étage:", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		container.NewBorder(nil, nil, nil, addBtn, container.NewGridWithColumns(2, nameEntry, levelEntry)),
+		widget.NewSeparator(),
+		container.NewHBox(closeBtn),
+	)
+
+	dialog = widget.NewModalPopUp(content, app.window.Canvas())
+	dialog.Resize(fyne.NewSize(600, 480))
+	dialog.Show()
+}
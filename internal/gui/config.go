@@ -130,6 +130,10 @@ func createConfigNavigationSection(app *App) fyne.CanvasObject {
 		app.showRooms()
 	})
 
+	zonesBtn := widget.NewButton("🧭 Gérer les Zones", func() {
+		app.showZones()
+	})
+
 	keysBtn := widget.NewButton("🔑 Gérer les Clés", func() {
 		app.showKeys()
 	})
@@ -138,11 +142,27 @@ func createConfigNavigationSection(app *App) fyne.CanvasObject {
 		app.showBorrowers()
 	})
 
+	storageBtn := widget.NewButton("🗄️ Emplacements et Armoires", func() {
+		app.showStorageLocations()
+	})
+
+	customFieldsBtn := widget.NewButton("🏷️ Champs Personnalisés", func() {
+		app.showCustomFields()
+	})
+
+	archivesBtn := widget.NewButton("📦 Archives", func() {
+		app.showArchives()
+	})
+
 	buttons := container.NewVBox(
 		buildingsBtn,
 		roomsBtn,
+		zonesBtn,
 		keysBtn,
 		borrowersBtn,
+		storageBtn,
+		customFieldsBtn,
+		archivesBtn,
 	)
 
 	return container.NewVBox(
@@ -193,6 +213,10 @@ func showBackupDialog(app *App) {
 
 // showRestoreDialog affiche la boîte de dialogue de restauration
 func showRestoreDialog(app *App) {
+	if !app.requireAdmin() {
+		return
+	}
+
 	openDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
 		if err != nil {
 			app.showError("Erreur", fmt.Sprintf("Erreur: %v", err))
@@ -212,7 +236,7 @@ func showRestoreDialog(app *App) {
 				"Voulez-vous continuer?",
 			func() {
 				// Effectuer la restauration
-				err := db.RestoreDatabase(backupPath, app.dbPath)
+				err := app.store.Restore(backupPath)
 				if err != nil {
 					app.showError("Erreur", fmt.Sprintf("Erreur lors de la restauration: %v", err))
 					return
@@ -256,6 +280,10 @@ func performQuickBackup(app *App) {
 
 // showResetDatabaseDialog affiche le dialogue de réinitialisation avec 3 confirmations
 func showResetDatabaseDialog(app *App) {
+	if !app.requireAdmin() {
+		return
+	}
+
 	// PREMIÈRE CONFIRMATION
 	app.showConfirm("⚠️ Réinitialisation - Étape 1/3",
 		"🚨 ATTENTION : Vous êtes sur le point de SUPPRIMER TOUTES LES DONNÉES !\n\n"+
@@ -264,6 +292,7 @@ func showResetDatabaseDialog(app *App) {
 			"• Tous les emprunteurs\n"+
 			"• Tous les emprunts\n"+
 			"• Tous les bâtiments et salles\n\n"+
+			"Les comptes opérateurs et le journal d'audit sont conservés.\n"+
 			"Une sauvegarde automatique sera créée avant la suppression.\n\n"+
 			"Êtes-vous ABSOLUMENT SÛR de vouloir continuer ?",
 		func() {
@@ -295,10 +324,8 @@ func showResetDatabaseDialog(app *App) {
 
 // performDatabaseReset effectue la réinitialisation de la base de données
 func performDatabaseReset(app *App) {
-	dbPath := app.dbPath
-
 	// Effectuer la réinitialisation
-	err := db.ResetDatabase(dbPath)
+	err := app.store.Reset()
 	if err != nil {
 		app.showError("Erreur", fmt.Sprintf("Erreur lors de la réinitialisation: %v", err))
 		return
@@ -314,6 +341,10 @@ func performDatabaseReset(app *App) {
 
 // showLoadDemoDialog affiche le dialogue pour charger la version démo
 func showLoadDemoDialog(app *App) {
+	if !app.requireAdmin() {
+		return
+	}
+
 	app.showConfirm("Charger la Version Démo",
 		"🎮 Voulez-vous charger des données de démonstration ?\n\n"+
 			"Cela va ajouter :\n"+
@@ -332,7 +363,7 @@ func showLoadDemoDialog(app *App) {
 // performLoadDemo charge les données de démonstration
 func performLoadDemo(app *App) {
 	// Charger les données de démo
-	err := db.GenerateDemoData()
+	err := app.store.GenerateDemoData()
 	if err != nil {
 		app.showError("Erreur", fmt.Sprintf("Erreur lors du chargement des données de démo: %v", err))
 		return
@@ -348,6 +379,10 @@ func performLoadDemo(app *App) {
 
 // showImportPythonDialog affiche le dialogue d'importation depuis Python
 func showImportPythonDialog(app *App) {
+	if !app.requireAdmin() {
+		return
+	}
+
 	openDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
 		if err != nil {
 			app.showError("Erreur", fmt.Sprintf("Erreur: %v", err))
@@ -371,7 +406,7 @@ func showImportPythonDialog(app *App) {
 				"Voulez-vous continuer ?",
 			func() {
 				// Effectuer l'importation
-				err := db.ImportFromPythonDB(pythonDBPath, app.dbPath)
+				err := app.store.ImportFromPythonDB(pythonDBPath)
 				if err != nil {
 					app.showError("Erreur", fmt.Sprintf("Erreur lors de l'importation: %v", err))
 					return
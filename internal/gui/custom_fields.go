@@ -0,0 +1,342 @@
+package gui
+
+import (
+	"clefs/internal/db"
+	"clefs/internal/pdf"
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// noCustomChoice est l'option d'une liste de choix personnalisée non renseignée
+const noCustomChoice = "—"
+
+// customFieldInputs regroupe la saisie des champs personnalisés d'un formulaire de clé,
+// de salle ou d'emprunteur
+type customFieldInputs struct {
+	fields  []db.CustomField
+	entries map[int]*widget.Entry
+	selects map[int]*widget.Select
+}
+
+// newCustomFieldInputs crée la saisie des champs personnalisés d'un enregistrement
+// (recordID 0 pour une création), préremplie avec ses valeurs
+func newCustomFieldInputs(app *App, entity string, recordID int) *customFieldInputs {
+	inputs := &customFieldInputs{
+		entries: make(map[int]*widget.Entry),
+		selects: make(map[int]*widget.Select),
+	}
+	inputs.fields, _ = app.store.GetRecordCustomFields(entity, recordID)
+	values := db.CustomValues{}
+	if recordID > 0 {
+		values, _ = app.store.GetCustomValues(entity, recordID)
+	}
+
+	for _, f := range inputs.fields {
+		value := f.Display(values[f.ID])
+		if f.Type == db.CustomFieldChoice {
+			options := append([]string{noCustomChoice}, f.Choices...)
+			// Un choix retiré de la liste reste proposé sur les fiches qui l'utilisent
+			if value != "" && !containsString(f.Choices, value) {
+				options = append(options, value)
+			}
+			choice := widget.NewSelect(options, nil)
+			choice.SetSelected(noCustomChoice)
+			if value != "" {
+				choice.SetSelected(value)
+			}
+			inputs.selects[f.ID] = choice
+			continue
+		}
+
+		entry := widget.NewEntry()
+		switch f.Type {
+		case db.CustomFieldNumber:
+			entry.SetPlaceHolder("Nombre (ex: 12,5)")
+		case db.CustomFieldDate:
+			entry.SetPlaceHolder("JJ/MM/AAAA")
+		}
+		entry.SetText(value)
+		inputs.entries[f.ID] = entry
+	}
+	return inputs
+}
+
+// containsString indique si values contient value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// content retourne la mise en page des champs (vide si le site n'en définit aucun)
+func (c *customFieldInputs) content() fyne.CanvasObject {
+	box := container.NewVBox()
+	if len(c.fields) == 0 {
+		return box
+	}
+	box.Add(widget.NewSeparator())
+	box.Add(widget.NewLabelWithStyle("Champs personnalisés:", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+	for _, f := range c.fields {
+		box.Add(widget.NewLabel(f.Label + ":"))
+		if choice, ok := c.selects[f.ID]; ok {
+			box.Add(choice)
+		} else {
+			box.Add(c.entries[f.ID])
+		}
+	}
+	return box
+}
+
+// height retourne la hauteur à ajouter à la boîte de dialogue pour afficher les champs
+func (c *customFieldInputs) height() float32 {
+	if len(c.fields) == 0 {
+		return 0
+	}
+	return 45 + 75*float32(len(c.fields))
+}
+
+// values retourne les valeurs saisies, vérifiées selon le type de chaque champ
+func (c *customFieldInputs) values() (db.CustomValues, error) {
+	values := make(db.CustomValues, len(c.fields))
+	for _, f := range c.fields {
+		var value string
+		if choice, ok := c.selects[f.ID]; ok {
+			if choice.Selected != noCustomChoice {
+				value = choice.Selected
+			}
+		} else {
+			value = c.entries[f.ID].Text
+		}
+		if _, err := f.Normalize(value); err != nil {
+			return nil, err
+		}
+		values[f.ID] = value
+	}
+	return values, nil
+}
+
+// addCustomFieldLabels ajoute à box les champs personnalisés renseignés d'un enregistrement
+func addCustomFieldLabels(app *App, box *fyne.Container, entity string, id int) {
+	values, err := app.store.GetRecordCustomFieldValues(entity, id)
+	if err != nil {
+		return
+	}
+	for _, v := range values {
+		if v.Value != "" {
+			box.Add(widget.NewLabel(fmt.Sprintf("🏷️ %s: %s", v.Field.Label, v.Value)))
+		}
+	}
+}
+
+// createCustomFieldsView crée la vue de définition des champs personnalisés du site
+func createCustomFieldsView(app *App) fyne.CanvasObject {
+	title := widget.NewLabelWithStyle("🏷️ Champs Personnalisés", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	info := widget.NewLabel("Chaque site peut ajouter ses propres champs aux clés, salles et emprunteurs " +
+		"(couleur de la clé, marque du cylindre, plaque du véhicule...). Ils apparaissent dans les formulaires, " +
+		fmt.Sprintf("sont trouvés par la recherche et, pour au plus %d champs par catégorie, forment des colonnes des rapports PDF.", db.MaxReportCustomFields))
+	info.Wrapping = fyne.TextWrapWord
+
+	exportBtn := widget.NewButton("📤 Exporter (PDF)", func() {
+		generateCustomFieldsExportPDF(app)
+	})
+
+	header := container.NewBorder(nil, nil, nil, exportBtn, title)
+
+	list := container.NewVBox()
+	for _, entity := range db.CustomFieldEntities {
+		e := entity // Capture
+
+		addBtn := widget.NewButton("➕ Ajouter", func() {
+			showCustomFieldDialog(app, e, nil)
+		})
+		addBtn.Importance = widget.HighImportance
+		list.Add(container.NewBorder(nil, nil, nil, addBtn,
+			widget.NewLabelWithStyle(db.CustomFieldEntityLabel(e), fyne.TextAlignLeading, fyne.TextStyle{Bold: true})))
+
+		fields, err := app.store.GetCustomFields(e)
+		if err != nil {
+			list.Add(widget.NewLabel(fmt.Sprintf("Erreur: %v", err)))
+			continue
+		}
+		if len(fields) == 0 {
+			list.Add(widget.NewLabel("  Aucun champ personnalisé"))
+		}
+
+		for _, field := range fields {
+			f := field // Capture
+
+			description := f.Type.Label()
+			if f.Type == db.CustomFieldChoice {
+				description += " : " + strings.Join(f.Choices, ", ")
+			}
+			if f.InReports {
+				description += " | 📄 dans les rapports"
+			}
+			fieldInfo := container.NewVBox(
+				widget.NewLabel("  "+f.Label),
+				widget.NewLabel("      "+description),
+			)
+
+			editBtn := widget.NewButton("✏️", func() {
+				edited := f
+				showCustomFieldDialog(app, f.Entity, &edited)
+			})
+			editBtn.Importance = widget.LowImportance
+
+			deleteBtn := widget.NewButton("🗑️", func() {
+				if !app.requireAdmin() {
+					return
+				}
+				app.showConfirm("Confirmer la suppression",
+					fmt.Sprintf("Supprimer le champ %s ?\nLes valeurs saisies sur toutes les fiches seront perdues.", f.Label),
+					func() {
+						if err := app.store.DeleteCustomField(f.ID); err != nil {
+							app.showError("Erreur", fmt.Sprintf("Impossible de supprimer le champ: %v", err))
+							return
+						}
+						app.showSuccess("Champ supprimé avec succès!")
+						app.showCustomFields()
+					})
+			})
+			deleteBtn.Importance = widget.DangerImportance
+
+			list.Add(container.NewBorder(nil, nil, nil, container.NewHBox(editBtn, deleteBtn), fieldInfo))
+		}
+		list.Add(widget.NewSeparator())
+	}
+
+	return container.NewBorder(
+		container.NewVBox(header, info, widget.NewSeparator()),
+		nil,
+		nil,
+		nil,
+		container.NewVScroll(list),
+	)
+}
+
+// showCustomFieldDialog affiche la boîte de dialogue de création (field nil) ou de modification
+// d'un champ personnalisé. Le type d'un champ existant n'est pas modifiable.
+func showCustomFieldDialog(app *App, entity string, field *db.CustomField) {
+	if !app.requireAdmin() {
+		return
+	}
+
+	dialogTitle := fmt.Sprintf("Nouveau champ (%s)", db.CustomFieldEntityLabel(entity))
+	labelEntry := widget.NewEntry()
+	labelEntry.SetPlaceHolder("Libellé (ex: Couleur, Marque du cylindre)")
+
+	typeOptions := make([]string, len(db.CustomFieldTypes))
+	typeMap := make(map[string]db.CustomFieldType)
+	for i, t := range db.CustomFieldTypes {
+		typeOptions[i] = t.Label()
+		typeMap[typeOptions[i]] = t
+	}
+	choicesEntry := widget.NewEntry()
+	choicesEntry.SetPlaceHolder("Choix séparés par des virgules (ex: Rouge, Bleu, Vert)")
+	choicesEntry.Disable()
+
+	typeSelect := widget.NewSelect(typeOptions, func(selected string) {
+		if typeMap[selected] == db.CustomFieldChoice {
+			choicesEntry.Enable()
+		} else {
+			choicesEntry.Disable()
+		}
+	})
+	typeSelect.SetSelected(db.CustomFieldText.Label())
+
+	reportsCheck := widget.NewCheck(fmt.Sprintf("Colonne des rapports PDF (au plus %d par catégorie)", db.MaxReportCustomFields), nil)
+
+	if field != nil {
+		dialogTitle = "Modifier le champ " + field.Label
+		labelEntry.SetText(field.Label)
+		typeSelect.SetSelected(field.Type.Label())
+		typeSelect.Disable()
+		choicesEntry.SetText(strings.Join(field.Choices, ", "))
+		reportsCheck.SetChecked(field.InReports)
+	}
+
+	var popupDialog *widget.PopUp
+
+	cancelBtn := widget.NewButton("Annuler", func() {
+		app.window.Canvas().Overlays().Remove(popupDialog)
+	})
+
+	saveBtn := widget.NewButton("Enregistrer", func() {
+		f := &db.CustomField{Entity: entity, Type: typeMap[typeSelect.Selected]}
+		if field != nil {
+			f = field
+		}
+		f.Label = labelEntry.Text
+		f.Choices = strings.Split(choicesEntry.Text, ",")
+		f.InReports = reportsCheck.Checked
+
+		var err error
+		if field == nil {
+			err = app.store.CreateCustomField(f)
+		} else {
+			err = app.store.UpdateCustomField(f)
+		}
+		if err != nil {
+			app.showError("Erreur", fmt.Sprintf("Erreur lors de l'enregistrement: %v", err))
+			return
+		}
+
+		app.window.Canvas().Overlays().Remove(popupDialog)
+		app.showSuccess("Champ enregistré avec succès!")
+		app.showCustomFields()
+	})
+	saveBtn.Importance = widget.HighImportance
+
+	content := container.NewVBox(
+		widget.NewLabelWithStyle(dialogTitle, fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
+		widget.NewSeparator(),
+		widget.NewLabel("Libellé:"),
+		labelEntry,
+		widget.NewLabel("Type (non modifiable une fois le champ créé):"),
+		typeSelect,
+		widget.NewLabel("Liste de choix:"),
+		choicesEntry,
+		reportsCheck,
+		widget.NewSeparator(),
+		container.NewHBox(cancelBtn, saveBtn),
+	)
+
+	popupDialog = widget.NewModalPopUp(content, app.window.Canvas())
+	popupDialog.Resize(fyne.NewSize(500, 380))
+	popupDialog.Show()
+}
+
+// generateCustomFieldsExportPDF exporte les champs personnalisés de toutes les fiches en service du site
+func generateCustomFieldsExportPDF(app *App) {
+	var exports []db.CustomFieldExport
+	for _, entity := range db.CustomFieldEntities {
+		export, err := app.store.GetCustomFieldExport(entity)
+		if err != nil {
+			app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération des champs personnalisés: %v", err))
+			return
+		}
+		exports = append(exports, *export)
+	}
+
+	pdfData, err := pdf.GenerateCustomFieldsExport(exports, app.siteLabel())
+	if err != nil {
+		app.showError("Erreur", fmt.Sprintf("Erreur lors de la génération du PDF: %v", err))
+		return
+	}
+
+	filename := pdf.GenerateFilename("champs_personnalises", 0)
+	filepath, err := pdf.SavePDF(filename, pdfData)
+	if err != nil {
+		app.showError("Erreur", fmt.Sprintf("Erreur lors de l'enregistrement: %v", err))
+		return
+	}
+
+	app.showSuccess(fmt.Sprintf("✅ Export enregistré : %s", filepath))
+}
@@ -24,7 +24,7 @@ func createDashboard(app *App) fyne.CanvasObject {
 	header := container.NewBorder(nil, nil, nil, newLoanBtn, title)
 
 	// Récupérer les clés avec disponibilité
-	keys, err := db.GetKeysWithAvailability()
+	keys, err := app.store.GetKeysWithAvailability()
 	if err != nil {
 		log.Printf("Erreur lors de la récupération des clés: %v", err)
 		return container.NewVBox(
@@ -225,8 +225,12 @@ func createKeysTable(keys []db.KeyWithAvailability, app *App) fyne.CanvasObject
 
 // showNewLoanDialog affiche la boîte de dialogue pour créer un nouvel emprunt
 func showNewLoanDialog(app *App) {
+	if !app.requireEdit() {
+		return
+	}
+
 	// Récupérer les clés disponibles
-	availableKeys, err := db.GetAvailableKeys()
+	availableKeys, err := app.store.GetAvailableKeys()
 	if err != nil {
 		app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération des clés: %v", err))
 		return
@@ -238,7 +242,7 @@ func showNewLoanDialog(app *App) {
 	}
 
 	// Récupérer les emprunteurs
-	borrowers, err := db.GetAllBorrowers()
+	borrowers, err := app.store.GetAllBorrowers()
 	if err != nil {
 		app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération des emprunteurs: %v", err))
 		return
@@ -255,15 +259,19 @@ func showNewLoanDialog(app *App) {
 
 // showNewLoanDialogWithKey affiche la boîte de dialogue pour créer un emprunt avec une clé présélectionnée
 func showNewLoanDialogWithKey(app *App, keyID int) {
+	if !app.requireEdit() {
+		return
+	}
+
 	// Récupérer les clés disponibles
-	availableKeys, err := db.GetAvailableKeys()
+	availableKeys, err := app.store.GetAvailableKeys()
 	if err != nil {
 		app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération des clés: %v", err))
 		return
 	}
 
 	// Récupérer les emprunteurs
-	borrowers, err := db.GetAllBorrowers()
+	borrowers, err := app.store.GetAllBorrowers()
 	if err != nil {
 		app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération des emprunteurs: %v", err))
 		return
@@ -316,6 +324,10 @@ func showLoanForm(app *App, availableKeys []db.Key, borrowers []db.Borrower, pre
 		borrowerSelect.SetSelected(borrowerOptions[0])
 	}
 
+	// Date de retour prévue (vide = durée par défaut de chaque clé)
+	dueDateEntry := widget.NewEntry()
+	dueDateEntry.SetPlaceHolder("JJ/MM/AAAA (vide = durée par défaut de chaque clé)")
+
 	// Formulaire
 	form := container.NewVBox(
 		widget.NewLabel("Sélectionnez les clés à emprunter:"),
@@ -323,6 +335,8 @@ func showLoanForm(app *App, availableKeys []db.Key, borrowers []db.Borrower, pre
 		widget.NewSeparator(),
 		widget.NewLabel("Emprunteur:"),
 		borrowerSelect,
+		widget.NewLabel("Date de retour prévue (JJ/MM/AAAA):"),
+		dueDateEntry,
 	)
 
 	// Boutons
@@ -351,12 +365,21 @@ func showLoanForm(app *App, availableKeys []db.Key, borrowers []db.Borrower, pre
 			return
 		}
 
+		dueDate, err := parseDueDate(dueDateEntry.Text)
+		if err != nil {
+			app.showError("Erreur", fmt.Sprintf("Date de retour invalide : %v", err))
+			return
+		}
+
 		borrowerID := borrowerMap[borrowerSelect.Selected]
 
 		// Créer les emprunts
-		err := db.CreateMultipleLoans(selectedKeyIDs, borrowerID)
+		err = app.store.CreateMultipleLoans(selectedKeyIDs, borrowerID, db.LoanOptions{DueDate: dueDate})
 		if err != nil {
-			app.showError("Erreur", fmt.Sprintf("Erreur lors de la création de l'emprunt: %v", err))
+			if showLoanError(app, err) {
+				app.window.Canvas().Overlays().Remove(dialog)
+				app.showDashboard()
+			}
 			return
 		}
 
@@ -383,8 +406,12 @@ func showLoanForm(app *App, availableKeys []db.Key, borrowers []db.Borrower, pre
 
 // showReturnDialog affiche la boîte de dialogue pour retourner une clé
 func showReturnDialog(app *App, keyID int) {
+	if !app.requireEdit() {
+		return
+	}
+
 	// Récupérer les emprunts actifs pour cette clé
-	loans, err := db.GetActiveLoansByKeyID(keyID)
+	loans, err := app.store.GetActiveLoansByKeyID(keyID)
 	if err != nil {
 		app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération des emprunts: %v", err))
 		return
@@ -395,20 +422,9 @@ func showReturnDialog(app *App, keyID int) {
 		return
 	}
 
-	// Si un seul emprunt, retourner directement
+	// Si un seul emprunt, retourner directement (avec le règlement de son éventuelle caution)
 	if len(loans) == 1 {
-		app.showConfirm("Confirmer le retour",
-			fmt.Sprintf("Confirmer le retour de la clé %s empruntée par %s?",
-				loans[0].KeyNumber, loans[0].BorrowerName),
-			func() {
-				err := db.ReturnLoan(loans[0].ID)
-				if err != nil {
-					app.showError("Erreur", fmt.Sprintf("Erreur lors du retour: %v", err))
-					return
-				}
-				app.showSuccess("Clé retournée avec succès!")
-				app.showDashboard()
-			})
+		confirmLoanReturn(app, loans[0], app.showDashboard)
 		return
 	}
 
@@ -418,13 +434,20 @@ func showReturnDialog(app *App, keyID int) {
 
 // showReturnSelectionDialog affiche la sélection d'emprunt à retourner
 func showReturnSelectionDialog(app *App, loans []db.LoanWithDetails) {
+	if !app.requireEdit() {
+		return
+	}
+
 	loanOptions := make([]string, len(loans))
-	loanMap := make(map[string]int)
+	loanMap := make(map[string]db.LoanWithDetails)
 
 	for i, loan := range loans {
 		option := fmt.Sprintf("%s - %s (%s)", loan.KeyNumber, loan.BorrowerName, loan.LoanDate.Format("02/01/2006"))
+		if loan.HasHeldDeposit() {
+			option += " - caution " + db.FormatDepositAmount(loan.DepositAmount)
+		}
 		loanOptions[i] = option
-		loanMap[option] = loan.ID
+		loanMap[option] = loan
 	}
 
 	loanSelect := widget.NewSelect(loanOptions, nil)
@@ -444,16 +467,9 @@ func showReturnSelectionDialog(app *App, loans []db.LoanWithDetails) {
 			return
 		}
 
-		loanID := loanMap[loanSelect.Selected]
-		err := db.ReturnLoan(loanID)
-		if err != nil {
-			app.showError("Erreur", fmt.Sprintf("Erreur lors du retour: %v", err))
-			return
-		}
-
+		// Le retour se poursuit par la signature ou le règlement de la caution
 		app.window.Canvas().Overlays().Remove(dialog)
-		app.showSuccess("Clé retournée avec succès!")
-		app.showDashboard()
+		confirmLoanReturn(app, loanMap[loanSelect.Selected], app.showDashboard)
 	})
 	confirmBtn.Importance = widget.HighImportance
 
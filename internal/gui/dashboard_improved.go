@@ -11,21 +11,61 @@ import (
 	"fyne.io/fyne/v2/widget"
 )
 
+// firstAvailableCopy est l'option du formulaire d'emprunt qui laisse choisir l'exemplaire automatiquement
+const firstAvailableCopy = "Premier exemplaire disponible"
+
+// borrowerOptionLabel retourne le libellé d'un emprunteur dans le formulaire d'emprunt
+func borrowerOptionLabel(b db.Borrower) string {
+	if id := b.Identification(); id != "" {
+		return fmt.Sprintf("%s (%s)", b.Name, id)
+	}
+	return b.Name
+}
+
 // showLoanFormImproved affiche le formulaire d'emprunt amélioré avec recherche
 func showLoanFormImproved(app *App, availableKeys []db.Key, borrowers []db.Borrower, preselectedKeys []int) {
-	// Sélection de l'emprunteur avec recherche
-	borrowerOptions := make([]string, len(borrowers))
+	if !app.requireEdit() {
+		return
+	}
+
+	// Sélection de l'emprunteur avec recherche (les personnes parties ne sont pas proposées)
+	var activeBorrowers []db.Borrower
 	borrowerMap := make(map[string]int)
-	for i, b := range borrowers {
-		borrowerOptions[i] = b.Name
-		borrowerMap[b.Name] = b.ID
+	for _, b := range borrowers {
+		if b.Status == db.BorrowerDeparted {
+			continue
+		}
+		activeBorrowers = append(activeBorrowers, b)
+		borrowerMap[borrowerOptionLabel(b)] = b.ID
 	}
 
-	borrowerSelect := widget.NewSelect(borrowerOptions, nil)
+	borrowerSelect := widget.NewSelect(nil, nil)
 	borrowerSelect.PlaceHolder = "Sélectionner un emprunteur..."
-	if len(borrowerOptions) > 0 {
-		borrowerSelect.SetSelected(borrowerOptions[0])
+
+	// Recherche de l'emprunteur par nom, badge ou service
+	borrowerSearchEntry := widget.NewEntry()
+	borrowerSearchEntry.SetPlaceHolder("🔍 Rechercher un emprunteur (nom, badge ou service)...")
+
+	updateBorrowerOptions := func(query string) {
+		query = strings.ToLower(query)
+		var options []string
+		for _, b := range activeBorrowers {
+			if query == "" ||
+				strings.Contains(strings.ToLower(b.Name), query) ||
+				strings.Contains(strings.ToLower(b.BadgeNumber), query) ||
+				strings.Contains(strings.ToLower(b.Department), query) {
+				options = append(options, borrowerOptionLabel(b))
+			}
+		}
+		borrowerSelect.Options = options
+		if len(options) > 0 {
+			borrowerSelect.SetSelected(options[0])
+		} else {
+			borrowerSelect.ClearSelected()
+		}
+		borrowerSelect.Refresh()
 	}
+	borrowerSearchEntry.OnChanged = updateBorrowerOptions
 
 	// Champ de recherche pour les clés
 	searchEntry := widget.NewEntry()
@@ -33,8 +73,37 @@ func showLoanFormImproved(app *App, availableKeys []db.Key, borrowers []db.Borro
 
 	// Sélection des clés (multi-sélection) avec checkboxes
 	keyCheckboxes := make(map[int]*widget.Check)
+	// Choix de l'exemplaire remis pour chaque clé (par défaut le premier disponible)
+	copySelects := make(map[int]*widget.Select)
+	copyMaps := make(map[int]map[string]int)
 	allKeys := availableKeys // Garder une copie de toutes les clés
-	
+
+	// Clés que l'emprunteur sélectionné est autorisé à prendre ; un administrateur
+	// peut afficher toutes les clés par dérogation
+	var authorizedKeys map[int]bool
+	overrideCheck := widget.NewCheck("Dérogation administrateur : proposer toutes les clés", nil)
+	if !app.isAdmin() {
+		overrideCheck.Hide()
+	}
+	keyAllowed := func(keyID int) bool {
+		return overrideCheck.Checked || authorizedKeys == nil || authorizedKeys[keyID]
+	}
+
+	// Compteur de clés sélectionnées
+	selectedCountLabel := widget.NewLabel("0 clé(s) sélectionnée(s)")
+	selectedCountLabel.TextStyle.Bold = true
+
+	// Mettre à jour le compteur
+	updateSelectedCount := func() {
+		count := 0
+		for keyID, checkbox := range keyCheckboxes {
+			if checkbox.Checked && keyAllowed(keyID) {
+				count++
+			}
+		}
+		selectedCountLabel.SetText(fmt.Sprintf("%d clé(s) sélectionnée(s)", count))
+	}
+
 	keySelectionBox := container.NewVBox()
 
 	// Fonction pour mettre à jour l'affichage des clés
@@ -43,6 +112,10 @@ func showLoanFormImproved(app *App, availableKeys []db.Key, borrowers []db.Borro
 		
 		for _, key := range allKeys {
 			k := key // Capture de la variable
+
+			if !keyAllowed(k.ID) {
+				continue
+			}
 			
 			// Filtrer par recherche
 			if query == "" ||
@@ -64,59 +137,89 @@ func showLoanFormImproved(app *App, availableKeys []db.Key, borrowers []db.Borro
 						}
 					}
 					
+					checkbox.OnChanged = func(bool) {
+						updateSelectedCount()
+					}
 					keyCheckboxes[k.ID] = checkbox
+
+					copies, _ := app.store.GetAvailableCopies(k.ID)
+					copyOptions := []string{firstAvailableCopy}
+					copyMap := make(map[string]int)
+					for _, c := range copies {
+						copyOptions = append(copyOptions, c.Identifier)
+						copyMap[c.Identifier] = c.ID
+					}
+					copySelect := widget.NewSelect(copyOptions, nil)
+					copySelect.SetSelected(firstAvailableCopy)
+					copySelects[k.ID] = copySelect
+					copyMaps[k.ID] = copyMap
 				}
 				
-				keySelectionBox.Add(checkbox)
+				keySelectionBox.Add(container.NewBorder(nil, nil, nil, copySelects[k.ID], checkbox))
 			}
 		}
 		keySelectionBox.Refresh()
 	}
 
-	// Initialiser l'affichage
-	updateKeyDisplay("")
-
 	// Mettre à jour lors de la recherche
 	searchEntry.OnChanged = func(query string) {
 		updateKeyDisplay(query)
 	}
 
+	// Recharger les clés autorisées à chaque changement d'emprunteur
+	borrowerSelect.OnChanged = func(selected string) {
+		authorizedKeys = nil
+		if borrowerID, ok := borrowerMap[selected]; ok {
+			keyIDs, err := app.store.GetAuthorizedKeyIDs(borrowerID)
+			if err != nil {
+				log.Printf("Erreur lors de la lecture des autorisations: %v", err)
+			} else {
+				authorizedKeys = keyIDs
+			}
+		} else {
+			authorizedKeys = map[int]bool{}
+		}
+		updateKeyDisplay(searchEntry.Text)
+		updateSelectedCount()
+	}
+	overrideCheck.OnChanged = func(bool) {
+		updateKeyDisplay(searchEntry.Text)
+		updateSelectedCount()
+	}
+
+	// Initialiser l'affichage
+	updateBorrowerOptions("")
+	updateSelectedCount()
+
 	// Scroll pour les clés
 	keyScroll := container.NewVScroll(keySelectionBox)
 	keyScroll.SetMinSize(fyne.NewSize(550, 300))
 
-	// Compteur de clés sélectionnées
-	selectedCountLabel := widget.NewLabel("0 clé(s) sélectionnée(s)")
-	selectedCountLabel.TextStyle.Bold = true
+	// Date de retour prévue (vide = durée par défaut de chaque clé)
+	dueDateEntry := widget.NewEntry()
+	dueDateEntry.SetPlaceHolder("JJ/MM/AAAA (vide = durée par défaut de chaque clé)")
 
-	// Mettre à jour le compteur
-	updateSelectedCount := func() {
-		count := 0
-		for _, checkbox := range keyCheckboxes {
-			if checkbox.Checked {
-				count++
-			}
-		}
-		selectedCountLabel.SetText(fmt.Sprintf("%d clé(s) sélectionnée(s)", count))
-	}
-
-	// Ajouter l'événement OnChanged à toutes les checkboxes
-	for _, checkbox := range keyCheckboxes {
-		cb := checkbox
-		cb.OnChanged = func(bool) {
-			updateSelectedCount()
-		}
-	}
+	// Caution demandée pour chaque clé (vide = sans caution)
+	depositEntry := widget.NewEntry()
+	depositEntry.SetPlaceHolder("Montant par clé en € (vide = sans caution)")
+	depositMethodSelect, depositMethod := newDepositMethodSelect()
 
 	// Formulaire
 	form := container.NewVBox(
 		widget.NewLabelWithStyle("Emprunteur:", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		borrowerSearchEntry,
 		borrowerSelect,
 		widget.NewSeparator(),
 		widget.NewLabelWithStyle("Clés à emprunter:", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
 		searchEntry,
+		overrideCheck,
 		keyScroll,
 		container.NewHBox(selectedCountLabel),
+		widget.NewSeparator(),
+		widget.NewLabelWithStyle("Date de retour prévue:", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		dueDateEntry,
+		widget.NewLabelWithStyle("Caution:", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		container.NewBorder(nil, nil, nil, depositMethodSelect, depositEntry),
 	)
 
 	// Boutons
@@ -130,7 +233,7 @@ func showLoanFormImproved(app *App, availableKeys []db.Key, borrowers []db.Borro
 		// Récupérer les clés sélectionnées
 		var selectedKeyIDs []int
 		for keyID, checkbox := range keyCheckboxes {
-			if checkbox.Checked {
+			if checkbox.Checked && keyAllowed(keyID) {
 				selectedKeyIDs = append(selectedKeyIDs, keyID)
 			}
 		}
@@ -145,18 +248,53 @@ func showLoanFormImproved(app *App, availableKeys []db.Key, borrowers []db.Borro
 			return
 		}
 
-		borrowerID := borrowerMap[borrowerSelect.Selected]
+		dueDate, err := parseDueDate(dueDateEntry.Text)
+		if err != nil {
+			app.showError("Erreur", fmt.Sprintf("Date de retour invalide : %v", err))
+			return
+		}
 
-		// Créer les emprunts
-		err := db.CreateMultipleLoans(selectedKeyIDs, borrowerID)
+		deposit, err := parseDepositAmount(depositEntry.Text)
 		if err != nil {
-			app.showError("Erreur", fmt.Sprintf("Erreur lors de la création de l'emprunt: %v", err))
+			app.showError("Erreur", fmt.Sprintf("Caution invalide : %v", err))
 			return
 		}
 
-		app.window.Canvas().Overlays().Remove(dialog)
-		app.showSuccess("Emprunt créé avec succès!")
-		app.showDashboard() // Rafraîchir
+		borrowerID := borrowerMap[borrowerSelect.Selected]
+
+		// Exemplaires choisis explicitement
+		copyIDs := make(map[int]int)
+		for _, keyID := range selectedKeyIDs {
+			if copyID, ok := copyMaps[keyID][copySelects[keyID].Selected]; ok {
+				copyIDs[keyID] = copyID
+			}
+		}
+
+		opts := db.LoanOptions{
+			DueDate:               dueDate,
+			CopyIDs:               copyIDs,
+			OverrideAuthorization: overrideCheck.Checked,
+			DepositAmount:         deposit,
+			DepositMethod:         depositMethod(),
+		}
+
+		// Faire signer l'emprunteur, puis créer les emprunts
+		message := fmt.Sprintf("%s reçoit %d clé(s). La signature est enregistrée avec les emprunts et figure sur les reçus.",
+			borrowerSelect.Selected, len(selectedKeyIDs))
+		showSignatureDialog(app, "Signature de l'Emprunteur", message, "Créer l'emprunt", func(signature []byte) {
+			opts.Signature = signature
+			if err := app.store.CreateMultipleLoans(selectedKeyIDs, borrowerID, opts); err != nil {
+				if showLoanError(app, err) {
+					app.window.Canvas().Overlays().Remove(dialog)
+					app.showDashboard()
+				}
+				return
+			}
+
+			app.window.Canvas().Overlays().Remove(dialog)
+			app.showSuccess("Emprunt créé avec succès!")
+			app.showDashboard() // Rafraîchir
+		})
 	})
 	confirmBtn.Importance = widget.HighImportance
 
@@ -171,7 +309,7 @@ func showLoanFormImproved(app *App, availableKeys []db.Key, borrowers []db.Borro
 	)
 
 	dialog = widget.NewModalPopUp(content, app.window.Canvas())
-	dialog.Resize(fyne.NewSize(650, 600))
+	dialog.Resize(fyne.NewSize(650, 650))
 	dialog.Show()
 }
 
@@ -4,6 +4,7 @@ import (
 	"clefs/internal/db"
 	"fmt"
 	"log"
+	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
@@ -29,13 +30,13 @@ func createModernDashboard(app *App) fyne.CanvasObject {
 	header := container.NewBorder(nil, nil, titleLabel, headerButtons)
 
 	// Récupérer les statistiques
-	stats := getStatistics()
+	stats := getStatistics(app)
 
 	// Créer les cards de statistiques simplifiées
 	statsCards := createStatisticsCards(stats)
 
 	// Récupérer les clés avec disponibilité
-	keys, err := db.GetKeysWithAvailability()
+	keys, err := app.store.GetKeysWithAvailability()
 	if err != nil {
 		log.Printf("Erreur lors de la récupération des clés: %v", err)
 		return container.NewVBox(
@@ -55,6 +56,15 @@ func createModernDashboard(app *App) fyne.CanvasObject {
 		widget.NewSeparator(),
 	)
 
+	// Section des emprunts en retard (affichée uniquement s'il y en a)
+	overdueLoans, err := app.store.GetOverdueLoans()
+	if err != nil {
+		log.Printf("Erreur lors de la récupération des emprunts en retard: %v", err)
+	} else if len(overdueLoans) > 0 {
+		topContent.Add(container.NewPadded(createOverdueSection(app, overdueLoans)))
+		topContent.Add(widget.NewSeparator())
+	}
+
 	// Utiliser un Border layout pour que le tableau prenne tout l'espace restant
 	content := container.NewBorder(
 		topContent,                     // Haut
@@ -68,23 +78,27 @@ func createModernDashboard(app *App) fyne.CanvasObject {
 }
 
 // getStatistics récupère les statistiques pour le dashboard
-func getStatistics() map[string]interface{} {
+func getStatistics(app *App) map[string]interface{} {
 	stats := make(map[string]interface{})
 
 	// Récupérer le nombre total de clés
-	keys, _ := db.GetAllKeys()
+	keys, _ := app.store.GetAllKeys()
 	stats["totalKeys"] = len(keys)
 
 	// Récupérer les emprunts actifs
-	activeLoans, _ := db.GetAllActiveLoans()
+	activeLoans, _ := app.store.GetAllActiveLoans()
 	stats["activeLoans"] = len(activeLoans)
 
+	// Récupérer les emprunts en retard
+	overdueLoans, _ := app.store.GetOverdueLoans()
+	stats["overdueLoans"] = len(overdueLoans)
+
 	// Récupérer les clés disponibles
-	availableKeys, _ := db.GetAvailableKeys()
+	availableKeys, _ := app.store.GetAvailableKeys()
 	stats["availableKeys"] = len(availableKeys)
 
 	// Récupérer le nombre d'emprunteurs
-	borrowers, _ := db.GetAllBorrowers()
+	borrowers, _ := app.store.GetAllBorrowers()
 	stats["totalBorrowers"] = len(borrowers)
 
 	return stats
@@ -95,6 +109,10 @@ func createStatisticsCards(stats map[string]interface{}) fyne.CanvasObject {
 	// Créer des labels simples pour les statistiques
 	totalKeysLabel := widget.NewLabel(fmt.Sprintf("🔑 Total des Clés: %d", stats["totalKeys"]))
 	activeLoansLabel := widget.NewLabel(fmt.Sprintf("📤 Emprunts Actifs: %d", stats["activeLoans"]))
+	overdueLoansLabel := widget.NewLabel(fmt.Sprintf("⏰ En Retard: %d", stats["overdueLoans"]))
+	if stats["overdueLoans"].(int) > 0 {
+		overdueLoansLabel.Importance = widget.DangerImportance
+	}
 	availableKeysLabel := widget.NewLabel(fmt.Sprintf("✅ Clés Disponibles: %d", stats["availableKeys"]))
 	borrowersLabel := widget.NewLabel(fmt.Sprintf("👥 Emprunteurs: %d", stats["totalBorrowers"]))
 
@@ -104,6 +122,8 @@ func createStatisticsCards(stats map[string]interface{}) fyne.CanvasObject {
 		widget.NewSeparator(),
 		activeLoansLabel,
 		widget.NewSeparator(),
+		overdueLoansLabel,
+		widget.NewSeparator(),
 		availableKeysLabel,
 		widget.NewSeparator(),
 		borrowersLabel,
@@ -112,6 +132,43 @@ func createStatisticsCards(stats map[string]interface{}) fyne.CanvasObject {
 	return container.NewCenter(statsContainer)
 }
 
+// createOverdueSection crée la liste des emprunts en retard affichée en haut du tableau de bord
+func createOverdueSection(app *App, loans []db.LoanWithDetails) fyne.CanvasObject {
+	title := widget.NewLabelWithStyle(
+		fmt.Sprintf("⚠️ Emprunts en retard (%d)", len(loans)),
+		fyne.TextAlignLeading,
+		fyne.TextStyle{Bold: true},
+	)
+	title.Importance = widget.DangerImportance
+
+	list := container.NewVBox()
+	now := time.Now()
+	for _, loan := range loans {
+		l := loan // Capture
+
+		info := widget.NewLabel(fmt.Sprintf("🔑 %s - 👤 %s - retour prévu le %s (%d jour(s) de retard)",
+			l.KeyNumber,
+			l.BorrowerName,
+			l.DueDate.Format("02/01/2006"),
+			l.DaysOverdue(now),
+		))
+
+		returnBtn := widget.NewButton("Retourner", func() {
+			confirmLoanReturn(app, l, app.showDashboard)
+		})
+
+		lossBtn := newDeclareLossButton(app, l, app.showDashboard)
+
+		list.Add(container.NewBorder(nil, nil, nil, container.NewHBox(lossBtn, returnBtn), info))
+	}
+
+	// Limiter la hauteur pour laisser la place au tableau des clés
+	scroll := container.NewVScroll(list)
+	scroll.SetMinSize(fyne.NewSize(0, 120))
+
+	return container.NewVBox(title, scroll)
+}
+
 // createStatsCard crée une card de statistique stylisée
 func createStatsCard(title string, value string, colorName fyne.ThemeColorName) fyne.CanvasObject {
 	valueLabel := widget.NewLabelWithStyle(value, fyne.TextAlignCenter, fyne.TextStyle{Bold: true})
@@ -181,6 +238,9 @@ func createSimpleKeysTable(keys []db.KeyWithAvailability, app *App) fyne.CanvasO
 					// Disponibilité simple avec texte coloré
 					usable := key.QuantityTotal - key.QuantityReserve
 					availText := fmt.Sprintf("%d / %d", key.AvailableCount, usable)
+					if key.ReservedCount > 0 {
+						availText += fmt.Sprintf(" (%d réservée(s))", key.ReservedCount)
+					}
 
 					availLabel := widget.NewLabel(availText)
 					if key.AvailableCount > 0 {
@@ -263,14 +323,14 @@ func createSimpleKeysTable(keys []db.KeyWithAvailability, app *App) fyne.CanvasO
 // showKeyDetails affiche les détails d'une clé
 func showKeyDetails(app *App, keyID int) {
 	// Récupérer les détails de la clé
-	key, err := db.GetKeyByID(keyID)
+	key, err := app.store.GetKeyByID(keyID)
 	if err != nil {
 		app.showError("Erreur", "Impossible de charger les détails de la clé")
 		return
 	}
 
 	// Récupérer les emprunts actifs
-	loans, _ := db.GetActiveLoansByKeyID(keyID)
+	loans, _ := app.store.GetActiveLoansByKeyID(keyID)
 
 	// Créer le contenu des détails
 	detailsContent := container.NewVBox(
@@ -290,6 +350,20 @@ func showKeyDetails(app *App, keyID int) {
 		widget.NewLabel(key.StorageLocation),
 	)
 
+	// Champs personnalisés renseignés
+	customValues, _ := app.store.GetRecordCustomFieldValues(db.AuditEntityKey, keyID)
+	for _, v := range customValues {
+		if v.Value == "" {
+			continue
+		}
+		detailsContent.Add(widget.NewSeparator())
+		detailsContent.Add(widget.NewLabelWithStyle(v.Field.Label+":", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+		detailsContent.Add(widget.NewLabel(v.Value))
+	}
+
+	// Photos du profil de la clé et autres pièces jointes
+	addAttachmentThumbnails(app, detailsContent, db.AuditEntityKey, keyID)
+
 	// Ajouter les emprunts actifs s'il y en a
 	if len(loans) > 0 {
 		detailsContent.Add(widget.NewSeparator())
@@ -310,12 +384,17 @@ func showKeyDetails(app *App, keyID int) {
 		app.window.Canvas().Overlays().Remove(dialog)
 	})
 
+	attachmentsBtn := newAttachmentsButton(app, db.AuditEntityKey, keyID, "Clé "+key.Number, func() {
+		app.window.Canvas().Overlays().Remove(dialog)
+		showKeyDetails(app, keyID)
+	})
+
 	content := container.NewVBox(
 		widget.NewLabelWithStyle("📋 Détails de la Clé", fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
 		widget.NewSeparator(),
 		container.NewScroll(detailsContent),
 		widget.NewSeparator(),
-		container.NewCenter(closeBtn),
+		container.NewCenter(container.NewHBox(attachmentsBtn, closeBtn)),
 	)
 
 	dialog = widget.NewModalPopUp(content, app.window.Canvas())
@@ -0,0 +1,237 @@
+package gui
+
+import (
+	"clefs/internal/db"
+	"clefs/internal/pdf"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// parseDepositAmount lit un montant de caution (« 50 », « 50,00 » ou « 50.00 € » ; vide = sans caution)
+func parseDepositAmount(text string) (float64, error) {
+	text = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(text), "€"))
+	if text == "" {
+		return 0, nil
+	}
+	amount, err := strconv.ParseFloat(strings.Replace(text, ",", ".", 1), 64)
+	if err != nil || amount < 0 {
+		return 0, fmt.Errorf("le montant %q n'est pas valide", text)
+	}
+	return amount, nil
+}
+
+// newDepositMethodSelect crée la liste des moyens de paiement d'une caution
+func newDepositMethodSelect() (*widget.Select, func() db.DepositMethod) {
+	options := make([]string, len(db.DepositMethods))
+	methodMap := make(map[string]db.DepositMethod)
+	for i, m := range db.DepositMethods {
+		options[i] = m.Label()
+		methodMap[options[i]] = m
+	}
+	methodSelect := widget.NewSelect(options, nil)
+	methodSelect.SetSelected(options[0])
+	return methodSelect, func() db.DepositMethod {
+		return methodMap[methodSelect.Selected]
+	}
+}
+
+// describeDeposit résume la caution d'un emprunt (ex: « caution 50,00 € (chèque) - Détenue »)
+func describeDeposit(l db.LoanWithDetails) string {
+	text := fmt.Sprintf("caution %s (%s) - %s", db.FormatDepositAmount(l.DepositAmount),
+		strings.ToLower(l.DepositMethod.Label()), l.DepositStatus.Label())
+	if l.DepositNotes != "" {
+		text += " : " + l.DepositNotes
+	}
+	return text
+}
+
+// confirmLoanReturn demande confirmation du retour d'une clé, que l'emprunteur peut signer ;
+// si une caution est détenue, le retour passe par le règlement de la caution.
+// refresh réaffiche l'écran appelant.
+func confirmLoanReturn(app *App, loan db.LoanWithDetails, refresh func()) {
+	if !app.requireEdit() {
+		return
+	}
+	if loan.HasHeldDeposit() {
+		showDepositSettlementDialog(app, loan, true, refresh)
+		return
+	}
+	showSignatureDialog(app, "Confirmer le retour",
+		fmt.Sprintf("Confirmer le retour de la clé %s empruntée par %s?", loan.KeyNumber, loan.BorrowerName),
+		"Confirmer le retour",
+		func(signature []byte) {
+			if err := app.store.ReturnLoan(loan.ID, signature); err != nil {
+				showWriteError(app, "Erreur lors du retour", err)
+				return
+			}
+			app.showSuccess("Clé retournée avec succès!")
+			refresh()
+		})
+}
+
+// showDepositSettlementDialog restitue ou retient la caution d'un emprunt ;
+// returning enregistre en même temps le retour de la clé
+func showDepositSettlementDialog(app *App, loan db.LoanWithDetails, returning bool, refresh func()) {
+	if !app.requireEdit() {
+		return
+	}
+
+	statusMap := map[string]db.DepositStatus{
+		"Restituer la caution": db.DepositRefunded,
+		"Retenir la caution":   db.DepositRetained,
+	}
+	statusRadio := widget.NewRadioGroup([]string{"Restituer la caution", "Retenir la caution"}, nil)
+	statusRadio.SetSelected("Restituer la caution")
+
+	notesEntry := widget.NewEntry()
+	notesEntry.SetPlaceHolder("Motif de la retenue (clé abîmée, non rendue...)")
+
+	title := "Caution de l'Emprunt"
+	text := fmt.Sprintf("Clé %s empruntée par %s : caution de %s versée en %s le %s.",
+		loan.KeyNumber, loan.BorrowerName, db.FormatDepositAmount(loan.DepositAmount),
+		strings.ToLower(loan.DepositMethod.Label()), loan.LoanDate.Format("02/01/2006"))
+	if returning {
+		title = "Retour de la Clé"
+	}
+	info := widget.NewLabel(text)
+	info.Wrapping = fyne.TextWrapWord
+
+	// Au retour, l'emprunteur signe en même temps que le règlement de sa caution
+	pad := newSignaturePad()
+
+	var popupDialog *widget.PopUp
+
+	cancelBtn := widget.NewButton("Annuler", func() {
+		app.window.Canvas().Overlays().Remove(popupDialog)
+	})
+
+	confirmBtn := widget.NewButton("Confirmer", func() {
+		settlement := statusMap[statusRadio.Selected]
+		if settlement == db.DepositRetained && strings.TrimSpace(notesEntry.Text) == "" {
+			app.showError("Erreur", "Veuillez indiquer le motif de la retenue.")
+			return
+		}
+
+		var err error
+		if returning {
+			var signature []byte
+			signature, err = pad.encode()
+			if err != nil {
+				app.showError("Erreur", fmt.Sprintf("Impossible d'enregistrer la signature: %v", err))
+				return
+			}
+			err = app.store.ReturnLoanWithDeposit(loan.ID, settlement, notesEntry.Text, signature)
+		} else {
+			err = app.store.SettleDeposit(loan.ID, settlement, notesEntry.Text)
+		}
+		if err != nil {
+			showWriteError(app, "Erreur lors du règlement de la caution", err)
+			return
+		}
+
+		app.window.Canvas().Overlays().Remove(popupDialog)
+		message := fmt.Sprintf("Caution de %s %s.", db.FormatDepositAmount(loan.DepositAmount), strings.ToLower(settlement.Label()))
+		if returning {
+			message = "Clé retournée avec succès! " + message
+		}
+		app.showSuccess(message)
+		refresh()
+	})
+	confirmBtn.Importance = widget.HighImportance
+
+	content := container.NewVBox(
+		widget.NewLabelWithStyle(title, fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
+		widget.NewSeparator(),
+		info,
+		statusRadio,
+		notesEntry,
+	)
+	if returning {
+		content.Add(newSignatureBox(pad))
+	}
+	content.Add(widget.NewSeparator())
+	content.Add(container.NewHBox(cancelBtn, confirmBtn))
+
+	popupDialog = widget.NewModalPopUp(content, app.window.Canvas())
+	popupDialog.Resize(fyne.NewSize(500, 0))
+	popupDialog.Show()
+}
+
+// createDepositsView crée la vue des cautions détenues, totalisées par emprunteur
+func createDepositsView(app *App) fyne.CanvasObject {
+	title := widget.NewLabelWithStyle("Cautions Détenues", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+
+	deposits, err := app.store.GetHeldDepositsByBorrower()
+	if err != nil {
+		return widget.NewLabel(fmt.Sprintf("Erreur: %v", err))
+	}
+
+	var total float64
+	listBox := container.NewVBox()
+	if len(deposits) == 0 {
+		listBox.Add(widget.NewLabel("Aucune caution détenue."))
+	}
+	for _, d := range deposits {
+		total += d.Total
+		listBox.Add(widget.NewLabelWithStyle(
+			fmt.Sprintf("👤 %s - %s", d.BorrowerName, db.FormatDepositAmount(d.Total)),
+			fyne.TextAlignLeading,
+			fyne.TextStyle{Bold: true},
+		))
+
+		for _, l := range d.Loans {
+			text := fmt.Sprintf("   🔑 %s - %s - %s versée en %s le %s", l.KeyNumber, l.KeyDescription,
+				db.FormatDepositAmount(l.DepositAmount), strings.ToLower(l.DepositMethod.Label()), l.LoanDate.Format("02/01/2006"))
+
+			// Une caution d'un emprunt clos (clé déclarée perdue) se règle ici
+			var action fyne.CanvasObject = widget.NewLabel("emprunt en cours")
+			if l.ReturnDate != nil {
+				if l.LossStatus != "" {
+					text += " - clé " + strings.ToLower(l.LossStatus.Label())
+				}
+				loan := l
+				settleBtn := widget.NewButton("💶 Régler", func() {
+					showDepositSettlementDialog(app, loan, false, app.showDeposits)
+				})
+				if !app.canEdit() {
+					settleBtn.Disable()
+				}
+				action = settleBtn
+			}
+			listBox.Add(container.NewBorder(nil, nil, nil, action, widget.NewLabel(text)))
+		}
+		listBox.Add(widget.NewSeparator())
+	}
+
+	totalLabel := widget.NewLabelWithStyle(fmt.Sprintf("Total détenu : %s", db.FormatDepositAmount(total)),
+		fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+
+	exportPDFBtn := widget.NewButton("📄 Exporter PDF", func() {
+		pdfData, err := pdf.GenerateDepositsReport(deposits, app.siteLabel())
+		if err != nil {
+			app.showError("Erreur", fmt.Sprintf("Erreur lors de la génération du PDF: %v", err))
+			return
+		}
+		filepath, err := pdf.SavePDF(pdf.GenerateFilename("cautions", 0), pdfData)
+		if err != nil {
+			app.showError("Erreur", fmt.Sprintf("Erreur lors de l'enregistrement: %v", err))
+			return
+		}
+		app.showSuccess(fmt.Sprintf("✅ Rapport enregistré : %s", filepath))
+	})
+
+	header := container.NewBorder(nil, nil, title, exportPDFBtn)
+
+	return container.NewBorder(
+		container.NewVBox(header, totalLabel, widget.NewSeparator()),
+		nil,
+		nil,
+		nil,
+		container.NewVScroll(listBox),
+	)
+}
@@ -49,7 +49,7 @@ func createHelpView() fyne.CanvasObject {
 		"🚀 Démarrage Rapide",
 		"Pour configurer votre inventaire :\n\n"+
 			"1. Créez vos bâtiments (Configuration > Bâtiments)\n"+
-			"2. Ajoutez des salles/points d'accès (Configuration > Salles)\n"+
+			"2. Ajoutez des salles/points d'accès (Configuration > Salles), avec si besoin leur étage et leur zone\n"+
 			"3. Enregistrez vos clés (Configuration > Clés)\n"+
 			"4. Ajoutez des emprunteurs (Configuration > Emprunteurs)\n"+
 			"5. Commencez à gérer les emprunts depuis le Tableau de Bord",
@@ -85,6 +85,12 @@ func createHelpView() fyne.CanvasObject {
 			"  1. Cliquez sur 'Retourner' sur la ligne de la clé\n"+
 			"  2. Si plusieurs personnes ont cette clé, choisissez qui la rend\n"+
 			"  3. Confirmez le retour\n\n"+
+			"Signature à l'écran :\n"+
+			"  • L'emprunteur signe à la souris ou au doigt en confirmant l'emprunt ou le retour ('🧽 Effacer' pour recommencer)\n"+
+			"  • La signature figure sur les reçus PDF et HTML ; sans signature, les reçus gardent une ligne à signer à la main\n\n"+
+			"Pièces jointes :\n"+
+			"  • '📎 Pièces jointes' sur une clé, une salle, un emprunteur ou un emprunt (y compris dans l'historique) joint une photo, un reçu signé scanné...\n"+
+			"  • Les fichiers sont enregistrés dans la base de données : les sauvegardes les contiennent\n\n"+
 			"💡 Astuce : Vous pouvez sélectionner plusieurs clés d'un coup lors d'un nouvel emprunt !",
 	)
 	accordions.Add(section5)
@@ -103,6 +109,9 @@ func createHelpView() fyne.CanvasObject {
 			"     • Lieu de stockage\n"+
 			"  3. Associez les salles que cette clé ouvre\n"+
 			"  4. Enregistrez\n\n"+
+			"Registre de stock :\n"+
+			"  • Les quantités se modifient ensuite par '📈 Stock' : achat, reproduction, destruction, perte, mise en réserve ou sortie de réserve, avec un motif\n"+
+			"  • '📒 Mouvements de Stock' exporte en PDF les mouvements d'une période\n\n"+
 			"📐 Formule : Disponible = Total - Réserve - Emprunts en cours",
 	)
 	accordions.Add(section6)
@@ -132,7 +141,8 @@ func createHelpView() fyne.CanvasObject {
 			"  • Vue par clé\n"+
 			"  • Liste de qui a quoi\n\n"+
 			"Plan de Clés :\n"+
-			"  • Vue hiérarchique : Bâtiments > Salles > Clés\n"+
+			"  • Vue hiérarchique : Bâtiments > Étages > Salles > Clés\n"+
+			"  • Onglet Zones : clés qui ouvrent chaque zone\n"+
 			"  • Export PDF du plan complet\n\n"+
 			"📂 Tous les documents sont générés automatiquement dans le dossier 'documents/'.",
 	)
@@ -143,9 +153,14 @@ func createHelpView() fyne.CanvasObject {
 		"⚙️ Configuration",
 		"Le menu Configuration vous permet de gérer :\n\n"+
 			"🏢 Bâtiments : Créez et organisez vos bâtiments\n"+
-			"🚪 Salles : Ajoutez des salles/points d'accès par bâtiment\n"+
+			"🚪 Salles : Ajoutez des salles/points d'accès par bâtiment ; '🏬 Étages' sur un bâtiment découpe ses salles par étage\n"+
+			"🧭 Zones : Regroupez des salles en ailes ou zones de sécurité et listez toutes les clés qui y donnent accès\n"+
 			"🔑 Clés : Gérez votre inventaire de clés\n"+
 			"👤 Emprunteurs : Enregistrez les personnes autorisées\n"+
+			"🗄️ Emplacements et Armoires : Définissez où les clés sont rangées ; une armoire a des crochets numérotés et son plan montre les crochets vides parce que la clé est sortie\n"+
+			"🏷️ Champs Personnalisés : Ajoutez vos propres champs (texte, nombre, date ou liste de choix) aux clés, salles et emprunteurs ; ils sont recherchables, exportables et jusqu'à 3 par catégorie apparaissent en colonnes des rapports\n"+
+			"📦 Archives : Remettez en service les éléments archivés (suppression définitive réservée aux administrateurs)\n"+
+			"🏫 Sites : Choisissez le site affiché dans le sélecteur du menu ; les administrateurs créent et renomment les sites\n"+
 			"💾 Sauvegardes : Gérez vos sauvegardes\n"+
 			"📥 Import V1 : Migrez vos données depuis l'ancienne version\n"+
 			"🎭 Mode Démo : Chargez des données de test\n"+
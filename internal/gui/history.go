@@ -0,0 +1,359 @@
+package gui
+
+import (
+	"clefs/internal/db"
+	"clefs/internal/pdf"
+	"fmt"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// historyPageSize est le nombre d'emprunts affichés par page dans l'historique
+const historyPageSize = 50
+
+// createLoanHistoryView crée la vue de l'historique des emprunts (rendus et en cours)
+func createLoanHistoryView(app *App) fyne.CanvasObject {
+	title := widget.NewLabelWithStyle("Historique des Emprunts", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+
+	// Listes de choix pour les filtres (la première option désactive le filtre)
+	keys, _ := app.store.GetAllKeys()
+	keyOptions := []string{"Toutes les clés"}
+	keyMap := make(map[string]int)
+	for _, k := range keys {
+		label := fmt.Sprintf("%s - %s", k.Number, k.Description)
+		keyOptions = append(keyOptions, label)
+		keyMap[label] = k.ID
+	}
+
+	borrowers, _ := app.store.GetAllBorrowers()
+	borrowerOptions := []string{"Tous les emprunteurs"}
+	borrowerMap := make(map[string]int)
+	for _, b := range borrowers {
+		borrowerOptions = append(borrowerOptions, b.Name)
+		borrowerMap[b.Name] = b.ID
+	}
+
+	buildings, _ := app.store.GetAllBuildings()
+	buildingOptions := []string{"Tous les bâtiments"}
+	buildingMap := make(map[string]int)
+	for _, b := range buildings {
+		buildingOptions = append(buildingOptions, b.Name)
+		buildingMap[b.Name] = b.ID
+	}
+
+	keySelect := widget.NewSelect(keyOptions, nil)
+	keySelect.SetSelected(keyOptions[0])
+	borrowerSelect := widget.NewSelect(borrowerOptions, nil)
+	borrowerSelect.SetSelected(borrowerOptions[0])
+	buildingSelect := widget.NewSelect(buildingOptions, nil)
+	buildingSelect.SetSelected(buildingOptions[0])
+
+	fromEntry := widget.NewEntry()
+	fromEntry.SetPlaceHolder("Du (JJ/MM/AAAA)")
+	toEntry := widget.NewEntry()
+	toEntry.SetPlaceHolder("Au (JJ/MM/AAAA)")
+
+	// État de la vue
+	var filter db.LoanHistoryFilter
+	page := 0
+	total := 0
+
+	resultsBox := container.NewVBox()
+	lastLoanLabel := widget.NewLabel("")
+	lastLoanLabel.Wrapping = fyne.TextWrapWord
+	pageLabel := widget.NewLabel("")
+	prevBtn := widget.NewButton("◀ Précédent", nil)
+	nextBtn := widget.NewButton("Suivant ▶", nil)
+
+	// loadPage recharge la page courante avec le filtre courant
+	var loadPage func()
+	loadPage = func() {
+		resultsBox.Objects = nil
+
+		var err error
+		total, err = app.store.CountLoanHistory(filter)
+		if err != nil {
+			resultsBox.Add(widget.NewLabel(fmt.Sprintf("Erreur: %v", err)))
+			resultsBox.Refresh()
+			return
+		}
+
+		pageFilter := filter
+		pageFilter.Limit = historyPageSize
+		pageFilter.Offset = page * historyPageSize
+		loans, err := app.store.GetLoanHistory(pageFilter)
+		if err != nil {
+			resultsBox.Add(widget.NewLabel(fmt.Sprintf("Erreur: %v", err)))
+			resultsBox.Refresh()
+			return
+		}
+
+		if len(loans) == 0 {
+			resultsBox.Add(widget.NewCard("", "Aucun emprunt",
+				widget.NewLabel("Aucun emprunt ne correspond à ces critères.")))
+		}
+		for _, loan := range loans {
+			resultsBox.Add(createHistoryRow(app, loan, loadPage))
+			resultsBox.Add(widget.NewSeparator())
+		}
+		resultsBox.Refresh()
+
+		// Dernier détenteur de la clé filtrée
+		lastLoanLabel.SetText("")
+		if filter.KeyID > 0 {
+			last, err := app.store.GetLastLoanForKey(filter.KeyID)
+			if err == nil && last != nil {
+				lastLoanLabel.SetText("🔎 Dernier emprunteur de cette clé : " + describeHistoryLoan(*last))
+			}
+		}
+
+		pages := (total + historyPageSize - 1) / historyPageSize
+		if pages == 0 {
+			pages = 1
+		}
+		pageLabel.SetText(fmt.Sprintf("Page %d / %d (%d emprunt(s))", page+1, pages, total))
+		if page > 0 {
+			prevBtn.Enable()
+		} else {
+			prevBtn.Disable()
+		}
+		if (page+1)*historyPageSize < total {
+			nextBtn.Enable()
+		} else {
+			nextBtn.Disable()
+		}
+	}
+
+	prevBtn.OnTapped = func() {
+		if page > 0 {
+			page--
+			loadPage()
+		}
+	}
+	nextBtn.OnTapped = func() {
+		if (page+1)*historyPageSize < total {
+			page++
+			loadPage()
+		}
+	}
+
+	// applyFilters lit les champs du formulaire et recharge la première page
+	applyFilters := func() {
+		from, err := parseHistoryDate(fromEntry.Text, false)
+		if err != nil {
+			app.showError("Erreur", err.Error())
+			return
+		}
+		to, err := parseHistoryDate(toEntry.Text, true)
+		if err != nil {
+			app.showError("Erreur", err.Error())
+			return
+		}
+
+		filter = db.LoanHistoryFilter{
+			KeyID:      keyMap[keySelect.Selected],
+			BorrowerID: borrowerMap[borrowerSelect.Selected],
+			BuildingID: buildingMap[buildingSelect.Selected],
+			From:       from,
+			To:         to,
+		}
+		page = 0
+		loadPage()
+	}
+
+	filterBtn := widget.NewButton("🔍 Filtrer", applyFilters)
+	filterBtn.Importance = widget.HighImportance
+
+	resetBtn := widget.NewButton("Réinitialiser", func() {
+		keySelect.SetSelected(keyOptions[0])
+		borrowerSelect.SetSelected(borrowerOptions[0])
+		buildingSelect.SetSelected(buildingOptions[0])
+		fromEntry.SetText("")
+		toEntry.SetText("")
+		applyFilters()
+	})
+
+	// Exports : tout l'historique filtré, sans pagination
+	describeFilters := func() string {
+		var parts []string
+		if name := app.siteName(); name != "" {
+			parts = append(parts, "site "+name)
+		}
+		if filter.KeyID > 0 {
+			parts = append(parts, "clé "+keySelect.Selected)
+		}
+		if filter.BorrowerID > 0 {
+			parts = append(parts, "emprunteur "+borrowerSelect.Selected)
+		}
+		if filter.BuildingID > 0 {
+			parts = append(parts, "bâtiment "+buildingSelect.Selected)
+		}
+		if filter.From != nil {
+			parts = append(parts, "depuis le "+filter.From.Format("02/01/2006"))
+		}
+		if filter.To != nil {
+			parts = append(parts, "jusqu'au "+filter.To.Format("02/01/2006"))
+		}
+		return strings.Join(parts, ", ")
+	}
+
+	exportPDFBtn := widget.NewButton("📄 Exporter PDF", func() {
+		generateLoanHistoryPDF(app, filter, describeFilters())
+	})
+
+	previewBtn := widget.NewButton("🌐 Aperçu HTML", func() {
+		loans, err := app.store.GetLoanHistory(filter)
+		if err != nil {
+			app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération de l'historique: %v", err))
+			return
+		}
+		description := describeFilters()
+		viewer := NewHTMLViewer(app, "Historique des Emprunts")
+		viewer.SetHTMLContent(GenerateLoanHistoryHTML(loans, description))
+		viewer.SetPDFGenerator(func() ([]byte, error) {
+			return pdf.GenerateLoanHistoryPDF(loans, description)
+		})
+		viewer.Show()
+	})
+
+	header := container.NewBorder(nil, nil, title, container.NewHBox(exportPDFBtn, previewBtn))
+
+	filters := container.NewVBox(
+		container.NewGridWithColumns(3, keySelect, borrowerSelect, buildingSelect),
+		container.NewGridWithColumns(4, fromEntry, toEntry, filterBtn, resetBtn),
+	)
+
+	pagination := container.NewHBox(prevBtn, pageLabel, nextBtn)
+
+	// Premier chargement sans filtre
+	loadPage()
+
+	return container.NewBorder(
+		container.NewVBox(header, filters, lastLoanLabel, widget.NewSeparator()),
+		container.NewCenter(pagination),
+		nil,
+		nil,
+		container.NewVScroll(resultsBox),
+	)
+}
+
+// createHistoryRow crée une ligne de l'historique, avec son reçu et ses pièces jointes ;
+// refresh recharge la page après un changement de ses pièces jointes
+func createHistoryRow(app *App, loan db.LoanWithDetails, refresh func()) fyne.CanvasObject {
+	keyLabel := widget.NewLabelWithStyle(
+		fmt.Sprintf("🔑 %s - %s", loan.KeyNumber, loan.KeyDescription),
+		fyne.TextAlignLeading,
+		fyne.TextStyle{Bold: true},
+	)
+
+	detailsLabel := widget.NewLabel("   " + describeHistoryLoan(loan))
+	if loan.IsOverdue(time.Now()) {
+		detailsLabel.Importance = widget.DangerImportance
+	}
+
+	attachmentsBtn := newAttachmentsButton(app, db.AuditEntityLoan, loan.ID, loanAttachmentsTitle(loan), refresh)
+	attachmentsBtn.Importance = widget.LowImportance
+
+	// Le reçu reprend les signatures recueillies à l'écran
+	receiptBtn := widget.NewButton("🧾 Reçu", func() {
+		ShowReceiptForLoan(app, loan.ID)
+	})
+	receiptBtn.Importance = widget.LowImportance
+
+	return container.NewBorder(nil, nil, nil, container.NewHBox(receiptBtn, attachmentsBtn), container.NewVBox(keyLabel, detailsLabel))
+}
+
+// describeHistoryLoan résume un emprunt : emprunteur, dates et statut
+func describeHistoryLoan(loan db.LoanWithDetails) string {
+	text := fmt.Sprintf("👤 %s - emprunté le %s", loan.BorrowerName, loan.LoanDate.Format("02/01/2006 à 15:04"))
+	if loan.LoanedBy != "" {
+		text += " (" + loan.LoanedBy + ")"
+	}
+	if loan.CopyIdentifier != "" {
+		text = fmt.Sprintf("🔢 %s - %s", loan.CopyIdentifier, text)
+	}
+	if loan.TransferredFrom != "" {
+		text += " - reçue par transfert de " + loan.TransferredFrom
+	}
+	if loan.DepositAmount > 0 {
+		text += " - " + describeDeposit(loan)
+	}
+	if loan.ReturnDate != nil && loan.TransferredTo != "" {
+		text += fmt.Sprintf(" - 🔁 transférée à %s le %s", loan.TransferredTo, loan.ReturnDate.Format("02/01/2006 à 15:04"))
+		if loan.ReturnedBy != "" {
+			text += " (" + loan.ReturnedBy + ")"
+		}
+		return text
+	}
+	if loan.ReturnDate != nil && loan.LossStatus != "" {
+		text += fmt.Sprintf(" - ⚠️ déclarée %s le %s", strings.ToLower(loan.LossStatus.Label()), loan.ReturnDate.Format("02/01/2006 à 15:04"))
+		if loan.ReturnedBy != "" {
+			text += " (" + loan.ReturnedBy + ")"
+		}
+		return text
+	}
+	if loan.ReturnDate != nil {
+		text += fmt.Sprintf(" - rendu le %s", loan.ReturnDate.Format("02/01/2006 à 15:04"))
+		if loan.ReturnedBy != "" {
+			text += " (" + loan.ReturnedBy + ")"
+		}
+		return text
+	}
+	if due := formatDueDate(loan.Loan); due != "" {
+		return text + " - en cours - " + due
+	}
+	return text + " - en cours"
+}
+
+// parseHistoryDate lit une borne de période au format JJ/MM/AAAA.
+// La borne de fin couvre toute la journée saisie.
+func parseHistoryDate(text string, endOfDay bool) (*time.Time, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, nil
+	}
+
+	day, err := time.ParseInLocation("02/01/2006", text, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("la date %q doit être au format JJ/MM/AAAA", text)
+	}
+	if endOfDay {
+		day = db.DueDateAfter(day, 0)
+	}
+	return &day, nil
+}
+
+// generateLoanHistoryPDF génère et enregistre l'historique filtré
+func generateLoanHistoryPDF(app *App, filter db.LoanHistoryFilter, filterDescription string) {
+	loans, err := app.store.GetLoanHistory(filter)
+	if err != nil {
+		app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération de l'historique: %v", err))
+		return
+	}
+
+	if len(loans) == 0 {
+		app.showError("Aucun emprunt", "Aucun emprunt à exporter.")
+		return
+	}
+
+	// Générer le PDF
+	pdfData, err := pdf.GenerateLoanHistoryPDF(loans, filterDescription)
+	if err != nil {
+		app.showError("Erreur", fmt.Sprintf("Erreur lors de la génération du PDF: %v", err))
+		return
+	}
+
+	// Enregistrer automatiquement
+	filename := pdf.GenerateFilename("historique_emprunts", 0)
+	filepath, err := pdf.SavePDF(filename, pdfData)
+	if err != nil {
+		app.showError("Erreur", fmt.Sprintf("Erreur lors de l'enregistrement: %v", err))
+		return
+	}
+
+	app.showSuccess(fmt.Sprintf("✅ Historique enregistré : %s", filepath))
+}
@@ -3,6 +3,7 @@ package gui
 import (
 	"clefs/internal/db"
 	"fmt"
+	"html"
 	"os"
 	"os/exec"
 	"runtime"
@@ -356,6 +357,21 @@ func GenerateKeyPlanHTML(buildings map[int]db.Building) string {
 			font-weight: bold;
 			margin-bottom: 15px;
 		}
+		.floor-name {
+			font-size: 1.2em;
+			color: #764ba2;
+			font-weight: bold;
+			margin: 20px 0 5px 0;
+		}
+		.room-zone {
+			display: inline-block;
+			margin-left: 8px;
+			padding: 2px 8px;
+			background: #fff3cd;
+			color: #856404;
+			border-radius: 10px;
+			font-size: 0.8em;
+		}
 		.room {
 			margin: 15px 0;
 			padding: 15px;
@@ -428,32 +444,51 @@ func GenerateKeyPlanHTML(buildings map[int]db.Building) string {
 		<div class="building">
 			<div class="building-name">%s</div>`, building.Name)
 
-		// Ajouter les salles
-		for _, room := range building.Rooms {
-			html += fmt.Sprintf(`
+		// Ajouter les salles, regroupées par étage lorsque le bâtiment en a
+		groups := db.GroupRoomsByFloor(building.Rooms)
+		for _, group := range groups {
+			switch {
+			case group.Floor != "":
+				html += fmt.Sprintf(`
+			<div class="floor-name">🏬 %s</div>`, group.Floor)
+			case len(groups) > 1:
+				html += `
+			<div class="floor-name">🏬 Sans étage</div>`
+			}
+
+			for _, room := range group.Rooms {
+				html += fmt.Sprintf(`
 			<div class="room">
 				<div class="room-name">%s`, room.Name)
 
-			if room.Type != "" {
-				html += fmt.Sprintf(` <span class="room-type">(%s)</span>`, room.Type)
-			}
-			html += `</div>`
+				if room.Type != "" {
+					html += fmt.Sprintf(` <span class="room-type">(%s)</span>`, room.Type)
+				}
+				if room.Zone != "" {
+					html += fmt.Sprintf(` <span class="room-zone">🧭 %s</span>`, room.Zone)
+				}
+				html += `</div>`
 
-			// Ajouter les clés
-			if len(room.Keys) > 0 {
-				html += `<div class="keys-list">`
-				for _, key := range room.Keys {
-					html += fmt.Sprintf(`
+				// Ajouter les clés
+				if len(room.Keys) > 0 {
+					html += `<div class="keys-list">`
+					for _, key := range room.Keys {
+						access := ""
+						if key.Access == db.AccessInherited {
+							access = fmt.Sprintf(` <span class="room-type">(passe-partout via %s)</span>`, key.AccessVia)
+						}
+						html += fmt.Sprintf(`
 					<div class="key-item">
-						<span class="key-number">Clé %s</span> - %s
-					</div>`, key.Number, key.Description)
+						<span class="key-number">Clé %s</span> - %s%s
+					</div>`, key.Number, key.Description, access)
+					}
+					html += `</div>`
+				} else {
+					html += `<div class="no-keys">Aucune clé associée</div>`
 				}
+
 				html += `</div>`
-			} else {
-				html += `<div class="no-keys">Aucune clé associée</div>`
 			}
-
-			html += `</div>`
 		}
 
 		html += `</div>`
@@ -629,6 +664,145 @@ func GenerateLoansReportHTML(loans []db.LoanWithDetails) string {
 	return html
 }
 
+// GenerateLoanHistoryHTML génère le HTML de l'historique des emprunts
+func GenerateLoanHistoryHTML(loans []db.LoanWithDetails, filterDescription string) string {
+	page := `<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="UTF-8">
+	<title>Historique des Emprunts</title>
+	<style>
+		body {
+			font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif;
+			max-width: 1200px;
+			margin: 0 auto;
+			padding: 20px;
+		}
+		.header {
+			text-align: center;
+			margin-bottom: 30px;
+			padding-bottom: 20px;
+			border-bottom: 3px solid #4a6fa5;
+		}
+		h1 {
+			color: #333;
+			margin: 0;
+		}
+		.meta {
+			color: #666;
+			margin-top: 10px;
+		}
+		table {
+			width: 100%;
+			border-collapse: collapse;
+		}
+		th {
+			background: #4a6fa5;
+			color: white;
+			padding: 10px;
+			text-align: left;
+		}
+		td {
+			padding: 8px 10px;
+			border-bottom: 1px solid #eee;
+		}
+		.key-number {
+			font-weight: bold;
+		}
+		.active {
+			color: #2b7a0b;
+			font-weight: bold;
+		}
+		tr.overdue td {
+			background: #ffe0e0;
+		}
+		.footer {
+			margin-top: 30px;
+			text-align: center;
+			color: #666;
+			font-size: 0.9em;
+		}
+	</style>
+</head>
+<body>
+	<div class="header">
+		<h1>🕘 Historique des Emprunts</h1>
+		<div class="meta">Généré le %s - %d emprunt(s)</div>
+		<div class="meta">%s</div>
+	</div>
+
+	<table>
+		<thead>
+			<tr>
+				<th>Clé</th>
+				<th>Description</th>
+				<th>Emprunteur</th>
+				<th>Emprunté le</th>
+				<th>Retour prévu</th>
+				<th>Rendu le</th>
+			</tr>
+		</thead>
+		<tbody>`
+
+	filters := "Aucun filtre"
+	if filterDescription != "" {
+		filters = "Filtres : " + html.EscapeString(filterDescription)
+	}
+	page = fmt.Sprintf(page, time.Now().Format("02/01/2006 à 15:04"), len(loans), filters)
+
+	now := time.Now()
+	for _, loan := range loans {
+		rowClass := ""
+		if loan.IsOverdue(now) {
+			rowClass = ` class="overdue"`
+		}
+		due := "-"
+		if loan.DueDate != nil {
+			due = loan.DueDate.Format("02/01/2006")
+		}
+		returned := `<span class="active">En cours</span>`
+		if loan.ReturnDate != nil {
+			returned = loan.ReturnDate.Format("02/01/2006 15:04")
+			if loan.LossStatus != "" {
+				returned += " (" + loan.LossStatus.Label() + ")"
+			}
+			if loan.TransferredTo != "" {
+				returned += " (transférée à " + html.EscapeString(loan.TransferredTo) + ")"
+			}
+		}
+
+		page += fmt.Sprintf(`
+			<tr%s>
+				<td><span class="key-number">%s</span></td>
+				<td>%s</td>
+				<td>%s</td>
+				<td>%s</td>
+				<td>%s</td>
+				<td>%s</td>
+			</tr>`,
+			rowClass,
+			html.EscapeString(loan.KeyNumber),
+			html.EscapeString(loan.KeyDescription),
+			html.EscapeString(loan.BorrowerName),
+			loan.LoanDate.Format("02/01/2006 15:04"),
+			due,
+			returned,
+		)
+	}
+
+	page += `
+		</tbody>
+	</table>
+
+	<div class="footer">
+		<p>Gestionnaire de Clés v2.0 - Document généré automatiquement</p>
+	</div>
+</body>
+</html>`
+
+	return page
+}
+
 // GenerateGlobalBorrowerReportHTML génère le HTML pour le rapport global des emprunts
 func GenerateGlobalBorrowerReportHTML(loansByBorrower map[string][]db.LoanWithDetails) string {
 	html := `<!DOCTYPE html>
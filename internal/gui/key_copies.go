@@ -0,0 +1,155 @@
+package gui
+
+import (
+	"clefs/internal/db"
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// editableCopyStatuses sont les états qu'un gestionnaire peut attribuer à la main
+// (l'état « emprunté » est posé par les emprunts)
+var editableCopyStatuses = []db.CopyStatus{db.CopyAvailable, db.CopyReserve, db.CopyLost, db.CopyStolen, db.CopyDestroyed}
+
+// showKeyCopiesDialog affiche la gestion des exemplaires d'une clé
+func showKeyCopiesDialog(app *App, key db.Key) {
+	var dialog *widget.PopUp
+
+	// reopen rafraîchit la boîte de dialogue après une modification
+	reopen := func() {
+		app.window.Canvas().Overlays().Remove(dialog)
+		showKeyCopiesDialog(app, key)
+	}
+
+	copies, err := app.store.GetCopiesForKey(key.ID)
+	if err != nil {
+		app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération des exemplaires: %v", err))
+		return
+	}
+
+	statusOptions := make([]string, len(editableCopyStatuses))
+	statusMap := make(map[string]db.CopyStatus)
+	for i, status := range editableCopyStatuses {
+		statusOptions[i] = status.Label()
+		statusMap[status.Label()] = status
+	}
+
+	// Les exemplaires d'une clé rangée dans une armoire reçoivent chacun un crochet
+	hooks := 0
+	if key.StorageLocationID != nil {
+		if loc, err := app.store.GetStorageLocationByID(*key.StorageLocationID); err == nil {
+			hooks = loc.HookCount
+		}
+	}
+	hookWidget := func(c db.KeyCopy) fyne.CanvasObject {
+		if hooks == 0 {
+			return nil
+		}
+		if !app.canEdit() {
+			if c.HookNumber == 0 {
+				return widget.NewLabel(noHook)
+			}
+			return widget.NewLabel(fmt.Sprintf("Crochet %d", c.HookNumber))
+		}
+		return newHookSelect(app, c, hooks, reopen)
+	}
+
+	copiesBox := container.NewVBox()
+	if len(copies) == 0 {
+		copiesBox.Add(widget.NewLabel("Aucun exemplaire pour cette clé."))
+	}
+
+	for _, kc := range copies {
+		c := kc // Capture
+
+		identifierLabel := widget.NewLabelWithStyle("🔢 "+c.Identifier, fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+
+		// Un exemplaire emprunté ne change d'état qu'à son retour
+		if c.Status == db.CopyLoaned {
+			copiesBox.Add(container.NewBorder(nil, nil, identifierLabel, hookWidget(c),
+				widget.NewLabel(fmt.Sprintf("%s par %s", c.Status.Label(), c.BorrowerName))))
+			copiesBox.Add(widget.NewSeparator())
+			continue
+		}
+
+		statusSelect := widget.NewSelect(statusOptions, nil)
+		statusSelect.SetSelected(c.Status.Label())
+
+		notesEntry := widget.NewEntry()
+		notesEntry.SetPlaceHolder("Notes (ex: rendue abîmée)")
+		notesEntry.SetText(c.Notes)
+
+		saveBtn := widget.NewButton("💾", func() {
+			if !app.requireEdit() {
+				return
+			}
+			err := app.store.SetCopyStatus(c.ID, statusMap[statusSelect.Selected], notesEntry.Text)
+			if err != nil {
+				app.showError("Erreur", fmt.Sprintf("Erreur lors de la modification de l'exemplaire: %v", err))
+				return
+			}
+			reopen()
+		})
+
+		fields := container.NewGridWithColumns(2, statusSelect, notesEntry)
+		if hook := hookWidget(c); hook != nil && c.Status.InService() {
+			fields = container.NewGridWithColumns(3, statusSelect, notesEntry, hook)
+		}
+		copiesBox.Add(container.NewBorder(nil, nil, identifierLabel, saveBtn, fields))
+		copiesBox.Add(widget.NewSeparator())
+	}
+
+	copiesScroll := container.NewVScroll(copiesBox)
+	copiesScroll.SetMinSize(fyne.NewSize(600, 350))
+
+	// Ajout d'un exemplaire, inscrit au registre de stock comme un achat ou une reproduction
+	identifierEntry := widget.NewEntry()
+	identifierEntry.SetPlaceHolder(fmt.Sprintf("Identifiant (vide = %s-N)", key.Number))
+
+	originSelect := widget.NewSelect([]string{db.MovementDuplication.Label(), db.MovementPurchase.Label()}, nil)
+	originSelect.SetSelected(db.MovementDuplication.Label())
+
+	reasonEntry := widget.NewEntry()
+	reasonEntry.SetPlaceHolder("Motif (ex: double pour le service technique)")
+
+	addBtn := widget.NewButton("➕ Ajouter un exemplaire", func() {
+		if !app.requireEdit() {
+			return
+		}
+		origin := db.MovementDuplication
+		if originSelect.Selected == db.MovementPurchase.Label() {
+			origin = db.MovementPurchase
+		}
+		reason := reasonEntry.Text
+		if reason == "" {
+			reason = "Ajout d'un exemplaire"
+		}
+		err := app.store.AddKeyCopy(key.ID, identifierEntry.Text, origin, reason)
+		if err != nil {
+			app.showError("Erreur", fmt.Sprintf("Erreur lors de l'ajout de l'exemplaire: %v", err))
+			return
+		}
+		reopen()
+	})
+
+	closeBtn := widget.NewButton("Fermer", func() {
+		app.window.Canvas().Overlays().Remove(dialog)
+		app.showKeys()
+	})
+
+	content := container.NewVBox(
+		widget.NewLabelWithStyle(fmt.Sprintf("Exemplaires de la clé %s", key.Number), fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
+		widget.NewSeparator(),
+		copiesScroll,
+		widget.NewSeparator(),
+		container.NewBorder(nil, nil, nil, addBtn, container.NewGridWithColumns(3, identifierEntry, originSelect, reasonEntry)),
+		widget.NewSeparator(),
+		container.NewHBox(closeBtn),
+	)
+
+	dialog = widget.NewModalPopUp(content, app.window.Canvas())
+	dialog.Resize(fyne.NewSize(700, 550))
+	dialog.Show()
+}
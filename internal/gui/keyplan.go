@@ -12,7 +12,7 @@ import (
 	"fyne.io/fyne/v2/widget"
 )
 
-// createKeyPlanView crée la vue du plan de clés avec 2 vues
+// createKeyPlanView crée la vue du plan de clés avec 3 vues
 func createKeyPlanView(app *App) fyne.CanvasObject {
 	title := widget.NewLabelWithStyle("Plan de Clés", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
 
@@ -25,7 +25,7 @@ func createKeyPlanView(app *App) fyne.CanvasObject {
 	buttonsContainer := container.NewHBox(exportBtn)
 
 	// Récupérer les données du plan de clés
-	buildingsMap, err := db.GetKeyPlanData()
+	buildingsMap, err := app.store.GetKeyPlanData()
 	if err != nil {
 		return container.NewVBox(
 			title,
@@ -35,12 +35,13 @@ func createKeyPlanView(app *App) fyne.CanvasObject {
 
 	// Créer les deux vues
 	roomsView := createRoomsToKeysView(buildingsMap)
-	keysView := createKeysToRoomsView()
+	keysView := createKeysToRoomsView(app)
 
 	// Créer les onglets
 	tabs := container.NewAppTabs(
 		container.NewTabItem("Portes -> Cles", container.NewVScroll(roomsView)),
-		container.NewTabItem("Cles -> Portes", container.NewVScroll(keysView)),
+		container.NewTabItem("Cles -> Portes (hiérarchie)", container.NewVScroll(keysView)),
+		container.NewTabItem("Zones -> Cles", container.NewVScroll(createZonesToKeysView(app))),
 	)
 
 	header := container.NewBorder(nil, nil, nil, buttonsContainer, title)
@@ -89,35 +90,49 @@ func createRoomsToKeysView(buildingsMap map[int]db.Building) fyne.CanvasObject {
 				return strings.ToLower(building.Rooms[i].Name) < strings.ToLower(building.Rooms[j].Name)
 			})
 
-			// Pour chaque salle
-			for _, room := range building.Rooms {
-				// Construction de la ligne salle + clés
-				var textBuilder strings.Builder
-				textBuilder.WriteString(fmt.Sprintf("  • %s", room.Name))
-				if room.Type != "" {
-					textBuilder.WriteString(fmt.Sprintf(" (%s)", room.Type))
+			// Pour chaque étage, puis chaque salle
+			groups := db.GroupRoomsByFloor(building.Rooms)
+			for _, group := range groups {
+				indent := "  "
+				if heading := floorGroupLabel(groups, group); heading != "" {
+					planBox.Add(widget.NewLabelWithStyle("  "+heading, fyne.TextAlignLeading, fyne.TextStyle{Italic: true}))
+					indent = "      "
 				}
-				textBuilder.WriteString(" : ")
 
-				if len(room.Keys) == 0 {
-					textBuilder.WriteString("Aucune clé")
-				} else {
-					// Trier les clés par numéro
-					sort.Slice(room.Keys, func(i, j int) bool {
-						return room.Keys[i].Number < room.Keys[j].Number
-					})
-
-					var keyTexts []string
-					for _, key := range room.Keys {
-						keyTexts = append(keyTexts, fmt.Sprintf("%s", key.Number))
+				for _, room := range group.Rooms {
+					// Construction de la ligne salle + clés
+					var textBuilder strings.Builder
+					textBuilder.WriteString(fmt.Sprintf("%s• %s", indent, room.Name))
+					if room.Type != "" {
+						textBuilder.WriteString(fmt.Sprintf(" (%s)", room.Type))
+					}
+					textBuilder.WriteString(roomZoneSuffix(room))
+					textBuilder.WriteString(" : ")
+
+					if len(room.Keys) == 0 {
+						textBuilder.WriteString("Aucune clé")
+					} else {
+						// Trier les clés par numéro
+						sort.Slice(room.Keys, func(i, j int) bool {
+							return room.Keys[i].Number < room.Keys[j].Number
+						})
+
+						var keyTexts []string
+						for _, key := range room.Keys {
+							keyText := key.Number
+							if key.Access == db.AccessInherited {
+								keyText += fmt.Sprintf(" (passe-partout via %s)", key.AccessVia)
+							}
+							keyTexts = append(keyTexts, keyText)
+						}
+						textBuilder.WriteString(strings.Join(keyTexts, ", "))
 					}
-					textBuilder.WriteString(strings.Join(keyTexts, ", "))
-				}
 
-				// Affichage compact sur une ligne
-				label := widget.NewLabel(textBuilder.String())
-				label.Wrapping = fyne.TextWrapWord
-				planBox.Add(label)
+					// Affichage compact sur une ligne
+					label := widget.NewLabel(textBuilder.String())
+					label.Wrapping = fyne.TextWrapWord
+					planBox.Add(label)
+				}
 			}
 		}
 		// Petit séparateur discret entre bâtiments
@@ -128,11 +143,11 @@ func createRoomsToKeysView(buildingsMap map[int]db.Building) fyne.CanvasObject {
 }
 
 // createKeysToRoomsView crée la vue Clés → Portes (Compacte et Triée)
-func createKeysToRoomsView() fyne.CanvasObject {
+func createKeysToRoomsView(app *App) fyne.CanvasObject {
 	planBox := container.NewVBox()
 
 	// Récupérer toutes les clés
-	keys, err := db.GetAllKeys()
+	keys, err := app.store.GetAllKeys()
 	if err != nil {
 		planBox.Add(widget.NewLabel(fmt.Sprintf("Erreur: %v", err)))
 		return planBox
@@ -143,19 +158,28 @@ func createKeysToRoomsView() fyne.CanvasObject {
 		return planBox
 	}
 
-	// Trier les clés par numéro
+	// Trier les clés par numéro, puis les ranger sous leur passe-partout
 	sort.Slice(keys, func(i, j int) bool {
 		return keys[i].Number < keys[j].Number
 	})
 
 	// Pour chaque clé
-	for _, key := range keys {
+	for _, entry := range db.KeyTree(keys) {
+		key := entry.Key
+		indent := strings.Repeat("      ", entry.Depth)
+
 		// En-tête de la clé
-		keyHeader := fmt.Sprintf("🔑 %s - %s", key.Number, key.Description)
+		keyHeader := fmt.Sprintf("%s🔑 %s - %s", indent, key.Number, key.Description)
+		if entry.Depth > 0 {
+			keyHeader = fmt.Sprintf("%s↳ 🔑 %s - %s", indent, key.Number, key.Description)
+		}
+		if entry.Children > 0 {
+			keyHeader += fmt.Sprintf(" (passe-partout de %d clé(s))", entry.Children)
+		}
 
-		// Récupérer les salles associées
-		rooms, err := db.GetRoomsForKey(key.ID)
-		var roomsText string
+		// Récupérer les salles ouvertes, directement ou par les clés subordonnées
+		rooms, err := app.store.GetRoomsForKey(key.ID)
+		var roomsText, inheritedText string
 
 		if err != nil {
 			roomsText = "Erreur de chargement"
@@ -167,21 +191,82 @@ func createKeysToRoomsView() fyne.CanvasObject {
 				return strings.ToLower(rooms[i].Name) < strings.ToLower(rooms[j].Name)
 			})
 
-			var roomNames []string
+			var roomNames, inheritedNames []string
 			for _, room := range rooms {
-				roomNames = append(roomNames, room.Name)
+				if room.Access == db.AccessInherited {
+					inheritedNames = append(inheritedNames, fmt.Sprintf("%s (via %s)", room.Name, room.AccessVia))
+				} else {
+					roomNames = append(roomNames, room.Name)
+				}
 			}
 			roomsText = strings.Join(roomNames, ", ")
+			if len(roomNames) == 0 {
+				roomsText = "Aucune porte en propre"
+			}
+			inheritedText = strings.Join(inheritedNames, ", ")
 		}
 
 		// Affichage compact : Clé en gras, liste des portes en dessous
 		keyLabel := widget.NewLabelWithStyle(keyHeader, fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
 		planBox.Add(keyLabel)
 
-		roomsLabel := widget.NewLabel("   -> Ouvre : " + roomsText)
+		roomsLabel := widget.NewLabel(indent + "   -> Ouvre : " + roomsText)
 		roomsLabel.Wrapping = fyne.TextWrapWord
 		planBox.Add(roomsLabel)
 
+		if inheritedText != "" {
+			inheritedLabel := widget.NewLabel(indent + "   -> Par ses clés subordonnées : " + inheritedText)
+			inheritedLabel.Wrapping = fyne.TextWrapWord
+			planBox.Add(inheritedLabel)
+		}
+
+		planBox.Add(widget.NewSeparator())
+	}
+
+	return container.NewPadded(planBox)
+}
+
+// createZonesToKeysView crée la vue Zones → Clés : pour chaque zone, les clés qui ouvrent
+// au moins une de ses salles
+func createZonesToKeysView(app *App) fyne.CanvasObject {
+	planBox := container.NewVBox()
+
+	zones, err := app.store.GetZones()
+	if err != nil {
+		planBox.Add(widget.NewLabel(fmt.Sprintf("Erreur: %v", err)))
+		return planBox
+	}
+
+	if len(zones) == 0 {
+		planBox.Add(widget.NewLabel("Aucune zone configurée (Configuration > Gérer les Zones)"))
+		return planBox
+	}
+
+	for _, zone := range zones {
+		planBox.Add(widget.NewLabelWithStyle(fmt.Sprintf("🧭 %s (%d salle(s))", zone.Name, zone.RoomCount),
+			fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+
+		keys, err := app.store.GetKeysForZone(zone.ID)
+		var keysText string
+		if err != nil {
+			keysText = "Erreur de chargement"
+		} else if len(keys) == 0 {
+			keysText = "Aucune clé"
+		} else {
+			var keyTexts []string
+			for _, key := range keys {
+				keyText := key.Number
+				if key.Access == db.AccessInherited {
+					keyText += fmt.Sprintf(" (passe-partout via %s)", key.AccessVia)
+				}
+				keyTexts = append(keyTexts, keyText)
+			}
+			keysText = strings.Join(keyTexts, ", ")
+		}
+
+		keysLabel := widget.NewLabel("   -> Clés : " + keysText)
+		keysLabel.Wrapping = fyne.TextWrapWord
+		planBox.Add(keysLabel)
 		planBox.Add(widget.NewSeparator())
 	}
 
@@ -191,7 +276,7 @@ func createKeysToRoomsView() fyne.CanvasObject {
 // generateKeyPlanPDF génère et enregistre le plan de clés en PDF
 func generateKeyPlanPDF(app *App) {
 	// Récupérer les données du plan de clés
-	buildingsMap, err := db.GetKeyPlanData()
+	buildingsMap, err := app.store.GetKeyPlanData()
 	if err != nil {
 		app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération des données: %v", err))
 		return
@@ -202,8 +287,14 @@ func generateKeyPlanPDF(app *App) {
 		return
 	}
 
+	keys, err := app.store.GetAllKeys()
+	if err != nil {
+		app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération des clés: %v", err))
+		return
+	}
+
 	// Générer le PDF
-	pdfData, err := pdf.GenerateKeyPlanPDF(buildingsMap)
+	pdfData, err := pdf.GenerateKeyPlanPDF(buildingsMap, keys, app.siteLabel())
 	if err != nil {
 		app.showError("Erreur", fmt.Sprintf("Erreur lors de la génération du PDF: %v", err))
 		return
@@ -24,10 +24,14 @@ func createKeysView(app *App) fyne.CanvasObject {
 		generateKeyStockReportPDF(app)
 	})
 
-	header := container.NewBorder(nil, nil, nil, container.NewHBox(stockReportBtn, addBtn), title)
+	movementsReportBtn := widget.NewButton("📒 Mouvements de Stock", func() {
+		showStockMovementsReportDialog(app)
+	})
+
+	header := container.NewBorder(nil, nil, nil, container.NewHBox(stockReportBtn, movementsReportBtn, addBtn), title)
 
 	// Récupérer les clés
-	keys, err := db.GetAllKeys()
+	keys, err := app.store.GetAllKeys()
 	if err != nil {
 		return container.NewVBox(
 			header,
@@ -68,10 +72,10 @@ func createKeysListView(keys []db.Key, app *App) fyne.CanvasObject {
 // createKeyAccordion crée un accordéon pour une clé
 func createKeyAccordion(app *App, key db.Key) *widget.Accordion {
 	// Récupérer les emprunts actifs pour cette clé
-	activeLoans, _ := db.GetActiveLoansForKey(key.ID)
+	activeLoans, _ := app.store.GetActiveLoansForKey(key.ID)
 
 	// Récupérer les salles associées
-	rooms, _ := db.GetRoomsForKey(key.ID)
+	rooms, _ := app.store.GetRoomsForKey(key.ID)
 	roomsText := "Aucune salle"
 	if len(rooms) > 0 {
 		roomsText = ""
@@ -80,12 +84,16 @@ func createKeyAccordion(app *App, key db.Key) *widget.Accordion {
 				roomsText += ", "
 			}
 			roomsText += room.Name
+			if room.Access == db.AccessInherited {
+				roomsText += fmt.Sprintf(" (via %s)", room.AccessVia)
+			}
 		}
 	}
 
-	// Calculer la disponibilité
+	// Calculer la disponibilité à partir des exemplaires
 	borrowed := len(activeLoans)
-	available := key.QuantityTotal - key.QuantityReserve - borrowed
+	availableCopies, _ := app.store.GetAvailableCopies(key.ID)
+	available := len(availableCopies)
 
 	// Créer le contenu détaillé
 	detailsContent := container.NewVBox()
@@ -94,7 +102,16 @@ func createKeyAccordion(app *App, key db.Key) *widget.Accordion {
 	detailsContent.Add(widget.NewLabel(fmt.Sprintf("📝 Description: %s", key.Description)))
 	detailsContent.Add(widget.NewLabel(fmt.Sprintf("📦 Quantité totale: %d | Réserve: %d", key.QuantityTotal, key.QuantityReserve)))
 	detailsContent.Add(widget.NewLabel(fmt.Sprintf("📍 Emplacement: %s", key.StorageLocation)))
+	if key.DefaultLoanDays > 0 {
+		detailsContent.Add(widget.NewLabel(fmt.Sprintf("⏱️ Durée d'emprunt par défaut: %d jour(s)", key.DefaultLoanDays)))
+	} else {
+		detailsContent.Add(widget.NewLabel("⏱️ Durée d'emprunt par défaut: sans date de retour"))
+	}
+	if key.ParentKeyID != nil {
+		detailsContent.Add(widget.NewLabel(fmt.Sprintf("🗝️ Passe-partout: %s", key.ParentNumber)))
+	}
 	detailsContent.Add(widget.NewLabel(fmt.Sprintf("🏢 Salles: %s", roomsText)))
+	addCustomFieldLabels(app, detailsContent, db.AuditEntityKey, key.ID)
 
 	// Statut de disponibilité avec couleur
 	statusText := fmt.Sprintf("✅ Disponibles: %d | 🔴 Sorties: %d", available, borrowed)
@@ -124,19 +141,15 @@ func createKeyAccordion(app *App, key db.Key) *widget.Accordion {
 				widget.NewLabel(fmt.Sprintf("   📅 Depuis le: %s (%s)",
 					l.LoanDate.Format("02/01/2006"), durationText)),
 			)
+			if l.CopyIdentifier != "" {
+				loanInfo.Add(widget.NewLabel(fmt.Sprintf("   🔢 Exemplaire: %s", l.CopyIdentifier)))
+			}
+			if due := formatDueDate(l.Loan); due != "" {
+				loanInfo.Add(widget.NewLabel("   " + due))
+			}
 
 			returnBtn := widget.NewButton("↩️ Retourner", func() {
-				app.showConfirm("Confirmer le retour",
-					fmt.Sprintf("Confirmer le retour de la clé %s empruntée par %s?", key.Number, l.BorrowerName),
-					func() {
-						err := db.ReturnLoan(l.ID)
-						if err != nil {
-							app.showError("Erreur", fmt.Sprintf("Erreur lors du retour: %v", err))
-							return
-						}
-						app.showSuccess("Clé retournée avec succès!")
-						app.showKeys()
-					})
+				confirmLoanReturn(app, l, app.showKeys)
 			})
 			returnBtn.Importance = widget.MediumImportance
 
@@ -154,22 +167,39 @@ func createKeyAccordion(app *App, key db.Key) *widget.Accordion {
 		showEditKeyDialog(app, key.ID)
 	})
 
-	deleteBtn := widget.NewButton("🗑️ Supprimer", func() {
-		app.showConfirm("Confirmer la suppression",
-			fmt.Sprintf("Êtes-vous sûr de vouloir supprimer la clé %s?", key.Number),
+	copiesBtn := widget.NewButton("🔢 Exemplaires", func() {
+		showKeyCopiesDialog(app, key)
+	})
+
+	authorizationsBtn := widget.NewButton("🔐 Autorisations", func() {
+		showKeyAuthorizationsDialog(app, key)
+	})
+
+	stockBtn := widget.NewButton("📈 Stock", func() {
+		showKeyStockDialog(app, key)
+	})
+
+	archiveBtn := widget.NewButton("📦 Archiver", func() {
+		if !app.requireEdit() {
+			return
+		}
+		app.showConfirm("Confirmer l'archivage",
+			fmt.Sprintf("Archiver la clé %s ?\nElle n'apparaîtra plus dans les listes mais reste dans l'historique des emprunts.", key.Number),
 			func() {
-				err := db.DeleteKey(key.ID)
+				err := app.store.ArchiveRecord(db.AuditEntityKey, key.ID)
 				if err != nil {
-					app.showError("Erreur", fmt.Sprintf("Erreur lors de la suppression: %v", err))
+					app.showError("Erreur", fmt.Sprintf("Impossible d'archiver la clé: %v", err))
 					return
 				}
-				app.showSuccess("Clé supprimée avec succès!")
+				app.showSuccess("Clé archivée avec succès!")
 				app.showKeys()
 			})
 	})
-	deleteBtn.Importance = widget.DangerImportance
+	archiveBtn.Importance = widget.DangerImportance
 
-	actions := container.NewHBox(editBtn, deleteBtn)
+	attachmentsBtn := newAttachmentsButton(app, db.AuditEntityKey, key.ID, "Clé "+key.Number, app.showKeys)
+
+	actions := container.NewHBox(editBtn, copiesBtn, stockBtn, authorizationsBtn, attachmentsBtn, archiveBtn)
 	detailsContent.Add(actions)
 
 	// Créer l'item d'accordéon
@@ -188,8 +218,12 @@ func createKeyAccordion(app *App, key db.Key) *widget.Accordion {
 
 // showAddKeyDialog affiche la boîte de dialogue pour ajouter une clé
 func showAddKeyDialog(app *App) {
+	if !app.requireEdit() {
+		return
+	}
+
 	// Récupérer les bâtiments et salles
-	buildings, err := db.GetAllBuildings()
+	buildings, err := app.store.GetAllBuildings()
 	if err != nil {
 		app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération des bâtiments: %v", err))
 		return
@@ -209,8 +243,15 @@ func showAddKeyDialog(app *App) {
 	reserveEntry.SetPlaceHolder("0")
 	reserveEntry.SetText("0")
 
-	storageEntry := widget.NewEntry()
-	storageEntry.SetPlaceHolder("Emplacement de stockage")
+	storageSelect, selectedStorage := newStorageLocationSelect(app, 0, nil)
+
+	loanDaysEntry := widget.NewEntry()
+	loanDaysEntry.SetPlaceHolder("0")
+	loanDaysEntry.SetText("0")
+
+	parentSelect, selectedParent := newParentKeySelect(app, 0, nil)
+
+	custom := newCustomFieldInputs(app, db.AuditEntityKey, 0)
 
 	// Sélection des salles
 	roomCheckboxes := make(map[int]*widget.Check)
@@ -220,7 +261,7 @@ func showAddKeyDialog(app *App) {
 		buildingLabel := widget.NewLabelWithStyle(building.Name, fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
 		roomsBox.Add(buildingLabel)
 
-		rooms, _ := db.GetRoomsByBuildingID(building.ID)
+		rooms, _ := app.store.GetRoomsByBuildingID(building.ID)
 		for _, room := range rooms {
 			r := room
 			checkbox := widget.NewCheck(r.Name, nil)
@@ -239,7 +280,12 @@ func showAddKeyDialog(app *App) {
 		widget.NewLabel("Quantité en réserve:"),
 		reserveEntry,
 		widget.NewLabel("Emplacement de stockage:"),
-		storageEntry,
+		storageSelect,
+		widget.NewLabel("Durée d'emprunt par défaut (jours, 0 = sans date de retour):"),
+		loanDaysEntry,
+		widget.NewLabel("Passe-partout parent (ouvre aussi les salles de cette clé):"),
+		parentSelect,
+		custom.content(),
 		widget.NewSeparator(),
 		widget.NewLabel("Salles associées:"),
 		container.NewVScroll(roomsBox),
@@ -269,6 +315,18 @@ func showAddKeyDialog(app *App) {
 			return
 		}
 
+		loanDays, err := strconv.Atoi(loanDaysEntry.Text)
+		if err != nil || loanDays < 0 {
+			app.showError("Erreur", "La durée d'emprunt doit être un nombre de jours positif ou zéro.")
+			return
+		}
+
+		customValues, err := custom.values()
+		if err != nil {
+			app.showError("Erreur", err.Error())
+			return
+		}
+
 		// Récupérer les salles sélectionnées
 		var selectedRoomIDs []int
 		for roomID, checkbox := range roomCheckboxes {
@@ -278,14 +336,17 @@ func showAddKeyDialog(app *App) {
 		}
 
 		key := &db.Key{
-			Number:          numberEntry.Text,
-			Description:     descEntry.Text,
-			QuantityTotal:   total,
-			QuantityReserve: reserve,
-			StorageLocation: storageEntry.Text,
+			Number:            numberEntry.Text,
+			Description:       descEntry.Text,
+			QuantityTotal:     total,
+			QuantityReserve:   reserve,
+			StorageLocationID: selectedStorage(),
+			DefaultLoanDays:   loanDays,
+			ParentKeyID:       selectedParent(),
+			CustomValues:      customValues,
 		}
 
-		err = db.CreateKey(key, selectedRoomIDs)
+		err = app.store.CreateKey(key, selectedRoomIDs)
 		if err != nil {
 			app.showError("Erreur", fmt.Sprintf("Erreur lors de la création: %v", err))
 			return
@@ -306,28 +367,68 @@ func showAddKeyDialog(app *App) {
 	)
 
 	dialog = widget.NewModalPopUp(content, app.window.Canvas())
-	dialog.Resize(fyne.NewSize(600, 600))
+	dialog.Resize(fyne.NewSize(600, 600+custom.height()))
 	dialog.Show()
 }
 
+// noParentKey est l'option des formulaires de clé pour une clé sans passe-partout
+const noParentKey = "Aucun"
+
+// newParentKeySelect crée la liste de choix du passe-partout parent d'une clé
+// (excludeID : la clé modifiée, 0 pour une création) et la fonction qui lit le choix
+func newParentKeySelect(app *App, excludeID int, current *int) (*widget.Select, func() *int) {
+	options := []string{noParentKey}
+	keyMap := make(map[string]int)
+	keys, _ := app.store.GetAllKeys()
+	selected := noParentKey
+	for _, k := range keys {
+		if k.ID == excludeID {
+			continue
+		}
+		label := fmt.Sprintf("%s - %s", k.Number, k.Description)
+		options = append(options, label)
+		keyMap[label] = k.ID
+		if current != nil && *current == k.ID {
+			selected = label
+		}
+	}
+
+	parentSelect := widget.NewSelect(options, nil)
+	parentSelect.SetSelected(selected)
+
+	return parentSelect, func() *int {
+		id, ok := keyMap[parentSelect.Selected]
+		if !ok {
+			return nil
+		}
+		return &id
+	}
+}
+
 // showEditKeyDialog affiche la boîte de dialogue pour modifier une clé
 func showEditKeyDialog(app *App, keyID int) {
+	if !app.requireEdit() {
+		return
+	}
+
 	// Récupérer la clé
-	key, err := db.GetKeyByID(keyID)
+	key, err := app.store.GetKeyByID(keyID)
 	if err != nil {
 		app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération de la clé: %v", err))
 		return
 	}
 
-	// Récupérer les salles actuelles
-	currentRooms, _ := db.GetRoomsForKey(keyID)
+	// Récupérer les salles actuelles (les salles héritées des clés subordonnées ne sont pas modifiables ici)
+	currentRooms, _ := app.store.GetRoomsForKey(keyID)
 	currentRoomIDs := make(map[int]bool)
 	for _, room := range currentRooms {
-		currentRoomIDs[room.ID] = true
+		if room.Access == db.AccessDirect {
+			currentRoomIDs[room.ID] = true
+		}
 	}
 
 	// Récupérer les bâtiments et salles
-	buildings, err := db.GetAllBuildings()
+	buildings, err := app.store.GetAllBuildings()
 	if err != nil {
 		app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération des bâtiments: %v", err))
 		return
@@ -339,14 +440,18 @@ func showEditKeyDialog(app *App, keyID int) {
 	descEntry := widget.NewEntry()
 	descEntry.SetText(key.Description)
 
-	totalEntry := widget.NewEntry()
-	totalEntry.SetText(strconv.Itoa(key.QuantityTotal))
+	// Les quantités découlent du registre de stock : elles ne se saisissent pas ici
+	quantitiesLabel := widget.NewLabel(fmt.Sprintf("%d au total, dont %d en réserve (📈 Stock pour enregistrer un achat, une perte...)",
+		key.QuantityTotal, key.QuantityReserve))
 
-	reserveEntry := widget.NewEntry()
-	reserveEntry.SetText(strconv.Itoa(key.QuantityReserve))
+	storageSelect, selectedStorage := newStorageLocationSelect(app, key.ID, key.StorageLocationID)
+
+	loanDaysEntry := widget.NewEntry()
+	loanDaysEntry.SetText(strconv.Itoa(key.DefaultLoanDays))
+
+	parentSelect, selectedParent := newParentKeySelect(app, key.ID, key.ParentKeyID)
 
-	storageEntry := widget.NewEntry()
-	storageEntry.SetText(key.StorageLocation)
+	custom := newCustomFieldInputs(app, db.AuditEntityKey, key.ID)
 
 	// Sélection des salles
 	roomCheckboxes := make(map[int]*widget.Check)
@@ -356,7 +461,7 @@ func showEditKeyDialog(app *App, keyID int) {
 		buildingLabel := widget.NewLabelWithStyle(building.Name, fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
 		roomsBox.Add(buildingLabel)
 
-		rooms, _ := db.GetRoomsByBuildingID(building.ID)
+		rooms, _ := app.store.GetRoomsByBuildingID(building.ID)
 		for _, room := range rooms {
 			r := room
 			checkbox := widget.NewCheck(r.Name, nil)
@@ -373,12 +478,15 @@ func showEditKeyDialog(app *App, keyID int) {
 		numberEntry,
 		widget.NewLabel("Description:"),
 		descEntry,
-		widget.NewLabel("Quantité totale:"),
-		totalEntry,
-		widget.NewLabel("Quantité en réserve:"),
-		reserveEntry,
+		widget.NewLabel("Quantités:"),
+		quantitiesLabel,
 		widget.NewLabel("Emplacement de stockage:"),
-		storageEntry,
+		storageSelect,
+		widget.NewLabel("Durée d'emprunt par défaut (jours, 0 = sans date de retour):"),
+		loanDaysEntry,
+		widget.NewLabel("Passe-partout parent (ouvre aussi les salles de cette clé):"),
+		parentSelect,
+		custom.content(),
 		widget.NewSeparator(),
 		widget.NewLabel("Salles associées:"),
 		container.NewVScroll(roomsBox),
@@ -396,15 +504,15 @@ func showEditKeyDialog(app *App, keyID int) {
 			return
 		}
 
-		total, err := strconv.Atoi(totalEntry.Text)
-		if err != nil || total < 1 {
-			app.showError("Erreur", "La quantité totale doit être un nombre positif.")
+		loanDays, err := strconv.Atoi(loanDaysEntry.Text)
+		if err != nil || loanDays < 0 {
+			app.showError("Erreur", "La durée d'emprunt doit être un nombre de jours positif ou zéro.")
 			return
 		}
 
-		reserve, err := strconv.Atoi(reserveEntry.Text)
-		if err != nil || reserve < 0 {
-			app.showError("Erreur", "La quantité en réserve doit être un nombre positif ou zéro.")
+		customValues, err := custom.values()
+		if err != nil {
+			app.showError("Erreur", err.Error())
 			return
 		}
 
@@ -418,11 +526,12 @@ func showEditKeyDialog(app *App, keyID int) {
 
 		key.Number = numberEntry.Text
 		key.Description = descEntry.Text
-		key.QuantityTotal = total
-		key.QuantityReserve = reserve
-		key.StorageLocation = storageEntry.Text
+		key.StorageLocationID = selectedStorage()
+		key.DefaultLoanDays = loanDays
+		key.ParentKeyID = selectedParent()
+		key.CustomValues = customValues
 
-		err = db.UpdateKey(key, selectedRoomIDs)
+		err = app.store.UpdateKey(key, selectedRoomIDs)
 		if err != nil {
 			app.showError("Erreur", fmt.Sprintf("Erreur lors de la modification: %v", err))
 			return
@@ -443,14 +552,14 @@ func showEditKeyDialog(app *App, keyID int) {
 	)
 
 	dialog = widget.NewModalPopUp(content, app.window.Canvas())
-	dialog.Resize(fyne.NewSize(600, 600))
+	dialog.Resize(fyne.NewSize(600, 600+custom.height()))
 	dialog.Show()
 }
 
 // generateKeyStockReportPDF génère et enregistre le bilan du stock de clés
 func generateKeyStockReportPDF(app *App) {
 	// Récupérer toutes les clés
-	keys, err := db.GetAllKeys()
+	keys, err := app.store.GetAllKeys()
 	if err != nil {
 		app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération des clés: %v", err))
 		return
@@ -459,12 +568,19 @@ func generateKeyStockReportPDF(app *App) {
 	// Récupérer les comptes d'emprunts pour chaque clé
 	loanCounts := make(map[int]int)
 	for _, key := range keys {
-		count, _ := db.GetKeyActiveLoanCount(key.ID)
+		count, _ := app.store.GetKeyActiveLoanCount(key.ID)
 		loanCounts[key.ID] = count
 	}
 
+	// Champs personnalisés affichés en colonnes
+	columns, err := app.store.GetReportColumns(db.AuditEntityKey)
+	if err != nil {
+		app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération des champs personnalisés: %v", err))
+		return
+	}
+
 	// Générer le PDF
-	pdfData, err := pdf.GenerateKeyStockReport(keys, loanCounts)
+	pdfData, err := pdf.GenerateKeyStockReport(keys, loanCounts, columns, app.siteLabel())
 	if err != nil {
 		app.showError("Erreur", fmt.Sprintf("Erreur lors de la génération du PDF: %v", err))
 		return
@@ -3,7 +3,9 @@ package gui
 import (
 	"clefs/internal/db"
 	"clefs/internal/pdf"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"fyne.io/fyne/v2"
@@ -16,7 +18,7 @@ func createActiveLoansView(app *App) fyne.CanvasObject {
 	title := widget.NewLabelWithStyle("Emprunts en Cours", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
 
 	// Récupérer les emprunts actifs
-	loans, err := db.GetAllActiveLoans()
+	loans, err := app.store.GetAllActiveLoans()
 	if err != nil {
 		return container.NewVBox(
 			title,
@@ -80,7 +82,7 @@ func createLoansReportView(app *App) fyne.CanvasObject {
 	header := container.NewBorder(nil, nil, nil, buttonsContainer, title)
 
 	// Récupérer les emprunts actifs
-	loans, err := db.GetAllActiveLoans()
+	loans, err := app.store.GetAllActiveLoans()
 	if err != nil {
 		return container.NewVBox(
 			header,
@@ -173,23 +175,27 @@ func createKeyLoansAccordion(app *App, keyNumber string, keyDesc string, loans [
 			widget.NewLabel(fmt.Sprintf("   📅 Emprunté le: %s (%s)",
 				l.LoanDate.Format("02/01/2006"), durationText)),
 		)
+		if due := formatDueDate(l.Loan); due != "" {
+			dueLabel := widget.NewLabel("   " + due)
+			if l.IsOverdue(time.Now()) {
+				dueLabel.Importance = widget.DangerImportance
+			}
+			borrowerInfo.Add(dueLabel)
+		}
+		if l.DepositAmount > 0 {
+			borrowerInfo.Add(widget.NewLabel("   💶 " + describeDeposit(l)))
+		}
 
 		returnBtn := widget.NewButton("↩️ Retourner", func() {
-			app.showConfirm("Confirmer le retour",
-				fmt.Sprintf("Confirmer le retour de la clé %s empruntée par %s?", l.KeyNumber, l.BorrowerName),
-				func() {
-					err := db.ReturnLoan(l.ID)
-					if err != nil {
-						app.showError("Erreur", fmt.Sprintf("Erreur lors du retour: %v", err))
-						return
-					}
-					app.showSuccess("Clé retournée avec succès!")
-					app.showLoansReport()
-				})
+			confirmLoanReturn(app, l, app.showLoansReport)
 		})
 		returnBtn.Importance = widget.MediumImportance
 
-		borrowerRow := container.NewBorder(nil, nil, nil, returnBtn, borrowerInfo)
+		lossBtn := newDeclareLossButton(app, l, app.showLoansReport)
+		transferBtn := newTransferLoanButton(app, l, app.showLoansReport)
+		attachmentsBtn := newAttachmentsButton(app, db.AuditEntityLoan, l.ID, loanAttachmentsTitle(l), app.showLoansReport)
+
+		borrowerRow := container.NewBorder(nil, nil, nil, container.NewHBox(attachmentsBtn, lossBtn, transferBtn, returnBtn), borrowerInfo)
 		detailsContent.Add(borrowerRow)
 		detailsContent.Add(widget.NewSeparator())
 	}
@@ -208,7 +214,7 @@ func createKeyLoansAccordion(app *App, keyNumber string, keyDesc string, loans [
 // generateLoansReportPDF génère et enregistre le rapport des clés sorties
 func generateLoansReportPDF(app *App) {
 	// Récupérer les emprunts actifs
-	loans, err := db.GetAllActiveLoans()
+	loans, err := app.store.GetAllActiveLoans()
 	if err != nil {
 		app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération des emprunts: %v", err))
 		return
@@ -219,8 +225,20 @@ func generateLoansReportPDF(app *App) {
 		return
 	}
 
+	// Champs personnalisés des clés et des emprunteurs affichés en colonnes
+	keyColumns, err := app.store.GetReportColumns(db.AuditEntityKey)
+	if err != nil {
+		app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération des champs personnalisés: %v", err))
+		return
+	}
+	borrowerColumns, err := app.store.GetReportColumns(db.AuditEntityBorrower)
+	if err != nil {
+		app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération des champs personnalisés: %v", err))
+		return
+	}
+
 	// Générer le PDF
-	pdfData, err := pdf.GenerateLoansReportPDF(loans)
+	pdfData, err := pdf.GenerateLoansReportPDF(loans, keyColumns, borrowerColumns, app.siteLabel())
 	if err != nil {
 		app.showError("Erreur", fmt.Sprintf("Erreur lors de la génération du PDF: %v", err))
 		return
@@ -244,12 +262,18 @@ func generateBorrowerReceiptPDF(app *App, loans []db.LoanWithDetails) {
 	}
 
 	// Récupérer l'emprunteur
-	borrower, err := db.GetBorrowerByID(loans[0].BorrowerID)
+	borrower, err := app.store.GetBorrowerByID(loans[0].BorrowerID)
 	if err != nil {
 		app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération de l'emprunteur: %v", err))
 		return
 	}
 
+	// Les signatures recueillies à l'écran figurent sur le reçu
+	if err := app.store.LoadLoanSignatures(loans); err != nil {
+		app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération des signatures: %v", err))
+		return
+	}
+
 	// Générer le PDF
 	pdfData, err := pdf.GenerateBorrowerReceipt(borrower, loans)
 	if err != nil {
@@ -271,7 +295,7 @@ func generateBorrowerReceiptPDF(app *App, loans []db.LoanWithDetails) {
 // generateGlobalBorrowerReportPDF génère et enregistre le rapport global par emprunteur
 func generateGlobalBorrowerReportPDF(app *App) {
 	// Récupérer les emprunts actifs
-	loans, err := db.GetAllActiveLoans()
+	loans, err := app.store.GetAllActiveLoans()
 	if err != nil {
 		app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération des emprunts: %v", err))
 		return
@@ -289,7 +313,7 @@ func generateGlobalBorrowerReportPDF(app *App) {
 	}
 
 	// Générer le PDF
-	pdfData, err := pdf.GenerateGlobalBorrowerReport(loansByBorrower)
+	pdfData, err := pdf.GenerateGlobalBorrowerReport(loansByBorrower, app.siteLabel())
 	if err != nil {
 		app.showError("Erreur", fmt.Sprintf("Erreur lors de la génération du PDF: %v", err))
 		return
@@ -332,23 +356,27 @@ func createBorrowerAccordion(app *App, borrowerName string, loans []db.LoanWithD
 			widget.NewLabel(fmt.Sprintf("   📅 Emprunté le: %s (%s)",
 				l.LoanDate.Format("02/01/2006"), durationText)),
 		)
+		if due := formatDueDate(l.Loan); due != "" {
+			dueLabel := widget.NewLabel("   " + due)
+			if l.IsOverdue(time.Now()) {
+				dueLabel.Importance = widget.DangerImportance
+			}
+			keyInfo.Add(dueLabel)
+		}
+		if l.DepositAmount > 0 {
+			keyInfo.Add(widget.NewLabel("   💶 " + describeDeposit(l)))
+		}
 
 		returnBtn := widget.NewButton("↩️ Retourner", func() {
-			app.showConfirm("Confirmer le retour",
-				fmt.Sprintf("Confirmer le retour de la clé %s?", l.KeyNumber),
-				func() {
-					err := db.ReturnLoan(l.ID)
-					if err != nil {
-						app.showError("Erreur", fmt.Sprintf("Erreur lors du retour: %v", err))
-						return
-					}
-					app.showSuccess("Clé retournée avec succès!")
-					app.showActiveLoans()
-				})
+			confirmLoanReturn(app, l, app.showActiveLoans)
 		})
 		returnBtn.Importance = widget.MediumImportance
 
-		keyRow := container.NewBorder(nil, nil, nil, returnBtn, keyInfo)
+		lossBtn := newDeclareLossButton(app, l, app.showActiveLoans)
+		transferBtn := newTransferLoanButton(app, l, app.showActiveLoans)
+		attachmentsBtn := newAttachmentsButton(app, db.AuditEntityLoan, l.ID, loanAttachmentsTitle(l), app.showActiveLoans)
+
+		keyRow := container.NewBorder(nil, nil, nil, container.NewHBox(attachmentsBtn, lossBtn, transferBtn, returnBtn), keyInfo)
 		detailsContent.Add(keyRow)
 		detailsContent.Add(widget.NewSeparator())
 	}
@@ -371,3 +399,61 @@ func createBorrowerAccordion(app *App, borrowerName string, loans []db.LoanWithD
 
 	return accordion
 }
+
+// loanAttachmentsTitle décrit un emprunt dans le titre de ses pièces jointes
+func loanAttachmentsTitle(loan db.LoanWithDetails) string {
+	return fmt.Sprintf("Emprunt de la clé %s par %s du %s", loan.KeyNumber, loan.BorrowerName, loan.LoanDate.Format("02/01/2006"))
+}
+
+// formatDueDate décrit l'échéance d'un emprunt pour l'affichage (vide si aucune date prévue)
+func formatDueDate(loan db.Loan) string {
+	if loan.DueDate == nil {
+		return ""
+	}
+	if days := loan.DaysOverdue(time.Now()); days > 0 {
+		return fmt.Sprintf("⚠️ EN RETARD de %d jour(s) (retour prévu le %s)", days, loan.DueDate.Format("02/01/2006"))
+	}
+	return fmt.Sprintf("⏰ Retour prévu le %s", loan.DueDate.Format("02/01/2006"))
+}
+
+// parseDueDate lit une date de retour saisie au format JJ/MM/AAAA (nil si le champ est vide)
+func parseDueDate(text string) (*time.Time, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, nil
+	}
+
+	day, err := time.ParseInLocation("02/01/2006", text, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("la date de retour doit être au format JJ/MM/AAAA")
+	}
+
+	due := db.DueDateAfter(day, 0)
+	if due.Before(time.Now()) {
+		return nil, fmt.Errorf("la date de retour ne peut pas être dans le passé")
+	}
+	return &due, nil
+}
+
+// showLoanError affiche l'échec d'un emprunt. Une clé prise entre-temps par un autre poste
+// et une base verrouillée ont leur propre message ; taken indique que la liste des clés
+// affichée n'est plus à jour et doit être rechargée.
+func showLoanError(app *App, err error) (taken bool) {
+	if errors.Is(err, db.ErrNoCopyAvailable) {
+		app.showError("Clé prise à l'instant",
+			fmt.Sprintf("La clé vient d'être prise par un autre poste (%v). La liste des clés disponibles va être mise à jour.", err))
+		return true
+	}
+	showWriteError(app, "Erreur lors de la création de l'emprunt", err)
+	return false
+}
+
+// showWriteError affiche l'échec d'un enregistrement (context : « Erreur lors du retour »...).
+// Une base verrouillée par un autre poste a son propre message.
+func showWriteError(app *App, context string, err error) {
+	if errors.Is(err, db.ErrDatabaseBusy) {
+		app.showError("Base occupée", fmt.Sprintf("Un autre poste enregistre une opération : %v.", err))
+		return
+	}
+	app.showError("Erreur", fmt.Sprintf("%s: %v", context, err))
+}
@@ -0,0 +1,123 @@
+package gui
+
+import (
+	"clefs/internal/db"
+	"errors"
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// showLogin affiche l'écran de connexion, ou la création du premier
+// administrateur si la base ne contient encore aucun compte
+func (a *App) showLogin() {
+	count, err := a.store.CountOperators()
+	if err != nil {
+		a.window.SetContent(widget.NewLabel(fmt.Sprintf("Erreur lors de la lecture des comptes: %v", err)))
+		return
+	}
+
+	if count == 0 {
+		a.window.SetContent(createFirstAdminView(a))
+		return
+	}
+	a.window.SetContent(createLoginView(a))
+}
+
+// createLoginView crée l'écran de connexion
+func createLoginView(app *App) fyne.CanvasObject {
+	title := widget.NewLabelWithStyle("🔑 Gestionnaire de Clés", fyne.TextAlignCenter, fyne.TextStyle{Bold: true})
+
+	usernameEntry := widget.NewEntry()
+	usernameEntry.SetPlaceHolder("Identifiant")
+	passwordEntry := widget.NewPasswordEntry()
+	passwordEntry.SetPlaceHolder("Mot de passe")
+
+	errorLabel := widget.NewLabel("")
+	errorLabel.Importance = widget.DangerImportance
+
+	submit := func() {
+		operator, err := app.store.Authenticate(usernameEntry.Text, passwordEntry.Text)
+		if errors.Is(err, db.ErrInvalidCredentials) {
+			errorLabel.SetText("Identifiant ou mot de passe incorrect.")
+			passwordEntry.SetText("")
+			return
+		}
+		if err != nil {
+			errorLabel.SetText(fmt.Sprintf("Erreur: %v", err))
+			return
+		}
+		app.login(operator)
+	}
+	passwordEntry.OnSubmitted = func(string) { submit() }
+
+	loginBtn := widget.NewButton("Se connecter", submit)
+	loginBtn.Importance = widget.HighImportance
+
+	form := container.NewVBox(
+		title,
+		widget.NewSeparator(),
+		widget.NewLabel("Identifiant:"),
+		usernameEntry,
+		widget.NewLabel("Mot de passe:"),
+		passwordEntry,
+		errorLabel,
+		loginBtn,
+	)
+
+	return container.NewCenter(container.NewGridWrap(fyne.NewSize(400, 380), form))
+}
+
+// createFirstAdminView crée l'écran de création du premier compte administrateur
+func createFirstAdminView(app *App) fyne.CanvasObject {
+	title := widget.NewLabelWithStyle("Bienvenue !", fyne.TextAlignCenter, fyne.TextStyle{Bold: true})
+	info := widget.NewLabel("Créez le compte administrateur qui permettra ensuite d'ajouter les autres opérateurs.")
+	info.Wrapping = fyne.TextWrapWord
+
+	usernameEntry := widget.NewEntry()
+	usernameEntry.SetPlaceHolder("Identifiant")
+	nameEntry := widget.NewEntry()
+	nameEntry.SetPlaceHolder("Nom affiché (facultatif)")
+	passwordEntry := widget.NewPasswordEntry()
+	passwordEntry.SetPlaceHolder(fmt.Sprintf("Mot de passe (%d caractères minimum)", db.MinPasswordLength))
+	confirmEntry := widget.NewPasswordEntry()
+	confirmEntry.SetPlaceHolder("Confirmer le mot de passe")
+
+	createBtn := widget.NewButton("Créer le compte", func() {
+		if passwordEntry.Text != confirmEntry.Text {
+			app.showError("Erreur", "Les deux mots de passe ne correspondent pas.")
+			return
+		}
+
+		operator := &db.Operator{
+			Username:    usernameEntry.Text,
+			DisplayName: nameEntry.Text,
+			Role:        db.RoleAdmin,
+		}
+		if err := app.store.CreateOperator(operator, passwordEntry.Text); err != nil {
+			app.showError("Erreur", fmt.Sprintf("Erreur lors de la création du compte: %v", err))
+			return
+		}
+
+		app.login(operator)
+	})
+	createBtn.Importance = widget.HighImportance
+
+	form := container.NewVBox(
+		title,
+		info,
+		widget.NewSeparator(),
+		widget.NewLabel("Identifiant:"),
+		usernameEntry,
+		widget.NewLabel("Nom affiché:"),
+		nameEntry,
+		widget.NewLabel("Mot de passe:"),
+		passwordEntry,
+		confirmEntry,
+		createBtn,
+	)
+
+	return container.NewCenter(container.NewGridWrap(fyne.NewSize(450, 500), form))
+}
@@ -0,0 +1,163 @@
+package gui
+
+import (
+	"clefs/internal/db"
+	"clefs/internal/pdf"
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// newDeclareLossButton crée le bouton « Perdue/volée » d'un emprunt en cours ;
+// refresh réaffiche l'écran appelant une fois le rapport d'impact fermé
+func newDeclareLossButton(app *App, loan db.LoanWithDetails, refresh func()) *widget.Button {
+	btn := widget.NewButton("⚠️ Perdue/volée", func() {
+		showDeclareLossDialog(app, loan, refresh)
+	})
+	btn.Importance = widget.WarningImportance
+	return btn
+}
+
+// showDeclareLossDialog affiche la déclaration de perte ou de vol d'une clé empruntée
+func showDeclareLossDialog(app *App, loan db.LoanWithDetails, refresh func()) {
+	if !app.requireEdit() {
+		return
+	}
+
+	statusMap := map[string]db.CopyStatus{
+		db.CopyLost.Label():   db.CopyLost,
+		db.CopyStolen.Label(): db.CopyStolen,
+	}
+	statusRadio := widget.NewRadioGroup([]string{db.CopyLost.Label(), db.CopyStolen.Label()}, nil)
+	statusRadio.Horizontal = true
+	statusRadio.SetSelected(db.CopyLost.Label())
+
+	notesEntry := widget.NewMultiLineEntry()
+	notesEntry.SetPlaceHolder("Circonstances (date, lieu, dépôt de plainte...)")
+
+	key := loan.KeyNumber
+	if loan.CopyIdentifier != "" {
+		key += " (" + loan.CopyIdentifier + ")"
+	}
+	info := widget.NewLabel(fmt.Sprintf("La clé %s empruntée par %s sera retirée du stock et l'emprunt clos.", key, loan.BorrowerName))
+	info.Wrapping = fyne.TextWrapWord
+
+	var popupDialog *widget.PopUp
+
+	cancelBtn := widget.NewButton("Annuler", func() {
+		app.window.Canvas().Overlays().Remove(popupDialog)
+	})
+
+	declareBtn := widget.NewButton("Déclarer", func() {
+		err := app.store.DeclareLoanLost(loan.ID, statusMap[statusRadio.Selected], strings.TrimSpace(notesEntry.Text))
+		if err != nil {
+			showWriteError(app, "Erreur lors de la déclaration", err)
+			return
+		}
+
+		app.window.Canvas().Overlays().Remove(popupDialog)
+		showLossImpactReport(app, loan.ID, refresh)
+	})
+	declareBtn.Importance = widget.DangerImportance
+
+	content := container.NewVBox(
+		widget.NewLabelWithStyle("Déclarer une Clé Perdue ou Volée", fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
+		widget.NewSeparator(),
+		info,
+		statusRadio,
+		notesEntry,
+		widget.NewSeparator(),
+		container.NewHBox(cancelBtn, declareBtn),
+	)
+
+	popupDialog = widget.NewModalPopUp(content, app.window.Canvas())
+	popupDialog.Resize(fyne.NewSize(500, 0))
+	popupDialog.Show()
+}
+
+// showLossImpactReport affiche les salles exposées par la perte d'une clé et les
+// autres clés qui les ouvrent, avec l'export PDF du rapport
+func showLossImpactReport(app *App, loanID int, refresh func()) {
+	report, err := app.store.GetLossImpactReport(loanID)
+	if err != nil {
+		app.showError("Erreur", fmt.Sprintf("Erreur lors de la préparation du rapport: %v", err))
+		refresh()
+		return
+	}
+	loan := report.Loan
+
+	summary := widget.NewLabel(fmt.Sprintf("Clé %s (%s) déclarée %s. %d salle(s) exposée(s), %d autre(s) exemplaire(s) de cette clé encore en service.",
+		loan.KeyNumber, loan.KeyDescription, strings.ToLower(loan.LossStatus.Label()), len(report.Rooms), report.OtherCopies))
+	summary.Wrapping = fyne.TextWrapWord
+
+	roomsBox := container.NewVBox()
+	if len(report.Rooms) == 0 {
+		roomsBox.Add(widget.NewLabel("Cette clé n'ouvre aucune salle enregistrée."))
+	}
+	for _, room := range report.Rooms {
+		roomText := fmt.Sprintf("🚪 %s - %s", room.Building.Name, room.Name)
+		if room.Access == db.AccessInherited {
+			roomText += fmt.Sprintf(" (via %s)", room.AccessVia)
+		}
+
+		var keyTexts []string
+		for _, k := range room.Keys {
+			if k.Access == db.AccessInherited {
+				keyTexts = append(keyTexts, fmt.Sprintf("%s (passe-partout)", k.Number))
+			} else {
+				keyTexts = append(keyTexts, k.Number)
+			}
+		}
+		keysText := "Aucune autre clé"
+		if len(keyTexts) > 0 {
+			keysText = "Autres clés : " + strings.Join(keyTexts, ", ")
+		}
+
+		roomsBox.Add(widget.NewLabelWithStyle(roomText, fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+		keysLabel := widget.NewLabel("   " + keysText)
+		keysLabel.Wrapping = fyne.TextWrapWord
+		roomsBox.Add(keysLabel)
+	}
+
+	roomsScroll := container.NewVScroll(roomsBox)
+	roomsScroll.SetMinSize(fyne.NewSize(600, 300))
+
+	var dialog *widget.PopUp
+
+	pdfBtn := widget.NewButton("📄 Générer le PDF", func() {
+		pdfData, err := pdf.GenerateLossImpactReport(report)
+		if err != nil {
+			app.showError("Erreur", fmt.Sprintf("Erreur lors de la génération du PDF: %v", err))
+			return
+		}
+		filename := pdf.GenerateFilename("rapport_perte", loan.ID)
+		filepath, err := pdf.SavePDF(filename, pdfData)
+		if err != nil {
+			app.showError("Erreur", fmt.Sprintf("Erreur lors de l'enregistrement: %v", err))
+			return
+		}
+		app.showSuccess(fmt.Sprintf("✅ Rapport enregistré : %s", filepath))
+	})
+	pdfBtn.Importance = widget.HighImportance
+
+	closeBtn := widget.NewButton("Fermer", func() {
+		app.window.Canvas().Overlays().Remove(dialog)
+		refresh()
+	})
+
+	content := container.NewVBox(
+		widget.NewLabelWithStyle("Rapport d'Impact de Sécurité", fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
+		widget.NewSeparator(),
+		summary,
+		roomsScroll,
+		widget.NewSeparator(),
+		container.NewHBox(closeBtn, pdfBtn),
+	)
+
+	dialog = widget.NewModalPopUp(content, app.window.Canvas())
+	dialog.Resize(fyne.NewSize(700, 500))
+	dialog.Show()
+}
@@ -0,0 +1,206 @@
+package gui
+
+import (
+	"clefs/internal/db"
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// createOperatorsView crée la vue de gestion des comptes opérateurs (administrateurs uniquement)
+func createOperatorsView(app *App) fyne.CanvasObject {
+	title := widget.NewLabelWithStyle("Gérer les Opérateurs", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+
+	addBtn := widget.NewButton("➕ Ajouter un Opérateur", func() {
+		showOperatorDialog(app, nil)
+	})
+	addBtn.Importance = widget.HighImportance
+
+	header := container.NewBorder(nil, nil, nil, addBtn, title)
+
+	operators, err := app.store.GetAllOperators()
+	if err != nil {
+		return container.NewVBox(
+			header,
+			widget.NewLabel(fmt.Sprintf("Erreur: %v", err)),
+		)
+	}
+
+	list := container.NewVBox()
+	for i, operator := range operators {
+		o := operator // Capture
+
+		status := "✅ Actif"
+		if !o.Active {
+			status = "⛔ Désactivé"
+		}
+
+		info := container.NewVBox(
+			widget.NewLabelWithStyle(fmt.Sprintf("%s (%s)", o.Name(), o.Username), fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+			widget.NewLabel(fmt.Sprintf("Rôle: %s | %s", o.Role.Label(), status)),
+		)
+
+		editBtn := widget.NewButton("✏️ Modifier", func() {
+			showOperatorDialog(app, &o)
+		})
+		passwordBtn := widget.NewButton("🔑 Mot de passe", func() {
+			showOperatorPasswordDialog(app, o)
+		})
+
+		list.Add(container.NewBorder(nil, nil, nil, container.NewHBox(editBtn, passwordBtn), info))
+		if i < len(operators)-1 {
+			list.Add(widget.NewSeparator())
+		}
+	}
+
+	return container.NewBorder(header, nil, nil, nil, container.NewVScroll(list))
+}
+
+// showOperatorDialog affiche la création (operator nil) ou la modification d'un opérateur
+func showOperatorDialog(app *App, operator *db.Operator) {
+	if !app.requireAdmin() {
+		return
+	}
+
+	usernameEntry := widget.NewEntry()
+	usernameEntry.SetPlaceHolder("Identifiant de connexion")
+	nameEntry := widget.NewEntry()
+	nameEntry.SetPlaceHolder("Nom affiché (facultatif)")
+	passwordEntry := widget.NewPasswordEntry()
+	passwordEntry.SetPlaceHolder(fmt.Sprintf("Mot de passe (%d caractères minimum)", db.MinPasswordLength))
+
+	roleOptions := make([]string, len(db.Roles))
+	roleMap := make(map[string]db.Role)
+	for i, role := range db.Roles {
+		roleOptions[i] = role.Label()
+		roleMap[role.Label()] = role
+	}
+	roleSelect := widget.NewSelect(roleOptions, nil)
+	roleSelect.SetSelected(db.RoleManager.Label())
+
+	activeCheck := widget.NewCheck("Compte actif", nil)
+	activeCheck.SetChecked(true)
+
+	titleText := "Ajouter un Opérateur"
+	form := container.NewVBox(
+		widget.NewLabel("Identifiant:"),
+		usernameEntry,
+		widget.NewLabel("Nom affiché:"),
+		nameEntry,
+		widget.NewLabel("Rôle:"),
+		roleSelect,
+	)
+
+	if operator == nil {
+		form.Add(widget.NewLabel("Mot de passe:"))
+		form.Add(passwordEntry)
+	} else {
+		titleText = "Modifier l'Opérateur"
+		usernameEntry.SetText(operator.Username)
+		usernameEntry.Disable()
+		nameEntry.SetText(operator.DisplayName)
+		roleSelect.SetSelected(operator.Role.Label())
+		activeCheck.SetChecked(operator.Active)
+		form.Add(activeCheck)
+	}
+
+	var popupDialog *widget.PopUp
+
+	cancelBtn := widget.NewButton("Annuler", func() {
+		app.window.Canvas().Overlays().Remove(popupDialog)
+	})
+
+	saveBtn := widget.NewButton("Enregistrer", func() {
+		var err error
+		if operator == nil {
+			err = app.store.CreateOperator(&db.Operator{
+				Username:    usernameEntry.Text,
+				DisplayName: nameEntry.Text,
+				Role:        roleMap[roleSelect.Selected],
+			}, passwordEntry.Text)
+		} else {
+			updated := *operator
+			updated.DisplayName = nameEntry.Text
+			updated.Role = roleMap[roleSelect.Selected]
+			updated.Active = activeCheck.Checked
+			err = app.store.UpdateOperator(&updated)
+			// Les droits de l'opérateur connecté suivent sa fiche
+			if err == nil && app.operator != nil && app.operator.ID == updated.ID {
+				app.operator = &updated
+			}
+		}
+		if err != nil {
+			app.showError("Erreur", fmt.Sprintf("Erreur lors de l'enregistrement: %v", err))
+			return
+		}
+
+		app.window.Canvas().Overlays().Remove(popupDialog)
+		app.showSuccess("Opérateur enregistré avec succès!")
+		if app.isAdmin() {
+			app.showOperators()
+		} else {
+			app.showDashboard()
+		}
+	})
+	saveBtn.Importance = widget.HighImportance
+
+	content := container.NewVBox(
+		widget.NewLabelWithStyle(titleText, fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
+		widget.NewSeparator(),
+		form,
+		widget.NewSeparator(),
+		container.NewHBox(cancelBtn, saveBtn),
+	)
+
+	popupDialog = widget.NewModalPopUp(content, app.window.Canvas())
+	popupDialog.Resize(fyne.NewSize(450, 0))
+	popupDialog.Show()
+}
+
+// showOperatorPasswordDialog affiche le changement de mot de passe d'un opérateur
+func showOperatorPasswordDialog(app *App, operator db.Operator) {
+	if !app.requireAdmin() {
+		return
+	}
+
+	passwordEntry := widget.NewPasswordEntry()
+	passwordEntry.SetPlaceHolder(fmt.Sprintf("Nouveau mot de passe (%d caractères minimum)", db.MinPasswordLength))
+	confirmEntry := widget.NewPasswordEntry()
+	confirmEntry.SetPlaceHolder("Confirmer le mot de passe")
+
+	var popupDialog *widget.PopUp
+
+	cancelBtn := widget.NewButton("Annuler", func() {
+		app.window.Canvas().Overlays().Remove(popupDialog)
+	})
+
+	saveBtn := widget.NewButton("Enregistrer", func() {
+		if passwordEntry.Text != confirmEntry.Text {
+			app.showError("Erreur", "Les deux mots de passe ne correspondent pas.")
+			return
+		}
+		if err := app.store.SetOperatorPassword(operator.ID, passwordEntry.Text); err != nil {
+			app.showError("Erreur", fmt.Sprintf("Erreur lors du changement de mot de passe: %v", err))
+			return
+		}
+
+		app.window.Canvas().Overlays().Remove(popupDialog)
+		app.showSuccess("Mot de passe modifié avec succès!")
+	})
+	saveBtn.Importance = widget.HighImportance
+
+	content := container.NewVBox(
+		widget.NewLabelWithStyle(fmt.Sprintf("Mot de passe de %s", operator.Name()), fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
+		widget.NewSeparator(),
+		passwordEntry,
+		confirmEntry,
+		widget.NewSeparator(),
+		container.NewHBox(cancelBtn, saveBtn),
+	)
+
+	popupDialog = widget.NewModalPopUp(content, app.window.Canvas())
+	popupDialog.Resize(fyne.NewSize(450, 0))
+	popupDialog.Show()
+}
@@ -1,8 +1,10 @@
 package gui
 
 import (
+	"bytes"
 	"clefs/internal/db"
 	"clefs/internal/pdf"
+	"encoding/base64"
 	"fmt"
 	"log"
 	"os"
@@ -11,6 +13,7 @@ import (
 	"time"
 
 	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/storage"
@@ -111,6 +114,12 @@ func (rv *ReceiptViewer) generateHTMLReceipt() string {
 				margin-left: auto;
 				margin-right: auto;
 			}
+			.signature-image {
+				display: block;
+				width: 300px;
+				margin: 20px auto 0;
+				border-bottom: 1px solid #333;
+			}
 			@media print {
 				body {
 					margin: 0;
@@ -165,7 +174,7 @@ func (rv *ReceiptViewer) generateHTMLReceipt() string {
 			<div class="info-row">
 				<span class="info-label">Description:</span>
 				<span class="info-value">%s</span>
-			</div>
+			</div>%s
 		</div>
 
 		<div class="signature-box">
@@ -173,9 +182,9 @@ func (rv *ReceiptViewer) generateHTMLReceipt() string {
 			<p style="font-size: 12px; color: #666;">
 				Je reconnais avoir emprunté la clé mentionnée ci-dessus et m'engage à la restituer en bon état.
 			</p>
-			<div class="signature-line"></div>
+			%s
 			<p style="text-align: center; font-size: 12px; margin-top: 10px;">Signature de l'emprunteur</p>
-		</div>
+		</div>%s
 
 		<div class="footer">
 			<p>Document généré le %s à %s</p>
@@ -191,6 +200,9 @@ func (rv *ReceiptViewer) generateHTMLReceipt() string {
 		rv.loan.BorrowerEmail,
 		rv.loan.KeyNumber,
 		rv.loan.KeyDescription,
+		receiptDepositRow(rv.loan),
+		receiptSignature(rv.loan.Signature),
+		receiptReturnSignature(rv.loan),
 		time.Now().Format("02/01/2006"),
 		time.Now().Format("15:04"),
 	)
@@ -223,8 +235,17 @@ func (rv *ReceiptViewer) Show() {
 	}()
 
 	// Créer un widget HTML custom
+	receiptBox := container.NewVBox(widget.NewLabel(rv.getSimplifiedHTML()))
+	if rv.loan.Signature != nil {
+		// Signature recueillie à l'écran lors de la remise
+		signature := canvas.NewImageFromReader(bytes.NewReader(rv.loan.Signature), "signature.png")
+		signature.FillMode = canvas.ImageFillContain
+		signature.SetMinSize(fyne.NewSize(300, 105))
+		receiptBox.Add(widget.NewLabel("Signée à l'écran:"))
+		receiptBox.Add(signature)
+	}
 	htmlDisplay := widget.NewCard("", "",
-		container.NewScroll(widget.NewLabel(rv.getSimplifiedHTML())),
+		container.NewScroll(receiptBox),
 	)
 	htmlDisplay.Resize(fyne.NewSize(600, 500))
 
@@ -448,7 +469,7 @@ func (rv *ReceiptViewer) openInBrowser() {
 // ShowReceiptForLoan affiche le reçu pour un emprunt donné
 func ShowReceiptForLoan(app *App, loanID int) {
 	// Récupérer les détails de l'emprunt
-	loan, err := db.GetLoanByID(loanID)
+	loan, err := app.store.GetLoanByID(loanID)
 	if err != nil {
 		app.showError("Erreur", fmt.Sprintf("Impossible de charger l'emprunt: %v", err))
 		return
@@ -479,6 +500,44 @@ func GenerateAndSaveReceipt(app *App, loan *db.LoanWithDetails) {
 	app.showSuccess(fmt.Sprintf("✅ Reçu enregistré : %s", filepath))
 }
 
+// receiptDepositRow retourne la ligne HTML de la caution versée (vide sans caution)
+func receiptDepositRow(loan *db.LoanWithDetails) string {
+	if loan.DepositAmount <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(`
+			<div class="info-row">
+				<span class="info-label">Caution:</span>
+				<span class="info-value">%s (%s)</span>
+			</div>`, db.FormatDepositAmount(loan.DepositAmount), loan.DepositMethod.Label())
+}
+
+// receiptSignature retourne l'image HTML d'une signature recueillie à l'écran,
+// ou la ligne à signer à la main si l'emprunteur n'a pas signé
+func receiptSignature(signature []byte) string {
+	if signature == nil {
+		return `<div class="signature-line"></div>`
+	}
+	return fmt.Sprintf(`<img class="signature-image" src="data:image/png;base64,%s" alt="Signature de l'emprunteur">`,
+		base64.StdEncoding.EncodeToString(signature))
+}
+
+// receiptReturnSignature retourne le bloc HTML de la signature au retour de la clé
+// (vide si la clé n'a pas été rendue avec une signature)
+func receiptReturnSignature(loan *db.LoanWithDetails) string {
+	if loan.ReturnSignature == nil || loan.ReturnDate == nil {
+		return ""
+	}
+	return fmt.Sprintf(`
+
+		<div class="signature-box">
+			<div class="section-title">✍️ RETOUR DE LA CLÉ</div>
+			<p style="font-size: 12px; color: #666;">Clé rendue le %s.</p>
+			%s
+			<p style="text-align: center; font-size: 12px; margin-top: 10px;">Signature de l'emprunteur</p>
+		</div>`, loan.ReturnDate.Format("02/01/2006 à 15:04"), receiptSignature(loan.ReturnSignature))
+}
+
 // GenerateReceiptHTML génère le HTML pour un reçu d'emprunt
 func GenerateReceiptHTML(loan *db.LoanWithDetails) string {
 	return fmt.Sprintf(`<!DOCTYPE html>
@@ -560,6 +619,12 @@ func GenerateReceiptHTML(loan *db.LoanWithDetails) string {
 			margin-left: auto;
 			margin-right: auto;
 		}
+		.signature-image {
+			display: block;
+			width: 300px;
+			margin: 20px auto 0;
+			border-bottom: 1px solid #333;
+		}
 		.footer {
 			margin-top: 40px;
 			padding-top: 20px;
@@ -630,7 +695,7 @@ func GenerateReceiptHTML(loan *db.LoanWithDetails) string {
 			<div class="info-row">
 				<span class="info-label">Description:</span>
 				<span class="info-value">%s</span>
-			</div>
+			</div>%s
 		</div>
 
 		<div class="signature-box">
@@ -638,9 +703,9 @@ func GenerateReceiptHTML(loan *db.LoanWithDetails) string {
 			<p style="font-size: 14px; color: #666; text-align: center;">
 				Je reconnais avoir emprunté la clé mentionnée ci-dessus et m'engage à la restituer en bon état.
 			</p>
-			<div class="signature-line"></div>
+			%s
 			<p style="text-align: center; font-size: 12px; margin-top: 10px; color: #666;">Signature de l'emprunteur</p>
-		</div>
+		</div>%s
 
 		<div class="footer">
 			<p>Document généré le %s à %s</p>
@@ -656,6 +721,9 @@ func GenerateReceiptHTML(loan *db.LoanWithDetails) string {
 		loan.BorrowerEmail,
 		loan.KeyNumber,
 		loan.KeyDescription,
+		receiptDepositRow(loan),
+		receiptSignature(loan.Signature),
+		receiptReturnSignature(loan),
 		time.Now().Format("02/01/2006"),
 		time.Now().Format("15:04"),
 	)
@@ -0,0 +1,260 @@
+package gui
+
+import (
+	"clefs/internal/db"
+	"fmt"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// createReservationsView crée la vue des réservations de clés
+func createReservationsView(app *App) fyne.CanvasObject {
+	title := widget.NewLabelWithStyle("Réservations de Clés", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+
+	listBox := container.NewVBox()
+
+	// load recharge la liste ; closed ajoute les réservations converties ou annulées
+	var load func(closed bool)
+	load = func(closed bool) {
+		listBox.Objects = nil
+
+		reservations, err := app.store.GetReservations(!closed)
+		if err != nil {
+			listBox.Add(widget.NewLabel(fmt.Sprintf("Erreur: %v", err)))
+			listBox.Refresh()
+			return
+		}
+		if len(reservations) == 0 {
+			listBox.Add(widget.NewLabel("Aucune réservation."))
+		}
+		for _, r := range reservations {
+			listBox.Add(createReservationRow(app, r))
+			listBox.Add(widget.NewSeparator())
+		}
+		listBox.Refresh()
+	}
+
+	closedCheck := widget.NewCheck("Afficher les réservations closes", func(checked bool) {
+		load(checked)
+	})
+
+	addBtn := widget.NewButton("➕ Nouvelle Réservation", func() {
+		showAddReservationDialog(app)
+	})
+	addBtn.Importance = widget.HighImportance
+	if !app.canEdit() {
+		addBtn.Hide()
+	}
+
+	header := container.NewBorder(nil, nil, title, addBtn)
+
+	load(false)
+
+	return container.NewBorder(
+		container.NewVBox(header, closedCheck, widget.NewSeparator()),
+		nil,
+		nil,
+		nil,
+		container.NewVScroll(listBox),
+	)
+}
+
+// createReservationRow crée une ligne de réservation avec ses actions
+func createReservationRow(app *App, r db.Reservation) fyne.CanvasObject {
+	keyLabel := widget.NewLabelWithStyle(
+		fmt.Sprintf("🔑 %s - %s", r.KeyNumber, r.KeyDescription),
+		fyne.TextAlignLeading,
+		fyne.TextStyle{Bold: true},
+	)
+
+	now := time.Now()
+	status := r.Status.Label()
+	if r.Expired(now) {
+		status = "Expirée"
+	}
+	text := fmt.Sprintf("👤 %s - du %s au %s - %s", r.BorrowerName,
+		r.StartDate.Format("02/01/2006"), r.EndDate.Format("02/01/2006"), status)
+	if r.CreatedBy != "" {
+		text += " (" + r.CreatedBy + ")"
+	}
+	if r.Notes != "" {
+		text += " - " + r.Notes
+	}
+	detailsLabel := widget.NewLabel("   " + text)
+	if r.Expired(now) {
+		detailsLabel.Importance = widget.DangerImportance
+	}
+
+	actions := container.NewHBox()
+	if r.Status == db.ReservationPending && app.canEdit() {
+		if !r.Expired(now) {
+			convertBtn := widget.NewButton("✅ Remettre la clé", func() {
+				convertReservation(app, r)
+			})
+			convertBtn.Importance = widget.HighImportance
+			actions.Add(convertBtn)
+		}
+
+		cancelBtn := widget.NewButton("✖ Annuler", func() {
+			app.showConfirm("Annuler la réservation",
+				fmt.Sprintf("Annuler la réservation de la clé %s par %s ?", r.KeyNumber, r.BorrowerName),
+				func() {
+					if err := app.store.CancelReservation(r.ID); err != nil {
+						app.showError("Erreur", fmt.Sprintf("Erreur lors de l'annulation: %v", err))
+						return
+					}
+					app.showReservations()
+				})
+		})
+		cancelBtn.Importance = widget.DangerImportance
+		actions.Add(cancelBtn)
+	}
+
+	return container.NewBorder(nil, nil, nil, actions, container.NewVBox(keyLabel, detailsLabel))
+}
+
+// convertReservation transforme une réservation en emprunt quand l'emprunteur se présente
+func convertReservation(app *App, r db.Reservation) {
+	if !app.requireEdit() {
+		return
+	}
+	if now := time.Now(); now.Before(r.StartDate) {
+		app.showConfirm("Remise anticipée",
+			fmt.Sprintf("La réservation commence le %s. Remettre la clé %s à %s dès maintenant ?",
+				r.StartDate.Format("02/01/2006"), r.KeyNumber, r.BorrowerName),
+			func() {
+				doConvertReservation(app, r)
+			})
+		return
+	}
+	doConvertReservation(app, r)
+}
+
+// doConvertReservation enregistre l'emprunt issu d'une réservation
+func doConvertReservation(app *App, r db.Reservation) {
+	if _, err := app.store.ConvertReservationToLoan(r.ID); err != nil {
+		if showLoanError(app, err) {
+			app.showReservations()
+		}
+		return
+	}
+	app.showSuccess(fmt.Sprintf("✅ Clé %s remise à %s, à rendre le %s", r.KeyNumber, r.BorrowerName, r.EndDate.Format("02/01/2006")))
+	app.showReservations()
+}
+
+// showAddReservationDialog affiche le formulaire de réservation d'une clé
+func showAddReservationDialog(app *App) {
+	if !app.requireEdit() {
+		return
+	}
+
+	keys, err := app.store.GetAllKeys()
+	if err != nil {
+		app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération des clés: %v", err))
+		return
+	}
+	keyOptions := make([]string, len(keys))
+	keyMap := make(map[string]int)
+	for i, k := range keys {
+		keyOptions[i] = fmt.Sprintf("%s - %s", k.Number, k.Description)
+		keyMap[keyOptions[i]] = k.ID
+	}
+	keySelect := widget.NewSelect(keyOptions, nil)
+	keySelect.PlaceHolder = "Clé..."
+
+	borrowers, err := app.store.GetAllBorrowers()
+	if err != nil {
+		app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération des emprunteurs: %v", err))
+		return
+	}
+	var borrowerOptions []string
+	borrowerMap := make(map[string]int)
+	for _, b := range borrowers {
+		if b.Status == db.BorrowerDeparted {
+			continue
+		}
+		label := borrowerOptionLabel(b)
+		borrowerOptions = append(borrowerOptions, label)
+		borrowerMap[label] = b.ID
+	}
+	borrowerSelect := widget.NewSelect(borrowerOptions, nil)
+	borrowerSelect.PlaceHolder = "Emprunteur..."
+
+	startEntry := widget.NewEntry()
+	startEntry.SetPlaceHolder("Du (JJ/MM/AAAA)")
+	endEntry := widget.NewEntry()
+	endEntry.SetPlaceHolder("Au (JJ/MM/AAAA, vide = même jour)")
+	notesEntry := widget.NewEntry()
+	notesEntry.SetPlaceHolder("Notes (entreprise, intervention...)")
+
+	var popupDialog *widget.PopUp
+
+	cancelBtn := widget.NewButton("Annuler", func() {
+		app.window.Canvas().Overlays().Remove(popupDialog)
+	})
+
+	saveBtn := widget.NewButton("Réserver", func() {
+		keyID, ok := keyMap[keySelect.Selected]
+		if !ok {
+			app.showError("Erreur", "Veuillez sélectionner une clé.")
+			return
+		}
+		borrowerID, ok := borrowerMap[borrowerSelect.Selected]
+		if !ok {
+			app.showError("Erreur", "Veuillez sélectionner un emprunteur.")
+			return
+		}
+		start, err := parseHistoryDate(startEntry.Text, false)
+		if err != nil {
+			app.showError("Erreur", err.Error())
+			return
+		}
+		if start == nil {
+			app.showError("Erreur", "Veuillez indiquer la date de début.")
+			return
+		}
+		end, err := parseHistoryDate(endEntry.Text, true)
+		if err != nil {
+			app.showError("Erreur", err.Error())
+			return
+		}
+		if end == nil {
+			sameDay := db.DueDateAfter(*start, 0)
+			end = &sameDay
+		}
+
+		reservation := &db.Reservation{
+			KeyID:      keyID,
+			BorrowerID: borrowerID,
+			StartDate:  *start,
+			EndDate:    *end,
+			Notes:      notesEntry.Text,
+		}
+		if err := app.store.CreateReservation(reservation); err != nil {
+			app.showError("Erreur", fmt.Sprintf("Erreur lors de la réservation: %v", err))
+			return
+		}
+
+		app.window.Canvas().Overlays().Remove(popupDialog)
+		app.showReservations()
+	})
+	saveBtn.Importance = widget.HighImportance
+
+	content := container.NewVBox(
+		widget.NewLabelWithStyle("Nouvelle Réservation", fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
+		widget.NewSeparator(),
+		keySelect,
+		borrowerSelect,
+		container.NewGridWithColumns(2, startEntry, endEntry),
+		notesEntry,
+		widget.NewSeparator(),
+		container.NewHBox(cancelBtn, saveBtn),
+	)
+
+	popupDialog = widget.NewModalPopUp(content, app.window.Canvas())
+	popupDialog.Resize(fyne.NewSize(600, 0))
+	popupDialog.Show()
+}
@@ -21,7 +21,7 @@ func createRoomsView(app *App) fyne.CanvasObject {
 	header := container.NewBorder(nil, nil, nil, addBtn, title)
 
 	// Récupérer les bâtiments avec leurs salles
-	buildings, err := db.GetAllBuildings()
+	buildings, err := app.store.GetAllBuildings()
 	if err != nil {
 		return container.NewVBox(
 			header,
@@ -43,7 +43,103 @@ func createRoomsView(app *App) fyne.CanvasObject {
 	return content
 }
 
-// createRoomsListView crée la liste des salles groupées par bâtiment
+// noFloor et noZone sont les options des salles sans étage ou sans zone
+const (
+	noFloor = "Sans étage"
+	noZone  = "Sans zone"
+)
+
+// floorGroupLabel retourne l'intitulé d'un groupe de salles d'un même étage,
+// vide lorsque le bâtiment n'a pas d'étages
+func floorGroupLabel(groups []db.FloorRooms, group db.FloorRooms) string {
+	switch {
+	case group.Floor != "":
+		return "🏬 " + group.Floor
+	case len(groups) > 1:
+		return "🏬 " + noFloor
+	}
+	return ""
+}
+
+// roomZoneSuffix retourne la mention de la zone d'une salle, vide pour une salle sans zone
+func roomZoneSuffix(r db.Room) string {
+	if r.Zone == "" {
+		return ""
+	}
+	return " 🧭 " + r.Zone
+}
+
+// roomPlacementFields regroupe le choix facultatif de l'étage et de la zone d'une salle
+type roomPlacementFields struct {
+	app    *App
+	floor  *widget.Select
+	zone   *widget.Select
+	floors map[string]int
+	zones  map[string]int
+}
+
+// newRoomPlacementFields crée les listes d'étage et de zone ; les zones sont celles du site sélectionné
+func newRoomPlacementFields(app *App, zoneID *int) *roomPlacementFields {
+	f := &roomPlacementFields{
+		app:    app,
+		floor:  widget.NewSelect([]string{noFloor}, nil),
+		floors: make(map[string]int),
+		zones:  make(map[string]int),
+	}
+	f.floor.SetSelected(noFloor)
+
+	zoneOptions := []string{noZone}
+	selected := noZone
+	zones, err := app.store.GetZones()
+	if err != nil {
+		app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération des zones: %v", err))
+	}
+	for _, z := range zones {
+		zoneOptions = append(zoneOptions, z.Name)
+		f.zones[z.Name] = z.ID
+		if zoneID != nil && *zoneID == z.ID {
+			selected = z.Name
+		}
+	}
+	f.zone = widget.NewSelect(zoneOptions, nil)
+	f.zone.SetSelected(selected)
+	return f
+}
+
+// loadFloors propose les étages du bâtiment choisi et sélectionne l'étage current
+func (f *roomPlacementFields) loadFloors(buildingID int, current *int) {
+	floors, err := f.app.store.GetFloorsByBuildingID(buildingID)
+	if err != nil {
+		f.app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération des étages: %v", err))
+		return
+	}
+
+	options := []string{noFloor}
+	selected := noFloor
+	f.floors = make(map[string]int)
+	for _, fl := range floors {
+		options = append(options, fl.Name)
+		f.floors[fl.Name] = fl.ID
+		if current != nil && *current == fl.ID {
+			selected = fl.Name
+		}
+	}
+	f.floor.Options = options
+	f.floor.SetSelected(selected)
+}
+
+// apply recopie l'étage et la zone choisis dans r
+func (f *roomPlacementFields) apply(r *db.Room) {
+	r.FloorID, r.ZoneID = nil, nil
+	if id, ok := f.floors[f.floor.Selected]; ok {
+		r.FloorID = &id
+	}
+	if id, ok := f.zones[f.zone.Selected]; ok {
+		r.ZoneID = &id
+	}
+}
+
+// createRoomsListView crée la liste des salles groupées par bâtiment, puis par étage
 func createRoomsListView(buildings []db.Building, app *App) fyne.CanvasObject {
 	list := container.NewVBox()
 
@@ -55,21 +151,32 @@ func createRoomsListView(buildings []db.Building, app *App) fyne.CanvasObject {
 		list.Add(buildingLabel)
 
 		// Récupérer les salles du bâtiment
-		rooms, err := db.GetRoomsByBuildingID(b.ID)
+		rooms, err := app.store.GetRoomsByBuildingID(b.ID)
 		if err != nil {
 			continue
 		}
 
 		if len(rooms) == 0 {
 			list.Add(widget.NewLabel("  Aucune salle"))
-		} else {
-			for _, room := range rooms {
+		}
+
+		// Les salles sont regroupées par étage lorsque le bâtiment en a
+		groups := db.GroupRoomsByFloor(rooms)
+		for _, group := range groups {
+			indent := "  "
+			if heading := floorGroupLabel(groups, group); heading != "" {
+				list.Add(widget.NewLabelWithStyle("  "+heading, fyne.TextAlignLeading, fyne.TextStyle{Italic: true}))
+				indent = "      "
+			}
+
+			for _, room := range group.Rooms {
 				r := room // Capture
 
-				roomText := fmt.Sprintf("  %s", r.Name)
+				roomText := indent + r.Name
 				if r.Type != "" {
 					roomText += fmt.Sprintf(" (%s)", r.Type)
 				}
+				roomText += roomZoneSuffix(r)
 
 				roomLabel := widget.NewLabel(roomText)
 
@@ -78,29 +185,28 @@ func createRoomsListView(buildings []db.Building, app *App) fyne.CanvasObject {
 				})
 				editBtn.Importance = widget.LowImportance
 
-				deleteBtn := widget.NewButton("🗑️", func() {
-					// Vérifier si des clés sont associées
-					keys, _ := db.GetKeysForRoom(r.ID)
-					if len(keys) > 0 {
-						app.showError("Impossible de supprimer", "Cette salle est associée à des clés.")
+				archiveBtn := widget.NewButton("📦", func() {
+					if !app.requireEdit() {
 						return
 					}
-
-					app.showConfirm("Confirmer la suppression",
-						fmt.Sprintf("Êtes-vous sûr de vouloir supprimer la salle %s?", r.Name),
+					app.showConfirm("Confirmer l'archivage",
+						fmt.Sprintf("Archiver la salle %s ?\nElle n'apparaîtra plus dans les listes ni dans le plan de clés.", r.Name),
 						func() {
-							err := db.DeleteRoom(r.ID)
+							err := app.store.ArchiveRecord(db.AuditEntityRoom, r.ID)
 							if err != nil {
-								app.showError("Erreur", fmt.Sprintf("Erreur lors de la suppression: %v", err))
+								app.showError("Erreur", fmt.Sprintf("Impossible d'archiver la salle: %v", err))
 								return
 							}
-							app.showSuccess("Salle supprimée avec succès!")
+							app.showSuccess("Salle archivée avec succès!")
 							app.showRooms()
 						})
 				})
-				deleteBtn.Importance = widget.DangerImportance
+				archiveBtn.Importance = widget.DangerImportance
+
+				attachmentsBtn := newAttachmentsButton(app, db.AuditEntityRoom, r.ID, "Salle "+r.Name, app.showRooms)
+				attachmentsBtn.Importance = widget.LowImportance
 
-				actions := container.NewHBox(editBtn, deleteBtn)
+				actions := container.NewHBox(editBtn, attachmentsBtn, archiveBtn)
 
 				roomRow := container.NewBorder(nil, nil, nil, actions, roomLabel)
 				list.Add(roomRow)
@@ -115,8 +221,12 @@ func createRoomsListView(buildings []db.Building, app *App) fyne.CanvasObject {
 
 // showAddRoomDialog affiche la boîte de dialogue pour ajouter une salle
 func showAddRoomDialog(app *App) {
+	if !app.requireEdit() {
+		return
+	}
+
 	// Récupérer les bâtiments
-	buildings, err := db.GetAllBuildings()
+	buildings, err := app.store.GetAllBuildings()
 	if err != nil {
 		app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération des bâtiments: %v", err))
 		return
@@ -141,7 +251,11 @@ func showAddRoomDialog(app *App) {
 		buildingMap[b.Name] = b.ID
 	}
 
-	buildingSelect := widget.NewSelect(buildingOptions, nil)
+	placement := newRoomPlacementFields(app, nil)
+	custom := newCustomFieldInputs(app, db.AuditEntityRoom, 0)
+	buildingSelect := widget.NewSelect(buildingOptions, func(selected string) {
+		placement.loadFloors(buildingMap[selected], nil)
+	})
 	if len(buildingOptions) > 0 {
 		buildingSelect.SetSelected(buildingOptions[0])
 	}
@@ -153,6 +267,9 @@ func showAddRoomDialog(app *App) {
 		typeEntry,
 		widget.NewLabel("Bâtiment:"),
 		buildingSelect,
+		widget.NewLabel("Étage et zone (facultatifs):"),
+		container.NewGridWithColumns(2, placement.floor, placement.zone),
+		custom.content(),
 	)
 
 	var popupDialog *widget.PopUp
@@ -172,13 +289,21 @@ func showAddRoomDialog(app *App) {
 			return
 		}
 
+		customValues, err := custom.values()
+		if err != nil {
+			app.showError("Erreur", err.Error())
+			return
+		}
+
 		room := &db.Room{
-			Name:       nameEntry.Text,
-			Type:       typeEntry.Text,
-			BuildingID: buildingMap[buildingSelect.Selected],
+			Name:         nameEntry.Text,
+			Type:         typeEntry.Text,
+			BuildingID:   buildingMap[buildingSelect.Selected],
+			CustomValues: customValues,
 		}
+		placement.apply(room)
 
-		err := db.CreateRoom(room)
+		err = app.store.CreateRoom(room)
 		if err != nil {
 			app.showError("Erreur", fmt.Sprintf("Erreur lors de la création: %v", err))
 			return
@@ -199,14 +324,18 @@ func showAddRoomDialog(app *App) {
 	)
 
 	popupDialog = widget.NewModalPopUp(content, app.window.Canvas())
-	popupDialog.Resize(fyne.NewSize(400, 300))
+	popupDialog.Resize(fyne.NewSize(450, 380+custom.height()))
 	popupDialog.Show()
 }
 
 // showEditRoomDialog affiche la boîte de dialogue pour modifier une salle
 func showEditRoomDialog(app *App, roomID int) {
+	if !app.requireEdit() {
+		return
+	}
+
 	// Récupérer la salle
-	rooms, err := db.GetAllRooms()
+	rooms, err := app.store.GetAllRooms()
 	if err != nil {
 		app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération de la salle: %v", err))
 		return
@@ -226,7 +355,7 @@ func showEditRoomDialog(app *App, roomID int) {
 	}
 
 	// Récupérer les bâtiments
-	buildings, err := db.GetAllBuildings()
+	buildings, err := app.store.GetAllBuildings()
 	if err != nil {
 		app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération des bâtiments: %v", err))
 		return
@@ -251,8 +380,16 @@ func showEditRoomDialog(app *App, roomID int) {
 		}
 	}
 
+	placement := newRoomPlacementFields(app, room.ZoneID)
+	placement.loadFloors(room.BuildingID, room.FloorID)
+	custom := newCustomFieldInputs(app, db.AuditEntityRoom, room.ID)
+
+	// Changer de bâtiment propose les étages du nouveau bâtiment
 	buildingSelect := widget.NewSelect(buildingOptions, nil)
 	buildingSelect.SetSelected(currentBuildingName)
+	buildingSelect.OnChanged = func(selected string) {
+		placement.loadFloors(buildingMap[selected], nil)
+	}
 
 	form := container.NewVBox(
 		widget.NewLabel("Nom de la salle:"),
@@ -261,6 +398,9 @@ func showEditRoomDialog(app *App, roomID int) {
 		typeEntry,
 		widget.NewLabel("Bâtiment:"),
 		buildingSelect,
+		widget.NewLabel("Étage et zone (facultatifs):"),
+		container.NewGridWithColumns(2, placement.floor, placement.zone),
+		custom.content(),
 	)
 
 	var popupDialog *widget.PopUp
@@ -280,11 +420,19 @@ func showEditRoomDialog(app *App, roomID int) {
 			return
 		}
 
+		customValues, err := custom.values()
+		if err != nil {
+			app.showError("Erreur", err.Error())
+			return
+		}
+
 		room.Name = nameEntry.Text
 		room.Type = typeEntry.Text
 		room.BuildingID = buildingMap[buildingSelect.Selected]
+		room.CustomValues = customValues
+		placement.apply(room)
 
-		err := db.UpdateRoom(room)
+		err = app.store.UpdateRoom(room)
 		if err != nil {
 			app.showError("Erreur", fmt.Sprintf("Erreur lors de la modification: %v", err))
 			return
@@ -305,6 +453,6 @@ func showEditRoomDialog(app *App, roomID int) {
 	)
 
 	popupDialog = widget.NewModalPopUp(content, app.window.Canvas())
-	popupDialog.Resize(fyne.NewSize(400, 300))
+	popupDialog.Resize(fyne.NewSize(450, 380+custom.height()))
 	popupDialog.Show()
 }
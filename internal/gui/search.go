@@ -0,0 +1,107 @@
+package gui
+
+import (
+	"clefs/internal/db"
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// searchResultsPerGroup borne le nombre de résultats affichés par catégorie
+const searchResultsPerGroup = 20
+
+// searchGroups donne le titre de chaque catégorie de la recherche globale
+var searchGroups = map[string]string{
+	db.AuditEntityKey:      "🔑 Clés",
+	db.AuditEntityRoom:     "🚪 Salles",
+	db.AuditEntityBuilding: "🏢 Bâtiments",
+	db.AuditEntityBorrower: "👤 Emprunteurs",
+}
+
+// createSearchBar crée la barre de recherche globale affichée au-dessus de chaque écran.
+// Le champ est conservé d'un écran à l'autre pour garder la dernière recherche.
+func (a *App) createSearchBar() fyne.CanvasObject {
+	if a.searchEntry == nil {
+		a.searchEntry = widget.NewEntry()
+		a.searchEntry.SetPlaceHolder("🔍 Rechercher une clé, une salle, un bâtiment ou un emprunteur...")
+		a.searchEntry.OnSubmitted = func(query string) {
+			a.showSearch(query)
+		}
+	}
+
+	searchBtn := widget.NewButton("Rechercher", func() {
+		a.showSearch(a.searchEntry.Text)
+	})
+
+	return container.NewPadded(container.NewBorder(nil, nil, nil, searchBtn, a.searchEntry))
+}
+
+// createSearchView crée la vue des résultats de la recherche globale, groupés par catégorie
+func createSearchView(app *App, query string) fyne.CanvasObject {
+	title := widget.NewLabelWithStyle(fmt.Sprintf("Résultats pour « %s »", strings.TrimSpace(query)),
+		fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+
+	results, err := app.store.Search(query, searchResultsPerGroup)
+	if err != nil {
+		return container.NewVBox(title, widget.NewLabel(fmt.Sprintf("Erreur: %v", err)))
+	}
+
+	listBox := container.NewVBox()
+	if len(results) == 0 {
+		listBox.Add(widget.NewLabel("Aucun résultat. Vérifiez l'orthographe ou saisissez le début d'un mot."))
+	}
+
+	var group *fyne.Container
+	for i, r := range results {
+		if i == 0 || results[i-1].Entity != r.Entity {
+			group = container.NewVBox()
+			listBox.Add(widget.NewCard(searchGroups[r.Entity], "", group))
+		}
+
+		text := r.Title
+		if r.Detail != "" {
+			text += " - " + r.Detail
+		}
+		result := r
+		openBtn := widget.NewButton("Ouvrir", func() {
+			openSearchResult(app, result)
+		})
+		group.Add(container.NewBorder(nil, nil, nil, openBtn, widget.NewLabel(text)))
+	}
+
+	return container.NewBorder(
+		container.NewVBox(title, widget.NewSeparator()),
+		nil,
+		nil,
+		nil,
+		container.NewVScroll(listBox),
+	)
+}
+
+// openSearchResult affiche l'écran du résultat choisi puis sa fiche ; les fiches
+// des salles, bâtiments et emprunteurs ne s'ouvrent qu'en modification
+func openSearchResult(app *App, r db.SearchResult) {
+	switch r.Entity {
+	case db.AuditEntityKey:
+		app.showKeys()
+		showKeyDetails(app, r.ID)
+	case db.AuditEntityRoom:
+		app.showRooms()
+		if app.canEdit() {
+			showEditRoomDialog(app, r.ID)
+		}
+	case db.AuditEntityBuilding:
+		app.showBuildings()
+		if app.canEdit() {
+			showEditBuildingDialog(app, r.ID)
+		}
+	case db.AuditEntityBorrower:
+		app.showBorrowers()
+		if app.canEdit() {
+			showEditBorrowerDialog(app, r.ID)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package gui
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// signaturePadSize est la taille de la zone de signature à l'écran
+var signaturePadSize = fyne.NewSize(460, 160)
+
+// signatureInk est la couleur du tracé, sur fond blanc quel que soit le thème
+var signatureInk = color.NRGBA{R: 20, G: 30, B: 90, A: 255}
+
+// signaturePad est une zone où l'emprunteur signe à la souris ou au doigt.
+// Chaque trait est la suite des positions parcourues pendant un glisser.
+type signaturePad struct {
+	widget.BaseWidget
+	strokes [][]fyne.Position
+	drawing bool
+}
+
+// newSignaturePad crée une zone de signature vide
+func newSignaturePad() *signaturePad {
+	pad := &signaturePad{}
+	pad.ExtendBaseWidget(pad)
+	return pad
+}
+
+// Dragged prolonge le trait en cours, ou en commence un nouveau
+func (p *signaturePad) Dragged(e *fyne.DragEvent) {
+	if !p.drawing {
+		p.strokes = append(p.strokes, []fyne.Position{p.clamp(e.Position.Subtract(e.Dragged))})
+		p.drawing = true
+	}
+	last := len(p.strokes) - 1
+	p.strokes[last] = append(p.strokes[last], p.clamp(e.Position))
+	p.Refresh()
+}
+
+// DragEnd termine le trait en cours
+func (p *signaturePad) DragEnd() {
+	p.drawing = false
+}
+
+// Tapped dessine un point (celui d'un « i » par exemple)
+func (p *signaturePad) Tapped(e *fyne.PointEvent) {
+	pos := p.clamp(e.Position)
+	p.strokes = append(p.strokes, []fyne.Position{pos, pos.AddXY(1, 0)})
+	p.Refresh()
+}
+
+// clamp ramène une position dans la zone de signature
+func (p *signaturePad) clamp(pos fyne.Position) fyne.Position {
+	size := p.Size()
+	pos.X = fyne.Max(0, fyne.Min(pos.X, size.Width))
+	pos.Y = fyne.Max(0, fyne.Min(pos.Y, size.Height))
+	return pos
+}
+
+// clear efface la signature
+func (p *signaturePad) clear() {
+	p.strokes = nil
+	p.drawing = false
+	p.Refresh()
+}
+
+// isEmpty indique si rien n'a été signé
+func (p *signaturePad) isEmpty() bool {
+	return len(p.strokes) == 0
+}
+
+// encode retourne la signature en image PNG, à deux fois la taille de la zone pour
+// rester nette à l'impression (nil si rien n'a été signé)
+func (p *signaturePad) encode() ([]byte, error) {
+	if p.isEmpty() {
+		return nil, nil
+	}
+
+	const scale = 2
+	const radius = 2 // Demi-épaisseur du trait, en pixels de l'image
+	size := p.Size()
+	width, height := int(size.Width*scale), int(size.Height*scale)
+	img := image.NewPaletted(image.Rect(0, 0, width, height), color.Palette{color.White, signatureInk})
+
+	// Tamponner un disque tous les pixels le long de chaque segment
+	dot := func(cx, cy float32) {
+		for y := int(cy) - radius; y <= int(cy)+radius; y++ {
+			for x := int(cx) - radius; x <= int(cx)+radius; x++ {
+				dx, dy := float32(x)-cx, float32(y)-cy
+				if dx*dx+dy*dy <= radius*radius {
+					img.SetColorIndex(x, y, 1)
+				}
+			}
+		}
+	}
+	for _, stroke := range p.strokes {
+		for i := 1; i < len(stroke); i++ {
+			from, to := stroke[i-1], stroke[i]
+			dx, dy := (to.X-from.X)*scale, (to.Y-from.Y)*scale
+			steps := int(fyne.Max(fyne.Max(dx, -dx), fyne.Max(dy, -dy))) + 1
+			for s := 0; s <= steps; s++ {
+				t := float32(s) / float32(steps)
+				dot(from.X*scale+dx*t, from.Y*scale+dy*t)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// CreateRenderer crée l'affichage de la zone de signature
+func (p *signaturePad) CreateRenderer() fyne.WidgetRenderer {
+	background := canvas.NewRectangle(color.White)
+	background.StrokeColor = color.NRGBA{R: 150, G: 150, B: 150, A: 255}
+	background.StrokeWidth = 1
+
+	baseline := canvas.NewLine(color.NRGBA{R: 200, G: 200, B: 200, A: 255})
+	hint := canvas.NewText("✍️ Signez ici avec la souris ou le doigt", color.NRGBA{R: 150, G: 150, B: 150, A: 255})
+
+	r := &signaturePadRenderer{pad: p, background: background, baseline: baseline, hint: hint}
+	r.Refresh()
+	return r
+}
+
+// signaturePadRenderer affiche le fond, la ligne de signature et les traits d'un signaturePad
+type signaturePadRenderer struct {
+	pad        *signaturePad
+	background *canvas.Rectangle
+	baseline   *canvas.Line
+	hint       *canvas.Text
+	lines      []fyne.CanvasObject
+}
+
+func (r *signaturePadRenderer) Layout(size fyne.Size) {
+	r.background.Resize(size)
+	r.baseline.Position1 = fyne.NewPos(20, size.Height-30)
+	r.baseline.Position2 = fyne.NewPos(size.Width-20, size.Height-30)
+	hintSize := r.hint.MinSize()
+	r.hint.Move(fyne.NewPos((size.Width-hintSize.Width)/2, (size.Height-hintSize.Height)/2))
+}
+
+func (r *signaturePadRenderer) MinSize() fyne.Size {
+	return signaturePadSize
+}
+
+func (r *signaturePadRenderer) Refresh() {
+	r.lines = r.lines[:0]
+	for _, stroke := range r.pad.strokes {
+		for i := 1; i < len(stroke); i++ {
+			line := canvas.NewLine(signatureInk)
+			line.StrokeWidth = 2.5
+			line.Position1 = stroke[i-1]
+			line.Position2 = stroke[i]
+			r.lines = append(r.lines, line)
+		}
+	}
+	r.hint.Hidden = !r.pad.isEmpty()
+	r.Layout(r.pad.Size())
+	canvas.Refresh(r.pad)
+}
+
+func (r *signaturePadRenderer) Objects() []fyne.CanvasObject {
+	return append([]fyne.CanvasObject{r.background, r.baseline, r.hint}, r.lines...)
+}
+
+func (r *signaturePadRenderer) Destroy() {}
+
+// newSignatureBox crée le cadre de signature d'un formulaire, avec son bouton « Effacer »
+func newSignatureBox(pad *signaturePad) fyne.CanvasObject {
+	clearBtn := widget.NewButton("🧽 Effacer", func() {
+		pad.clear()
+	})
+	note := widget.NewLabel("Facultatif : sans signature, le reçu garde une ligne à signer à la main.")
+	note.Wrapping = fyne.TextWrapWord
+
+	return container.NewVBox(
+		widget.NewLabelWithStyle("✍️ Signature de l'emprunteur:", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		container.NewCenter(pad),
+		container.NewBorder(nil, nil, nil, clearBtn, note),
+	)
+}
+
+// showSignatureDialog fait signer l'emprunteur avant une remise ou un retour de clé ;
+// onConfirm reçoit la signature (nil si l'emprunteur n'a pas signé)
+func showSignatureDialog(app *App, title, message, confirmLabel string, onConfirm func(signature []byte)) {
+	pad := newSignaturePad()
+
+	info := widget.NewLabel(message)
+	info.Wrapping = fyne.TextWrapWord
+
+	var popupDialog *widget.PopUp
+
+	cancelBtn := widget.NewButton("Annuler", func() {
+		app.window.Canvas().Overlays().Remove(popupDialog)
+	})
+
+	confirmBtn := widget.NewButton(confirmLabel, func() {
+		signature, err := pad.encode()
+		if err != nil {
+			app.showError("Erreur", fmt.Sprintf("Impossible d'enregistrer la signature: %v", err))
+			return
+		}
+		app.window.Canvas().Overlays().Remove(popupDialog)
+		onConfirm(signature)
+	})
+	confirmBtn.Importance = widget.HighImportance
+
+	content := container.NewVBox(
+		widget.NewLabelWithStyle(title, fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
+		widget.NewSeparator(),
+		info,
+		newSignatureBox(pad),
+		widget.NewSeparator(),
+		container.NewHBox(cancelBtn, confirmBtn),
+	)
+
+	popupDialog = widget.NewModalPopUp(content, app.window.Canvas())
+	popupDialog.Resize(fyne.NewSize(520, 0))
+	popupDialog.Show()
+}
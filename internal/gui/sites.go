@@ -0,0 +1,222 @@
+package gui
+
+import (
+	"clefs/internal/db"
+	"clefs/internal/pdf"
+	"fmt"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// createSitesView crée la vue de gestion des sites (réservée aux administrateurs)
+func createSitesView(app *App) fyne.CanvasObject {
+	title := widget.NewLabelWithStyle("🏫 Gérer les Sites", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	info := widget.NewLabel("Chaque site regroupe ses bâtiments, ses clés et ses emprunteurs. " +
+		"Le sélecteur du menu choisit le site affiché dans toutes les listes et tous les rapports.")
+	info.Wrapping = fyne.TextWrapWord
+
+	addBtn := widget.NewButton("➕ Ajouter un Site", func() {
+		showSiteDialog(app, nil)
+	})
+	addBtn.Importance = widget.HighImportance
+
+	header := container.NewBorder(nil, nil, nil, addBtn, title)
+
+	summaries, err := app.store.GetSiteSummaries()
+	if err != nil {
+		return container.NewVBox(header, widget.NewLabel(fmt.Sprintf("Erreur: %v", err)))
+	}
+
+	list := container.NewVBox()
+	for i, summary := range summaries {
+		sum := summary // Capture
+
+		siteInfo := container.NewVBox(
+			widget.NewLabelWithStyle(sum.Name, fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+			widget.NewLabel(fmt.Sprintf("%d bâtiment(s), %d clé(s), %d emprunteur(s)", sum.Buildings, sum.Keys, sum.Borrowers)),
+		)
+
+		editBtn := widget.NewButton("✏️ Renommer", func() {
+			site := sum.Site
+			showSiteDialog(app, &site)
+		})
+
+		deleteBtn := widget.NewButton("🗑️ Supprimer", func() {
+			if !app.requireAdmin() {
+				return
+			}
+			app.showConfirm("Confirmer la suppression",
+				fmt.Sprintf("Supprimer le site %s ? Seul un site vide peut être supprimé.", sum.Name),
+				func() {
+					if err := app.store.DeleteSite(sum.ID); err != nil {
+						app.showError("Erreur", fmt.Sprintf("Impossible de supprimer le site: %v", err))
+						return
+					}
+					if app.store.Site() == sum.ID {
+						app.store.SetSite(0)
+					}
+					app.showSuccess("Site supprimé avec succès!")
+					app.showSites()
+				})
+		})
+		deleteBtn.Importance = widget.DangerImportance
+
+		list.Add(container.NewBorder(nil, nil, nil, container.NewHBox(editBtn, deleteBtn), siteInfo))
+		if i < len(summaries)-1 {
+			list.Add(widget.NewSeparator())
+		}
+	}
+
+	return container.NewBorder(
+		container.NewVBox(header, info, widget.NewSeparator()),
+		nil,
+		nil,
+		nil,
+		container.NewVScroll(list),
+	)
+}
+
+// showSiteDialog affiche la boîte de dialogue de création (site nil) ou de renommage d'un site
+func showSiteDialog(app *App, site *db.Site) {
+	if !app.requireAdmin() {
+		return
+	}
+
+	dialogTitle := "Ajouter un Site"
+	nameEntry := widget.NewEntry()
+	nameEntry.SetPlaceHolder("Nom du site (ex: Campus Nord)")
+	if site != nil {
+		dialogTitle = "Renommer le Site"
+		nameEntry.SetText(site.Name)
+	}
+
+	var popupDialog *widget.PopUp
+
+	cancelBtn := widget.NewButton("Annuler", func() {
+		app.window.Canvas().Overlays().Remove(popupDialog)
+	})
+
+	saveBtn := widget.NewButton("Enregistrer", func() {
+		var err error
+		if site == nil {
+			err = app.store.CreateSite(&db.Site{Name: nameEntry.Text})
+		} else {
+			site.Name = nameEntry.Text
+			err = app.store.UpdateSite(site)
+		}
+		if err != nil {
+			app.showError("Erreur", fmt.Sprintf("Erreur lors de l'enregistrement: %v", err))
+			return
+		}
+
+		app.window.Canvas().Overlays().Remove(popupDialog)
+		app.showSuccess("Site enregistré avec succès!")
+		app.showSites()
+	})
+	saveBtn.Importance = widget.HighImportance
+
+	content := container.NewVBox(
+		widget.NewLabelWithStyle(dialogTitle, fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
+		widget.NewSeparator(),
+		widget.NewLabel("Nom du site:"),
+		nameEntry,
+		widget.NewSeparator(),
+		container.NewHBox(cancelBtn, saveBtn),
+	)
+
+	popupDialog = widget.NewModalPopUp(content, app.window.Canvas())
+	popupDialog.Resize(fyne.NewSize(400, 200))
+	popupDialog.Show()
+}
+
+// createSitesReportView crée le rapport consolidé de la direction : les chiffres clés de
+// chaque site, quel que soit le site sélectionné, et leur total
+func createSitesReportView(app *App) fyne.CanvasObject {
+	title := widget.NewLabelWithStyle("🌐 Vue Multi-Sites", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+
+	exportBtn := widget.NewButton("📄 Exporter PDF", func() {
+		generateSitesReportPDF(app)
+	})
+	exportBtn.Importance = widget.HighImportance
+
+	header := container.NewBorder(nil, nil, nil, exportBtn, title)
+
+	summaries, err := app.store.GetSiteSummaries()
+	if err != nil {
+		return container.NewVBox(header, widget.NewLabel(fmt.Sprintf("Erreur: %v", err)))
+	}
+
+	infoLabel := widget.NewLabel(fmt.Sprintf("Généré le %s | %d site(s)",
+		time.Now().Format("02/01/2006 à 15:04"), len(summaries)))
+
+	var total db.SiteSummary
+	cards := container.NewVBox()
+	for _, sum := range summaries {
+		cards.Add(createSiteSummaryCard(sum.Name, sum))
+
+		total.Buildings += sum.Buildings
+		total.Rooms += sum.Rooms
+		total.Keys += sum.Keys
+		total.Copies += sum.Copies
+		total.Borrowers += sum.Borrowers
+		total.ActiveLoans += sum.ActiveLoans
+		total.OverdueLoans += sum.OverdueLoans
+		total.HeldDeposits += sum.HeldDeposits
+	}
+	if len(summaries) > 1 {
+		cards.Add(createSiteSummaryCard("Total tous sites", total))
+	}
+
+	return container.NewBorder(
+		container.NewVBox(header, infoLabel, widget.NewSeparator()),
+		nil,
+		nil,
+		nil,
+		container.NewVScroll(cards),
+	)
+}
+
+// createSiteSummaryCard crée la carte des chiffres clés d'un site
+func createSiteSummaryCard(title string, sum db.SiteSummary) fyne.CanvasObject {
+	overdue := fmt.Sprintf("%d", sum.OverdueLoans)
+	if sum.OverdueLoans > 0 {
+		overdue = "⚠️ " + overdue
+	}
+	grid := container.NewGridWithColumns(4,
+		widget.NewLabel(fmt.Sprintf("🏢 Bâtiments : %d", sum.Buildings)),
+		widget.NewLabel(fmt.Sprintf("🚪 Salles : %d", sum.Rooms)),
+		widget.NewLabel(fmt.Sprintf("🔑 Clés : %d (%d exemplaires)", sum.Keys, sum.Copies)),
+		widget.NewLabel(fmt.Sprintf("👥 Emprunteurs : %d", sum.Borrowers)),
+		widget.NewLabel(fmt.Sprintf("📋 Emprunts en cours : %d", sum.ActiveLoans)),
+		widget.NewLabel("⏰ En retard : "+overdue),
+		widget.NewLabel("💶 Cautions détenues : "+db.FormatDepositAmount(sum.HeldDeposits)),
+	)
+	return widget.NewCard(title, "", grid)
+}
+
+// generateSitesReportPDF génère et enregistre le rapport consolidé des sites
+func generateSitesReportPDF(app *App) {
+	summaries, err := app.store.GetSiteSummaries()
+	if err != nil {
+		app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération des sites: %v", err))
+		return
+	}
+
+	pdfData, err := pdf.GenerateSitesReport(summaries)
+	if err != nil {
+		app.showError("Erreur", fmt.Sprintf("Erreur lors de la génération du PDF: %v", err))
+		return
+	}
+
+	filename := pdf.GenerateFilename("rapport_sites", 0)
+	filepath, err := pdf.SavePDF(filename, pdfData)
+	if err != nil {
+		app.showError("Erreur", fmt.Sprintf("Erreur lors de l'enregistrement: %v", err))
+		return
+	}
+
+	app.showSuccess(fmt.Sprintf("✅ Rapport enregistré : %s", filepath))
+}
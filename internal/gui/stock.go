@@ -0,0 +1,205 @@
+package gui
+
+import (
+	"clefs/internal/db"
+	"clefs/internal/pdf"
+	"fmt"
+	"strconv"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// describeStockMovement résume un mouvement du registre de stock sur une ligne
+func describeStockMovement(m db.StockMovement) string {
+	text := fmt.Sprintf("%s - %s x%d", m.MovedAt.Format("02/01/2006 15:04"), m.Type.Label(), m.Quantity)
+	if m.CopyIdentifier != "" {
+		text += fmt.Sprintf(" (%s)", m.CopyIdentifier)
+	}
+	text += " : " + m.Reason
+	if m.Operator != "" {
+		text += " - " + m.Operator
+	}
+	return text
+}
+
+// showKeyStockDialog affiche l'historique de stock d'une clé et permet d'y inscrire un mouvement
+func showKeyStockDialog(app *App, key db.Key) {
+	var dialog *widget.PopUp
+
+	// reopen rafraîchit la boîte de dialogue après un mouvement
+	reopen := func() {
+		app.window.Canvas().Overlays().Remove(dialog)
+		if refreshed, err := app.store.GetKeyByID(key.ID); err == nil {
+			key = *refreshed
+		}
+		showKeyStockDialog(app, key)
+	}
+
+	movements, err := app.store.GetStockMovements(db.StockMovementFilter{KeyID: key.ID})
+	if err != nil {
+		app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération du registre de stock: %v", err))
+		return
+	}
+
+	// Solde après chaque mouvement, le plus récent en haut
+	listBox := container.NewVBox()
+	if len(movements) == 0 {
+		listBox.Add(widget.NewLabel("Aucun mouvement de stock pour cette clé."))
+	}
+	total, reserve := 0, 0
+	rows := make([]fyne.CanvasObject, len(movements))
+	for i, m := range movements {
+		dt, dr := m.Type.Deltas()
+		total += dt * m.Quantity
+		reserve += dr * m.Quantity
+
+		label := widget.NewLabel(describeStockMovement(m))
+		label.Wrapping = fyne.TextWrapWord
+		balance := widget.NewLabel(fmt.Sprintf("Stock : %d (réserve %d)", total, reserve))
+		rows[len(movements)-1-i] = container.NewBorder(nil, nil, nil, balance, label)
+	}
+	for _, row := range rows {
+		listBox.Add(row)
+		listBox.Add(widget.NewSeparator())
+	}
+
+	listScroll := container.NewVScroll(listBox)
+	listScroll.SetMinSize(fyne.NewSize(650, 280))
+
+	// Saisie d'un mouvement
+	movementOptions := make([]string, len(db.ManualStockMovements))
+	movementMap := make(map[string]db.StockMovementType)
+	for i, m := range db.ManualStockMovements {
+		movementOptions[i] = m.Label()
+		movementMap[movementOptions[i]] = m
+	}
+	movementSelect := widget.NewSelect(movementOptions, nil)
+	movementSelect.PlaceHolder = "Mouvement..."
+
+	quantityEntry := widget.NewEntry()
+	quantityEntry.SetText("1")
+
+	reasonEntry := widget.NewEntry()
+	reasonEntry.SetPlaceHolder("Motif (ex: commande n° 2024-118)")
+
+	addBtn := widget.NewButton("➕ Mouvement", func() {
+		if !app.requireEdit() {
+			return
+		}
+		movement, ok := movementMap[movementSelect.Selected]
+		if !ok {
+			app.showError("Erreur", "Veuillez sélectionner un type de mouvement.")
+			return
+		}
+		quantity, err := strconv.Atoi(quantityEntry.Text)
+		if err != nil {
+			app.showError("Erreur", "La quantité doit être un nombre.")
+			return
+		}
+		if err := app.store.RecordStockMovement(key.ID, movement, quantity, reasonEntry.Text); err != nil {
+			app.showError("Erreur", fmt.Sprintf("Erreur lors de l'enregistrement du mouvement: %v", err))
+			return
+		}
+		reopen()
+	})
+	addBtn.Importance = widget.HighImportance
+
+	closeBtn := widget.NewButton("Fermer", func() {
+		app.window.Canvas().Overlays().Remove(dialog)
+		app.showKeys()
+	})
+
+	content := container.NewVBox(
+		widget.NewLabelWithStyle(fmt.Sprintf("Stock de la clé %s", key.Number), fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
+		widget.NewLabel(fmt.Sprintf("%d exemplaire(s) au total, dont %d en réserve", key.QuantityTotal, key.QuantityReserve)),
+		widget.NewSeparator(),
+		listScroll,
+		widget.NewSeparator(),
+		widget.NewLabelWithStyle("Nouveau mouvement:", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		container.NewBorder(nil, nil, nil, addBtn, container.NewGridWithColumns(3, movementSelect, quantityEntry, reasonEntry)),
+		widget.NewSeparator(),
+		container.NewHBox(closeBtn),
+	)
+
+	dialog = widget.NewModalPopUp(content, app.window.Canvas())
+	dialog.Resize(fyne.NewSize(750, 550))
+	dialog.Show()
+}
+
+// showStockMovementsReportDialog demande la période du rapport des mouvements de stock
+func showStockMovementsReportDialog(app *App) {
+	var dialog *widget.PopUp
+
+	fromEntry := widget.NewEntry()
+	fromEntry.SetPlaceHolder("Du (JJ/MM/AAAA)")
+	toEntry := widget.NewEntry()
+	toEntry.SetPlaceHolder("Au (JJ/MM/AAAA)")
+
+	generateBtn := widget.NewButton("📄 Générer le PDF", func() {
+		from, err := parseHistoryDate(fromEntry.Text, false)
+		if err != nil {
+			app.showError("Erreur", err.Error())
+			return
+		}
+		to, err := parseHistoryDate(toEntry.Text, true)
+		if err != nil {
+			app.showError("Erreur", err.Error())
+			return
+		}
+		app.window.Canvas().Overlays().Remove(dialog)
+		generateStockMovementsReportPDF(app, db.StockMovementFilter{From: from, To: to})
+	})
+	generateBtn.Importance = widget.HighImportance
+
+	cancelBtn := widget.NewButton("Annuler", func() {
+		app.window.Canvas().Overlays().Remove(dialog)
+	})
+
+	content := container.NewVBox(
+		widget.NewLabelWithStyle("📒 Rapport des Mouvements de Stock", fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
+		widget.NewLabel("Laissez les dates vides pour inclure tout le registre."),
+		container.NewGridWithColumns(2, fromEntry, toEntry),
+		widget.NewSeparator(),
+		container.NewHBox(cancelBtn, generateBtn),
+	)
+
+	dialog = widget.NewModalPopUp(content, app.window.Canvas())
+	dialog.Resize(fyne.NewSize(450, 200))
+	dialog.Show()
+}
+
+// generateStockMovementsReportPDF génère le rapport des mouvements de stock de la période
+func generateStockMovementsReportPDF(app *App, filter db.StockMovementFilter) {
+	movements, err := app.store.GetStockMovements(filter)
+	if err != nil {
+		app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération du registre de stock: %v", err))
+		return
+	}
+
+	period := "Tout le registre"
+	switch {
+	case filter.From != nil && filter.To != nil:
+		period = fmt.Sprintf("Du %s au %s", filter.From.Format("02/01/2006"), filter.To.Format("02/01/2006"))
+	case filter.From != nil:
+		period = fmt.Sprintf("À partir du %s", filter.From.Format("02/01/2006"))
+	case filter.To != nil:
+		period = fmt.Sprintf("Jusqu'au %s", filter.To.Format("02/01/2006"))
+	}
+
+	pdfData, err := pdf.GenerateStockMovementsReport(movements, period, app.siteLabel())
+	if err != nil {
+		app.showError("Erreur", fmt.Sprintf("Erreur lors de la génération du PDF: %v", err))
+		return
+	}
+
+	filename := pdf.GenerateFilename("mouvements_stock", 0)
+	filepath, err := pdf.SavePDF(filename, pdfData)
+	if err != nil {
+		app.showError("Erreur", fmt.Sprintf("Erreur lors de l'enregistrement: %v", err))
+		return
+	}
+
+	app.showSuccess(fmt.Sprintf("✅ Rapport enregistré : %s", filepath))
+}
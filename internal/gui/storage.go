@@ -0,0 +1,398 @@
+package gui
+
+import (
+	"clefs/internal/db"
+	"fmt"
+	"strconv"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// noHook est l'option des exemplaires rangés sans crochet
+const noHook = "Sans crochet"
+
+// createStorageLocationsView crée la vue de gestion des emplacements de rangement et des armoires à clés
+func createStorageLocationsView(app *App) fyne.CanvasObject {
+	title := widget.NewLabelWithStyle("🗄️ Emplacements et Armoires", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	info := widget.NewLabel("Les clés se rangent dans un emplacement choisi dans leur fiche. " +
+		"Un emplacement doté de crochets numérotés est une armoire : chaque exemplaire y reçoit son crochet " +
+		"et le plan de l'armoire montre les crochets vides parce que la clé est sortie.")
+	info.Wrapping = fyne.TextWrapWord
+
+	addBtn := widget.NewButton("➕ Ajouter un Emplacement", func() {
+		showStorageLocationDialog(app, nil)
+	})
+	addBtn.Importance = widget.HighImportance
+
+	header := container.NewBorder(nil, nil, nil, addBtn, title)
+
+	locations, err := app.store.GetStorageLocations()
+	if err != nil {
+		return container.NewVBox(header, widget.NewLabel(fmt.Sprintf("Erreur: %v", err)))
+	}
+
+	list := container.NewVBox()
+	if len(locations) == 0 {
+		list.Add(widget.NewLabel("Aucun emplacement. Ajoutez l'accueil, une armoire à clés ou un coffre."))
+	}
+
+	for i, location := range locations {
+		loc := location // Capture
+
+		kind := "Emplacement sans crochets"
+		if loc.IsCabinet() {
+			kind = fmt.Sprintf("Armoire de %d crochet(s), %d occupé(s)", loc.HookCount, loc.UsedHooks)
+		}
+		locInfo := container.NewVBox(
+			widget.NewLabelWithStyle(loc.Name, fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+			widget.NewLabel(fmt.Sprintf("%s | %d clé(s)", kind, loc.KeyCount)),
+		)
+		if loc.Notes != "" {
+			locInfo.Add(widget.NewLabel("📝 " + loc.Notes))
+		}
+
+		actions := container.NewHBox()
+		if loc.IsCabinet() {
+			actions.Add(widget.NewButton("🗺️ Plan", func() {
+				app.showCabinetMap(loc.ID)
+			}))
+		}
+		actions.Add(widget.NewButton("✏️ Modifier", func() {
+			l := loc
+			showStorageLocationDialog(app, &l)
+		}))
+		actions.Add(widget.NewButton("🔀 Fusionner", func() {
+			showMergeStorageLocationDialog(app, loc)
+		}))
+
+		deleteBtn := widget.NewButton("🗑️ Supprimer", func() {
+			if !app.requireEdit() {
+				return
+			}
+			app.showConfirm("Confirmer la suppression",
+				fmt.Sprintf("Supprimer l'emplacement %s ? Seul un emplacement sans clé peut être supprimé.", loc.Name),
+				func() {
+					if err := app.store.DeleteStorageLocation(loc.ID); err != nil {
+						app.showError("Erreur", fmt.Sprintf("Impossible de supprimer l'emplacement: %v", err))
+						return
+					}
+					app.showSuccess("Emplacement supprimé avec succès!")
+					app.showStorageLocations()
+				})
+		})
+		deleteBtn.Importance = widget.DangerImportance
+		actions.Add(deleteBtn)
+
+		list.Add(container.NewBorder(nil, nil, nil, actions, locInfo))
+		if i < len(locations)-1 {
+			list.Add(widget.NewSeparator())
+		}
+	}
+
+	return container.NewBorder(
+		container.NewVBox(header, info, widget.NewSeparator()),
+		nil,
+		nil,
+		nil,
+		container.NewVScroll(list),
+	)
+}
+
+// showStorageLocationDialog affiche la boîte de dialogue de création (loc nil) ou de modification d'un emplacement
+func showStorageLocationDialog(app *App, loc *db.StorageLocation) {
+	if !app.requireEdit() {
+		return
+	}
+
+	dialogTitle := "Ajouter un Emplacement"
+	nameEntry := widget.NewEntry()
+	nameEntry.SetPlaceHolder("Nom (ex: Armoire de l'accueil)")
+	hooksEntry := widget.NewEntry()
+	hooksEntry.SetText("0")
+	notesEntry := widget.NewEntry()
+	notesEntry.SetPlaceHolder("Notes (ex: code du cadenas chez le gardien)")
+	if loc != nil {
+		dialogTitle = "Modifier l'Emplacement"
+		nameEntry.SetText(loc.Name)
+		hooksEntry.SetText(strconv.Itoa(loc.HookCount))
+		notesEntry.SetText(loc.Notes)
+	}
+
+	var popupDialog *widget.PopUp
+
+	cancelBtn := widget.NewButton("Annuler", func() {
+		app.window.Canvas().Overlays().Remove(popupDialog)
+	})
+
+	saveBtn := widget.NewButton("Enregistrer", func() {
+		hooks, err := strconv.Atoi(hooksEntry.Text)
+		if err != nil || hooks < 0 {
+			app.showError("Erreur", "Le nombre de crochets doit être un nombre positif ou zéro.")
+			return
+		}
+
+		if loc == nil {
+			err = app.store.CreateStorageLocation(&db.StorageLocation{Name: nameEntry.Text, HookCount: hooks, Notes: notesEntry.Text})
+		} else {
+			loc.Name = nameEntry.Text
+			loc.HookCount = hooks
+			loc.Notes = notesEntry.Text
+			err = app.store.UpdateStorageLocation(loc)
+		}
+		if err != nil {
+			app.showError("Erreur", fmt.Sprintf("Erreur lors de l'enregistrement: %v", err))
+			return
+		}
+
+		app.window.Canvas().Overlays().Remove(popupDialog)
+		app.showSuccess("Emplacement enregistré avec succès!")
+		app.showStorageLocations()
+	})
+	saveBtn.Importance = widget.HighImportance
+
+	content := container.NewVBox(
+		widget.NewLabelWithStyle(dialogTitle, fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
+		widget.NewSeparator(),
+		widget.NewLabel("Nom de l'emplacement:"),
+		nameEntry,
+		widget.NewLabel("Nombre de crochets numérotés (0 = emplacement sans crochets):"),
+		hooksEntry,
+		widget.NewLabel("Notes:"),
+		notesEntry,
+		widget.NewSeparator(),
+		container.NewHBox(cancelBtn, saveBtn),
+	)
+
+	popupDialog = widget.NewModalPopUp(content, app.window.Canvas())
+	popupDialog.Resize(fyne.NewSize(450, 320))
+	popupDialog.Show()
+}
+
+// showMergeStorageLocationDialog propose de fusionner un emplacement dans un autre du même site,
+// pour corriger un doublon (ex: « Acceuil » dans « Accueil »)
+func showMergeStorageLocationDialog(app *App, loc db.StorageLocation) {
+	if !app.requireEdit() {
+		return
+	}
+
+	locations, err := app.store.GetStorageLocations()
+	if err != nil {
+		app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération des emplacements: %v", err))
+		return
+	}
+
+	var options []string
+	targets := make(map[string]db.StorageLocation)
+	for _, other := range locations {
+		if other.ID == loc.ID || other.SiteID != loc.SiteID {
+			continue
+		}
+		options = append(options, other.Name)
+		targets[other.Name] = other
+	}
+	if len(options) == 0 {
+		app.showError("Fusion impossible", "Aucun autre emplacement sur ce site.")
+		return
+	}
+
+	targetSelect := widget.NewSelect(options, nil)
+	targetSelect.PlaceHolder = "Choisir l'emplacement conservé"
+
+	var popupDialog *widget.PopUp
+
+	cancelBtn := widget.NewButton("Annuler", func() {
+		app.window.Canvas().Overlays().Remove(popupDialog)
+	})
+
+	mergeBtn := widget.NewButton("🔀 Fusionner", func() {
+		target, ok := targets[targetSelect.Selected]
+		if !ok {
+			app.showError("Erreur", "Choisissez l'emplacement dans lequel fusionner.")
+			return
+		}
+		app.showConfirm("Confirmer la fusion",
+			fmt.Sprintf("Déplacer les %d clé(s) de %s vers %s et supprimer %s ?", loc.KeyCount, loc.Name, target.Name, loc.Name),
+			func() {
+				if err := app.store.MergeStorageLocation(loc.ID, target.ID); err != nil {
+					app.showError("Erreur", fmt.Sprintf("Erreur lors de la fusion: %v", err))
+					return
+				}
+				app.window.Canvas().Overlays().Remove(popupDialog)
+				app.showSuccess("Emplacements fusionnés avec succès!")
+				app.showStorageLocations()
+			})
+	})
+	mergeBtn.Importance = widget.HighImportance
+
+	info := widget.NewLabel("Les crochets qui n'existent pas dans l'armoire conservée ou y sont déjà occupés seront libérés.")
+	info.Wrapping = fyne.TextWrapWord
+
+	content := container.NewVBox(
+		widget.NewLabelWithStyle(fmt.Sprintf("Fusionner %s", loc.Name), fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
+		widget.NewSeparator(),
+		widget.NewLabel("Emplacement conservé:"),
+		targetSelect,
+		info,
+		widget.NewSeparator(),
+		container.NewHBox(cancelBtn, mergeBtn),
+	)
+
+	popupDialog = widget.NewModalPopUp(content, app.window.Canvas())
+	popupDialog.Resize(fyne.NewSize(450, 250))
+	popupDialog.Show()
+}
+
+// createCabinetMapView crée le plan d'une armoire à clés : un crochet par case, avec
+// l'exemplaire présent, sorti (et son emprunteur) ou le crochet libre
+func createCabinetMapView(app *App, locationID int) fyne.CanvasObject {
+	backBtn := widget.NewButton("⬅️ Emplacements", func() {
+		app.showStorageLocations()
+	})
+
+	cabinet, err := app.store.GetCabinetMap(locationID)
+	if err != nil {
+		return container.NewVBox(backBtn, widget.NewLabel(fmt.Sprintf("Erreur: %v", err)))
+	}
+
+	title := widget.NewLabelWithStyle("🗺️ "+cabinet.Location.Name, fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	header := container.NewBorder(nil, nil, title, backBtn)
+
+	var present, out, free int
+	grid := container.NewGridWithColumns(6)
+	for _, hook := range cabinet.Hooks {
+		switch {
+		case hook.Copy == nil:
+			free++
+		case hook.Out():
+			out++
+		default:
+			present++
+		}
+		grid.Add(createCabinetHookCard(hook))
+	}
+
+	summary := widget.NewLabelWithStyle(
+		fmt.Sprintf("🟢 Présentes : %d | 🔴 Sorties : %d | ⚪ Crochets libres : %d", present, out, free),
+		fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+
+	body := container.NewVBox(grid)
+	if len(cabinet.Unassigned) > 0 {
+		body.Add(widget.NewSeparator())
+		body.Add(widget.NewLabelWithStyle("Exemplaires sans crochet (à attribuer depuis « 🔢 Exemplaires » de la clé) :",
+			fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+		for _, hook := range cabinet.Unassigned {
+			body.Add(widget.NewLabel(fmt.Sprintf("   %s %s - %s", cabinetHookIcon(hook), hook.Copy.Identifier, cabinetHookState(hook))))
+		}
+	}
+
+	return container.NewBorder(
+		container.NewVBox(header, summary, widget.NewSeparator()),
+		nil,
+		nil,
+		nil,
+		container.NewVScroll(body),
+	)
+}
+
+// createCabinetHookCard crée la case d'un crochet dans le plan de l'armoire
+func createCabinetHookCard(hook db.CabinetHook) fyne.CanvasObject {
+	title := fmt.Sprintf("%s Crochet %d", cabinetHookIcon(hook), hook.Number)
+	if hook.Copy == nil {
+		return widget.NewCard(title, "", widget.NewLabel("Libre"))
+	}
+	state := widget.NewLabel(cabinetHookState(hook))
+	state.Wrapping = fyne.TextWrapWord
+	return widget.NewCard(title, hook.Copy.Identifier, state)
+}
+
+// cabinetHookIcon retourne la pastille d'un crochet : présent, en réserve, sorti ou libre
+func cabinetHookIcon(hook db.CabinetHook) string {
+	switch {
+	case hook.Copy == nil:
+		return "⚪"
+	case hook.Out():
+		return "🔴"
+	case hook.Copy.Status == db.CopyReserve:
+		return "🟡"
+	}
+	return "🟢"
+}
+
+// cabinetHookState décrit l'exemplaire d'un crochet (clé, emprunteur s'il est sorti)
+func cabinetHookState(hook db.CabinetHook) string {
+	if hook.Out() {
+		return fmt.Sprintf("Sortie : %s", hook.Copy.BorrowerName)
+	}
+	if hook.KeyDescription != "" {
+		return fmt.Sprintf("%s (%s) - %s", hook.KeyNumber, hook.KeyDescription, hook.Copy.Status.Label())
+	}
+	return fmt.Sprintf("%s - %s", hook.KeyNumber, hook.Copy.Status.Label())
+}
+
+// noStorageLocation est l'option des formulaires de clé pour une clé sans emplacement
+const noStorageLocation = "Aucun"
+
+// newStorageLocationSelect crée la liste de choix de l'emplacement d'une clé (keyID 0 pour
+// une création) et la fonction qui lit le choix
+func newStorageLocationSelect(app *App, keyID int, current *int) (*widget.Select, func() *int) {
+	options := []string{noStorageLocation}
+	locationMap := make(map[string]int)
+	locations, _ := app.store.GetKeyStorageLocations(keyID)
+	selected := noStorageLocation
+	for _, loc := range locations {
+		label := loc.Name
+		if loc.IsCabinet() {
+			label = fmt.Sprintf("%s (armoire, %d crochets)", loc.Name, loc.HookCount)
+		}
+		options = append(options, label)
+		locationMap[label] = loc.ID
+		if current != nil && *current == loc.ID {
+			selected = label
+		}
+	}
+
+	locationSelect := widget.NewSelect(options, nil)
+	locationSelect.SetSelected(selected)
+
+	return locationSelect, func() *int {
+		id, ok := locationMap[locationSelect.Selected]
+		if !ok {
+			return nil
+		}
+		return &id
+	}
+}
+
+// newHookSelect crée la liste de choix du crochet d'un exemplaire dans une armoire de hooks
+// crochets (réservée aux opérateurs qui peuvent modifier) ; changer le choix range aussitôt
+// l'exemplaire, puis onDone rafraîchit l'affichage
+func newHookSelect(app *App, c db.KeyCopy, hooks int, onDone func()) *widget.Select {
+	options := []string{noHook}
+	for n := 1; n <= hooks; n++ {
+		options = append(options, fmt.Sprintf("Crochet %d", n))
+	}
+
+	hookSelect := widget.NewSelect(options, nil)
+	if c.HookNumber >= 1 && c.HookNumber <= hooks {
+		hookSelect.SetSelected(options[c.HookNumber])
+	} else {
+		hookSelect.SetSelected(noHook)
+	}
+
+	hookSelect.OnChanged = func(selected string) {
+		hook := 0
+		for n, option := range options {
+			if option == selected {
+				hook = n
+			}
+		}
+		err := app.store.AssignCopyHook(c.ID, hook)
+		onDone()
+		if err != nil {
+			app.showError("Erreur", fmt.Sprintf("Erreur lors de l'attribution du crochet: %v", err))
+		}
+	}
+	return hookSelect
+}
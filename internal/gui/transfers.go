@@ -0,0 +1,204 @@
+package gui
+
+import (
+	"clefs/internal/db"
+	"clefs/internal/pdf"
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// newTransferLoanButton crée le bouton « Transférer » d'un emprunt en cours ;
+// refresh réaffiche l'écran appelant une fois le transfert terminé
+func newTransferLoanButton(app *App, loan db.LoanWithDetails, refresh func()) *widget.Button {
+	return widget.NewButton("🔁 Transférer", func() {
+		showTransferLoanDialog(app, loan, refresh)
+	})
+}
+
+// showTransferLoanDialog affiche le transfert d'une clé empruntée à un autre emprunteur
+func showTransferLoanDialog(app *App, loan db.LoanWithDetails, refresh func()) {
+	if !app.requireEdit() {
+		return
+	}
+
+	borrowers, err := app.store.GetAllBorrowers()
+	if err != nil {
+		app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération des emprunteurs: %v", err))
+		return
+	}
+
+	// Les personnes parties et l'emprunteur actuel ne sont pas proposés
+	var candidates []db.Borrower
+	borrowerMap := make(map[string]int)
+	for _, b := range borrowers {
+		if b.Status == db.BorrowerDeparted || b.ID == loan.BorrowerID {
+			continue
+		}
+		candidates = append(candidates, b)
+		borrowerMap[borrowerOptionLabel(b)] = b.ID
+	}
+
+	borrowerSelect := widget.NewSelect(nil, nil)
+	borrowerSelect.PlaceHolder = "Nouvel emprunteur..."
+
+	searchEntry := widget.NewEntry()
+	searchEntry.SetPlaceHolder("🔍 Rechercher un emprunteur (nom, badge ou service)...")
+	searchEntry.OnChanged = func(query string) {
+		query = strings.ToLower(query)
+		var options []string
+		for _, b := range candidates {
+			if query == "" ||
+				strings.Contains(strings.ToLower(b.Name), query) ||
+				strings.Contains(strings.ToLower(b.BadgeNumber), query) ||
+				strings.Contains(strings.ToLower(b.Department), query) {
+				options = append(options, borrowerOptionLabel(b))
+			}
+		}
+		borrowerSelect.Options = options
+		borrowerSelect.ClearSelected()
+		borrowerSelect.Refresh()
+	}
+	searchEntry.OnChanged("")
+
+	dueDateEntry := widget.NewEntry()
+	dueDateEntry.SetPlaceHolder("Retour prévu JJ/MM/AAAA (vide = durée par défaut de la clé)")
+
+	overrideCheck := widget.NewCheck("Dérogation administrateur : emprunteur non autorisé", nil)
+	if !app.isAdmin() {
+		overrideCheck.Hide()
+	}
+
+	key := loan.KeyNumber
+	if loan.CopyIdentifier != "" {
+		key += " (" + loan.CopyIdentifier + ")"
+	}
+	text := fmt.Sprintf("La clé %s empruntée par %s est remise directement au nouvel emprunteur, sans repasser par le stock.", key, loan.BorrowerName)
+	info := widget.NewLabel(text)
+	info.Wrapping = fyne.TextWrapWord
+
+	// Le sort d'une caution détenue est choisi explicitement : rien n'est coché par défaut
+	depositLabel := fmt.Sprintf("Caution de %s versée par %s :", db.FormatDepositAmount(loan.DepositAmount), loan.BorrowerName)
+	carryOver := "Reporter la caution sur le nouvel emprunt"
+	refund := fmt.Sprintf("Restituer la caution à %s", loan.BorrowerName)
+	depositMap := map[string]db.DepositStatus{
+		carryOver: db.DepositTransferred,
+		refund:    db.DepositRefunded,
+	}
+	depositRadio := widget.NewRadioGroup([]string{carryOver, refund}, nil)
+	depositBox := container.NewVBox(widget.NewLabel(depositLabel), depositRadio)
+	if !loan.HasHeldDeposit() {
+		depositBox.Hide()
+	}
+
+	var popupDialog *widget.PopUp
+
+	cancelBtn := widget.NewButton("Annuler", func() {
+		app.window.Canvas().Overlays().Remove(popupDialog)
+	})
+
+	transferBtn := widget.NewButton("Transférer", func() {
+		borrowerID, ok := borrowerMap[borrowerSelect.Selected]
+		if !ok {
+			app.showError("Erreur", "Veuillez sélectionner le nouvel emprunteur.")
+			return
+		}
+		deposit, ok := depositMap[depositRadio.Selected]
+		if loan.HasHeldDeposit() && !ok {
+			app.showError("Erreur", "Veuillez indiquer si la caution est reportée ou restituée.")
+			return
+		}
+		dueDate, err := parseDueDate(dueDateEntry.Text)
+		if err != nil {
+			app.showError("Erreur", fmt.Sprintf("Date de retour invalide : %v", err))
+			return
+		}
+
+		newLoanID, err := app.store.TransferLoan(loan.ID, borrowerID, deposit, db.LoanOptions{
+			DueDate:               dueDate,
+			OverrideAuthorization: overrideCheck.Checked,
+		})
+		if err != nil {
+			app.showError("Erreur", fmt.Sprintf("Erreur lors du transfert: %v", err))
+			return
+		}
+
+		app.window.Canvas().Overlays().Remove(popupDialog)
+		showTransferReceiptDialog(app, loan.ID, newLoanID, refresh)
+	})
+	transferBtn.Importance = widget.HighImportance
+
+	content := container.NewVBox(
+		widget.NewLabelWithStyle("Transférer une Clé", fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
+		widget.NewSeparator(),
+		info,
+		searchEntry,
+		borrowerSelect,
+		dueDateEntry,
+		depositBox,
+		overrideCheck,
+		widget.NewSeparator(),
+		container.NewHBox(cancelBtn, transferBtn),
+	)
+
+	popupDialog = widget.NewModalPopUp(content, app.window.Canvas())
+	popupDialog.Resize(fyne.NewSize(550, 0))
+	popupDialog.Show()
+}
+
+// showTransferReceiptDialog confirme un transfert et propose le bon de transfert à faire signer
+func showTransferReceiptDialog(app *App, fromLoanID, toLoanID int, refresh func()) {
+	from, err := app.store.GetLoanByID(fromLoanID)
+	if err != nil {
+		app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération de l'emprunt: %v", err))
+		refresh()
+		return
+	}
+	to, err := app.store.GetLoanByID(toLoanID)
+	if err != nil {
+		app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération de l'emprunt: %v", err))
+		refresh()
+		return
+	}
+
+	info := widget.NewLabel(fmt.Sprintf("✅ Clé %s transférée de %s à %s.", to.KeyNumber, from.BorrowerName, to.BorrowerName))
+	info.Wrapping = fyne.TextWrapWord
+
+	var dialog *widget.PopUp
+
+	pdfBtn := widget.NewButton("📄 Bon de transfert", func() {
+		pdfData, err := pdf.GenerateTransferReceipt(from, to)
+		if err != nil {
+			app.showError("Erreur", fmt.Sprintf("Erreur lors de la génération du PDF: %v", err))
+			return
+		}
+		filepath, err := pdf.SavePDF(pdf.GenerateFilename("bon_transfert", to.ID), pdfData)
+		if err != nil {
+			app.showError("Erreur", fmt.Sprintf("Erreur lors de l'enregistrement: %v", err))
+			return
+		}
+		app.showSuccess(fmt.Sprintf("✅ Bon de transfert enregistré : %s", filepath))
+	})
+	pdfBtn.Importance = widget.HighImportance
+
+	closeBtn := widget.NewButton("Fermer", func() {
+		app.window.Canvas().Overlays().Remove(dialog)
+		refresh()
+	})
+
+	content := container.NewVBox(
+		widget.NewLabelWithStyle("Transfert Enregistré", fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
+		widget.NewSeparator(),
+		info,
+		widget.NewLabel("Faites signer le bon de transfert par les deux emprunteurs."),
+		widget.NewSeparator(),
+		container.NewHBox(closeBtn, pdfBtn),
+	)
+
+	dialog = widget.NewModalPopUp(content, app.window.Canvas())
+	dialog.Resize(fyne.NewSize(500, 0))
+	dialog.Show()
+}
@@ -0,0 +1,218 @@
+package gui
+
+import (
+	"clefs/internal/db"
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// createZonesView crée la vue de gestion des zones (ailes, zones de sécurité)
+func createZonesView(app *App) fyne.CanvasObject {
+	title := widget.NewLabelWithStyle("🧭 Zones", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	info := widget.NewLabel("Une zone regroupe des salles du site, éventuellement de plusieurs bâtiments : " +
+		"une aile, une zone de sécurité... Les salles y sont rattachées depuis leur fiche, " +
+		"et « 🔑 Accès » liste toutes les clés qui ouvrent au moins une salle de la zone.")
+	info.Wrapping = fyne.TextWrapWord
+
+	addBtn := widget.NewButton("➕ Ajouter une Zone", func() {
+		showZoneDialog(app, nil)
+	})
+	addBtn.Importance = widget.HighImportance
+
+	header := container.NewBorder(nil, nil, nil, addBtn, title)
+
+	zones, err := app.store.GetZones()
+	if err != nil {
+		return container.NewVBox(header, widget.NewLabel(fmt.Sprintf("Erreur: %v", err)))
+	}
+
+	list := container.NewVBox()
+	if len(zones) == 0 {
+		list.Add(widget.NewLabel("Aucune zone. Ajoutez par exemple « Aile Nord » ou « Labo »."))
+	}
+
+	for i, zone := range zones {
+		z := zone // Capture
+
+		zoneInfo := container.NewVBox(
+			widget.NewLabelWithStyle(z.Name, fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+			widget.NewLabel(fmt.Sprintf("%d salle(s)", z.RoomCount)),
+		)
+		if z.Description != "" {
+			zoneInfo.Add(widget.NewLabel("📝 " + z.Description))
+		}
+
+		accessBtn := widget.NewButton("🔑 Accès", func() {
+			showZoneAccessDialog(app, z)
+		})
+
+		editBtn := widget.NewButton("✏️ Modifier", func() {
+			edited := z
+			showZoneDialog(app, &edited)
+		})
+
+		deleteBtn := widget.NewButton("🗑️ Supprimer", func() {
+			if !app.requireEdit() {
+				return
+			}
+			app.showConfirm("Confirmer la suppression",
+				fmt.Sprintf("Supprimer la zone %s ? Ses salles sont conservées, sans zone.", z.Name),
+				func() {
+					if err := app.store.DeleteZone(z.ID); err != nil {
+						app.showError("Erreur", fmt.Sprintf("Impossible de supprimer la zone: %v", err))
+						return
+					}
+					app.showSuccess("Zone supprimée avec succès!")
+					app.showZones()
+				})
+		})
+		deleteBtn.Importance = widget.DangerImportance
+
+		actions := container.NewHBox(accessBtn, editBtn, deleteBtn)
+		list.Add(container.NewBorder(nil, nil, nil, actions, zoneInfo))
+		if i < len(zones)-1 {
+			list.Add(widget.NewSeparator())
+		}
+	}
+
+	return container.NewBorder(
+		container.NewVBox(header, info, widget.NewSeparator()),
+		nil,
+		nil,
+		nil,
+		container.NewVScroll(list),
+	)
+}
+
+// showZoneDialog affiche la boîte de dialogue de création (zone nil) ou de modification d'une zone
+func showZoneDialog(app *App, zone *db.Zone) {
+	if !app.requireEdit() {
+		return
+	}
+
+	dialogTitle := "Ajouter une Zone"
+	nameEntry := widget.NewEntry()
+	nameEntry.SetPlaceHolder("Nom (ex: Aile Nord, Labo)")
+	descriptionEntry := widget.NewEntry()
+	descriptionEntry.SetPlaceHolder("Description (ex: accès restreint au personnel habilité)")
+	if zone != nil {
+		dialogTitle = "Modifier la Zone"
+		nameEntry.SetText(zone.Name)
+		descriptionEntry.SetText(zone.Description)
+	}
+
+	var popupDialog *widget.PopUp
+
+	cancelBtn := widget.NewButton("Annuler", func() {
+		app.window.Canvas().Overlays().Remove(popupDialog)
+	})
+
+	saveBtn := widget.NewButton("Enregistrer", func() {
+		var err error
+		if zone == nil {
+			err = app.store.CreateZone(&db.Zone{Name: nameEntry.Text, Description: descriptionEntry.Text})
+		} else {
+			zone.Name = nameEntry.Text
+			zone.Description = descriptionEntry.Text
+			err = app.store.UpdateZone(zone)
+		}
+		if err != nil {
+			app.showError("Erreur", fmt.Sprintf("Erreur lors de l'enregistrement: %v", err))
+			return
+		}
+
+		app.window.Canvas().Overlays().Remove(popupDialog)
+		app.showSuccess("Zone enregistrée avec succès!")
+		app.showZones()
+	})
+	saveBtn.Importance = widget.HighImportance
+
+	content := container.NewVBox(
+		widget.NewLabelWithStyle(dialogTitle, fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
+		widget.NewSeparator(),
+		widget.NewLabel("Nom de la zone:"),
+		nameEntry,
+		widget.NewLabel("Description:"),
+		descriptionEntry,
+		widget.NewSeparator(),
+		container.NewHBox(cancelBtn, saveBtn),
+	)
+
+	popupDialog = widget.NewModalPopUp(content, app.window.Canvas())
+	popupDialog.Resize(fyne.NewSize(450, 260))
+	popupDialog.Show()
+}
+
+// showZoneAccessDialog affiche les salles d'une zone et toutes les clés qui en ouvrent au moins une
+func showZoneAccessDialog(app *App, zone db.Zone) {
+	rooms, err := app.store.GetRoomsForZone(zone.ID)
+	if err != nil {
+		app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération des salles: %v", err))
+		return
+	}
+	keys, err := app.store.GetKeysForZone(zone.ID)
+	if err != nil {
+		app.showError("Erreur", fmt.Sprintf("Erreur lors de la récupération des clés: %v", err))
+		return
+	}
+
+	buildingNames := make(map[int]string)
+	if buildings, err := app.store.GetAllBuildings(); err == nil {
+		for _, b := range buildings {
+			buildingNames[b.ID] = b.Name
+		}
+	}
+
+	roomsBox := container.NewVBox()
+	if len(rooms) == 0 {
+		roomsBox.Add(widget.NewLabel("Aucune salle dans cette zone."))
+	}
+	for _, r := range rooms {
+		place := buildingNames[r.BuildingID]
+		if r.Floor != "" {
+			place += " > " + r.Floor
+		}
+		roomsBox.Add(widget.NewLabel(fmt.Sprintf("🚪 %s (%s)", r.Name, place)))
+	}
+
+	keysBox := container.NewVBox()
+	if len(keys) == 0 {
+		keysBox.Add(widget.NewLabel("Aucune clé n'ouvre de salle de cette zone."))
+	}
+	for _, k := range keys {
+		text := fmt.Sprintf("🔑 %s - %s", k.Number, k.Description)
+		if k.Access == db.AccessInherited {
+			text += fmt.Sprintf(" (passe-partout via %s)", k.AccessVia)
+		}
+		keysBox.Add(widget.NewLabel(text))
+	}
+
+	var dialog *widget.PopUp
+	closeBtn := widget.NewButton("Fermer", func() {
+		app.window.Canvas().Overlays().Remove(dialog)
+	})
+
+	body := container.NewVScroll(container.NewVBox(
+		widget.NewLabelWithStyle(fmt.Sprintf("Salles (%d):", len(rooms)), fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		roomsBox,
+		widget.NewSeparator(),
+		widget.NewLabelWithStyle(fmt.Sprintf("Clés qui ouvrent la zone (%d):", len(keys)), fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		keysBox,
+	))
+	body.SetMinSize(fyne.NewSize(600, 380))
+
+	content := container.NewVBox(
+		widget.NewLabelWithStyle(fmt.Sprintf("Accès à la zone %s", zone.Name), fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
+		widget.NewSeparator(),
+		body,
+		widget.NewSeparator(),
+		container.NewHBox(closeBtn),
+	)
+
+	dialog = widget.NewModalPopUp(content, app.window.Canvas())
+	dialog.Resize(fyne.NewSize(650, 500))
+	dialog.Show()
+}
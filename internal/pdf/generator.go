@@ -12,7 +12,8 @@ import (
 	"github.com/phpdave11/gofpdf"
 )
 
-// GenerateLoanReceipt génère un reçu PDF pour un emprunt
+// GenerateLoanReceipt génère un reçu PDF pour un emprunt, avec les signatures de
+// l'emprunteur recueillies à l'écran (ligne à signer à la main sinon)
 func GenerateLoanReceipt(loan *db.LoanWithDetails) ([]byte, error) {
 	pdf := gofpdf.New("P", "mm", "A4", "")
 	pdf.AddPage()
@@ -37,31 +38,87 @@ func GenerateLoanReceipt(loan *db.LoanWithDetails) ([]byte, error) {
 	pdf.Cell(0, 10, tr(loan.KeyDescription))
 	pdf.Ln(8)
 
+	if loan.CopyIdentifier != "" {
+		pdf.Cell(70, 10, tr("Exemplaire :"))
+		pdf.SetFont("Arial", "B", 12)
+		pdf.Cell(0, 10, tr(loan.CopyIdentifier))
+		pdf.SetFont("Arial", "", 12)
+		pdf.Ln(8)
+	}
+
 	pdf.Cell(70, 10, tr("Emprunté par :"))
 	pdf.SetFont("Arial", "B", 12)
 	pdf.Cell(0, 10, tr(loan.BorrowerName))
 	pdf.Ln(8)
 
 	pdf.SetFont("Arial", "", 12)
+	writeBorrowerIdentity(pdf, tr, loan.BorrowerBadgeNumber, loan.BorrowerDepartment, "")
+
 	pdf.Cell(70, 10, tr("Date d'emprunt :"))
 	pdf.Cell(0, 10, tr(loan.LoanDate.Format("02/01/2006 à 15:04")))
-	pdf.Ln(15)
+	pdf.Ln(8)
+
+	if loan.LoanedBy != "" {
+		pdf.Cell(70, 10, tr("Remise par :"))
+		pdf.Cell(0, 10, tr(loan.LoanedBy))
+		pdf.Ln(8)
+	}
+
+	// Date de retour prévue
+	restitution := "à la fin de son utilisation"
+	if loan.DueDate != nil {
+		pdf.Cell(70, 10, tr("Date de retour prévue :"))
+		pdf.SetFont("Arial", "B", 12)
+		pdf.Cell(0, 10, tr(loan.DueDate.Format("02/01/2006")))
+		pdf.SetFont("Arial", "", 12)
+		pdf.Ln(8)
+		restitution = "au plus tard le " + loan.DueDate.Format("02/01/2006")
+	}
+
+	if loan.DepositAmount > 0 {
+		pdf.Cell(70, 10, tr("Caution versée :"))
+		pdf.SetFont("Arial", "B", 12)
+		pdf.Cell(0, 10, tr(fmt.Sprintf("%s (%s)", db.FormatDepositAmount(loan.DepositAmount), loan.DepositMethod.Label())))
+		pdf.SetFont("Arial", "", 12)
+		pdf.Ln(8)
+	}
+	pdf.Ln(7)
 
 	// Texte d'engagement
 	pdf.SetFont("Arial", "", 11)
 	text := fmt.Sprintf("Je soussigné(e), %s, reconnais avoir reçu la clé mentionnée ci-dessus. "+
-		"Je m'engage à en prendre soin et à la restituer à la fin de son utilisation. "+
+		"Je m'engage à en prendre soin et à la restituer %s. "+
 		"En cas de perte ou de dégradation, je suis conscient(e) que ma responsabilité "+
-		"pourra être engagée.", loan.BorrowerName)
+		"pourra être engagée.", loan.BorrowerName, restitution)
+	if loan.DepositAmount > 0 {
+		text += fmt.Sprintf(" La caution de %s me sera restituée au retour de la clé en bon état.",
+			db.FormatDepositAmount(loan.DepositAmount))
+	}
 
 	pdf.MultiCell(0, 6, tr(text), "", "", false)
 	pdf.Ln(20)
 
-	// Signature
+	// Signature, recueillie à l'écran ou à faire à la main
+	if pdf.GetY() > 230 {
+		pdf.AddPage()
+	}
 	pdf.SetFont("Arial", "", 12)
 	pdf.Cell(0, 10, tr("Signature de l'emprunteur :"))
 	pdf.Ln(8)
-	pdf.Line(80, pdf.GetY(), 180, pdf.GetY())
+	writeSignature(pdf, tr, "signature_remise", loan.Signature,
+		"Signée à l'écran le "+loan.LoanDate.Format("02/01/2006 à 15:04"))
+
+	if loan.ReturnSignature != nil && loan.ReturnDate != nil {
+		pdf.Ln(10)
+		if pdf.GetY() > 240 {
+			pdf.AddPage()
+		}
+		pdf.SetFont("Arial", "", 12)
+		pdf.Cell(0, 10, tr("Signature au retour de la clé :"))
+		pdf.Ln(8)
+		writeSignature(pdf, tr, "signature_retour", loan.ReturnSignature,
+			"Signée à l'écran le "+loan.ReturnDate.Format("02/01/2006 à 15:04"))
+	}
 
 	var buf bytes.Buffer
 	err := pdf.Output(&buf)
@@ -71,7 +128,63 @@ func GenerateLoanReceipt(loan *db.LoanWithDetails) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-// GenerateBorrowerReceipt génère un reçu PDF pour tous les emprunts d'un emprunteur
+// signatureWidth est la largeur, en mm, d'une signature recueillie à l'écran dans un reçu
+const signatureWidth = 70
+
+// writeSignature dessine à la position courante une signature recueillie à l'écran (PNG),
+// soulignée et suivie de caption. Sans signature, seule la ligne à signer à la main est tracée.
+func writeSignature(pdf *gofpdf.Fpdf, tr func(string) string, name string, signature []byte, caption string) {
+	if signature == nil {
+		pdf.Line(80, pdf.GetY(), 180, pdf.GetY())
+		return
+	}
+
+	options := gofpdf.ImageOptions{ImageType: "PNG"}
+	info := pdf.RegisterImageOptionsReader(name, options, bytes.NewReader(signature))
+	if info == nil || info.Width() == 0 {
+		// L'erreur est conservée par pdf et remontée par Output
+		return
+	}
+	y := pdf.GetY()
+	height := signatureWidth * info.Height() / info.Width()
+	pdf.ImageOptions(name, 80, y, signatureWidth, height, false, options, 0, "")
+	pdf.Line(80, y+height, 180, y+height)
+
+	pdf.SetY(y + height + 1)
+	pdf.SetX(80)
+	pdf.SetFont("Arial", "I", 9)
+	pdf.Cell(0, 5, tr(caption))
+	pdf.Ln(5)
+}
+
+// generatedLine retourne la ligne de date d'un rapport, suivie du site couvert (ex: « Site : Nord »)
+func generatedLine(site string) string {
+	line := fmt.Sprintf("Généré le %s", time.Now().Format("02/01/2006 à 15:04"))
+	if site != "" {
+		line += " - " + site
+	}
+	return line
+}
+
+// writeBorrowerIdentity écrit le badge, le service et le téléphone de l'emprunteur lorsqu'ils sont renseignés
+func writeBorrowerIdentity(pdf *gofpdf.Fpdf, tr func(string) string, badge, department, phone string) {
+	lines := []struct{ label, value string }{
+		{"Badge / matricule :", badge},
+		{"Service :", department},
+		{"Téléphone :", phone},
+	}
+	for _, line := range lines {
+		if line.value == "" {
+			continue
+		}
+		pdf.Cell(70, 10, tr(line.label))
+		pdf.Cell(0, 10, tr(line.value))
+		pdf.Ln(8)
+	}
+}
+
+// GenerateBorrowerReceipt génère un reçu PDF pour tous les emprunts d'un emprunteur.
+// Les signatures des emprunts doivent avoir été lues par LoadLoanSignatures.
 func GenerateBorrowerReceipt(borrower *db.Borrower, loans []db.LoanWithDetails) ([]byte, error) {
 	pdf := gofpdf.New("P", "mm", "A4", "")
 	pdf.AddPage()
@@ -90,6 +203,8 @@ func GenerateBorrowerReceipt(borrower *db.Borrower, loans []db.LoanWithDetails)
 	pdf.Ln(8)
 
 	pdf.SetFont("Arial", "", 12)
+	writeBorrowerIdentity(pdf, tr, borrower.BadgeNumber, borrower.Department, borrower.Phone)
+
 	pdf.Cell(70, 10, tr("Date :"))
 	pdf.Cell(0, 10, tr(time.Now().Format("02/01/2006 à 15:04")))
 	pdf.Ln(8)
@@ -108,6 +223,7 @@ func GenerateBorrowerReceipt(borrower *db.Borrower, loans []db.LoanWithDetails)
 	pdf.Ln(8)
 
 	pdf.SetFont("Arial", "", 11)
+	var totalDeposit float64
 	for i, loan := range loans {
 		if pdf.GetY() > 250 {
 			pdf.AddPage()
@@ -118,11 +234,28 @@ func GenerateBorrowerReceipt(borrower *db.Borrower, loans []db.LoanWithDetails)
 			loan.KeyNumber,
 			loan.KeyDescription,
 			loan.LoanDate.Format("02/01/2006"))
+		if loan.CopyIdentifier != "" {
+			text += fmt.Sprintf(" - exemplaire %s", loan.CopyIdentifier)
+		}
+		if loan.DueDate != nil {
+			text += fmt.Sprintf(" - retour prévu le %s", loan.DueDate.Format("02/01/2006"))
+		}
+		if loan.DepositAmount > 0 {
+			text += fmt.Sprintf(" - caution %s (%s)", db.FormatDepositAmount(loan.DepositAmount), strings.ToLower(loan.DepositMethod.Label()))
+			totalDeposit += loan.DepositAmount
+		}
 
 		pdf.Cell(0, 7, tr(text))
 		pdf.Ln(7)
 	}
 
+	if totalDeposit > 0 {
+		pdf.Ln(3)
+		pdf.SetFont("Arial", "B", 11)
+		pdf.Cell(0, 7, tr("Total des cautions versées : "+db.FormatDepositAmount(totalDeposit)))
+		pdf.Ln(7)
+	}
+
 	pdf.Ln(10)
 
 	// Texte d'engagement
@@ -135,11 +268,34 @@ func GenerateBorrowerReceipt(borrower *db.Borrower, loans []db.LoanWithDetails)
 	pdf.MultiCell(0, 6, tr(text), "", "", false)
 	pdf.Ln(20)
 
-	// Signature
+	// Signatures recueillies à l'écran : une par lot de clés prêtées ensemble
+	groups, unsigned := groupLoanSignatures(loans)
+	if pdf.GetY() > 230 {
+		pdf.AddPage()
+	}
 	pdf.SetFont("Arial", "", 12)
 	pdf.Cell(0, 10, tr("Signature de l'emprunteur :"))
 	pdf.Ln(8)
-	pdf.Line(80, pdf.GetY(), 180, pdf.GetY())
+	detailed := len(groups) > 1 || (len(groups) > 0 && len(unsigned) > 0)
+	for i, g := range groups {
+		if i > 0 && pdf.GetY() > 250 {
+			pdf.AddPage()
+		}
+		caption := "Signée à l'écran le " + g.signedAt.Format("02/01/2006 à 15:04")
+		if detailed {
+			caption += " pour : " + strings.Join(g.keys, ", ")
+		}
+		writeSignature(pdf, tr, fmt.Sprintf("signature_%d", i), g.signature, caption)
+		pdf.Ln(4)
+	}
+	if len(unsigned) > 0 {
+		if detailed {
+			pdf.SetFont("Arial", "", 11)
+			pdf.Cell(0, 10, tr("Pour : "+strings.Join(unsigned, ", ")))
+			pdf.Ln(14)
+		}
+		writeSignature(pdf, tr, "", nil, "")
+	}
 
 	var buf bytes.Buffer
 	err := pdf.Output(&buf)
@@ -149,8 +305,81 @@ func GenerateBorrowerReceipt(borrower *db.Borrower, loans []db.LoanWithDetails)
 	return buf.Bytes(), nil
 }
 
-// GenerateKeyPlanPDF génère un PDF du plan de clés (Compact et Trié)
-func GenerateKeyPlanPDF(buildingsMap map[int]db.Building) ([]byte, error) {
+// loanSignatureGroup rassemble les clés d'un reçu signées par une même signature à l'écran
+type loanSignatureGroup struct {
+	signature []byte
+	signedAt  time.Time
+	keys      []string
+}
+
+// groupLoanSignatures regroupe les emprunts par signature : les clés prêtées ensemble
+// partagent la même image. unsigned liste les clés sans signature.
+func groupLoanSignatures(loans []db.LoanWithDetails) (groups []loanSignatureGroup, unsigned []string) {
+	for _, loan := range loans {
+		if loan.Signature == nil {
+			unsigned = append(unsigned, loan.KeyNumber)
+			continue
+		}
+		found := false
+		for i := range groups {
+			if bytes.Equal(groups[i].signature, loan.Signature) {
+				groups[i].keys = append(groups[i].keys, loan.KeyNumber)
+				found = true
+				break
+			}
+		}
+		if !found {
+			groups = append(groups, loanSignatureGroup{signature: loan.Signature, signedAt: loan.LoanDate, keys: []string{loan.KeyNumber}})
+		}
+	}
+	return groups, unsigned
+}
+
+// writeKeyHierarchy écrit l'arbre des passe-partout et de leurs clés subordonnées
+func writeKeyHierarchy(pdf *gofpdf.Fpdf, tr func(string) string, keys []db.Key) {
+	sorted := append([]db.Key(nil), keys...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Number < sorted[j].Number
+	})
+
+	// Seules les branches qui comportent un passe-partout sont utiles
+	var entries []db.KeyTreeEntry
+	for _, entry := range db.KeyTree(sorted) {
+		if entry.Depth > 0 || entry.Children > 0 {
+			entries = append(entries, entry)
+		}
+	}
+	if len(entries) == 0 {
+		return
+	}
+
+	if pdf.GetY() > 250 {
+		pdf.AddPage()
+	}
+	pdf.SetFont("Arial", "B", 12)
+	pdf.SetFillColor(230, 230, 230)
+	pdf.CellFormat(0, 7, tr("Hiérarchie des passe-partout"), "1", 1, "L", true, 0, "")
+
+	for _, entry := range entries {
+		if pdf.GetY() > 270 {
+			pdf.AddPage()
+		}
+		text := fmt.Sprintf("%s - %s", entry.Number, entry.Description)
+		if entry.Children > 0 {
+			pdf.SetFont("Arial", "B", 10)
+			text += fmt.Sprintf(" (passe-partout de %d clé(s))", entry.Children)
+		} else {
+			pdf.SetFont("Arial", "", 9)
+		}
+		pdf.Cell(float64(5+entry.Depth*8), 6, "")
+		pdf.Cell(0, 6, tr(text))
+		pdf.Ln(6)
+	}
+}
+
+// GenerateKeyPlanPDF génère un PDF du plan de clés (Compact et Trié, salles regroupées par étage),
+// suivi de la hiérarchie des passe-partout lorsqu'il y en a. site indique le site couvert.
+func GenerateKeyPlanPDF(buildingsMap map[int]db.Building, keys []db.Key, site string) ([]byte, error) {
 	pdf := gofpdf.New("P", "mm", "A4", "")
 	pdf.AddPage()
 	tr := pdf.UnicodeTranslatorFromDescriptor("")
@@ -161,7 +390,7 @@ func GenerateKeyPlanPDF(buildingsMap map[int]db.Building) ([]byte, error) {
 	pdf.Ln(10)
 
 	pdf.SetFont("Arial", "", 9)
-	pdf.Cell(0, 6, tr(fmt.Sprintf("Généré le %s", time.Now().Format("02/01/2006 à 15:04"))))
+	pdf.Cell(0, 6, tr(generatedLine(site)))
 	pdf.Ln(10)
 
 	// Convertir la map en slice pour le tri
@@ -196,53 +425,80 @@ func GenerateKeyPlanPDF(buildingsMap map[int]db.Building) ([]byte, error) {
 				return strings.ToLower(building.Rooms[i].Name) < strings.ToLower(building.Rooms[j].Name)
 			})
 
-			// Pour chaque salle
-			for _, room := range building.Rooms {
-				if pdf.GetY() > 270 {
-					pdf.AddPage()
+			// Pour chaque étage, puis chaque salle
+			groups := db.GroupRoomsByFloor(building.Rooms)
+			for _, group := range groups {
+				indent := ""
+				if group.Floor != "" || len(groups) > 1 {
+					if pdf.GetY() > 270 {
+						pdf.AddPage()
+					}
+					floor := group.Floor
+					if floor == "" {
+						floor = "Sans étage"
+					}
+					pdf.SetFont("Arial", "BI", 10)
+					pdf.Cell(0, 6, tr(floor))
+					pdf.Ln(6)
+					indent = "    "
 				}
 
-				// Salle
-				pdf.SetFont("Arial", "B", 10)
-				roomText := fmt.Sprintf("• %s", room.Name)
-				if room.Type != "" {
-					roomText += fmt.Sprintf(" (%s)", room.Type)
-				}
-				pdf.Cell(80, 6, tr(roomText))
-
-				// Clés associées (sur la même ligne si possible)
-				if len(room.Keys) > 0 {
-					// Trier les clés
-					sort.Slice(room.Keys, func(i, j int) bool {
-						return room.Keys[i].Number < room.Keys[j].Number
-					})
-
-					var keyTexts []string
-					for _, key := range room.Keys {
-						keyTexts = append(keyTexts, key.Number)
+				for _, room := range group.Rooms {
+					if pdf.GetY() > 270 {
+						pdf.AddPage()
 					}
-					keysString := strings.Join(keyTexts, ", ")
-
-					pdf.SetFont("Arial", "", 9)
-					// Si la liste est trop longue, on la met à la ligne
-					if len(keysString) > 60 {
-						pdf.Ln(5)
-						pdf.Cell(10, 5, "") // Indentation
-						pdf.MultiCell(0, 5, tr("Clés : "+keysString), "", "L", false)
+
+					// Salle
+					pdf.SetFont("Arial", "B", 10)
+					roomText := fmt.Sprintf("%s• %s", indent, room.Name)
+					if room.Type != "" {
+						roomText += fmt.Sprintf(" (%s)", room.Type)
+					}
+					if room.Zone != "" {
+						roomText += fmt.Sprintf(" [%s]", room.Zone)
+					}
+					pdf.Cell(80, 6, tr(roomText))
+
+					// Clés associées (sur la même ligne si possible)
+					if len(room.Keys) > 0 {
+						// Trier les clés
+						sort.Slice(room.Keys, func(i, j int) bool {
+							return room.Keys[i].Number < room.Keys[j].Number
+						})
+
+						var keyTexts []string
+						for _, key := range room.Keys {
+							if key.Access == db.AccessInherited {
+								keyTexts = append(keyTexts, fmt.Sprintf("%s (via %s)", key.Number, key.AccessVia))
+							} else {
+								keyTexts = append(keyTexts, key.Number)
+							}
+						}
+						keysString := strings.Join(keyTexts, ", ")
+
+						pdf.SetFont("Arial", "", 9)
+						// Si la liste est trop longue, on la met à la ligne
+						if len(keysString) > 60 {
+							pdf.Ln(5)
+							pdf.Cell(10, 5, "") // Indentation
+							pdf.MultiCell(0, 5, tr("Clés : "+keysString), "", "L", false)
+						} else {
+							pdf.Cell(0, 6, tr(": "+keysString))
+							pdf.Ln(6)
+						}
 					} else {
-						pdf.Cell(0, 6, tr(": "+keysString))
+						pdf.SetFont("Arial", "I", 9)
+						pdf.Cell(0, 6, tr(": Aucune clé"))
 						pdf.Ln(6)
 					}
-				} else {
-					pdf.SetFont("Arial", "I", 9)
-					pdf.Cell(0, 6, tr(": Aucune clé"))
-					pdf.Ln(6)
 				}
 			}
 		}
 		pdf.Ln(4) // Petit espace après chaque bâtiment
 	}
 
+	writeKeyHierarchy(pdf, tr, keys)
+
 	var buf bytes.Buffer
 	err := pdf.Output(&buf)
 	if err != nil {
@@ -251,9 +507,19 @@ func GenerateKeyPlanPDF(buildingsMap map[int]db.Building) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-// GenerateLoansReportPDF génère un rapport PDF des emprunts actifs
-func GenerateLoansReportPDF(loans []db.LoanWithDetails) ([]byte, error) {
-	pdf := gofpdf.New("P", "mm", "A4", "")
+// GenerateLoansReportPDF génère un rapport PDF des emprunts actifs. Les champs personnalisés
+// des clés et des emprunteurs affichés dans les rapports forment des colonnes supplémentaires,
+// en format paysage.
+func GenerateLoansReportPDF(loans []db.LoanWithDetails, keyColumns, borrowerColumns db.CustomColumns, site string) ([]byte, error) {
+	extra := len(keyColumns.Labels) + len(borrowerColumns.Labels)
+	orientation := "P"
+	var extraWidth float64
+	if extra > 0 {
+		orientation = "L"
+		extraWidth = customColumnWidth(97, extra)
+	}
+
+	pdf := gofpdf.New(orientation, "mm", "A4", "")
 	pdf.AddPage()
 	tr := pdf.UnicodeTranslatorFromDescriptor("")
 
@@ -263,32 +529,35 @@ func GenerateLoansReportPDF(loans []db.LoanWithDetails) ([]byte, error) {
 	pdf.Ln(15)
 
 	pdf.SetFont("Arial", "", 10)
-	pdf.Cell(0, 6, tr(fmt.Sprintf("Généré le %s", time.Now().Format("02/01/2006 à 15:04"))))
+	pdf.Cell(0, 6, tr(generatedLine(site)))
 	pdf.Ln(8)
 	pdf.Cell(0, 6, tr(fmt.Sprintf("Nombre total d'emprunts actifs : %d", len(loans))))
 	pdf.Ln(12)
 
 	// En-têtes du tableau
-	pdf.SetFont("Arial", "B", 10)
-	pdf.CellFormat(30, 7, tr("Clé"), "1", 0, "C", false, 0, "")
-	pdf.CellFormat(60, 7, tr("Description"), "1", 0, "C", false, 0, "")
-	pdf.CellFormat(50, 7, tr("Emprunteur"), "1", 0, "C", false, 0, "")
-	pdf.CellFormat(40, 7, tr("Date"), "1", 0, "C", false, 0, "")
-	pdf.Ln(7)
+	writeHeader := func() {
+		pdf.SetFont("Arial", "B", 10)
+		pdf.CellFormat(30, 7, tr("Clé"), "1", 0, "C", false, 0, "")
+		pdf.CellFormat(60, 7, tr("Description"), "1", 0, "C", false, 0, "")
+		pdf.CellFormat(50, 7, tr("Emprunteur"), "1", 0, "C", false, 0, "")
+		pdf.CellFormat(40, 7, tr("Date"), "1", 0, "C", false, 0, "")
+		writeCustomHeaders(pdf, tr, keyColumns, extraWidth, 7, false)
+		writeCustomHeaders(pdf, tr, borrowerColumns, extraWidth, 7, false)
+		pdf.Ln(7)
+		pdf.SetFont("Arial", "", 9)
+	}
+	writeHeader()
 
 	// Données
-	pdf.SetFont("Arial", "", 9)
+	pageBottom := 270.0
+	if extra > 0 {
+		pageBottom = 185
+	}
 	for _, loan := range loans {
-		if pdf.GetY() > 270 {
+		if pdf.GetY() > pageBottom {
 			pdf.AddPage()
 			// Répéter les en-têtes
-			pdf.SetFont("Arial", "B", 10)
-			pdf.CellFormat(30, 7, tr("Clé"), "1", 0, "C", false, 0, "")
-			pdf.CellFormat(60, 7, tr("Description"), "1", 0, "C", false, 0, "")
-			pdf.CellFormat(50, 7, tr("Emprunteur"), "1", 0, "C", false, 0, "")
-			pdf.CellFormat(40, 7, tr("Date"), "1", 0, "C", false, 0, "")
-			pdf.Ln(7)
-			pdf.SetFont("Arial", "", 9)
+			writeHeader()
 		}
 
 		pdf.CellFormat(30, 6, tr(loan.KeyNumber), "1", 0, "L", false, 0, "")
@@ -308,8 +577,134 @@ func GenerateLoansReportPDF(loans []db.LoanWithDetails) ([]byte, error) {
 		pdf.CellFormat(50, 6, tr(name), "1", 0, "L", false, 0, "")
 
 		pdf.CellFormat(40, 6, tr(loan.LoanDate.Format("02/01/2006")), "1", 0, "C", false, 0, "")
+		writeCustomCells(pdf, tr, keyColumns.Cells(loan.KeyID), extraWidth, 6, false)
+		writeCustomCells(pdf, tr, borrowerColumns.Cells(loan.BorrowerID), extraWidth, 6, false)
+		pdf.Ln(6)
+	}
+
+	var buf bytes.Buffer
+	err := pdf.Output(&buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// customColumnWidth répartit available mm entre count colonnes personnalisées, 30 mm au plus chacune
+func customColumnWidth(available float64, count int) float64 {
+	if width := available / float64(count); width < 30 {
+		return width
+	}
+	return 30
+}
+
+// fitCell tronque text pour qu'il tienne dans une cellule de width mm (environ 1,8 mm par caractère)
+func fitCell(text string, width float64) string {
+	limit := int(width / 1.8)
+	runes := []rune(text)
+	if len(runes) <= limit || limit < 4 {
+		return text
+	}
+	return string(runes[:limit-3]) + "..."
+}
+
+// writeCustomHeaders écrit les en-têtes des colonnes personnalisées d'un tableau
+func writeCustomHeaders(pdf *gofpdf.Fpdf, tr func(string) string, columns db.CustomColumns, width, height float64, fill bool) {
+	for _, label := range columns.Labels {
+		pdf.CellFormat(width, height, tr(fitCell(label, width)), "1", 0, "C", fill, 0, "")
+	}
+}
+
+// writeCustomCells écrit les valeurs des colonnes personnalisées d'une ligne
+func writeCustomCells(pdf *gofpdf.Fpdf, tr func(string) string, cells []string, width, height float64, fill bool) {
+	for _, cell := range cells {
+		pdf.CellFormat(width, height, tr(fitCell(cell, width)), "1", 0, "L", fill, 0, "")
+	}
+}
+
+// GenerateLoanHistoryPDF génère un PDF de l'historique des emprunts (rendus et en cours).
+// filterDescription résume les filtres appliqués (vide si aucun).
+func GenerateLoanHistoryPDF(loans []db.LoanWithDetails, filterDescription string) ([]byte, error) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	// Titre
+	pdf.SetFont("Arial", "B", 18)
+	pdf.Cell(0, 10, tr("Historique des Emprunts"))
+	pdf.Ln(15)
+
+	pdf.SetFont("Arial", "", 10)
+	pdf.Cell(0, 6, tr(fmt.Sprintf("Généré le %s", time.Now().Format("02/01/2006 à 15:04"))))
+	pdf.Ln(6)
+	if filterDescription != "" {
+		pdf.Cell(0, 6, tr("Filtres : "+filterDescription))
 		pdf.Ln(6)
 	}
+	pdf.Cell(0, 6, tr(fmt.Sprintf("Nombre d'emprunts : %d", len(loans))))
+	pdf.Ln(10)
+
+	writeHeader := func() {
+		pdf.SetFont("Arial", "B", 9)
+		pdf.SetFillColor(200, 220, 255)
+		pdf.CellFormat(25, 7, tr("Clé"), "1", 0, "C", true, 0, "")
+		pdf.CellFormat(50, 7, tr("Description"), "1", 0, "C", true, 0, "")
+		pdf.CellFormat(45, 7, tr("Emprunteur"), "1", 0, "C", true, 0, "")
+		pdf.CellFormat(25, 7, tr("Emprunt"), "1", 0, "C", true, 0, "")
+		pdf.CellFormat(20, 7, tr("Prévu"), "1", 0, "C", true, 0, "")
+		pdf.CellFormat(25, 7, tr("Retour"), "1", 1, "C", true, 0, "")
+		pdf.SetFont("Arial", "", 8)
+	}
+	writeHeader()
+
+	now := time.Now()
+	for _, loan := range loans {
+		if pdf.GetY() > 270 {
+			pdf.AddPage()
+			writeHeader()
+		}
+
+		desc := loan.KeyDescription
+		if len(desc) > 30 {
+			desc = desc[:27] + "..."
+		}
+		name := loan.BorrowerName
+		if len(name) > 27 {
+			name = name[:24] + "..."
+		}
+		due := "-"
+		if loan.DueDate != nil {
+			due = loan.DueDate.Format("02/01/06")
+		}
+		returned := "En cours"
+		if loan.ReturnDate != nil {
+			returned = loan.ReturnDate.Format("02/01/2006")
+			if loan.LossStatus != "" {
+				returned += " (" + loan.LossStatus.Label() + ")"
+			}
+			if loan.TransferredTo != "" {
+				returned += " (à " + loan.TransferredTo + ")"
+			}
+		}
+
+		// Les emprunts en retard sont surlignés
+		fill := loan.IsOverdue(now)
+		if fill {
+			pdf.SetFillColor(255, 200, 200)
+		}
+
+		key := loan.KeyNumber
+		if loan.CopyIdentifier != "" {
+			key = loan.CopyIdentifier
+		}
+
+		pdf.CellFormat(25, 6, tr(key), "1", 0, "L", fill, 0, "")
+		pdf.CellFormat(50, 6, tr(desc), "1", 0, "L", fill, 0, "")
+		pdf.CellFormat(45, 6, tr(name), "1", 0, "L", fill, 0, "")
+		pdf.CellFormat(25, 6, tr(loan.LoanDate.Format("02/01/2006")), "1", 0, "C", fill, 0, "")
+		pdf.CellFormat(20, 6, tr(due), "1", 0, "C", fill, 0, "")
+		pdf.CellFormat(25, 6, tr(returned), "1", 1, "C", fill, 0, "")
+	}
 
 	var buf bytes.Buffer
 	err := pdf.Output(&buf)
@@ -320,7 +715,7 @@ func GenerateLoansReportPDF(loans []db.LoanWithDetails) ([]byte, error) {
 }
 
 // GenerateGlobalBorrowerReport génère un rapport PDF global groupé par emprunteur
-func GenerateGlobalBorrowerReport(loansByBorrower map[string][]db.LoanWithDetails) ([]byte, error) {
+func GenerateGlobalBorrowerReport(loansByBorrower map[string][]db.LoanWithDetails, site string) ([]byte, error) {
 	pdf := gofpdf.New("P", "mm", "A4", "")
 	pdf.AddPage()
 	tr := pdf.UnicodeTranslatorFromDescriptor("")
@@ -331,7 +726,7 @@ func GenerateGlobalBorrowerReport(loansByBorrower map[string][]db.LoanWithDetail
 	pdf.Ln(10)
 
 	pdf.SetFont("Arial", "", 10)
-	pdf.Cell(0, 6, tr(fmt.Sprintf("Généré le %s", time.Now().Format("02/01/2006 à 15:04"))))
+	pdf.Cell(0, 6, tr(generatedLine(site)))
 	pdf.Ln(15)
 
 	// Calculer le total
@@ -405,9 +800,19 @@ func GenerateGlobalBorrowerReport(loansByBorrower map[string][]db.LoanWithDetail
 	return buf.Bytes(), nil
 }
 
-// GenerateKeyStockReport génère un bilan PDF du stock de clés
-func GenerateKeyStockReport(keys []db.Key, loanCounts map[int]int) ([]byte, error) {
-	pdf := gofpdf.New("P", "mm", "A4", "")
+// GenerateKeyStockReport génère un bilan PDF du stock de clés. Les champs personnalisés des clés
+// affichés dans les rapports forment des colonnes supplémentaires, en format paysage.
+func GenerateKeyStockReport(keys []db.Key, loanCounts map[int]int, columns db.CustomColumns, site string) ([]byte, error) {
+	orientation := "P"
+	pageBottom := 270.0
+	var extraWidth float64
+	if len(columns.Labels) > 0 {
+		orientation = "L"
+		pageBottom = 185
+		extraWidth = customColumnWidth(87, len(columns.Labels))
+	}
+
+	pdf := gofpdf.New(orientation, "mm", "A4", "")
 	pdf.AddPage()
 	tr := pdf.UnicodeTranslatorFromDescriptor("")
 
@@ -417,36 +822,31 @@ func GenerateKeyStockReport(keys []db.Key, loanCounts map[int]int) ([]byte, erro
 	pdf.Ln(10)
 
 	pdf.SetFont("Arial", "", 10)
-	pdf.Cell(0, 6, tr(fmt.Sprintf("Généré le %s", time.Now().Format("02/01/2006 à 15:04"))))
+	pdf.Cell(0, 6, tr(generatedLine(site)))
 	pdf.Ln(15)
 
 	// En-têtes du tableau
-	pdf.SetFont("Arial", "B", 10)
-	pdf.SetFillColor(200, 220, 255)
-
-	pdf.CellFormat(25, 8, tr("Numéro"), "1", 0, "C", true, 0, "")
-	pdf.CellFormat(75, 8, tr("Description"), "1", 0, "C", true, 0, "")
-	pdf.CellFormat(20, 8, tr("Total"), "1", 0, "C", true, 0, "")
-	pdf.CellFormat(20, 8, tr("Réserve"), "1", 0, "C", true, 0, "")
-	pdf.CellFormat(25, 8, tr("Sorties"), "1", 0, "C", true, 0, "")
-	pdf.CellFormat(25, 8, tr("Dispo"), "1", 1, "C", true, 0, "")
+	writeHeader := func() {
+		pdf.SetFont("Arial", "B", 10)
+		pdf.SetFillColor(200, 220, 255)
+		pdf.CellFormat(25, 8, tr("Numéro"), "1", 0, "C", true, 0, "")
+		pdf.CellFormat(75, 8, tr("Description"), "1", 0, "C", true, 0, "")
+		pdf.CellFormat(20, 8, tr("Total"), "1", 0, "C", true, 0, "")
+		pdf.CellFormat(20, 8, tr("Réserve"), "1", 0, "C", true, 0, "")
+		pdf.CellFormat(25, 8, tr("Sorties"), "1", 0, "C", true, 0, "")
+		pdf.CellFormat(25, 8, tr("Dispo"), "1", 0, "C", true, 0, "")
+		writeCustomHeaders(pdf, tr, columns, extraWidth, 8, true)
+		pdf.Ln(8)
+		pdf.SetFont("Arial", "", 9)
+	}
+	writeHeader()
 
 	// Données
-	pdf.SetFont("Arial", "", 9)
-
 	for _, key := range keys {
-		if pdf.GetY() > 270 {
+		if pdf.GetY() > pageBottom {
 			pdf.AddPage()
 			// Répéter en-têtes
-			pdf.SetFont("Arial", "B", 10)
-			pdf.SetFillColor(200, 220, 255)
-			pdf.CellFormat(25, 8, tr("Numéro"), "1", 0, "C", true, 0, "")
-			pdf.CellFormat(75, 8, tr("Description"), "1", 0, "C", true, 0, "")
-			pdf.CellFormat(20, 8, tr("Total"), "1", 0, "C", true, 0, "")
-			pdf.CellFormat(20, 8, tr("Réserve"), "1", 0, "C", true, 0, "")
-			pdf.CellFormat(25, 8, tr("Sorties"), "1", 0, "C", true, 0, "")
-			pdf.CellFormat(25, 8, tr("Dispo"), "1", 1, "C", true, 0, "")
-			pdf.SetFont("Arial", "", 9)
+			writeHeader()
 		}
 
 		borrowed := loanCounts[key.ID]
@@ -476,9 +876,533 @@ func GenerateKeyStockReport(keys []db.Key, loanCounts map[int]int) ([]byte, erro
 
 		// Gras pour la disponibilité
 		pdf.SetFont("Arial", "B", 9)
-		pdf.CellFormat(25, 6, fmt.Sprintf("%d", available), "1", 1, "C", fill, 0, "")
+		pdf.CellFormat(25, 6, fmt.Sprintf("%d", available), "1", 0, "C", fill, 0, "")
 		pdf.SetFont("Arial", "", 9)
+		writeCustomCells(pdf, tr, columns.Cells(key.ID), extraWidth, 6, fill)
+		pdf.Ln(6)
+	}
+
+	var buf bytes.Buffer
+	err := pdf.Output(&buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GenerateStockMovementsReport génère le rapport des mouvements du registre de stock sur une période
+func GenerateStockMovementsReport(movements []db.StockMovement, period string, site string) ([]byte, error) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	// Titre
+	pdf.SetFont("Arial", "B", 18)
+	pdf.Cell(0, 10, tr("Mouvements de Stock"))
+	pdf.Ln(10)
+
+	pdf.SetFont("Arial", "", 10)
+	pdf.Cell(0, 6, tr(generatedLine(site)))
+	pdf.Ln(6)
+	pdf.Cell(0, 6, tr(fmt.Sprintf("Période : %s - %d mouvement(s)", period, len(movements))))
+	pdf.Ln(12)
+
+	writeHeader := func() {
+		pdf.SetFont("Arial", "B", 10)
+		pdf.SetFillColor(200, 220, 255)
+		pdf.CellFormat(28, 8, tr("Date"), "1", 0, "C", true, 0, "")
+		pdf.CellFormat(20, 8, tr("Clé"), "1", 0, "C", true, 0, "")
+		pdf.CellFormat(32, 8, tr("Mouvement"), "1", 0, "C", true, 0, "")
+		pdf.CellFormat(12, 8, tr("Qté"), "1", 0, "C", true, 0, "")
+		pdf.CellFormat(68, 8, tr("Motif"), "1", 0, "C", true, 0, "")
+		pdf.CellFormat(30, 8, tr("Opérateur"), "1", 1, "C", true, 0, "")
+		pdf.SetFont("Arial", "", 8)
+	}
+	writeHeader()
+
+	if len(movements) == 0 {
+		pdf.CellFormat(190, 6, tr("Aucun mouvement sur la période."), "1", 1, "C", false, 0, "")
+	}
+
+	for _, m := range movements {
+		if pdf.GetY() > 270 {
+			pdf.AddPage()
+			// Répéter en-têtes
+			writeHeader()
+		}
+
+		// Les sorties du stock sont signalées en rouge clair
+		fill := false
+		if total, _ := m.Type.Deltas(); total < 0 {
+			pdf.SetFillColor(255, 200, 200)
+			fill = true
+		}
+
+		reason := m.Reason
+		if m.CopyIdentifier != "" {
+			reason = m.CopyIdentifier + " : " + reason
+		}
+		if len(reason) > 45 {
+			reason = reason[:42] + "..."
+		}
+
+		pdf.CellFormat(28, 6, m.MovedAt.Format("02/01/2006 15:04"), "1", 0, "L", fill, 0, "")
+		pdf.CellFormat(20, 6, tr(m.KeyNumber), "1", 0, "L", fill, 0, "")
+		pdf.CellFormat(32, 6, tr(m.Type.Label()), "1", 0, "L", fill, 0, "")
+		pdf.CellFormat(12, 6, fmt.Sprintf("%d", m.Quantity), "1", 0, "C", fill, 0, "")
+		pdf.CellFormat(68, 6, tr(reason), "1", 0, "L", fill, 0, "")
+		pdf.CellFormat(30, 6, tr(m.Operator), "1", 1, "L", fill, 0, "")
+	}
+
+	var buf bytes.Buffer
+	err := pdf.Output(&buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GenerateCustomFieldsExport génère l'export des champs personnalisés : pour chaque catégorie
+// qui en définit, un tableau des fiches en service avec la valeur de chaque champ
+func GenerateCustomFieldsExport(exports []db.CustomFieldExport, site string) ([]byte, error) {
+	pdf := gofpdf.New("L", "mm", "A4", "")
+	pdf.AddPage()
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	// Titre
+	pdf.SetFont("Arial", "B", 18)
+	pdf.Cell(0, 10, tr("Champs Personnalisés"))
+	pdf.Ln(10)
+
+	pdf.SetFont("Arial", "", 10)
+	pdf.Cell(0, 6, tr(generatedLine(site)))
+	pdf.Ln(12)
+
+	written := false
+	for _, export := range exports {
+		if len(export.Columns.Labels) == 0 {
+			continue
+		}
+		written = true
+		width := 207 / float64(len(export.Columns.Labels))
+
+		if pdf.GetY() > 170 {
+			pdf.AddPage()
+		}
+		pdf.SetFont("Arial", "B", 14)
+		pdf.Cell(0, 8, tr(fmt.Sprintf("%s (%d)", db.CustomFieldEntityLabel(export.Entity), len(export.Records))))
+		pdf.Ln(10)
+
+		writeHeader := func() {
+			pdf.SetFont("Arial", "B", 9)
+			pdf.SetFillColor(200, 220, 255)
+			pdf.CellFormat(70, 7, tr("Fiche"), "1", 0, "C", true, 0, "")
+			writeCustomHeaders(pdf, tr, export.Columns, width, 7, true)
+			pdf.Ln(7)
+			pdf.SetFont("Arial", "", 8)
+		}
+		writeHeader()
+
+		for _, record := range export.Records {
+			if pdf.GetY() > 185 {
+				pdf.AddPage()
+				// Répéter en-têtes
+				writeHeader()
+			}
+			pdf.CellFormat(70, 6, tr(fitCell(record.Title, 70)), "1", 0, "L", false, 0, "")
+			writeCustomCells(pdf, tr, export.Columns.Cells(record.ID), width, 6, false)
+			pdf.Ln(6)
+		}
+		pdf.Ln(8)
+	}
+
+	if !written {
+		pdf.SetFont("Arial", "", 11)
+		pdf.Cell(0, 8, tr("Aucun champ personnalisé n'est défini."))
+	}
+
+	var buf bytes.Buffer
+	err := pdf.Output(&buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GenerateLossImpactReport génère le rapport de sécurité d'une clé perdue ou volée :
+// salles exposées et autres clés qui les ouvrent, pour décider des changements de cylindre
+func GenerateLossImpactReport(report *db.LossImpactReport) ([]byte, error) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	loan := report.Loan
+
+	// Titre
+	pdf.SetFont("Arial", "B", 18)
+	pdf.Cell(0, 10, tr(fmt.Sprintf("Clé %s : Rapport d'Impact", strings.ToLower(loan.LossStatus.Label()))))
+	pdf.Ln(12)
+
+	pdf.SetFont("Arial", "", 10)
+	pdf.Cell(0, 6, tr(fmt.Sprintf("Généré le %s", time.Now().Format("02/01/2006 à 15:04"))))
+	pdf.Ln(10)
+
+	// Circonstances
+	key := loan.KeyNumber
+	if loan.CopyIdentifier != "" {
+		key += " (exemplaire " + loan.CopyIdentifier + ")"
+	}
+	lines := []struct{ label, value string }{
+		{"Clé :", key + " - " + loan.KeyDescription},
+		{"État :", loan.LossStatus.Label()},
+		{"Emprunteur :", loan.BorrowerName},
+		{"Emprunté le :", loan.LoanDate.Format("02/01/2006")},
+	}
+	if loan.ReturnDate != nil {
+		lines = append(lines, struct{ label, value string }{"Déclaré le :", loan.ReturnDate.Format("02/01/2006 à 15:04")})
+	}
+	if loan.ReturnedBy != "" {
+		lines = append(lines, struct{ label, value string }{"Déclaré par :", loan.ReturnedBy})
+	}
+	for _, line := range lines {
+		pdf.SetFont("Arial", "", 11)
+		pdf.Cell(45, 7, tr(line.label))
+		pdf.SetFont("Arial", "B", 11)
+		pdf.Cell(0, 7, tr(line.value))
+		pdf.Ln(7)
+	}
+	if loan.LossNotes != "" {
+		pdf.SetFont("Arial", "", 11)
+		pdf.MultiCell(0, 6, tr("Circonstances : "+loan.LossNotes), "", "L", false)
+	}
+	pdf.SetFont("Arial", "", 11)
+	pdf.Cell(0, 7, tr(fmt.Sprintf("Autres exemplaires de cette clé encore en service : %d", report.OtherCopies)))
+	pdf.Ln(12)
+
+	// Salles exposées
+	pdf.SetFont("Arial", "B", 12)
+	pdf.Cell(0, 8, tr(fmt.Sprintf("Salles exposées (%d)", len(report.Rooms))))
+	pdf.Ln(9)
+
+	if len(report.Rooms) == 0 {
+		pdf.SetFont("Arial", "I", 10)
+		pdf.Cell(0, 6, tr("Cette clé n'ouvre aucune salle enregistrée."))
+		pdf.Ln(6)
+	} else {
+		writeHeader := func() {
+			pdf.SetFont("Arial", "B", 10)
+			pdf.SetFillColor(200, 220, 255)
+			pdf.CellFormat(40, 8, tr("Bâtiment"), "1", 0, "C", true, 0, "")
+			pdf.CellFormat(50, 8, tr("Salle"), "1", 0, "C", true, 0, "")
+			pdf.CellFormat(100, 8, tr("Autres clés qui l'ouvrent"), "1", 1, "C", true, 0, "")
+			pdf.SetFont("Arial", "", 9)
+		}
+		writeHeader()
+
+		for _, room := range report.Rooms {
+			if pdf.GetY() > 270 {
+				pdf.AddPage()
+				writeHeader()
+			}
+
+			roomName := room.Name
+			if room.Access == db.AccessInherited {
+				roomName += " (via " + room.AccessVia + ")"
+			}
+
+			var keyTexts []string
+			for _, k := range room.Keys {
+				if k.Access == db.AccessInherited {
+					keyTexts = append(keyTexts, fmt.Sprintf("%s (passe-partout)", k.Number))
+				} else {
+					keyTexts = append(keyTexts, k.Number)
+				}
+			}
+			keysText := strings.Join(keyTexts, ", ")
+			if keysText == "" {
+				keysText = "Aucune"
+			}
+			if len(keysText) > 60 {
+				keysText = keysText[:57] + "..."
+			}
+
+			pdf.CellFormat(40, 6, tr(room.Building.Name), "1", 0, "L", false, 0, "")
+			pdf.CellFormat(50, 6, tr(roomName), "1", 0, "L", false, 0, "")
+			pdf.CellFormat(100, 6, tr(keysText), "1", 1, "L", false, 0, "")
+		}
+	}
+
+	var buf bytes.Buffer
+	err := pdf.Output(&buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GenerateDepositsReport génère le rapport des cautions détenues, totalisées par emprunteur
+func GenerateDepositsReport(deposits []db.BorrowerDeposits, site string) ([]byte, error) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	// Titre
+	pdf.SetFont("Arial", "B", 18)
+	pdf.Cell(0, 10, tr("Cautions Détenues"))
+	pdf.Ln(10)
+
+	pdf.SetFont("Arial", "", 10)
+	pdf.Cell(0, 6, tr(generatedLine(site)))
+	pdf.Ln(15)
+
+	var total float64
+	count := 0
+	for _, d := range deposits {
+		total += d.Total
+		count += len(d.Loans)
+	}
+	pdf.SetFont("Arial", "B", 12)
+	pdf.Cell(0, 8, tr(fmt.Sprintf("Total : %s pour %d caution(s), %d emprunteur(s)", db.FormatDepositAmount(total), count, len(deposits))))
+	pdf.Ln(12)
+
+	for _, d := range deposits {
+		if pdf.GetY() > 250 {
+			pdf.AddPage()
+		}
+
+		// En-tête Emprunteur
+		pdf.SetFont("Arial", "B", 14)
+		pdf.SetFillColor(230, 230, 250)
+		pdf.CellFormat(0, 10, tr(fmt.Sprintf("  %s - %s", d.BorrowerName, db.FormatDepositAmount(d.Total))), "1", 1, "L", true, 0, "")
+
+		// En-têtes colonnes
+		pdf.SetFont("Arial", "B", 10)
+		pdf.CellFormat(30, 7, tr("Clé"), "L", 0, "C", false, 0, "")
+		pdf.CellFormat(60, 7, tr("Date d'emprunt"), "", 0, "C", false, 0, "")
+		pdf.CellFormat(40, 7, tr("Statut"), "", 0, "C", false, 0, "")
+		pdf.CellFormat(30, 7, tr("Moyen"), "", 0, "C", false, 0, "")
+		pdf.CellFormat(30, 7, tr("Montant"), "R", 1, "R", false, 0, "")
+
+		pdf.SetFont("Arial", "", 10)
+		for _, loan := range d.Loans {
+			if pdf.GetY() > 270 {
+				pdf.AddPage()
+			}
+
+			status := "En cours"
+			if loan.ReturnDate != nil {
+				status = "Rendue"
+				if loan.LossStatus != "" {
+					status = loan.LossStatus.Label()
+				}
+			}
+
+			pdf.CellFormat(30, 6, tr(loan.KeyNumber), "L", 0, "C", false, 0, "")
+			pdf.CellFormat(60, 6, tr(loan.LoanDate.Format("02/01/2006")), "", 0, "C", false, 0, "")
+			pdf.CellFormat(40, 6, tr(status), "", 0, "C", false, 0, "")
+			pdf.CellFormat(30, 6, tr(loan.DepositMethod.Label()), "", 0, "C", false, 0, "")
+			pdf.CellFormat(30, 6, tr(db.FormatDepositAmount(loan.DepositAmount)), "R", 1, "R", false, 0, "")
+		}
+
+		// Ligne de séparation bas de section
+		pdf.CellFormat(0, 1, "", "T", 1, "", false, 0, "")
+		pdf.Ln(5)
+	}
+
+	var buf bytes.Buffer
+	err := pdf.Output(&buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GenerateTransferReceipt génère le bon de transfert d'une clé remise directement
+// d'un emprunteur (from, emprunt clos) à un autre (to, emprunt ouvert), signé par les deux
+func GenerateTransferReceipt(from, to *db.LoanWithDetails) ([]byte, error) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	// Titre
+	pdf.SetFont("Arial", "B", 18)
+	pdf.Cell(0, 10, tr("Bon de Transfert de Clé"))
+	pdf.Ln(15)
+
+	// Clé transférée
+	pdf.SetFont("Arial", "", 12)
+	pdf.Cell(70, 10, tr("Numéro de la clé :"))
+	pdf.SetFont("Arial", "B", 12)
+	pdf.Cell(0, 10, tr(to.KeyNumber))
+	pdf.Ln(8)
+
+	pdf.SetFont("Arial", "", 12)
+	pdf.Cell(70, 10, tr("Description :"))
+	pdf.Cell(0, 10, tr(to.KeyDescription))
+	pdf.Ln(8)
+
+	if to.CopyIdentifier != "" {
+		pdf.Cell(70, 10, tr("Exemplaire :"))
+		pdf.SetFont("Arial", "B", 12)
+		pdf.Cell(0, 10, tr(to.CopyIdentifier))
+		pdf.SetFont("Arial", "", 12)
+		pdf.Ln(8)
+	}
+
+	pdf.Cell(70, 10, tr("Date du transfert :"))
+	pdf.Cell(0, 10, tr(to.LoanDate.Format("02/01/2006 à 15:04")))
+	pdf.Ln(8)
+
+	if to.LoanedBy != "" {
+		pdf.Cell(70, 10, tr("Enregistré par :"))
+		pdf.Cell(0, 10, tr(to.LoanedBy))
+		pdf.Ln(8)
+	}
+	pdf.Ln(4)
+
+	// Emprunteur précédent
+	pdf.SetFont("Arial", "B", 13)
+	pdf.Cell(0, 10, tr("Remise par"))
+	pdf.Ln(9)
+	pdf.SetFont("Arial", "", 12)
+	pdf.Cell(70, 10, tr("Nom :"))
+	pdf.SetFont("Arial", "B", 12)
+	pdf.Cell(0, 10, tr(from.BorrowerName))
+	pdf.Ln(8)
+	pdf.SetFont("Arial", "", 12)
+	writeBorrowerIdentity(pdf, tr, from.BorrowerBadgeNumber, from.BorrowerDepartment, "")
+	pdf.Cell(70, 10, tr("Détenue depuis le :"))
+	pdf.Cell(0, 10, tr(from.LoanDate.Format("02/01/2006")))
+	pdf.Ln(12)
+
+	// Nouvel emprunteur
+	pdf.SetFont("Arial", "B", 13)
+	pdf.Cell(0, 10, tr("Reçue par"))
+	pdf.Ln(9)
+	pdf.SetFont("Arial", "", 12)
+	pdf.Cell(70, 10, tr("Nom :"))
+	pdf.SetFont("Arial", "B", 12)
+	pdf.Cell(0, 10, tr(to.BorrowerName))
+	pdf.Ln(8)
+	pdf.SetFont("Arial", "", 12)
+	writeBorrowerIdentity(pdf, tr, to.BorrowerBadgeNumber, to.BorrowerDepartment, "")
+
+	restitution := "à la fin de son utilisation"
+	if to.DueDate != nil {
+		pdf.Cell(70, 10, tr("Date de retour prévue :"))
+		pdf.SetFont("Arial", "B", 12)
+		pdf.Cell(0, 10, tr(to.DueDate.Format("02/01/2006")))
+		pdf.SetFont("Arial", "", 12)
+		pdf.Ln(8)
+		restitution = "au plus tard le " + to.DueDate.Format("02/01/2006")
+	}
+	if to.DepositAmount > 0 {
+		pdf.Cell(70, 10, tr("Caution reportée :"))
+		pdf.Cell(0, 10, tr(fmt.Sprintf("%s (%s)", db.FormatDepositAmount(to.DepositAmount), to.DepositMethod.Label())))
+		pdf.Ln(8)
+	}
+	pdf.Ln(7)
+
+	// Texte d'engagement
+	pdf.SetFont("Arial", "", 11)
+	text := fmt.Sprintf("%s déclare avoir remis la clé mentionnée ci-dessus à %s, qui reconnaît l'avoir reçue. "+
+		"%s est désormais responsable de la clé et s'engage à en prendre soin et à la restituer %s. "+
+		"En cas de perte ou de dégradation, sa responsabilité pourra être engagée.",
+		from.BorrowerName, to.BorrowerName, to.BorrowerName, restitution)
+	pdf.MultiCell(0, 6, tr(text), "", "", false)
+	pdf.Ln(15)
+
+	// Signatures des deux parties
+	y := pdf.GetY()
+	pdf.SetFont("Arial", "", 12)
+	pdf.SetXY(10, y)
+	pdf.Cell(90, 10, tr("Signature de "+from.BorrowerName+" :"))
+	pdf.SetXY(110, y)
+	pdf.Cell(90, 10, tr("Signature de "+to.BorrowerName+" :"))
+	pdf.Line(10, y+30, 95, y+30)
+	pdf.Line(110, y+30, 195, y+30)
+
+	var buf bytes.Buffer
+	err := pdf.Output(&buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GenerateSitesReport génère le rapport consolidé de la direction : une ligne par site
+// et une ligne de total, tous sites confondus
+func GenerateSitesReport(summaries []db.SiteSummary) ([]byte, error) {
+	pdf := gofpdf.New("L", "mm", "A4", "")
+	pdf.AddPage()
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	// Titre
+	pdf.SetFont("Arial", "B", 18)
+	pdf.Cell(0, 10, tr("Rapport Consolidé des Sites"))
+	pdf.Ln(10)
+
+	pdf.SetFont("Arial", "", 10)
+	pdf.Cell(0, 6, tr(generatedLine(fmt.Sprintf("%d site(s)", len(summaries)))))
+	pdf.Ln(15)
+
+	headers := []string{"Site", "Bâtiments", "Salles", "Clés", "Exemplaires", "Emprunteurs", "Emprunts", "En retard", "Cautions"}
+	widths := []float64{65, 22, 22, 22, 26, 26, 26, 24, 30}
+
+	pdf.SetFont("Arial", "B", 10)
+	pdf.SetFillColor(200, 220, 255)
+	for i, h := range headers {
+		pdf.CellFormat(widths[i], 8, tr(h), "1", 0, "C", true, 0, "")
+	}
+	pdf.Ln(8)
+
+	var total db.SiteSummary
+	total.Name = "Total"
+	writeRow := func(sum db.SiteSummary, bold bool) {
+		style := ""
+		if bold {
+			style = "B"
+		}
+		pdf.SetFont("Arial", style, 9)
+		// Les sites ayant des emprunts en retard sont signalés en rouge clair
+		fill := sum.OverdueLoans > 0 && !bold
+		pdf.SetFillColor(255, 200, 200)
+		values := []string{
+			sum.Name,
+			fmt.Sprintf("%d", sum.Buildings),
+			fmt.Sprintf("%d", sum.Rooms),
+			fmt.Sprintf("%d", sum.Keys),
+			fmt.Sprintf("%d", sum.Copies),
+			fmt.Sprintf("%d", sum.Borrowers),
+			fmt.Sprintf("%d", sum.ActiveLoans),
+			fmt.Sprintf("%d", sum.OverdueLoans),
+			db.FormatDepositAmount(sum.HeldDeposits),
+		}
+		for i, v := range values {
+			align := "C"
+			if i == 0 {
+				align = "L"
+			}
+			pdf.CellFormat(widths[i], 7, tr(v), "1", 0, align, fill, 0, "")
+		}
+		pdf.Ln(7)
+	}
+
+	for _, sum := range summaries {
+		if pdf.GetY() > 185 {
+			pdf.AddPage()
+		}
+		writeRow(sum, false)
+
+		total.Buildings += sum.Buildings
+		total.Rooms += sum.Rooms
+		total.Keys += sum.Keys
+		total.Copies += sum.Copies
+		total.Borrowers += sum.Borrowers
+		total.ActiveLoans += sum.ActiveLoans
+		total.OverdueLoans += sum.OverdueLoans
+		total.HeldDeposits += sum.HeldDeposits
 	}
+	writeRow(total, true)
 
 	var buf bytes.Buffer
 	err := pdf.Output(&buf)